@@ -6,24 +6,36 @@ import (
 	"encoding/base64"
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	_ "github.com/lib/pq"
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/krok-o/krok/pkg/krok/providers/ready"
 
 	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/krok/providers/artifacts"
 	"github.com/krok-o/krok/pkg/krok/providers/auth"
+	"github.com/krok-o/krok/pkg/krok/providers/bitbucket"
+	"github.com/krok-o/krok/pkg/krok/providers/bitbucketserver"
+	"github.com/krok-o/krok/pkg/krok/providers/containerruntime"
 	"github.com/krok-o/krok/pkg/krok/providers/environment"
 	"github.com/krok-o/krok/pkg/krok/providers/executor"
 	"github.com/krok-o/krok/pkg/krok/providers/filevault"
+	"github.com/krok-o/krok/pkg/krok/providers/generic"
+	"github.com/krok-o/krok/pkg/krok/providers/gitea"
 	"github.com/krok-o/krok/pkg/krok/providers/github"
 	"github.com/krok-o/krok/pkg/krok/providers/gitlab"
 	"github.com/krok-o/krok/pkg/krok/providers/handlers"
 	"github.com/krok-o/krok/pkg/krok/providers/livestore"
+	"github.com/krok-o/krok/pkg/krok/providers/logstream"
 	"github.com/krok-o/krok/pkg/krok/providers/mailgun"
+	"github.com/krok-o/krok/pkg/krok/providers/scheduler"
 	"github.com/krok-o/krok/pkg/krok/providers/vault"
 	"github.com/krok-o/krok/pkg/models"
 	"github.com/krok-o/krok/pkg/server"
@@ -37,13 +49,33 @@ var (
 		Run:   runKrokCmd,
 	}
 	krokArgs struct {
-		devMode   bool
-		debug     bool
-		server    server.Config
-		store     livestore.Config
-		email     mailgun.Config
-		fileVault filevault.Config
-		executer  executor.Config
+		devMode         bool
+		debug           bool
+		server          server.Config
+		store           livestore.Config
+		email           mailgun.Config
+		fileVault       filevault.Config
+		executer        executor.Config
+		kubernetes      executor.KubernetesConfig
+		remote          executor.RemoteConfig
+		kubeconfig      string
+		containerd      containerruntime.ContainerdConfig
+		scheduler       scheduler.Config
+		logStream       logstream.Config
+		artifacts       artifacts.Config
+		gitea           gitea.Config
+		bitbucket       bitbucket.Config
+		bitbucketServer bitbucketserver.Config
+		// executorKind selects the executor implementation.
+		executorKind string
+		// runnerToken is the token remote runners authenticate with.
+		runnerToken string
+		// runnerTimeout is how long a remote runner may go without contacting Krok in seconds.
+		runnerTimeout int
+		// containerRuntime selects the container runtime of the in-memory and persistent executors.
+		containerRuntime string
+		// workspacePermissions are the permissions of the workspaces as an octal number.
+		workspacePermissions string
 	}
 )
 
@@ -77,8 +109,43 @@ func init() {
 	flag.StringVar(&krokArgs.fileVault.Location, "file-vault-location", "/tmp/krok/vault", "--file-vault-location /tmp/krok/vault")
 
 	// Executer config
-	flag.IntVar(&krokArgs.executer.DefaultMaximumCommandRuntime, "default-maximum-command-runtime", 120, "Given in seconds.")
+	flag.IntVar(&krokArgs.executer.DefaultMaximumCommandRuntime, "default-maximum-command-runtime", 120, "Timeout of commands which don't define one, and the upper limit for the ones which do. Given in seconds.")
 	flag.IntVar(&krokArgs.executer.MaximumParallelCommands, "maximum-parallel-commands", 50, "The maximum number of parallel running containers commands")
+	flag.IntVar(&krokArgs.executer.MaximumParallelCommandsPerRepository, "maximum-parallel-commands-per-repository", 0, "--maximum-parallel-commands-per-repository 5. The maximum number of parallel running commands of a single repository. 0 means no limit besides --maximum-parallel-commands.")
+	flag.StringVar(&krokArgs.executer.SecretsLocation, "secrets-location", executor.DefaultSecretsLocation, "--secrets-location "+executor.DefaultSecretsLocation+". Settings delivered as files are written here and mounted into the command containers. Use a tmpfs, so they never hit the disk.")
+	flag.IntVar(&krokArgs.executer.ContainerUID, "container-uid", 0, "--container-uid 1000. The user the command containers run as. The secret files are owned by it, so only it can read them. 0 keeps the user of Krok.")
+	flag.IntVar(&krokArgs.executer.ContainerGID, "container-gid", 0, "--container-gid 1000. The group the command containers run as, which owns the secret files. 0 keeps the group of Krok.")
+	flag.StringVar(&krokArgs.executorKind, "executor", "in-memory", "--executor in-memory|persistent|kubernetes|remote. The persistent executor picks up unfinished runs after a restart. The kubernetes executor runs every command as a Job. The remote executor hands the commands to runners started with krok runner.")
+	flag.StringVar(&krokArgs.runnerToken, "runner-token", "", "--runner-token <somerandomdata>. The token runners authenticate with. Required by the remote executor.")
+	flag.IntVar(&krokArgs.runnerTimeout, "runner-timeout", 60, "--runner-timeout 60. How long a runner may go without contacting Krok before its command runs fail. Given in seconds.")
+	flag.StringVar(&krokArgs.containerRuntime, "container-runtime", "docker", "--container-runtime docker|containerd. Podman can be used through its Docker compatible API by setting DOCKER_HOST.")
+	flag.StringVar(&krokArgs.containerd.Address, "containerd-address", containerruntime.DefaultContainerdAddress, "--containerd-address "+containerruntime.DefaultContainerdAddress)
+	flag.StringVar(&krokArgs.containerd.Namespace, "containerd-namespace", containerruntime.DefaultContainerdNamespace, "--containerd-namespace "+containerruntime.DefaultContainerdNamespace)
+	flag.StringVar(&krokArgs.containerd.LogLocation, "containerd-log-location", "/tmp/krok/logs", "--containerd-log-location /tmp/krok/logs. The output of the containers is written here.")
+	flag.StringVar(&krokArgs.kubeconfig, "kubeconfig", "", "--kubeconfig ~/.kube/config. If not set, the in-cluster configuration is used by the kubernetes executor.")
+	flag.StringVar(&krokArgs.kubernetes.Namespace, "kubernetes-namespace", "default", "--kubernetes-namespace krok. The namespace of the Jobs of the kubernetes executor.")
+	flag.StringVar(&krokArgs.kubernetes.ServiceAccountName, "kubernetes-service-account", "", "--kubernetes-service-account krok-commands. The service account of the Jobs of the kubernetes executor.")
+	flag.StringVar(&krokArgs.kubernetes.WorkspaceClaimName, "kubernetes-workspace-claim", "", "--kubernetes-workspace-claim krok-workspaces. The PersistentVolumeClaim mounted into Krok at --workspace-location which holds the workspaces of the Jobs.")
+
+	// Artifacts config
+	flag.StringVar(&krokArgs.artifacts.Location, "workspace-location", "/tmp/krok/workspaces", "--workspace-location /tmp/krok/workspaces. The workspaces of runs are created here and mounted into the command containers, so the container runtime must be able to access it.")
+	flag.StringVar(&krokArgs.workspacePermissions, "workspace-permissions", "0777", "--workspace-permissions 0770. The permissions of the workspaces. Everyone can write into them by default, because commands might run as any user.")
+	flag.IntVar(&krokArgs.artifacts.Retention, "workspace-retention", 168, "--workspace-retention 24. How long workspaces are kept after they were last written to. Given in hours, 0 keeps them forever.")
+
+	// Log stream config
+	flag.IntVar(&krokArgs.logStream.BacklogSize, "log-backlog-size", 1024*1024, "The maximum number of bytes of logs kept per running command for late subscribers.")
+
+	// Gitea config
+	flag.StringVar(&krokArgs.gitea.BaseURL, "gitea-base-url", "", "--gitea-base-url https://gitea.com. If not set, the address is taken from the URL of the repository.")
+
+	// Bitbucket config
+	flag.StringVar(&krokArgs.bitbucket.BaseURL, "bitbucket-base-url", bitbucket.DefaultBaseURL, "--bitbucket-base-url "+bitbucket.DefaultBaseURL)
+	flag.StringSliceVar(&krokArgs.bitbucket.AllowedIPRanges, "bitbucket-allowed-ip-ranges", nil, "--bitbucket-allowed-ip-ranges 104.192.136.0/21,185.166.140.0/22. Bitbucket Cloud hooks are only accepted from these ranges. If not set, the source isn't checked, which lets anyone who knows the hook UUID send events.")
+	flag.StringSliceVar(&krokArgs.bitbucket.TrustedProxies, "bitbucket-trusted-proxies", nil, "--bitbucket-trusted-proxies 10.0.0.0/8. The ranges of the reverse proxies or ingresses in front of Krok. For requests from them, the source is taken from X-Forwarded-For. If not set, the source is the address of the direct peer, which is the proxy if there is one.")
+	flag.StringVar(&krokArgs.bitbucketServer.BaseURL, "bitbucket-server-base-url", "", "--bitbucket-server-base-url https://bitbucket.example.com. If not set, the address is taken from the URL of the repository.")
+
+	// Scheduler config
+	flag.IntVar(&krokArgs.scheduler.RefreshInterval, "scheduler-refresh-interval", 60, "How often to look for changed command schedules. Given in seconds.")
 }
 
 // runKrokCmd builds up all the components and starts the krok server.
@@ -191,12 +258,96 @@ func runKrokCmd(cmd *cobra.Command, args []string) {
 		Connector:    connector,
 	})
 
-	ex := executor.NewInMemoryExecutor(krokArgs.executer, executor.Dependencies{
-		Logger:           log,
-		CommandRuns:      commandRunStore,
-		CommandStorer:    commandStore,
-		RepositoryStorer: repoStore,
-		Clock:            clock,
+	permissions, err := strconv.ParseUint(krokArgs.workspacePermissions, 8, 32)
+	if err != nil {
+		log.Fatal().Err(err).Str("permissions", krokArgs.workspacePermissions).Msg("Invalid workspace permissions.")
+	}
+	krokArgs.artifacts.Permissions = os.FileMode(permissions)
+	artifactStore := artifacts.NewFileStore(krokArgs.artifacts, artifacts.Dependencies{
+		Logger: log,
+		Clock:  clock,
+	})
+
+	logBroker := logstream.NewBroker(krokArgs.logStream, logstream.Dependencies{
+		Logger: log,
+	})
+
+	registryCredentialProvider := auth.NewRegistryCredentialProvider(auth.RegistryCredentialProviderDependencies{
+		Logger: log,
+		Vault:  v,
+	})
+	executorDeps := executor.Dependencies{
+		Logger:              log,
+		CommandRuns:         commandRunStore,
+		CommandStorer:       commandStore,
+		RepositoryStorer:    repoStore,
+		EventsStorer:        eventStorer,
+		Clock:               clock,
+		LogStreamer:         logBroker,
+		ArtifactStorer:      artifactStore,
+		RegistryCredentials: registryCredentialProvider,
+	}
+	var (
+		ex providers.Executor
+		// coordinator stays nil unless the executor supports remote runners.
+		coordinator providers.RunnerCoordinator
+	)
+	if krokArgs.executorKind != "kubernetes" && krokArgs.executorKind != "remote" {
+		var err error
+		executorDeps.ContainerRuntime, err = newContainerRuntime(krokArgs.containerRuntime, krokArgs.containerd, containerruntime.Dependencies{
+			Logger: log,
+		})
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create container runtime.")
+		}
+	}
+	switch krokArgs.executorKind {
+	case "in-memory":
+		ex = executor.NewInMemoryExecutor(krokArgs.executer, executorDeps)
+	case "persistent":
+		pe := executor.NewPersistentExecutor(krokArgs.executer, executorDeps)
+		if err := pe.Reconcile(context.Background()); err != nil {
+			log.Error().Err(err).Msg("Failed to reconcile unfinished command runs.")
+		}
+		ex = pe
+	case "kubernetes":
+		config, err := clientcmd.BuildConfigFromFlags("", krokArgs.kubeconfig)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load kubernetes configuration.")
+		}
+		client, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create kubernetes client.")
+		}
+		krokArgs.kubernetes.Config = krokArgs.executer
+		krokArgs.kubernetes.WorkspaceClaimPath = krokArgs.artifacts.Location
+		ex = executor.NewKubernetesExecutor(krokArgs.kubernetes, executor.KubernetesDependencies{
+			Dependencies: executorDeps,
+			Client:       client,
+		})
+	case "remote":
+		if krokArgs.runnerToken == "" {
+			log.Fatal().Msg("must provide --runner-token flag for the remote executor")
+		}
+		krokArgs.remote.Config = krokArgs.executer
+		krokArgs.remote.RunnerTimeout = time.Duration(krokArgs.runnerTimeout) * time.Second
+		re := executor.NewRemoteExecutor(krokArgs.remote, executor.RemoteDependencies{
+			Dependencies:  executorDeps,
+			UUIDGenerator: uuidGenerator,
+		})
+		ex = re
+		coordinator = re
+	default:
+		log.Fatal().Str("executor", krokArgs.executorKind).Msg("Unknown executor.")
+	}
+
+	sch := scheduler.NewCronScheduler(krokArgs.scheduler, scheduler.Dependencies{
+		Logger:        log,
+		CommandStorer: commandStore,
+		EventsStorer:  eventStorer,
+		Executor:      ex,
+		Clock:         clock,
+		UUIDGenerator: uuidGenerator,
 	})
 
 	// ************************
@@ -220,6 +371,31 @@ func runKrokCmd(cmd *cobra.Command, args []string) {
 		AuthProvider:          a,
 		UUIDGenerator:         uuidGenerator,
 	})
+
+	giteaProvider := gitea.NewGiteaPlatformProvider(krokArgs.gitea, gitea.Dependencies{
+		Logger:                log,
+		PlatformTokenProvider: platformTokenProvider,
+		AuthProvider:          a,
+	})
+
+	bitbucketProvider := bitbucket.NewBitbucketPlatformProvider(krokArgs.bitbucket, bitbucket.Dependencies{
+		Logger:                log,
+		PlatformTokenProvider: platformTokenProvider,
+		Vault:                 v,
+	})
+
+	bitbucketServerProvider := bitbucketserver.NewBitbucketServerPlatformProvider(krokArgs.bitbucketServer, bitbucketserver.Dependencies{
+		Logger:                log,
+		PlatformTokenProvider: platformTokenProvider,
+		AuthProvider:          a,
+	})
+
+	genericProvider := generic.NewGenericPlatformProvider(generic.Dependencies{
+		Logger:           log,
+		AuthProvider:     a,
+		RepositoryStorer: repoStore,
+		UUIDGenerator:    uuidGenerator,
+	})
 	// ************************
 	// Set up handlers
 	// ************************
@@ -250,6 +426,10 @@ func runKrokCmd(cmd *cobra.Command, args []string) {
 	platformProviders := make(map[int]providers.Platform)
 	platformProviders[models.GITHUB] = githubProvider
 	platformProviders[models.GITLAB] = gitlabProvider
+	platformProviders[models.GITEA] = giteaProvider
+	platformProviders[models.BITBUCKET] = bitbucketProvider
+	platformProviders[models.BITBUCKETSERVER] = bitbucketServerProvider
+	platformProviders[models.GENERIC] = genericProvider
 	repoHandler, _ := handlers.NewRepositoryHandler(handlers.RepoConfig{
 		Protocol: krokArgs.server.Proto,
 		HookBase: krokArgs.server.HookBase,
@@ -278,6 +458,8 @@ func runKrokCmd(cmd *cobra.Command, args []string) {
 
 	commandRunHandler := handlers.NewCommandRunHandler(handlers.CommandRunHandlerDependencies{
 		CommandRunStorer: commandRunStore,
+		LogStreamer:      logBroker,
+		Executor:         ex,
 		Logger:           log,
 	})
 
@@ -313,9 +495,19 @@ func runKrokCmd(cmd *cobra.Command, args []string) {
 		TokenProvider: platformTokenProvider,
 	})
 
+	registryCredentialHandler := handlers.NewRegistryCredentialHandler(handlers.RegistryCredentialHandlerDependencies{
+		Logger:              log,
+		RegistryCredentials: registryCredentialProvider,
+	})
+
 	eventHandler := handlers.NewEventHandler(handlers.EventHandlerDependencies{
-		Logger:       log,
-		EventsStorer: eventStorer,
+		Logger:           log,
+		EventsStorer:     eventStorer,
+		ArtifactStorer:   artifactStore,
+		Executor:         ex,
+		RepositoryStorer: repoStore,
+		Clock:            clock,
+		UUIDGenerator:    uuidGenerator,
 	})
 
 	userHandler := handlers.NewUserHandler(handlers.UserHandlerDependencies{
@@ -344,27 +536,44 @@ func runKrokCmd(cmd *cobra.Command, args []string) {
 		Checker: readyProvider,
 	})
 
+	runnerMiddleware := krokmiddleware.NewRunnerMiddleware(krokmiddleware.RunnerMiddlewareConfig{
+		RunnerToken: krokArgs.runnerToken,
+	}, krokmiddleware.RunnerMiddlewareDeps{
+		Logger: log,
+	})
+
+	runnerHandler := handlers.NewRunnerHandler(handlers.RunnerHandlerConfig{
+		// Runners which wait for a command run have to ask again well before they are forgotten.
+		ClaimTimeout: time.Duration(krokArgs.runnerTimeout) * time.Second / 2,
+	}, handlers.RunnerHandlerDependencies{
+		Logger:      log,
+		Coordinator: coordinator,
+	})
+
 	// ************************
 	// Set up the server
 	// ************************
 
 	sv := server.NewKrokServer(krokArgs.server, server.Dependencies{
-		Logger:                 log,
-		HookHandler:            hookHandler,
-		UserMiddleware:         userMiddleware,
-		CommandHandler:         commandHandler,
-		CommandSettingsHandler: commandSettingsHandler,
-		CommandRunHandler:      commandRunHandler,
-		RepositoryHandler:      repoHandler,
-		APIKeyHandler:          apiKeysHandler,
-		AuthHandler:            authHandler,
-		TokenHandler:           tp,
-		VCSTokenHandler:        vcsTokenHandler,
-		SupportedPlatformList:  supportedPlatformListHandler,
-		EventsHandler:          eventHandler,
-		VaultHandler:           vaultHandler,
-		UserHandler:            userHandler,
-		ReadyHandler:           readyHandler,
+		Logger:                    log,
+		HookHandler:               hookHandler,
+		UserMiddleware:            userMiddleware,
+		CommandHandler:            commandHandler,
+		CommandSettingsHandler:    commandSettingsHandler,
+		CommandRunHandler:         commandRunHandler,
+		RepositoryHandler:         repoHandler,
+		APIKeyHandler:             apiKeysHandler,
+		AuthHandler:               authHandler,
+		TokenHandler:              tp,
+		VCSTokenHandler:           vcsTokenHandler,
+		RegistryCredentialHandler: registryCredentialHandler,
+		SupportedPlatformList:     supportedPlatformListHandler,
+		EventsHandler:             eventHandler,
+		VaultHandler:              vaultHandler,
+		UserHandler:               userHandler,
+		ReadyHandler:              readyHandler,
+		RunnerMiddleware:          runnerMiddleware,
+		RunnerHandler:             runnerHandler,
 	})
 
 	// Run service & server
@@ -374,11 +583,30 @@ func runKrokCmd(cmd *cobra.Command, args []string) {
 		return sv.Run(ctx)
 	})
 
+	g.Go(func() error {
+		return sch.Run(ctx)
+	})
+
+	g.Go(func() error {
+		return artifactStore.Run(ctx)
+	})
+
 	if err := g.Wait(); err != nil {
 		log.Err(err).Msg("Failed to run")
 	}
 }
 
+// newContainerRuntime creates the container runtime the commands run with.
+func newContainerRuntime(kind string, containerd containerruntime.ContainerdConfig, deps containerruntime.Dependencies) (providers.ContainerRuntime, error) {
+	switch kind {
+	case "docker":
+		return containerruntime.NewDockerRuntime(deps)
+	case "containerd":
+		return containerruntime.NewContainerdRuntime(containerd, deps)
+	}
+	return nil, fmt.Errorf("unknown container runtime %s", kind)
+}
+
 // Execute runs the main krok command.
 func Execute() error {
 	return krokCmd.Execute()
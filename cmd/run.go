@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/krok/providers/containerruntime"
+	"github.com/krok-o/krok/pkg/krok/providers/executor"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+var (
+	runCmd = &cobra.Command{
+		Use:   "run",
+		Short: "Run a command image locally",
+		Long: `Run a command image once against a payload file with the same arguments Krok hands to it for an event.
+No database or server is needed, so it's meant for developing and trying out command images.`,
+		Run: runRunCmd,
+	}
+	runArgs struct {
+		debug     bool
+		image     string
+		platform  string
+		eventType string
+		payload   string
+		// settings and secrets are given as key=value. Secrets are handled like settings in the vault.
+		settings         []string
+		secrets          []string
+		timeout          int
+		pullPolicy       string
+		containerRuntime string
+		containerd       containerruntime.ContainerdConfig
+		secretsLocation  string
+		containerUID     int
+		containerGID     int
+	}
+)
+
+func init() {
+	krokCmd.AddCommand(runCmd)
+	flag := runCmd.Flags()
+	flag.BoolVar(&runArgs.debug, "debug", false, "--debug")
+	flag.StringVar(&runArgs.image, "image", "", "--image krokhook/slack-notification:v0.0.1. The image of the command.")
+	flag.StringVar(&runArgs.platform, "platform", "github", "--platform github. The name of the platform the event comes from.")
+	flag.StringVar(&runArgs.eventType, "event-type", "push", "--event-type push")
+	flag.StringVar(&runArgs.payload, "payload", "", "--payload payload.json. The file with the payload of the event. If not set, the payload is an empty object.")
+	flag.StringArrayVar(&runArgs.settings, "setting", nil, "--setting channel=builds. A setting of the command, can be given multiple times.")
+	flag.StringArrayVar(&runArgs.secrets, "secret", nil, "--secret token=s3cr3t. A setting which is handled like a setting in the vault: it's delivered as a file and masked in the output. Can be given multiple times.")
+	flag.IntVar(&runArgs.timeout, "timeout", 120, "--timeout 120. How long the command may run. Given in seconds.")
+	flag.StringVar(&runArgs.pullPolicy, "pull-policy", "", "--pull-policy always|if-not-present|never. Defaults to always.")
+	flag.StringVar(&runArgs.containerRuntime, "container-runtime", "docker", "--container-runtime docker|containerd. Podman can be used through its Docker compatible API by setting DOCKER_HOST.")
+	flag.StringVar(&runArgs.containerd.Address, "containerd-address", containerruntime.DefaultContainerdAddress, "--containerd-address "+containerruntime.DefaultContainerdAddress)
+	flag.StringVar(&runArgs.containerd.Namespace, "containerd-namespace", containerruntime.DefaultContainerdNamespace, "--containerd-namespace "+containerruntime.DefaultContainerdNamespace)
+	flag.StringVar(&runArgs.containerd.LogLocation, "containerd-log-location", "/tmp/krok/logs", "--containerd-log-location /tmp/krok/logs. The output of the containers is written here.")
+	flag.StringVar(&runArgs.secretsLocation, "secrets-location", executor.DefaultSecretsLocation, "--secrets-location "+executor.DefaultSecretsLocation+". Settings delivered as files are written here and mounted into the command container.")
+	flag.IntVar(&runArgs.containerUID, "container-uid", 0, "--container-uid 1000. The user the command containers run as. The secret files are owned by it, so only it can read them. 0 keeps the current user.")
+	flag.IntVar(&runArgs.containerGID, "container-gid", 0, "--container-gid 1000. The group the command containers run as, which owns the secret files. 0 keeps the current group.")
+}
+
+// runRunCmd runs a single command image locally and prints its arguments and outcome.
+func runRunCmd(cmd *cobra.Command, args []string) {
+	out := zerolog.ConsoleWriter{
+		Out: os.Stderr,
+	}
+	level := zerolog.InfoLevel
+	if runArgs.debug {
+		level = zerolog.DebugLevel
+	}
+	log := zerolog.New(out).Level(level).With().
+		Timestamp().
+		Logger()
+
+	if runArgs.image == "" {
+		log.Fatal().Msg("must provide --image flag")
+	}
+	platform, err := platformByName(runArgs.platform)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid platform.")
+	}
+	payload := []byte("{}")
+	if runArgs.payload != "" {
+		if payload, err = ioutil.ReadFile(runArgs.payload); err != nil {
+			log.Fatal().Err(err).Msg("Failed to read payload.")
+		}
+	}
+	settings, err := parseSettings(runArgs.settings, false)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid setting.")
+	}
+	secrets, err := parseSettings(runArgs.secrets, true)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid secret.")
+	}
+	runtime, err := newContainerRuntime(runArgs.containerRuntime, runArgs.containerd, containerruntime.Dependencies{
+		Logger: log,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create container runtime.")
+	}
+
+	// The command is stopped with its stop signal on an interrupt.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	result, err := executor.RunLocal(ctx, executor.Config{
+		DefaultMaximumCommandRuntime: runArgs.timeout,
+		SecretsLocation:              runArgs.secretsLocation,
+		ContainerUID:                 runArgs.containerUID,
+		ContainerGID:                 runArgs.containerGID,
+	}, executor.Dependencies{
+		Logger:           log,
+		Clock:            providers.NewClock(),
+		ContainerRuntime: runtime,
+	}, executor.LocalRun{
+		Command: &models.Command{
+			Name:       "local",
+			Image:      runArgs.image,
+			Enabled:    true,
+			PullPolicy: runArgs.pullPolicy,
+		},
+		Event: &models.Event{
+			VCS:       platform.ID,
+			EventType: runArgs.eventType,
+			Payload:   string(payload),
+		},
+		Settings: append(settings, secrets...),
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to run command.")
+	}
+
+	fmt.Println("Arguments:")
+	for _, arg := range result.Args {
+		fmt.Printf("  %s\n", arg)
+	}
+	last := result.Runs[len(result.Runs)-1]
+	outcome, err := strconv.Unquote(last.Outcome)
+	if err != nil {
+		outcome = last.Outcome
+	}
+	fmt.Printf("Status: %s\n", last.Status)
+	if last.Signal != "" {
+		fmt.Printf("Signal: %s\n", last.Signal)
+	}
+	fmt.Printf("Outcome:\n%s\n", outcome)
+	if last.Status != models.RunStatusSuccess {
+		os.Exit(1)
+	}
+}
+
+// platformByName returns the supported platform with the given name.
+func platformByName(name string) (models.Platform, error) {
+	for _, platform := range models.SupportedPlatforms {
+		if platform.Name == name {
+			return platform, nil
+		}
+	}
+	return models.Platform{}, fmt.Errorf("platform %s is not supported", name)
+}
+
+// parseSettings parses settings given as key=value.
+func parseSettings(values []string, inVault bool) ([]*models.CommandSetting, error) {
+	var settings []*models.CommandSetting
+	for _, v := range values {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("setting %q is not in the form key=value", v)
+		}
+		settings = append(settings, &models.CommandSetting{
+			Key:     key,
+			Value:   value,
+			InVault: inVault,
+		})
+	}
+	return settings, nil
+}
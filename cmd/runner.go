@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/krok/providers/containerruntime"
+	"github.com/krok-o/krok/pkg/krok/providers/executor"
+	"github.com/krok-o/krok/pkg/krok/providers/runner"
+)
+
+var (
+	runnerCmd = &cobra.Command{
+		Use:   "runner",
+		Short: "Run commands for a Krok server",
+		Long: `Register with a Krok server which uses the remote executor and run the command runs it hands out with the
+local container runtime. The logs and outcomes are sent back to the server.`,
+		Run: runRunnerCmd,
+	}
+	runnerArgs struct {
+		debug             bool
+		server            string
+		token             string
+		name              string
+		labels            []string
+		parallel          int
+		heartbeatInterval int
+		containerRuntime  string
+		containerd        containerruntime.ContainerdConfig
+		secretsLocation   string
+		containerUID      int
+		containerGID      int
+	}
+)
+
+func init() {
+	krokCmd.AddCommand(runnerCmd)
+	hostname, _ := os.Hostname()
+	flag := runnerCmd.Flags()
+	flag.BoolVar(&runnerArgs.debug, "debug", false, "--debug")
+	flag.StringVar(&runnerArgs.server, "server", "http://localhost:9998", "--server https://krok.example.com. The address of the Krok server.")
+	flag.StringVar(&runnerArgs.token, "runner-token", "", "--runner-token s3cr3t. The runner token the Krok server has been started with.")
+	flag.StringVar(&runnerArgs.name, "name", hostname, "--name build-arm64-1. The name of the runner as it's shown in Krok. Defaults to the hostname.")
+	flag.StringArrayVar(&runnerArgs.labels, "label", nil, "--label arm64. A label of the runner, can be given multiple times. Commands with runner labels only run on runners which have all of them.")
+	flag.IntVar(&runnerArgs.parallel, "parallel", 1, "--parallel 1. How many commands the runner runs at the same time.")
+	flag.IntVar(&runnerArgs.heartbeatInterval, "heartbeat-interval", 5, "--heartbeat-interval 5. How often the logs of running commands are sent to Krok. Must be well below the runner timeout of Krok. Given in seconds.")
+	flag.StringVar(&runnerArgs.containerRuntime, "container-runtime", "docker", "--container-runtime docker|containerd. Podman can be used through its Docker compatible API by setting DOCKER_HOST.")
+	flag.StringVar(&runnerArgs.containerd.Address, "containerd-address", containerruntime.DefaultContainerdAddress, "--containerd-address "+containerruntime.DefaultContainerdAddress)
+	flag.StringVar(&runnerArgs.containerd.Namespace, "containerd-namespace", containerruntime.DefaultContainerdNamespace, "--containerd-namespace "+containerruntime.DefaultContainerdNamespace)
+	flag.StringVar(&runnerArgs.containerd.LogLocation, "containerd-log-location", "/tmp/krok/logs", "--containerd-log-location /tmp/krok/logs. The output of the containers is written here.")
+	flag.StringVar(&runnerArgs.secretsLocation, "secrets-location", executor.DefaultSecretsLocation, "--secrets-location "+executor.DefaultSecretsLocation+". Settings delivered as files are written here and mounted into the command container.")
+	flag.IntVar(&runnerArgs.containerUID, "container-uid", 0, "--container-uid 1000. The user the command containers run as. The secret files are owned by it, so only it can read them. 0 keeps the current user.")
+	flag.IntVar(&runnerArgs.containerGID, "container-gid", 0, "--container-gid 1000. The group the command containers run as, which owns the secret files. 0 keeps the current group.")
+}
+
+// runRunnerCmd runs a remote runner until it's interrupted.
+func runRunnerCmd(cmd *cobra.Command, args []string) {
+	out := zerolog.ConsoleWriter{
+		Out: os.Stderr,
+	}
+	level := zerolog.InfoLevel
+	if runnerArgs.debug {
+		level = zerolog.DebugLevel
+	}
+	log := zerolog.New(out).Level(level).With().
+		Timestamp().
+		Logger()
+
+	if runnerArgs.token == "" {
+		log.Fatal().Msg("must provide --runner-token flag")
+	}
+	if runnerArgs.name == "" {
+		log.Fatal().Msg("must provide --name flag")
+	}
+	runtime, err := newContainerRuntime(runnerArgs.containerRuntime, runnerArgs.containerd, containerruntime.Dependencies{
+		Logger: log,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create container runtime.")
+	}
+
+	// Running commands are stopped with their stop signal on an interrupt and reported as cancelled.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	r := runner.NewRunner(runner.Config{
+		Server:            runnerArgs.server,
+		Token:             runnerArgs.token,
+		Name:              runnerArgs.name,
+		Labels:            runnerArgs.labels,
+		Parallel:          runnerArgs.parallel,
+		HeartbeatInterval: time.Duration(runnerArgs.heartbeatInterval) * time.Second,
+		Executor: executor.Config{
+			SecretsLocation: runnerArgs.secretsLocation,
+			ContainerUID:    runnerArgs.containerUID,
+			ContainerGID:    runnerArgs.containerGID,
+		},
+	}, runner.Dependencies{
+		Logger:           log,
+		ContainerRuntime: runtime,
+		Clock:            providers.NewClock(),
+	})
+	if err := r.Run(ctx); err != nil {
+		log.Fatal().Err(err).Msg("Runner failed.")
+	}
+}
@@ -5,7 +5,30 @@ create table commands (
     image varchar not null,
     name varchar unique not null,
     schedule varchar,
-    requires_clone boolean
+    requires_clone boolean,
+    -- limits of the command container; 0 means no limit.
+    timeout int not null default 0,
+    cpus double precision not null default 0,
+    memory_limit bigint not null default 0,
+    pids_limit bigint not null default 0,
+    disk_limit bigint not null default 0,
+    -- how the command container is stopped on a timeout or when it's cancelled.
+    stop_signal varchar not null default '',
+    stop_grace_period int not null default 0,
+    -- the retry policy of the command; 0 attempts means the command isn't retried.
+    retry_max_attempts int not null default 0,
+    retry_backoff int not null default 0,
+    retry_on varchar[] not null default '{}',
+    -- the place of the command in the run queue and how many of its runs may run at the same time; 0 means no limit.
+    priority int not null default 0,
+    max_parallel_runs int not null default 0,
+    -- the concurrency group of the command; empty means the runs of the command aren't grouped.
+    concurrency_group varchar not null default '',
+    concurrency_cancel_in_progress boolean not null default false,
+    -- when the image of the command is pulled; empty means always.
+    pull_policy varchar not null default '',
+    -- the labels a remote runner needs to run the command; empty means any runner can run it.
+    runner_labels varchar[] not null default '{}'
 );
 
 create table command_settings
@@ -21,6 +44,8 @@ create table command_settings
     key varchar,
     value varchar,
     in_vault boolean,
+    -- how the setting is handed to the container: arg, env or file. Empty means the default.
+    delivery varchar not null default '',
     -- for a command make sure a key is unique. But for other commands the same key can be used.
     unique(command_id, key)
 );
@@ -30,13 +55,23 @@ create table repositories (
     name varchar ( 256 ) unique not null,
     url varchar ( 256 ),
     vcs int,
-    project_id int null
+    project_id int null,
+    -- the id of the hook on the platform of the repository.
+    hook_id varchar null,
+    -- settings of repositories with the generic platform.
+    generic_settings jsonb null,
+    -- the events the hook on the platform is registered for.
+    events varchar[] not null default '{}'
 );
 
 create table rel_commands_repositories (
     id serial primary key,
     repository_id int,
     command_id int,
+    -- the command only runs for these event types. Empty means all events.
+    event_types varchar[] not null default '{}',
+    -- glob pattern the branch of an event has to match. Empty means all branches.
+    branch_filter varchar not null default '',
     constraint fk_repository_id
         foreign key (repository_id)
             references repositories(id)
@@ -61,6 +96,23 @@ create table rel_commands_platforms (
             on delete cascade
 );
 
+-- The relationship which defines that a command depends on another command.
+-- A command only runs after all of its dependencies which are part of the same run succeeded.
+create table rel_commands_dependencies (
+    id serial primary key,
+    command_id int,
+    depends_on_id int,
+    constraint fk_command_id
+        foreign key (command_id)
+            references commands(id)
+            on delete cascade,
+    constraint fk_depends_on_id
+        foreign key (depends_on_id)
+            references commands(id)
+            on delete cascade,
+    unique(command_id, depends_on_id)
+);
+
 create table users (
     id serial primary key,
     -- email is coming from openid registration.
@@ -89,7 +141,13 @@ create table events (
     payload varchar,
     created_at date,
     vcs int,
-    event_type varchar
+    event_type varchar,
+    -- the event which this event runs the commands of again. 0 if it isn't a rerun.
+    rerun_of int not null default 0,
+    -- where the event came from, i.e.: hook, schedule or manual.
+    source varchar not null default '',
+    -- the user who triggered a manual event.
+    triggered_by int not null default 0
 );
 
 -- store a run for a command. This is associated with an event.
@@ -100,9 +158,18 @@ create table events (
 create table command_run (
     id serial primary key,
     command_name varchar,
+    -- command_id is kept without a foreign key, so it can be used to
+    -- pick up runs after a restart as long as the command still exists.
+    command_id int,
+    -- the attempt of the command this run is for, starting at 1.
+    attempt int not null default 1,
+    -- the container which executes this run, if it has been created already.
+    container_id varchar,
     event_id int,
     status varchar,
     outcome varchar,
+    -- the signal which stopped a cancelled or timed out run.
+    signal varchar not null default '',
     created_at date
 );
 
@@ -0,0 +1,185 @@
+// Package payload contains helpers to extract information from the payloads of platform events.
+package payload
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/krok-o/krok/pkg/models"
+)
+
+const (
+	branchRefPrefix = "refs/heads/"
+	tagRefPrefix    = "refs/tags/"
+)
+
+// branchPaths are the locations of the branch in the payloads of the supported platforms.
+// The first one which is found is used. For pull and merge requests it is the target branch.
+var branchPaths = []string{
+	// push events of GitHub, GitLab and Gitea, and create and delete events of GitHub and Gitea.
+	"ref",
+	// pull request events of GitHub and Gitea.
+	"pull_request.base.ref",
+	// merge request events of GitLab.
+	"object_attributes.target_branch",
+	// push events of Bitbucket Cloud. Tags are pushed the same way, see refTypePaths.
+	"push.changes.0.new.name",
+	// pull request events of Bitbucket Cloud.
+	"pullrequest.destination.branch.name",
+	// push events of Bitbucket Server.
+	"changes.0.refId",
+	// pull request events of Bitbucket Server.
+	"pullRequest.toRef.id",
+}
+
+// pullRequestPaths are the locations of the number of a pull or merge request in the payloads of the supported platforms.
+var pullRequestPaths = []string{
+	// pull request events of GitHub and Gitea.
+	"pull_request.number",
+	// merge request events of GitLab.
+	"object_attributes.iid",
+	// pull request events of Bitbucket Cloud.
+	"pullrequest.id",
+	// pull request events of Bitbucket Server.
+	"pullRequest.id",
+}
+
+// refTypePaths are the locations of the type of the ref in the payloads of the supported platforms. Events
+// which have one belong to a branch only if it is "branch".
+var refTypePaths = []string{
+	// create and delete events of GitHub and Gitea, which send the ref without refs/heads/ or refs/tags/.
+	"ref_type",
+	// push events of Bitbucket Cloud.
+	"push.changes.0.new.type",
+}
+
+// Lookup returns the value at a dot separated path in a JSON payload, i.e.: pull_request.base.ref.
+// Array elements can be addressed with their index. Values which aren't strings are returned in
+// their JSON representation.
+func Lookup(payload []byte, p string) (string, bool) {
+	var data interface{}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return "", false
+	}
+	for _, key := range strings.Split(p, ".") {
+		switch v := data.(type) {
+		case map[string]interface{}:
+			value, ok := v[key]
+			if !ok {
+				return "", false
+			}
+			data = value
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return "", false
+			}
+			data = v[i]
+		default:
+			return "", false
+		}
+	}
+	switch v := data.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	default:
+		out, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(out), true
+	}
+}
+
+// Branch returns the branch an event belongs to. For tags and events which don't
+// belong to a branch it returns false.
+func Branch(payload []byte) (string, bool) {
+	for _, p := range refTypePaths {
+		if refType, ok := Lookup(payload, p); ok && refType != "branch" {
+			return "", false
+		}
+	}
+	for _, p := range branchPaths {
+		ref, ok := Lookup(payload, p)
+		if !ok || ref == "" {
+			continue
+		}
+		if strings.HasPrefix(ref, tagRefPrefix) {
+			return "", false
+		}
+		return strings.TrimPrefix(ref, branchRefPrefix), true
+	}
+	return "", false
+}
+
+// PullRequest returns the number of the pull or merge request an event belongs to. For events which don't
+// belong to a pull request it returns false.
+func PullRequest(payload []byte) (string, bool) {
+	for _, p := range pullRequestPaths {
+		if number, ok := Lookup(payload, p); ok && number != "" {
+			return number, true
+		}
+	}
+	return "", false
+}
+
+// ConcurrencyGroup returns the value of a concurrency group for an event. The group is either the branch,
+// the pull request or a path in the payload. It returns false if the event doesn't have a value for it.
+func ConcurrencyGroup(group string, payload []byte) (string, bool) {
+	switch group {
+	case "":
+		return "", false
+	case models.ConcurrencyGroupBranch:
+		return Branch(payload)
+	case models.ConcurrencyGroupPullRequest:
+		return PullRequest(payload)
+	default:
+		return Lookup(payload, group)
+	}
+}
+
+// ValidateFilter returns an error if the filter is invalid.
+func ValidateFilter(filter *models.CommandFilter) error {
+	if filter == nil || filter.Branch == "" {
+		return nil
+	}
+	if _, err := path.Match(filter.Branch, ""); err != nil {
+		return fmt.Errorf("invalid branch pattern %q: %w", filter.Branch, err)
+	}
+	return nil
+}
+
+// Matches returns true if an event with the given type and payload passes the filter.
+// An empty filter matches everything.
+func Matches(filter *models.CommandFilter, eventType string, payload []byte) bool {
+	if filter == nil {
+		return true
+	}
+	if len(filter.EventTypes) > 0 {
+		found := false
+		for _, t := range filter.EventTypes {
+			if t == eventType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if filter.Branch != "" {
+		branch, ok := Branch(payload)
+		if !ok {
+			return false
+		}
+		if match, err := path.Match(filter.Branch, branch); err != nil || !match {
+			return false
+		}
+	}
+	return true
+}
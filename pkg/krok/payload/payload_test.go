@@ -0,0 +1,124 @@
+package payload
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/krok-o/krok/pkg/models"
+)
+
+func TestLookup(t *testing.T) {
+	payload := []byte(`{"pull_request":{"base":{"ref":"main"},"number":5},"commits":[{"id":"abc"}],"null":null}`)
+	v, ok := Lookup(payload, "pull_request.base.ref")
+	assert.True(t, ok)
+	assert.Equal(t, "main", v)
+	v, ok = Lookup(payload, "pull_request.number")
+	assert.True(t, ok)
+	assert.Equal(t, "5", v)
+	v, ok = Lookup(payload, "commits.0.id")
+	assert.True(t, ok)
+	assert.Equal(t, "abc", v)
+	_, ok = Lookup(payload, "commits.1.id")
+	assert.False(t, ok)
+	_, ok = Lookup(payload, "null")
+	assert.False(t, ok)
+	_, ok = Lookup(payload, "missing")
+	assert.False(t, ok)
+	_, ok = Lookup([]byte("not json"), "ref")
+	assert.False(t, ok)
+}
+
+func TestBranch(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		payload string
+		branch  string
+		ok      bool
+	}{
+		{name: "push", payload: `{"ref":"refs/heads/main"}`, branch: "main", ok: true},
+		{name: "push nested branch", payload: `{"ref":"refs/heads/release/v1"}`, branch: "release/v1", ok: true},
+		{name: "tag", payload: `{"ref":"refs/tags/v1.0.0"}`, ok: false},
+		{name: "github pull request", payload: `{"pull_request":{"base":{"ref":"main"},"head":{"ref":"feature"}}}`, branch: "main", ok: true},
+		{name: "gitlab merge request", payload: `{"object_attributes":{"target_branch":"develop"}}`, branch: "develop", ok: true},
+		{name: "ping", payload: `{"zen":"Keep it logically awesome."}`, ok: false},
+	} {
+		t.Run(tc.name, func(tt *testing.T) {
+			branch, ok := Branch([]byte(tc.payload))
+			assert.Equal(tt, tc.ok, ok)
+			assert.Equal(tt, tc.branch, branch)
+		})
+	}
+}
+
+func TestBranch_Payloads(t *testing.T) {
+	for _, tc := range []struct {
+		file   string
+		branch string
+		ok     bool
+	}{
+		{file: "bitbucket_push.json", branch: "release/v1", ok: true},
+		{file: "bitbucket_tag_push.json", ok: false},
+		{file: "bitbucket_pullrequest_created.json", branch: "release/v1", ok: true},
+		{file: "bitbucket_server_push.json", branch: "release/v1", ok: true},
+		{file: "bitbucket_server_tag_push.json", ok: false},
+		{file: "bitbucket_server_pr_opened.json", branch: "release/v1", ok: true},
+		{file: "github_create_branch.json", branch: "release/v1", ok: true},
+		{file: "github_create_tag.json", ok: false},
+	} {
+		t.Run(tc.file, func(tt *testing.T) {
+			content, err := ioutil.ReadFile(filepath.Join("testdata", tc.file))
+			require.NoError(tt, err)
+			branch, ok := Branch(content)
+			assert.Equal(tt, tc.ok, ok)
+			assert.Equal(tt, tc.branch, branch)
+			assert.Equal(tt, tc.ok, Matches(&models.CommandFilter{Branch: "release/*"}, "push", content))
+		})
+	}
+}
+
+func TestConcurrencyGroup(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		group   string
+		payload string
+		value   string
+		ok      bool
+	}{
+		{name: "no group", payload: `{"ref":"refs/heads/main"}`, ok: false},
+		{name: "branch", group: models.ConcurrencyGroupBranch, payload: `{"ref":"refs/heads/main"}`, value: "main", ok: true},
+		{name: "github pull request", group: models.ConcurrencyGroupPullRequest, payload: `{"pull_request":{"number":5}}`, value: "5", ok: true},
+		{name: "gitlab merge request", group: models.ConcurrencyGroupPullRequest, payload: `{"object_attributes":{"iid":7}}`, value: "7", ok: true},
+		{name: "bitbucket pull request", group: models.ConcurrencyGroupPullRequest, payload: `{"pullrequest":{"id":3}}`, value: "3", ok: true},
+		{name: "push without pull request", group: models.ConcurrencyGroupPullRequest, payload: `{"ref":"refs/heads/main"}`, ok: false},
+		{name: "path", group: "repository.name", payload: `{"repository":{"name":"krok"}}`, value: "krok", ok: true},
+	} {
+		t.Run(tc.name, func(tt *testing.T) {
+			value, ok := ConcurrencyGroup(tc.group, []byte(tc.payload))
+			assert.Equal(tt, tc.ok, ok)
+			assert.Equal(tt, tc.value, value)
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	push := []byte(`{"ref":"refs/heads/main"}`)
+	assert.True(t, Matches(nil, "ping", nil))
+	assert.True(t, Matches(&models.CommandFilter{}, "ping", nil))
+	assert.True(t, Matches(&models.CommandFilter{EventTypes: []string{"push", "pull_request"}}, "push", push))
+	assert.False(t, Matches(&models.CommandFilter{EventTypes: []string{"push", "pull_request"}}, "ping", nil))
+	assert.True(t, Matches(&models.CommandFilter{Branch: "main"}, "push", push))
+	assert.True(t, Matches(&models.CommandFilter{Branch: "ma*"}, "push", push))
+	assert.False(t, Matches(&models.CommandFilter{Branch: "release/*"}, "push", push))
+	assert.False(t, Matches(&models.CommandFilter{Branch: "main"}, "ping", []byte(`{}`)))
+	assert.False(t, Matches(&models.CommandFilter{EventTypes: []string{"pull_request"}, Branch: "main"}, "push", push))
+}
+
+func TestValidateFilter(t *testing.T) {
+	assert.NoError(t, ValidateFilter(nil))
+	assert.NoError(t, ValidateFilter(&models.CommandFilter{Branch: "release/*"}))
+	assert.Error(t, ValidateFilter(&models.CommandFilter{Branch: "release/["}))
+}
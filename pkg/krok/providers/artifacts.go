@@ -0,0 +1,20 @@
+package providers
+
+import (
+	"context"
+	"io"
+
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// ArtifactStorer manages the workspaces which are shared between the commands of a run
+// and the artifacts the commands leave in them.
+type ArtifactStorer interface {
+	// Workspace returns the location of the workspace for an event, creating it if it doesn't exist.
+	Workspace(ctx context.Context, eventID int) (string, error)
+	// List returns all the artifacts of an event.
+	List(ctx context.Context, eventID int) ([]*models.Artifact, error)
+	// Open opens an artifact of an event for reading. Returns errors.ErrNotFound if the artifact
+	// doesn't exist.
+	Open(ctx context.Context, eventID int, name string) (io.ReadCloser, error)
+}
@@ -0,0 +1,248 @@
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// Config has the configuration options for the artifact store.
+type Config struct {
+	// Location is the folder under which the workspaces of the events are created.
+	// This location is mounted into the command containers, therefore it must be
+	// accessible by the container runtime.
+	Location string
+	// Permissions of the workspaces. Commands might run as any user, so by default
+	// everyone can write into them. If all commands run as the same user, this can be limited.
+	Permissions os.FileMode
+	// Retention is how long workspaces are kept after they were last written to, in hours.
+	// 0 keeps them forever.
+	Retention int
+}
+
+const (
+	// defaultPermissions are used if no permissions are configured for the workspaces.
+	defaultPermissions os.FileMode = 0777
+	// locationPermissions lets everyone reach a workspace without being able to list the others.
+	locationPermissions os.FileMode = 0711
+	// cleanupInterval is how often expired workspaces are looked for.
+	cleanupInterval = time.Hour
+)
+
+// Dependencies defines the dependencies for the artifact store.
+type Dependencies struct {
+	Logger zerolog.Logger
+	Clock  providers.Clock
+}
+
+// FileStore keeps the workspaces of events in folders on the local file system.
+type FileStore struct {
+	Config
+	Dependencies
+}
+
+var _ providers.ArtifactStorer = &FileStore{}
+
+// NewFileStore creates a new file based artifact store.
+func NewFileStore(cfg Config, deps Dependencies) *FileStore {
+	if cfg.Permissions == 0 {
+		cfg.Permissions = defaultPermissions
+	}
+	return &FileStore{
+		Config:       cfg,
+		Dependencies: deps,
+	}
+}
+
+// workspace returns the location of the workspace of an event.
+func (f *FileStore) workspace(eventID int) string {
+	return filepath.Join(f.Location, strconv.Itoa(eventID))
+}
+
+// Workspace returns the location of the workspace for an event, creating it if it doesn't exist.
+func (f *FileStore) Workspace(ctx context.Context, eventID int) (string, error) {
+	location, err := filepath.Abs(f.workspace(eventID))
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path of workspace: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(location), locationPermissions); err != nil {
+		f.Logger.Debug().Err(err).Str("location", location).Msg("Failed to create workspace location.")
+		return "", fmt.Errorf("failed to create workspace location: %w", err)
+	}
+	if err := os.MkdirAll(location, f.Permissions); err != nil {
+		f.Logger.Debug().Err(err).Str("location", location).Msg("Failed to create workspace.")
+		return "", fmt.Errorf("failed to create workspace: %w", err)
+	}
+	// The umask is applied on creation, so the permissions are set explicitly.
+	if err := os.Chmod(location, f.Permissions); err != nil {
+		return "", fmt.Errorf("failed to set permissions of workspace: %w", err)
+	}
+	return location, nil
+}
+
+// List returns all the artifacts of an event.
+func (f *FileStore) List(ctx context.Context, eventID int) ([]*models.Artifact, error) {
+	root := f.workspace(eventID)
+	result := make([]*models.Artifact, 0)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		result = append(result, &models.Artifact{
+			Name:       filepath.ToSlash(name),
+			Size:       info.Size(),
+			ModifiedAt: info.ModTime(),
+		})
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		f.Logger.Debug().Err(err).Int("event_id", eventID).Msg("Failed to list artifacts.")
+		return nil, fmt.Errorf("failed to list artifacts: %w", err)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// Open opens an artifact of an event for reading.
+func (f *FileStore) Open(ctx context.Context, eventID int, name string) (io.ReadCloser, error) {
+	root := f.workspace(eventID)
+	clean := filepath.Clean(filepath.FromSlash("/" + name))
+	path := filepath.Join(root, clean)
+	if !strings.HasPrefix(path, root+string(filepath.Separator)) {
+		return nil, kerr.ErrNotFound
+	}
+	// Do not follow links which a command might have placed in the workspace.
+	resolved, err := filepath.EvalSymlinks(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, kerr.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open artifact: %w", err)
+	}
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open artifact: %w", err)
+	}
+	if resolved != filepath.Join(resolvedRoot, clean) {
+		return nil, kerr.ErrNotFound
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open artifact: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, kerr.ErrNotFound
+	}
+	file, err := os.Open(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open artifact: %w", err)
+	}
+	return file, nil
+}
+
+// Run periodically removes the workspaces which are older than the retention until the context is cancelled.
+func (f *FileStore) Run(ctx context.Context) error {
+	if f.Retention <= 0 {
+		return nil
+	}
+	f.Logger.Info().Int("retention", f.Retention).Msg("Starting workspace cleanup...")
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+	for {
+		if err := f.Cleanup(ctx); err != nil {
+			f.Logger.Error().Err(err).Msg("Failed to clean up workspaces.")
+		}
+		select {
+		case <-ctx.Done():
+			f.Logger.Info().Msg("Stopping workspace cleanup...")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Cleanup removes the workspaces which haven't been written to within the retention.
+func (f *FileStore) Cleanup(ctx context.Context) error {
+	if f.Retention <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(f.Location)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list workspaces: %w", err)
+	}
+	expiry := f.Clock.Now().Add(-time.Duration(f.Retention) * time.Hour)
+	for _, e := range entries {
+		// Only workspaces of events are removed, everything else in the location is left alone.
+		if _, err := strconv.Atoi(e.Name()); err != nil || !e.IsDir() {
+			continue
+		}
+		location := filepath.Join(f.Location, e.Name())
+		log := f.Logger.With().Str("location", location).Logger()
+		modified, err := lastModified(location)
+		if err != nil {
+			log.Debug().Err(err).Msg("Failed to check when the workspace was modified.")
+			continue
+		}
+		if modified.After(expiry) {
+			continue
+		}
+		if err := os.RemoveAll(location); err != nil {
+			log.Error().Err(err).Msg("Failed to remove expired workspace.")
+			continue
+		}
+		log.Debug().Msg("Removed expired workspace.")
+	}
+	return nil
+}
+
+// lastModified returns the last time anything in a folder has been modified.
+func lastModified(root string) (time.Time, error) {
+	var latest time.Time
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	return latest, err
+}
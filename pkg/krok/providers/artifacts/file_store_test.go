@@ -0,0 +1,100 @@
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers"
+)
+
+func TestFileStore_Flow(t *testing.T) {
+	location, err := ioutil.TempDir("", "TestFileStore_Flow")
+	require.NoError(t, err)
+	defer os.RemoveAll(location)
+	fs := NewFileStore(Config{Location: location}, Dependencies{Logger: zerolog.New(os.Stderr)})
+	ctx := context.Background()
+
+	artifacts, err := fs.List(ctx, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, artifacts)
+
+	workspace, err := fs.Workspace(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(location, "1"), workspace)
+	require.NoError(t, os.MkdirAll(filepath.Join(workspace, "public"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(workspace, "public", "index.html"), []byte("<html/>"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(workspace, "build.log"), []byte("done"), 0644))
+	require.NoError(t, os.Symlink("/etc/passwd", filepath.Join(workspace, "passwd")))
+	require.NoError(t, os.Symlink("/etc", filepath.Join(workspace, "etc")))
+
+	artifacts, err = fs.List(ctx, 1)
+	assert.NoError(t, err)
+	require.Len(t, artifacts, 2)
+	assert.Equal(t, "build.log", artifacts[0].Name)
+	assert.Equal(t, "public/index.html", artifacts[1].Name)
+	assert.Equal(t, int64(7), artifacts[1].Size)
+
+	r, err := fs.Open(ctx, 1, "public/index.html")
+	require.NoError(t, err)
+	content, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+	assert.Equal(t, "<html/>", string(content))
+
+	_, err = fs.Open(ctx, 1, "missing")
+	assert.True(t, errors.Is(err, kerr.ErrNotFound))
+	_, err = fs.Open(ctx, 1, "public")
+	assert.True(t, errors.Is(err, kerr.ErrNotFound))
+	_, err = fs.Open(ctx, 1, "passwd")
+	assert.True(t, errors.Is(err, kerr.ErrNotFound))
+	_, err = fs.Open(ctx, 1, "etc/passwd")
+	assert.True(t, errors.Is(err, kerr.ErrNotFound))
+	_, err = fs.Open(ctx, 2, "../1/build.log")
+	assert.True(t, errors.Is(err, kerr.ErrNotFound))
+}
+
+func TestFileStore_Cleanup(t *testing.T) {
+	location, err := ioutil.TempDir("", "TestFileStore_Cleanup")
+	require.NoError(t, err)
+	defer os.RemoveAll(location)
+	fs := NewFileStore(Config{Location: location, Retention: 24, Permissions: 0770}, Dependencies{
+		Logger: zerolog.New(os.Stderr),
+		Clock:  providers.NewClock(),
+	})
+	ctx := context.Background()
+
+	expired, err := fs.Workspace(ctx, 1)
+	require.NoError(t, err)
+	info, err := os.Stat(expired)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0770), info.Mode().Perm())
+	require.NoError(t, ioutil.WriteFile(filepath.Join(expired, "build.log"), []byte("done"), 0644))
+	old := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(expired, "build.log"), old, old))
+	require.NoError(t, os.Chtimes(expired, old, old))
+
+	// recently written files keep the workspace around.
+	recent, err := fs.Workspace(ctx, 2)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(recent, "build.log"), []byte("done"), 0644))
+	require.NoError(t, os.Chtimes(recent, old, old))
+
+	other := filepath.Join(location, "keep-me")
+	require.NoError(t, os.Mkdir(other, 0755))
+	require.NoError(t, os.Chtimes(other, old, old))
+
+	require.NoError(t, fs.Cleanup(ctx))
+	assert.NoDirExists(t, expired)
+	assert.DirExists(t, recent)
+	assert.DirExists(t, other)
+}
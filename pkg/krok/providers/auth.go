@@ -21,6 +21,17 @@ type RepositoryAuth interface {
 	CreateRepositoryAuth(ctx context.Context, repositoryID int, info *models.Auth) error
 }
 
+// RegistryCredentialProvider stores the credentials of container registries in the vault.
+type RegistryCredentialProvider interface {
+	// GetRegistryCredential returns the credential to pull the images of a command from a registry. A credential
+	// of the command is used instead of the one of the whole registry. Returns nil if there is none.
+	GetRegistryCredential(ctx context.Context, commandID int, host string) (*models.RegistryCredential, error)
+	// SaveRegistryCredential creates or replaces a registry credential.
+	SaveRegistryCredential(ctx context.Context, credential *models.RegistryCredential) error
+	// DeleteRegistryCredential deletes a registry credential. Returns ErrNotFound if there is none.
+	DeleteRegistryCredential(ctx context.Context, commandID int, host string) error
+}
+
 // APIKeysAuthenticator deals with authenticating api keys.
 type APIKeysAuthenticator interface {
 	// Match matches a given user's api keys with the stored ones.
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+const (
+	registryFormat        = "REGISTRY_%s"
+	commandRegistryFormat = prefixFormat + registryFormat
+)
+
+// RegistryCredentialProviderDependencies defines the dependencies for the registry credential provider.
+type RegistryCredentialProviderDependencies struct {
+	Logger zerolog.Logger
+	Vault  providers.Vault
+}
+
+// RegistryCredentialProvider keeps the credentials of container registries in the vault.
+type RegistryCredentialProvider struct {
+	RegistryCredentialProviderDependencies
+}
+
+// NewRegistryCredentialProvider creates a new registry credential provider.
+func NewRegistryCredentialProvider(deps RegistryCredentialProviderDependencies) *RegistryCredentialProvider {
+	return &RegistryCredentialProvider{
+		RegistryCredentialProviderDependencies: deps,
+	}
+}
+
+var _ providers.RegistryCredentialProvider = &RegistryCredentialProvider{}
+
+// registryCredentialKey returns the key of a credential in the vault.
+func registryCredentialKey(commandID int, host string) string {
+	host = normaliseRegistryHost(host)
+	if commandID > 0 {
+		return fmt.Sprintf(commandRegistryFormat, commandID, host)
+	}
+	return fmt.Sprintf(registryFormat, host)
+}
+
+// normaliseRegistryHost removes the scheme and path a host might have been given with, so
+// https://ghcr.io/ is the same registry as ghcr.io.
+func normaliseRegistryHost(host string) string {
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	host = strings.SplitN(host, "/", 2)[0]
+	return strings.ToLower(host)
+}
+
+// storedRegistryCredential is how a credential is saved in the vault.
+type storedRegistryCredential struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// GetRegistryCredential returns the credential of a command for a registry, or the one of the registry if
+// the command doesn't have one. Returns nil if there is neither.
+func (r *RegistryCredentialProvider) GetRegistryCredential(ctx context.Context, commandID int, host string) (*models.RegistryCredential, error) {
+	log := r.Logger.With().Int("command_id", commandID).Str("host", host).Logger()
+	if err := r.Vault.LoadSecrets(); err != nil {
+		log.Debug().Err(err).Msg("Failed to load secrets")
+		return nil, fmt.Errorf("failed to get registry credential: %w", err)
+	}
+	ids := []int{0}
+	if commandID > 0 {
+		ids = []int{commandID, 0}
+	}
+	for _, id := range ids {
+		value, err := r.Vault.GetSecret(registryCredentialKey(id, host))
+		if errors.Is(err, kerr.ErrNotFound) {
+			continue
+		} else if err != nil {
+			log.Debug().Err(err).Msg("GetSecret failed")
+			return nil, fmt.Errorf("failed to get registry credential: %w", err)
+		}
+		var stored storedRegistryCredential
+		if err := json.Unmarshal(value, &stored); err != nil {
+			log.Debug().Err(err).Msg("Failed to unmarshal registry credential")
+			return nil, fmt.Errorf("failed to unmarshal registry credential: %w", err)
+		}
+		return &models.RegistryCredential{
+			Host:      normaliseRegistryHost(host),
+			CommandID: id,
+			Username:  stored.Username,
+			Password:  stored.Password,
+		}, nil
+	}
+	log.Debug().Msg("No registry credential for the given host.")
+	return nil, nil
+}
+
+// SaveRegistryCredential saves a registry credential in the vault, replacing the existing one.
+func (r *RegistryCredentialProvider) SaveRegistryCredential(ctx context.Context, credential *models.RegistryCredential) error {
+	log := r.Logger.With().Int("command_id", credential.CommandID).Str("host", credential.Host).Logger()
+	if normaliseRegistryHost(credential.Host) == "" {
+		return errors.New("host is empty")
+	}
+	if credential.Username == "" || credential.Password == "" {
+		return errors.New("username or password is empty")
+	}
+	value, err := json.Marshal(storedRegistryCredential{
+		Username: credential.Username,
+		Password: credential.Password,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal registry credential: %w", err)
+	}
+	if err := r.Vault.LoadSecrets(); err != nil {
+		log.Debug().Err(err).Msg("Failed to load secrets")
+		return fmt.Errorf("failed to get secrets: %w", err)
+	}
+	log.Debug().Msg("Store registry credential")
+	r.Vault.AddSecret(registryCredentialKey(credential.CommandID, credential.Host), value)
+	if err := r.Vault.SaveSecrets(); err != nil {
+		log.Debug().Err(err).Msg("Failed to save secrets")
+		return fmt.Errorf("failed to save secrets: %w", err)
+	}
+	return nil
+}
+
+// DeleteRegistryCredential removes a registry credential from the vault.
+func (r *RegistryCredentialProvider) DeleteRegistryCredential(ctx context.Context, commandID int, host string) error {
+	log := r.Logger.With().Int("command_id", commandID).Str("host", host).Logger()
+	if err := r.Vault.LoadSecrets(); err != nil {
+		log.Debug().Err(err).Msg("Failed to load secrets")
+		return fmt.Errorf("failed to get secrets: %w", err)
+	}
+	key := registryCredentialKey(commandID, host)
+	if _, err := r.Vault.GetSecret(key); err != nil {
+		return fmt.Errorf("failed to get registry credential: %w", err)
+	}
+	r.Vault.DeleteSecret(key)
+	if err := r.Vault.SaveSecrets(); err != nil {
+		log.Debug().Err(err).Msg("Failed to save secrets")
+		return fmt.Errorf("failed to save secrets: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers/filevault"
+	"github.com/krok-o/krok/pkg/krok/providers/vault"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+func TestRegistryCredentialProvider(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	location, _ := ioutil.TempDir("", "TestRegistryCredentialProvider")
+	defer os.RemoveAll(location)
+	fileStore := filevault.NewFileStorer(filevault.Config{
+		Location: location,
+		Key:      "password123",
+	}, filevault.Dependencies{Logger: logger})
+	err := fileStore.Init()
+	require.NoError(t, err)
+	v := vault.NewKrokVault(vault.Dependencies{Logger: logger, Storer: fileStore})
+	rcp := NewRegistryCredentialProvider(RegistryCredentialProviderDependencies{
+		Logger: logger,
+		Vault:  v,
+	})
+	ctx := context.Background()
+
+	credential, err := rcp.GetRegistryCredential(ctx, 1, "ghcr.io")
+	assert.NoError(t, err)
+	assert.Nil(t, credential)
+
+	err = rcp.SaveRegistryCredential(ctx, &models.RegistryCredential{Host: "https://GHCR.io/", Username: "krok", Password: "registry-token"})
+	assert.NoError(t, err)
+	err = rcp.SaveRegistryCredential(ctx, &models.RegistryCredential{Host: "ghcr.io", CommandID: 2, Username: "deployer", Password: "deploy-token"})
+	assert.NoError(t, err)
+	err = rcp.SaveRegistryCredential(ctx, &models.RegistryCredential{Host: "ghcr.io"})
+	assert.Error(t, err)
+
+	// Commands without an own credential use the one of the registry.
+	credential, err = rcp.GetRegistryCredential(ctx, 1, "ghcr.io")
+	assert.NoError(t, err)
+	assert.Equal(t, &models.RegistryCredential{Host: "ghcr.io", Username: "krok", Password: "registry-token"}, credential)
+	credential, err = rcp.GetRegistryCredential(ctx, 2, "ghcr.io")
+	assert.NoError(t, err)
+	assert.Equal(t, &models.RegistryCredential{Host: "ghcr.io", CommandID: 2, Username: "deployer", Password: "deploy-token"}, credential)
+	credential, err = rcp.GetRegistryCredential(ctx, 1, "docker.io")
+	assert.NoError(t, err)
+	assert.Nil(t, credential)
+
+	err = rcp.DeleteRegistryCredential(ctx, 2, "ghcr.io")
+	assert.NoError(t, err)
+	credential, err = rcp.GetRegistryCredential(ctx, 2, "ghcr.io")
+	assert.NoError(t, err)
+	assert.Equal(t, "krok", credential.Username)
+	err = rcp.DeleteRegistryCredential(ctx, 2, "ghcr.io")
+	assert.ErrorIs(t, err, kerr.ErrNotFound)
+}
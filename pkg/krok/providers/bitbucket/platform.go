@@ -0,0 +1,325 @@
+package bitbucket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	"gopkg.in/go-playground/webhooks.v5/bitbucket"
+
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/krok/providers/platformapi"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+const (
+	// DefaultBaseURL is the address of the Bitbucket Cloud API.
+	DefaultBaseURL = "https://api.bitbucket.org/2.0"
+
+	hookUUIDFormat = "%d_BITBUCKET_HOOK_UUID"
+)
+
+// Config has the configuration options for the Bitbucket Cloud platform provider.
+type Config struct {
+	// BaseURL is the address of the Bitbucket Cloud API. Defaults to DefaultBaseURL.
+	BaseURL string
+	// AllowedIPRanges is a list of CIDRs hooks are accepted from. Bitbucket Cloud doesn't
+	// sign its requests, so this should be set to the ranges published by Atlassian.
+	// If empty, the source of the request isn't checked.
+	AllowedIPRanges []string
+	// TrustedProxies is a list of CIDRs of the reverse proxies in front of Krok. For requests coming
+	// from them, the source is the nearest address in X-Forwarded-For which isn't a trusted proxy.
+	// If empty, the source is the address the request comes from directly.
+	TrustedProxies []string
+}
+
+// Dependencies defines the dependencies for the plugin provider.
+type Dependencies struct {
+	Logger                zerolog.Logger
+	PlatformTokenProvider providers.PlatformTokenProvider
+	Vault                 providers.Vault
+}
+
+// Bitbucket is a Bitbucket Cloud based platform implementation.
+type Bitbucket struct {
+	Config
+	Dependencies
+
+	api *platformapi.Client
+}
+
+// NewBitbucketPlatformProvider creates a new hook platform provider for Bitbucket Cloud.
+func NewBitbucketPlatformProvider(cfg Config, deps Dependencies) *Bitbucket {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	if len(cfg.AllowedIPRanges) == 0 {
+		deps.Logger.Warn().Msg("No allowed ip ranges are configured for Bitbucket Cloud, hooks are accepted from anywhere.")
+	}
+	return &Bitbucket{Config: cfg, Dependencies: deps, api: platformapi.NewClient("bitbucket", platformapi.BearerToken, deps.Logger)}
+}
+
+var _ providers.Platform = &Bitbucket{}
+
+// ValidateRequest will take a hook and verify it being a valid hook request according to
+// Bitbucket's rules. Since Bitbucket Cloud doesn't sign the payload, the request has to come
+// from an allowed IP range and carry the UUID of the hook which was created for the repository.
+func (b *Bitbucket) ValidateRequest(ctx context.Context, req *http.Request, repoID int) error {
+	req.Header.Set("Content-type", "application/json")
+	log := b.Logger.With().Int("repository_id", repoID).Logger()
+
+	if err := b.checkSource(req); err != nil {
+		log.Debug().Err(err).Msg("Request is not coming from an allowed source.")
+		return err
+	}
+
+	if err := b.Vault.LoadSecrets(); err != nil {
+		log.Debug().Err(err).Msg("Failed to load secrets.")
+		return err
+	}
+	uuid, err := b.Vault.GetSecret(fmt.Sprintf(hookUUIDFormat, repoID))
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get the UUID of the hook.")
+		return errors.New("no hook uuid found for repository")
+	}
+
+	hook, _ := bitbucket.New(bitbucket.Options.UUID(string(uuid)))
+	_, err = hook.Parse(req,
+		bitbucket.RepoPushEvent,
+		bitbucket.RepoForkEvent,
+		bitbucket.RepoUpdatedEvent,
+		bitbucket.RepoCommitCommentCreatedEvent,
+		bitbucket.RepoCommitStatusCreatedEvent,
+		bitbucket.RepoCommitStatusUpdatedEvent,
+		bitbucket.IssueCreatedEvent,
+		bitbucket.IssueUpdatedEvent,
+		bitbucket.IssueCommentCreatedEvent,
+		bitbucket.PullRequestCreatedEvent,
+		bitbucket.PullRequestUpdatedEvent,
+		bitbucket.PullRequestApprovedEvent,
+		bitbucket.PullRequestUnapprovedEvent,
+		bitbucket.PullRequestMergedEvent,
+		bitbucket.PullRequestDeclinedEvent,
+		bitbucket.PullRequestCommentCreatedEvent,
+		bitbucket.PullRequestCommentUpdatedEvent,
+		bitbucket.PullRequestCommentDeletedEvent)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to parse bitbucket event.")
+		return err
+	}
+	return nil
+}
+
+// checkSource verifies that the request is coming from one of the allowed ip ranges.
+func (b *Bitbucket) checkSource(req *http.Request) error {
+	if len(b.AllowedIPRanges) == 0 {
+		return nil
+	}
+	source, err := b.source(req)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(source)
+	if ip == nil {
+		return fmt.Errorf("invalid remote address %q", req.RemoteAddr)
+	}
+	for _, r := range b.AllowedIPRanges {
+		_, cidr, err := net.ParseCIDR(r)
+		if err != nil {
+			return fmt.Errorf("invalid ip range %q: %w", r, err)
+		}
+		if cidr.Contains(ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("ip %s is not allowed", ip)
+}
+
+// source returns the address a request is coming from. X-Forwarded-For is only
+// taken into account for requests from trusted proxies.
+func (b *Bitbucket) source(req *http.Request) (string, error) {
+	if len(b.TrustedProxies) == 0 {
+		return echo.ExtractIPDirect()(req), nil
+	}
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, r := range b.TrustedProxies {
+		_, cidr, err := net.ParseCIDR(r)
+		if err != nil {
+			return "", fmt.Errorf("invalid trusted proxy range %q: %w", r, err)
+		}
+		options = append(options, echo.TrustIPRange(cidr))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)(req), nil
+}
+
+// GetEventID Based on the platform, retrieve the ID of the event.
+func (b *Bitbucket) GetEventID(ctx context.Context, r *http.Request) (string, error) {
+	id := r.Header.Get("X-Request-UUID")
+	if id == "" {
+		return "", errors.New("event id not found for request")
+	}
+	return id, nil
+}
+
+// GetEventType Based on the platform, retrieve the Type of the event.
+func (b *Bitbucket) GetEventType(ctx context.Context, r *http.Request) (string, error) {
+	event := r.Header.Get("X-Event-Key")
+	if len(event) == 0 {
+		return "", fmt.Errorf("failed to get event type")
+	}
+	return event, nil
+}
+
+// hookRequest is the body of the create hook call of the Bitbucket API.
+type hookRequest struct {
+	Description string   `json:"description"`
+	URL         string   `json:"url"`
+	Active      bool     `json:"active"`
+	Events      []string `json:"events"`
+}
+
+// hookResponse is the part of the created hook which krok cares about.
+type hookResponse struct {
+	UUID string `json:"uuid"`
+}
+
+var repoURLRegex = regexp.MustCompile("^(https://|git@|ssh://git@)([^/:]+)[/:]([^/]+)/([^/]+?)(\\.git)?/?$")
+
+// CreateHook can create a hook for the Bitbucket Cloud platform.
+func (b *Bitbucket) CreateHook(ctx context.Context, repo *models.Repository) error {
+	log := b.Logger.With().Str("unique_url", repo.UniqueURL).Str("repo", repo.Name).Strs("events", repo.Events).Logger()
+	token, err := b.PlatformTokenProvider.GetTokenForPlatform(repo.VCS)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get platform token.")
+		return err
+	}
+	if len(repo.Events) == 0 {
+		log.Error().Msg("No events provided to subscribe to.")
+		return errors.New("no events provided to subscribe to")
+	}
+	if repo.UniqueURL == "" {
+		log.Error().Msg("Unique callback url is empty.")
+		return errors.New("unique callback url is empty")
+	}
+	hooksURL, err := b.hooksURL(repo.URL)
+	if err != nil {
+		log.Debug().Err(err).Str("url", repo.URL).Msg("Failed to extract url parameters.")
+		return err
+	}
+	var hook hookResponse
+	if _, err := b.api.Do(ctx, "hook creation", http.MethodPost, hooksURL, token, hookRequest{
+		Description: "krok",
+		URL:         repo.UniqueURL,
+		Active:      true,
+		Events:      repo.Events,
+	}, &hook); err != nil {
+		log.Debug().Err(err).Msg("CreateHook failed.")
+		return err
+	}
+	if hook.UUID == "" {
+		return errors.New("no uuid returned for the created hook")
+	}
+	repo.HookID = hook.UUID
+
+	// The UUID is sent with every request of the hook, which is what ValidateRequest checks.
+	if err := b.Vault.LoadSecrets(); err != nil {
+		log.Debug().Err(err).Msg("Failed to load secrets.")
+		return err
+	}
+	b.Vault.AddSecret(fmt.Sprintf(hookUUIDFormat, repo.ID), []byte(hook.UUID))
+	if err := b.Vault.SaveSecrets(); err != nil {
+		log.Debug().Err(err).Msg("Failed to save hook uuid.")
+		return err
+	}
+	log.Debug().Str("uuid", hook.UUID).Msg("Successfully created hook for bitbucket!")
+	return nil
+}
+
+// UpdateHook updates the events and url of the hook of the repository.
+func (b *Bitbucket) UpdateHook(ctx context.Context, repo *models.Repository) error {
+	log := b.Logger.With().Str("unique_url", repo.UniqueURL).Str("repo", repo.Name).Str("hook_id", repo.HookID).Logger()
+	if repo.HookID == "" {
+		return errors.New("repository has no hook id")
+	}
+	if len(repo.Events) == 0 {
+		// Bitbucket replaces the events of the hook, so the stored events must not be empty.
+		log.Error().Msg("No events provided to subscribe to.")
+		return errors.New("no events provided to subscribe to")
+	}
+	token, err := b.PlatformTokenProvider.GetTokenForPlatform(repo.VCS)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get platform token.")
+		return err
+	}
+	hooksURL, err := b.hooksURL(repo.URL)
+	if err != nil {
+		log.Debug().Err(err).Str("url", repo.URL).Msg("Failed to extract url parameters.")
+		return err
+	}
+	if _, err := b.api.Do(ctx, "hook update", http.MethodPut, hooksURL+"/"+url.PathEscape(repo.HookID), token, hookRequest{
+		Description: "krok",
+		URL:         repo.UniqueURL,
+		Active:      true,
+		Events:      repo.Events,
+	}, nil); err != nil {
+		log.Debug().Err(err).Msg("UpdateHook failed.")
+		return err
+	}
+	log.Debug().Msg("Successfully updated hook for bitbucket!")
+	return nil
+}
+
+// DeleteHook deletes the hook of the repository and forgets its UUID.
+func (b *Bitbucket) DeleteHook(ctx context.Context, repo *models.Repository) error {
+	log := b.Logger.With().Str("repo", repo.Name).Str("hook_id", repo.HookID).Logger()
+	if repo.HookID == "" {
+		return errors.New("repository has no hook id")
+	}
+	token, err := b.PlatformTokenProvider.GetTokenForPlatform(repo.VCS)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get platform token.")
+		return err
+	}
+	hooksURL, err := b.hooksURL(repo.URL)
+	if err != nil {
+		log.Debug().Err(err).Str("url", repo.URL).Msg("Failed to extract url parameters.")
+		return err
+	}
+	code, err := b.api.Do(ctx, "hook deletion", http.MethodDelete, hooksURL+"/"+url.PathEscape(repo.HookID), token, nil, nil)
+	if err != nil && code != http.StatusNotFound {
+		log.Debug().Err(err).Msg("DeleteHook failed.")
+		return err
+	}
+	if err := b.Vault.LoadSecrets(); err != nil {
+		log.Debug().Err(err).Msg("Failed to load secrets.")
+		return err
+	}
+	b.Vault.DeleteSecret(fmt.Sprintf(hookUUIDFormat, repo.ID))
+	if err := b.Vault.SaveSecrets(); err != nil {
+		log.Debug().Err(err).Msg("Failed to save secrets.")
+		return err
+	}
+	log.Debug().Msg("Successfully deleted hook for bitbucket!")
+	return nil
+}
+
+// hooksURL returns the api url of the hooks of a repository.
+func (b *Bitbucket) hooksURL(repoURL string) (string, error) {
+	m := repoURLRegex.FindStringSubmatch(repoURL)
+	if m == nil {
+		return "", errors.New("failed to extract url parameters from git url")
+	}
+	workspace, slug := m[3], m[4]
+	return fmt.Sprintf("%s/repositories/%s/%s/hooks", strings.TrimSuffix(b.BaseURL, "/"), url.PathEscape(workspace), url.PathEscape(slug)), nil
+}
@@ -0,0 +1,209 @@
+package bitbucket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/krok/providers/filevault"
+	"github.com/krok-o/krok/pkg/krok/providers/vault"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+type mockPlatformTokenProvider struct {
+	providers.PlatformTokenProvider
+}
+
+func (mptp *mockPlatformTokenProvider) GetTokenForPlatform(vcs int) (string, error) {
+	return "token", nil
+}
+
+func newProvider(t *testing.T, cfg Config) *Bitbucket {
+	logger := zerolog.New(os.Stderr)
+	location, _ := ioutil.TempDir("", "TestBitbucket")
+	t.Cleanup(func() { _ = os.RemoveAll(location) })
+	fileStore := filevault.NewFileStorer(filevault.Config{
+		Location: location,
+		Key:      "password123",
+	}, filevault.Dependencies{Logger: logger})
+	require.NoError(t, fileStore.Init())
+	return NewBitbucketPlatformProvider(cfg, Dependencies{
+		Logger:                logger,
+		PlatformTokenProvider: &mockPlatformTokenProvider{},
+		Vault:                 vault.NewKrokVault(vault.Dependencies{Logger: logger, Storer: fileStore}),
+	})
+}
+
+func TestBitbucket_CreateHook(t *testing.T) {
+	var got hookRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/repositories/krok-o/krok/hooks", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"uuid": "{hook-uuid}", "active": true}`))
+	}))
+	defer ts.Close()
+
+	npp := newProvider(t, Config{BaseURL: ts.URL})
+	err := npp.CreateHook(context.Background(), &models.Repository{
+		Name:      "test",
+		ID:        1,
+		URL:       "git@bitbucket.org:krok-o/krok.git",
+		VCS:       models.BITBUCKET,
+		UniqueURL: "https://krok.com/hooks/1/4/callback",
+		Events:    []string{"repo:push"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, hookRequest{
+		Description: "krok",
+		URL:         "https://krok.com/hooks/1/4/callback",
+		Active:      true,
+		Events:      []string{"repo:push"},
+	}, got)
+	require.NoError(t, npp.Vault.LoadSecrets())
+	uuid, err := npp.Vault.GetSecret("1_BITBUCKET_HOOK_UUID")
+	require.NoError(t, err)
+	assert.Equal(t, "{hook-uuid}", string(uuid))
+}
+
+func TestBitbucket_CreateHook_Errors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	npp := newProvider(t, Config{BaseURL: ts.URL})
+	repo := &models.Repository{
+		Name:      "test",
+		ID:        1,
+		URL:       "https://bitbucket.org/krok-o/krok",
+		VCS:       models.BITBUCKET,
+		UniqueURL: "https://krok.com/hooks/1/4/callback",
+		Events:    []string{"repo:push"},
+	}
+	err := npp.CreateHook(context.Background(), repo)
+	assert.EqualError(t, err, "invalid status code 403 received from hook creation")
+
+	repo.URL = "https://bitbucket.org/krok-o"
+	err = npp.CreateHook(context.Background(), repo)
+	assert.EqualError(t, err, "failed to extract url parameters from git url")
+}
+
+func TestBitbucket_ValidateRequest(t *testing.T) {
+	npp := newProvider(t, Config{AllowedIPRanges: []string{"192.0.2.0/24"}})
+	require.NoError(t, npp.Vault.LoadSecrets())
+	npp.Vault.AddSecret("1_BITBUCKET_HOOK_UUID", []byte("{hook-uuid}"))
+	require.NoError(t, npp.Vault.SaveSecrets())
+
+	newRequest := func(uuid string) *http.Request {
+		// httptest requests come from 192.0.2.1.
+		req := httptest.NewRequest(http.MethodPost, "/hooks/1/4/callback", bytes.NewReader([]byte(`{}`)))
+		req.Header.Set("X-Event-Key", "repo:push")
+		req.Header.Set("X-Hook-UUID", uuid)
+		return req
+	}
+
+	err := npp.ValidateRequest(context.Background(), newRequest("{hook-uuid}"), 1)
+	assert.NoError(t, err)
+
+	err = npp.ValidateRequest(context.Background(), newRequest("{other-uuid}"), 1)
+	assert.EqualError(t, err, "UUID verification failed")
+
+	err = npp.ValidateRequest(context.Background(), newRequest("{hook-uuid}"), 2)
+	assert.EqualError(t, err, "no hook uuid found for repository")
+
+	npp.AllowedIPRanges = []string{"104.192.136.0/21"}
+	err = npp.ValidateRequest(context.Background(), newRequest("{hook-uuid}"), 1)
+	assert.EqualError(t, err, "ip 192.0.2.1 is not allowed")
+
+	// Behind a trusted proxy, the address it forwarded the request for is checked.
+	req := newRequest("{hook-uuid}")
+	req.Header.Set("X-Forwarded-For", "104.192.136.5")
+	err = npp.ValidateRequest(context.Background(), req, 1)
+	assert.EqualError(t, err, "ip 192.0.2.1 is not allowed")
+	npp.TrustedProxies = []string{"192.0.2.0/24"}
+	req = newRequest("{hook-uuid}")
+	req.Header.Set("X-Forwarded-For", "104.192.136.5")
+	err = npp.ValidateRequest(context.Background(), req, 1)
+	assert.NoError(t, err)
+	req = newRequest("{hook-uuid}")
+	req.Header.Set("X-Forwarded-For", "104.192.136.5, 198.51.100.7")
+	err = npp.ValidateRequest(context.Background(), req, 1)
+	assert.EqualError(t, err, "ip 198.51.100.7 is not allowed")
+}
+
+func TestBitbucket_GetEventIDAndType(t *testing.T) {
+	npp := newProvider(t, Config{})
+	header := http.Header{}
+	header.Add("X-Request-UUID", "ID")
+	header.Add("X-Event-Key", "repo:push")
+	req := &http.Request{Header: header}
+
+	id, err := npp.GetEventID(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "ID", id)
+	event, err := npp.GetEventType(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "repo:push", event)
+
+	_, err = npp.GetEventID(context.Background(), &http.Request{})
+	assert.EqualError(t, err, "event id not found for request")
+	_, err = npp.GetEventType(context.Background(), &http.Request{Header: http.Header{}})
+	assert.Error(t, err)
+}
+
+func TestBitbucket_UpdateAndDeleteHook(t *testing.T) {
+	var (
+		method string
+		path   string
+		got    hookRequest
+	)
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		if r.Method == http.MethodPut {
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		}
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	npp := newProvider(t, Config{BaseURL: ts.URL})
+	require.NoError(t, npp.Vault.LoadSecrets())
+	npp.Vault.AddSecret("1_BITBUCKET_HOOK_UUID", []byte("{hook-uuid}"))
+	require.NoError(t, npp.Vault.SaveSecrets())
+	repo := &models.Repository{
+		Name:      "test",
+		ID:        1,
+		URL:       "https://bitbucket.org/krok-o/krok",
+		VCS:       models.BITBUCKET,
+		UniqueURL: "https://krok.com/hooks/1/4/callback",
+		Events:    []string{"repo:push", "pullrequest:created"},
+		HookID:    "{hook-uuid}",
+	}
+	err := npp.UpdateHook(context.Background(), repo)
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/repositories/krok-o/krok/hooks/{hook-uuid}", path)
+	assert.Equal(t, []string{"repo:push", "pullrequest:created"}, got.Events)
+
+	status = http.StatusNotFound
+	err = npp.DeleteHook(context.Background(), repo)
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodDelete, method)
+	require.NoError(t, npp.Vault.LoadSecrets())
+	_, err = npp.Vault.GetSecret("1_BITBUCKET_HOOK_UUID")
+	assert.Error(t, err)
+}
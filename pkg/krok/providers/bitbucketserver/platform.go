@@ -0,0 +1,281 @@
+package bitbucketserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/krok/providers/platformapi"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+const signaturePrefix = "sha256="
+
+// Config has the configuration options for the Bitbucket Server platform provider.
+type Config struct {
+	// BaseURL is the address of the Bitbucket Server instance, i.e.: https://bitbucket.example.com.
+	// If it's empty, the address is taken from the URL of the repository.
+	BaseURL string
+}
+
+// Dependencies defines the dependencies for the plugin provider.
+type Dependencies struct {
+	Logger                zerolog.Logger
+	PlatformTokenProvider providers.PlatformTokenProvider
+	AuthProvider          providers.RepositoryAuth
+}
+
+// BitbucketServer is a Bitbucket Server and Data Center based platform implementation.
+type BitbucketServer struct {
+	Config
+	Dependencies
+
+	api *platformapi.Client
+}
+
+// NewBitbucketServerPlatformProvider creates a new hook platform provider for Bitbucket Server.
+func NewBitbucketServerPlatformProvider(cfg Config, deps Dependencies) *BitbucketServer {
+	return &BitbucketServer{Config: cfg, Dependencies: deps, api: platformapi.NewClient("bitbucket server", platformapi.BearerToken, deps.Logger)}
+}
+
+var _ providers.Platform = &BitbucketServer{}
+
+// ValidateRequest will take a hook and verify it being a valid hook request according to
+// Bitbucket Server's rules. The payload is signed with the secret of the hook using HMAC-SHA256.
+func (b *BitbucketServer) ValidateRequest(ctx context.Context, req *http.Request, repoID int) error {
+	repoAuth, err := b.AuthProvider.GetRepositoryAuth(ctx, repoID)
+	if err != nil {
+		b.Logger.Debug().Err(err).Msg("Failed to get Repository Auth information.")
+		return err
+	}
+
+	if repoAuth == nil || repoAuth.Secret == "" {
+		b.Logger.Debug().Msg("Auth is not present.")
+		return errors.New("no auth specified")
+	}
+
+	if req.Method != http.MethodPost {
+		return errors.New("invalid http method")
+	}
+	if req.Header.Get("X-Event-Key") == "" {
+		return errors.New("missing X-Event-Key header")
+	}
+	signature := req.Header.Get("X-Hub-Signature")
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return errors.New("missing or invalid X-Hub-Signature header")
+	}
+	if req.Body == nil {
+		return errors.New("empty payload")
+	}
+	payload, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		b.Logger.Debug().Err(err).Msg("Failed to read request body.")
+		return err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewBuffer(payload))
+
+	mac := hmac.New(sha256.New, []byte(repoAuth.Secret))
+	_, _ = mac.Write(payload)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(strings.TrimPrefix(signature, signaturePrefix)), []byte(expected)) {
+		b.Logger.Debug().Msg("Signature of the request does not match.")
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// GetEventID Based on the platform, retrieve the ID of the event.
+func (b *BitbucketServer) GetEventID(ctx context.Context, r *http.Request) (string, error) {
+	id := r.Header.Get("X-Request-Id")
+	if id == "" {
+		return "", errors.New("event id not found for request")
+	}
+	return id, nil
+}
+
+// GetEventType Based on the platform, retrieve the Type of the event.
+func (b *BitbucketServer) GetEventType(ctx context.Context, r *http.Request) (string, error) {
+	event := r.Header.Get("X-Event-Key")
+	if len(event) == 0 {
+		return "", fmt.Errorf("failed to get event type")
+	}
+	return event, nil
+}
+
+// webhookRequest is the body of the create webhook call of the Bitbucket Server API.
+type webhookRequest struct {
+	Name          string            `json:"name"`
+	URL           string            `json:"url"`
+	Active        bool              `json:"active"`
+	Events        []string          `json:"events"`
+	Configuration map[string]string `json:"configuration"`
+}
+
+// webhookResponse is the part of the created webhook which krok cares about.
+type webhookResponse struct {
+	ID int `json:"id"`
+}
+
+var (
+	// i.e.: https://bitbucket.example.com/scm/PROJ/repo.git
+	scmURLRegex = regexp.MustCompile("^(.*)/scm/([^/]+)/([^/]+?)(\\.git)?/?$")
+	// i.e.: https://bitbucket.example.com/projects/PROJ/repos/repo/browse
+	browseURLRegex = regexp.MustCompile("^(.*)/projects/([^/]+)/repos/([^/]+)(/.*)?$")
+	// i.e.: ssh://git@bitbucket.example.com:7999/proj/repo.git
+	sshURLRegex = regexp.MustCompile("^ssh://[^@/]+@([^/:]+)(:[0-9]+)?/([^/]+)/([^/]+?)(\\.git)?$")
+)
+
+// parseRepoURL returns the address of the Bitbucket Server instance, the project key and
+// the slug of the repository from the URL of the repository.
+func parseRepoURL(raw string) (string, string, string, error) {
+	if m := scmURLRegex.FindStringSubmatch(raw); m != nil {
+		return m[1], m[2], m[3], nil
+	}
+	if m := browseURLRegex.FindStringSubmatch(raw); m != nil {
+		return m[1], m[2], m[3], nil
+	}
+	if m := sshURLRegex.FindStringSubmatch(raw); m != nil {
+		// The ssh port is not the port of the REST API, so guess https on the default port.
+		return "https://" + m[1], m[3], m[4], nil
+	}
+	return "", "", "", fmt.Errorf("unrecognised bitbucket server url %q", raw)
+}
+
+// CreateHook can create a hook for the Bitbucket Server platform.
+func (b *BitbucketServer) CreateHook(ctx context.Context, repo *models.Repository) error {
+	log := b.Logger.With().Str("unique_url", repo.UniqueURL).Str("repo", repo.Name).Strs("events", repo.Events).Logger()
+	token, err := b.PlatformTokenProvider.GetTokenForPlatform(repo.VCS)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get platform token.")
+		return err
+	}
+	if repo.Auth == nil {
+		log.Error().Msg("No auth provided for the repository.")
+		return errors.New("no auth provided with the repository")
+	}
+	if repo.Auth.Secret == "" {
+		log.Error().Msg("No secret provided for the repository.")
+		return errors.New("no secret provided to create a hook")
+	}
+	if len(repo.Events) == 0 {
+		log.Error().Msg("No events provided to subscribe to.")
+		return errors.New("no events provided to subscribe to")
+	}
+	if repo.UniqueURL == "" {
+		log.Error().Msg("Unique callback url is empty.")
+		return errors.New("unique callback url is empty")
+	}
+	webhooksURL, err := b.webhooksURL(repo.URL)
+	if err != nil {
+		log.Debug().Err(err).Str("url", repo.URL).Msg("Failed to extract url parameters.")
+		return errors.New("failed to extract url parameters from git url")
+	}
+	var hook webhookResponse
+	if _, err := b.api.Do(ctx, "hook creation", http.MethodPost, webhooksURL, token, webhookPayload(repo), &hook); err != nil {
+		log.Debug().Err(err).Msg("CreateHook failed.")
+		return err
+	}
+	repo.HookID = strconv.Itoa(hook.ID)
+	log.Debug().Str("hook_id", repo.HookID).Msg("Successfully created hook for bitbucket server!")
+	return nil
+}
+
+// UpdateHook updates the events, secret and url of the hook of the repository.
+func (b *BitbucketServer) UpdateHook(ctx context.Context, repo *models.Repository) error {
+	log := b.Logger.With().Str("unique_url", repo.UniqueURL).Str("repo", repo.Name).Str("hook_id", repo.HookID).Logger()
+	if repo.HookID == "" {
+		return errors.New("repository has no hook id")
+	}
+	if repo.Auth == nil || repo.Auth.Secret == "" {
+		log.Error().Msg("No secret provided for the repository.")
+		return errors.New("no secret provided to update a hook")
+	}
+	if len(repo.Events) == 0 {
+		// Bitbucket replaces the events of the hook, so the stored events must not be empty.
+		log.Error().Msg("No events provided to subscribe to.")
+		return errors.New("no events provided to subscribe to")
+	}
+	token, err := b.PlatformTokenProvider.GetTokenForPlatform(repo.VCS)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get platform token.")
+		return err
+	}
+	webhooksURL, err := b.webhooksURL(repo.URL)
+	if err != nil {
+		log.Debug().Err(err).Str("url", repo.URL).Msg("Failed to extract url parameters.")
+		return errors.New("failed to extract url parameters from git url")
+	}
+	if _, err := b.api.Do(ctx, "hook update", http.MethodPut, webhooksURL+"/"+url.PathEscape(repo.HookID), token, webhookPayload(repo), nil); err != nil {
+		log.Debug().Err(err).Msg("UpdateHook failed.")
+		return err
+	}
+	log.Debug().Msg("Successfully updated hook for bitbucket server!")
+	return nil
+}
+
+// DeleteHook deletes the hook of the repository.
+func (b *BitbucketServer) DeleteHook(ctx context.Context, repo *models.Repository) error {
+	log := b.Logger.With().Str("repo", repo.Name).Str("hook_id", repo.HookID).Logger()
+	if repo.HookID == "" {
+		return errors.New("repository has no hook id")
+	}
+	token, err := b.PlatformTokenProvider.GetTokenForPlatform(repo.VCS)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get platform token.")
+		return err
+	}
+	webhooksURL, err := b.webhooksURL(repo.URL)
+	if err != nil {
+		log.Debug().Err(err).Str("url", repo.URL).Msg("Failed to extract url parameters.")
+		return errors.New("failed to extract url parameters from git url")
+	}
+	code, err := b.api.Do(ctx, "hook deletion", http.MethodDelete, webhooksURL+"/"+url.PathEscape(repo.HookID), token, nil, nil)
+	if code == http.StatusNotFound {
+		log.Debug().Msg("Hook is already gone.")
+		return nil
+	}
+	if err != nil {
+		log.Debug().Err(err).Msg("DeleteHook failed.")
+		return err
+	}
+	log.Debug().Msg("Successfully deleted hook for bitbucket server!")
+	return nil
+}
+
+// webhookPayload returns the webhook of the repository.
+func webhookPayload(repo *models.Repository) webhookRequest {
+	return webhookRequest{
+		Name:   "krok",
+		URL:    repo.UniqueURL,
+		Active: true,
+		Events: repo.Events,
+		Configuration: map[string]string{
+			"secret": repo.Auth.Secret,
+		},
+	}
+}
+
+// webhooksURL returns the api url of the webhooks of a repository.
+func (b *BitbucketServer) webhooksURL(repoURL string) (string, error) {
+	baseURL, project, slug, err := parseRepoURL(repoURL)
+	if err != nil {
+		return "", err
+	}
+	if b.BaseURL != "" {
+		baseURL = b.BaseURL
+	}
+	return fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos/%s/webhooks", strings.TrimSuffix(baseURL, "/"), url.PathEscape(project), url.PathEscape(slug)), nil
+}
@@ -0,0 +1,208 @@
+package bitbucketserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+type mockAuthProvider struct {
+	providers.RepositoryAuth
+	getAuth *models.Auth
+}
+
+func (mp *mockAuthProvider) GetRepositoryAuth(ctx context.Context, id int) (*models.Auth, error) {
+	return mp.getAuth, nil
+}
+
+type mockPlatformTokenProvider struct {
+	providers.PlatformTokenProvider
+}
+
+func (mptp *mockPlatformTokenProvider) GetTokenForPlatform(vcs int) (string, error) {
+	return "token", nil
+}
+
+func newProvider(cfg Config) *BitbucketServer {
+	return NewBitbucketServerPlatformProvider(cfg, Dependencies{
+		Logger:                zerolog.New(os.Stderr),
+		PlatformTokenProvider: &mockPlatformTokenProvider{},
+		AuthProvider:          &mockAuthProvider{getAuth: &models.Auth{Secret: "secret"}},
+	})
+}
+
+func TestBitbucketServer_CreateHook(t *testing.T) {
+	var got webhookRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/bitbucket/rest/api/1.0/projects/KROK/repos/krok/webhooks", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": 1, "name": "krok"}`))
+	}))
+	defer ts.Close()
+
+	npp := newProvider(Config{})
+	err := npp.CreateHook(context.Background(), &models.Repository{
+		Name:      "test",
+		URL:       ts.URL + "/bitbucket/scm/KROK/krok.git",
+		VCS:       models.BITBUCKETSERVER,
+		Auth:      &models.Auth{Secret: "secret"},
+		UniqueURL: "https://krok.com/hooks/0/5/callback",
+		Events:    []string{"repo:refs_changed"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, webhookRequest{
+		Name:          "krok",
+		URL:           "https://krok.com/hooks/0/5/callback",
+		Active:        true,
+		Events:        []string{"repo:refs_changed"},
+		Configuration: map[string]string{"secret": "secret"},
+	}, got)
+}
+
+func TestBitbucketServer_CreateHook_Errors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	npp := newProvider(Config{BaseURL: ts.URL})
+	repo := &models.Repository{
+		Name:      "test",
+		URL:       "ssh://git@bitbucket.example.com:7999/krok/krok.git",
+		VCS:       models.BITBUCKETSERVER,
+		Auth:      &models.Auth{Secret: "secret"},
+		UniqueURL: "https://krok.com/hooks/0/5/callback",
+		Events:    []string{"repo:refs_changed"},
+	}
+	err := npp.CreateHook(context.Background(), repo)
+	assert.EqualError(t, err, "invalid status code 401 received from hook creation")
+
+	repo.URL = "https://bitbucket.example.com/krok"
+	err = npp.CreateHook(context.Background(), repo)
+	assert.EqualError(t, err, "failed to extract url parameters from git url")
+
+	repo.Auth = nil
+	err = npp.CreateHook(context.Background(), repo)
+	assert.EqualError(t, err, "no auth provided with the repository")
+}
+
+func TestParseRepoURL(t *testing.T) {
+	for _, tc := range []struct {
+		url, base, project, slug string
+	}{
+		{url: "https://bitbucket.example.com/scm/KROK/krok.git", base: "https://bitbucket.example.com", project: "KROK", slug: "krok"},
+		{url: "https://bitbucket.example.com/projects/KROK/repos/krok/browse", base: "https://bitbucket.example.com", project: "KROK", slug: "krok"},
+		{url: "ssh://git@bitbucket.example.com:7999/krok/krok.git", base: "https://bitbucket.example.com", project: "krok", slug: "krok"},
+	} {
+		t.Run(tc.url, func(t *testing.T) {
+			base, project, slug, err := parseRepoURL(tc.url)
+			require.NoError(t, err)
+			assert.Equal(t, tc.base, base)
+			assert.Equal(t, tc.project, project)
+			assert.Equal(t, tc.slug, slug)
+		})
+	}
+}
+
+func TestBitbucketServer_ValidateRequest(t *testing.T) {
+	payload := []byte(`{"eventKey": "repo:refs_changed"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	_, _ = mac.Write(payload)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	newRequest := func(signature string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/hooks/1/5/callback", bytes.NewReader(payload))
+		req.Header.Set("X-Event-Key", "repo:refs_changed")
+		req.Header.Set("X-Hub-Signature", signature)
+		return req
+	}
+
+	npp := newProvider(Config{})
+	err := npp.ValidateRequest(context.Background(), newRequest(signature), 1)
+	assert.NoError(t, err)
+
+	err = npp.ValidateRequest(context.Background(), newRequest("sha256=invalid"), 1)
+	assert.EqualError(t, err, "invalid signature")
+
+	err = npp.ValidateRequest(context.Background(), newRequest("x"), 1)
+	assert.EqualError(t, err, "missing or invalid X-Hub-Signature header")
+}
+
+func TestBitbucketServer_GetEventIDAndType(t *testing.T) {
+	npp := newProvider(Config{})
+	header := http.Header{}
+	header.Add("X-Request-Id", "ID")
+	header.Add("X-Event-Key", "repo:refs_changed")
+	req := &http.Request{Header: header}
+
+	id, err := npp.GetEventID(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "ID", id)
+	event, err := npp.GetEventType(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "repo:refs_changed", event)
+
+	_, err = npp.GetEventID(context.Background(), &http.Request{})
+	assert.EqualError(t, err, "event id not found for request")
+}
+
+func TestBitbucketServer_UpdateAndDeleteHook(t *testing.T) {
+	var (
+		method string
+		path   string
+		got    webhookRequest
+	)
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		if r.Method == http.MethodPut {
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"id": 1}`))
+	}))
+	defer ts.Close()
+
+	npp := newProvider(Config{})
+	repo := &models.Repository{
+		Name:      "test",
+		URL:       ts.URL + "/scm/KROK/krok.git",
+		VCS:       models.BITBUCKETSERVER,
+		Auth:      &models.Auth{Secret: "new-secret"},
+		UniqueURL: "https://krok.com/hooks/0/5/callback",
+		Events:    []string{"repo:refs_changed", "pr:opened"},
+		HookID:    "1",
+	}
+	err := npp.UpdateHook(context.Background(), repo)
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/rest/api/1.0/projects/KROK/repos/krok/webhooks/1", path)
+	assert.Equal(t, []string{"repo:refs_changed", "pr:opened"}, got.Events)
+	assert.Equal(t, "new-secret", got.Configuration["secret"])
+
+	status = http.StatusNoContent
+	err = npp.DeleteHook(context.Background(), repo)
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodDelete, method)
+
+	status = http.StatusNotFound
+	err = npp.DeleteHook(context.Background(), repo)
+	assert.NoError(t, err)
+}
@@ -12,4 +12,10 @@ type CommandRunStorer interface {
 	CreateRun(ctx context.Context, run *models.CommandRun) (*models.CommandRun, error)
 	UpdateRunStatus(ctx context.Context, id int, status string, outcome string) error
 	Get(ctx context.Context, id int) (*models.CommandRun, error)
+	// UpdateRunContainer saves the ID of the container which executes the command run.
+	UpdateRunContainer(ctx context.Context, id int, containerID string) error
+	// UpdateRunSignal saves the signal which stopped a cancelled or timed out command run.
+	UpdateRunSignal(ctx context.Context, id int, signal string) error
+	// ListRunsWithStatus returns all command runs which are in any of the given statuses.
+	ListRunsWithStatus(ctx context.Context, statuses ...string) ([]*models.CommandRun, error)
 }
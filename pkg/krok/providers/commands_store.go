@@ -23,6 +23,9 @@ type CommandStorer interface {
 	AddCommandRelForRepository(ctx context.Context, commandID int, repositoryID int) error
 	// RemoveCommandRelForRepository remove a relation to a repository for a command.
 	RemoveCommandRelForRepository(ctx context.Context, commandID int, repositoryID int) error
+	// UpdateCommandRelForRepository sets the filter which restricts the events of the repository
+	// for which the command runs. A nil filter removes all restrictions.
+	UpdateCommandRelForRepository(ctx context.Context, commandID int, repositoryID int, filter *models.CommandFilter) error
 
 	// Settings
 
@@ -44,4 +47,14 @@ type CommandStorer interface {
 	RemoveCommandRelForPlatform(ctx context.Context, commandID int, platformID int) error
 	// IsPlatformSupported returns if a command supports a platform or not.
 	IsPlatformSupported(ctx context.Context, commandID, platformID int) (bool, error)
+
+	// Dependency manager
+
+	// AddCommandDependency defines that a command depends on another command. A command
+	// only runs once all of its dependencies in the same run succeeded.
+	AddCommandDependency(ctx context.Context, commandID int, dependsOnID int) error
+	// RemoveCommandDependency removes a dependency of a command.
+	RemoveCommandDependency(ctx context.Context, commandID int, dependsOnID int) error
+	// GetCommandDependencies returns the IDs of the commands the given command depends on.
+	GetCommandDependencies(ctx context.Context, commandID int) ([]int, error)
 }
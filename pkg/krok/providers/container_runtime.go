@@ -0,0 +1,70 @@
+package providers
+
+import (
+	"context"
+	"io"
+
+	"github.com/krok-o/krok/pkg/models"
+)
+
+const (
+	// ContainerStateCreated is the state of a container which has been created but not yet started.
+	ContainerStateCreated = "created"
+	// ContainerStateRunning is the state of a container which is currently running.
+	ContainerStateRunning = "running"
+	// ContainerStateExited is the state of a container which has stopped.
+	ContainerStateExited = "exited"
+)
+
+// ContainerMount is a location on the host which is mounted into a container.
+type ContainerMount struct {
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+// ContainerLimits restricts the resources a container can use. A limit of 0 means no limit.
+type ContainerLimits struct {
+	// CPUs is the number of CPUs. Fractions are allowed.
+	CPUs float64
+	// Memory is the maximum memory in bytes.
+	Memory int64
+	// Pids is the maximum number of processes.
+	Pids int64
+	// Disk is the maximum disk space in bytes the container can write.
+	Disk int64
+}
+
+// ContainerConfig defines the container of a command.
+type ContainerConfig struct {
+	Image  string
+	Args   []string
+	Env    []string
+	Mounts []ContainerMount
+	Limits ContainerLimits
+}
+
+// ContainerRuntime runs the containers of commands. The executors use it instead of
+// talking to a specific container engine.
+type ContainerRuntime interface {
+	// Pull makes sure that the image is available to create containers from. If credential isn't nil,
+	// it's used to log into the registry of the image.
+	Pull(ctx context.Context, image string, credential *models.RegistryCredential) error
+	// HasImage returns if the image is available without pulling it.
+	HasImage(ctx context.Context, image string) (bool, error)
+	// Create creates a container and returns its ID. The container is not started.
+	Create(ctx context.Context, cfg ContainerConfig) (string, error)
+	// Start starts a created container.
+	Start(ctx context.Context, id string) error
+	// Wait blocks until the container stopped and returns its exit code.
+	Wait(ctx context.Context, id string) (int, error)
+	// Logs returns the combined output of a container. If follow is set, the reader returns
+	// the output as it's written until the container stops.
+	Logs(ctx context.Context, id string, follow bool) (io.ReadCloser, error)
+	// Kill sends a signal, i.e.: SIGKILL, to a running container.
+	Kill(ctx context.Context, id string, signal string) error
+	// Remove removes a container regardless of its state.
+	Remove(ctx context.Context, id string) error
+	// State returns the ContainerState of a container. Returns errors.ErrNotFound if it doesn't exist.
+	State(ctx context.Context, id string) (string, error)
+}
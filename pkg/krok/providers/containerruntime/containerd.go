@@ -0,0 +1,350 @@
+package containerruntime
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/oci"
+	"github.com/containerd/containerd/remotes/docker"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+const (
+	// DefaultContainerdAddress is the default address of the containerd socket.
+	DefaultContainerdAddress = "/run/containerd/containerd.sock"
+	// DefaultContainerdNamespace is the containerd namespace in which the containers of krok are created.
+	DefaultContainerdNamespace = "krok"
+)
+
+// ContainerdConfig has the configuration options for the containerd runtime.
+type ContainerdConfig struct {
+	// Address is the address of the containerd socket.
+	Address string
+	// Namespace is the containerd namespace of the containers.
+	Namespace string
+	// LogLocation is the folder in which the output of the containers is saved.
+	LogLocation string
+}
+
+// Containerd is a container runtime which talks to containerd directly. This also works
+// for hosts which don't have a Docker daemon, like Kubernetes nodes.
+type Containerd struct {
+	ContainerdConfig
+	Dependencies
+
+	client *containerd.Client
+	// pollInterval is how often a followed log is checked for new output.
+	pollInterval time.Duration
+}
+
+var _ providers.ContainerRuntime = &Containerd{}
+
+// NewContainerdRuntime creates a new container runtime for containerd.
+func NewContainerdRuntime(cfg ContainerdConfig, deps Dependencies) (*Containerd, error) {
+	if cfg.Address == "" {
+		cfg.Address = DefaultContainerdAddress
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = DefaultContainerdNamespace
+	}
+	if err := os.MkdirAll(cfg.LogLocation, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create log location: %w", err)
+	}
+	client, err := containerd.New(cfg.Address, containerd.WithDefaultNamespace(cfg.Namespace))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create containerd client: %w", err)
+	}
+	return &Containerd{
+		ContainerdConfig: cfg,
+		Dependencies:     deps,
+		client:           client,
+		pollInterval:     100 * time.Millisecond,
+	}, nil
+}
+
+// Pull pulls and unpacks the image of a command.
+func (c *Containerd) Pull(ctx context.Context, image string, credential *models.RegistryCredential) error {
+	opts := []containerd.RemoteOpt{containerd.WithPullUnpack}
+	if credential != nil {
+		authorizer := docker.NewDockerAuthorizer(docker.WithAuthCreds(func(string) (string, string, error) {
+			return credential.Username, credential.Password, nil
+		}))
+		opts = append(opts, containerd.WithResolver(docker.NewResolver(docker.ResolverOptions{
+			Hosts: docker.ConfigureDefaultRegistries(docker.WithAuthorizer(authorizer)),
+		})))
+	}
+	_, err := c.client.Pull(ctx, image, opts...)
+	return err
+}
+
+// HasImage returns if the image is in the namespace of Krok.
+func (c *Containerd) HasImage(ctx context.Context, image string) (bool, error) {
+	if _, err := c.client.GetImage(ctx, image); errdefs.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Create creates the container of a command. The arguments replace the command of the image.
+func (c *Containerd) Create(ctx context.Context, cfg providers.ContainerConfig) (string, error) {
+	image, err := c.client.GetImage(ctx, cfg.Image)
+	if err != nil {
+		return "", fmt.Errorf("failed to get image: %w", err)
+	}
+	id, err := newContainerID()
+	if err != nil {
+		return "", err
+	}
+	mounts := make([]specs.Mount, 0, len(cfg.Mounts))
+	for _, m := range cfg.Mounts {
+		mode := "rw"
+		if m.ReadOnly {
+			mode = "ro"
+		}
+		mounts = append(mounts, specs.Mount{
+			Type:        "bind",
+			Source:      m.Source,
+			Destination: m.Target,
+			Options:     []string{"rbind", mode},
+		})
+	}
+	if cfg.Limits.Disk > 0 {
+		c.Logger.Warn().Str("image", cfg.Image).Msg("The disk limit is not supported by containerd and is ignored.")
+	}
+	if _, err := c.client.NewContainer(ctx, id,
+		containerd.WithImage(image),
+		containerd.WithNewSnapshot(id, image),
+		containerd.WithNewSpec(
+			oci.WithImageConfigArgs(image, cfg.Args),
+			oci.WithEnv(cfg.Env),
+			oci.WithMounts(mounts),
+			withLimits(cfg.Limits),
+		),
+	); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Start creates the task of a container, which writes its output into a log file, and starts it.
+func (c *Containerd) Start(ctx context.Context, id string) error {
+	container, err := c.client.LoadContainer(ctx, id)
+	if err != nil {
+		return c.notFound(id, err)
+	}
+	task, err := container.NewTask(ctx, cio.LogFile(c.logFile(id)))
+	if err != nil {
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+	return task.Start(ctx)
+}
+
+// Wait waits for the task of a container to exit.
+func (c *Containerd) Wait(ctx context.Context, id string) (int, error) {
+	task, err := c.task(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	exit, err := task.Wait(ctx)
+	if err != nil {
+		return 0, err
+	}
+	select {
+	case status := <-exit:
+		code, _, err := status.Result()
+		return int(code), err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// Logs returns the log file of a container.
+func (c *Containerd) Logs(ctx context.Context, id string, follow bool) (io.ReadCloser, error) {
+	f, err := os.Open(c.logFile(id))
+	if err != nil {
+		return nil, err
+	}
+	if !follow {
+		return f, nil
+	}
+	return &followReader{ctx: ctx, file: f, interval: c.pollInterval, running: func() bool {
+		state, err := c.State(ctx, id)
+		return err == nil && state != providers.ContainerStateExited
+	}}, nil
+}
+
+// Kill sends a signal to the task of a container.
+func (c *Containerd) Kill(ctx context.Context, id string, signal string) error {
+	sig, err := containerd.ParseSignal(signal)
+	if err != nil {
+		return err
+	}
+	task, err := c.task(ctx, id)
+	if err != nil {
+		return err
+	}
+	return task.Kill(ctx, sig)
+}
+
+// Remove kills the task of a container and deletes it together with its snapshot and log.
+func (c *Containerd) Remove(ctx context.Context, id string) error {
+	container, err := c.client.LoadContainer(ctx, id)
+	if err != nil {
+		return c.notFound(id, err)
+	}
+	if task, err := container.Task(ctx, nil); err == nil {
+		if _, err := task.Delete(ctx, containerd.WithProcessKill); err != nil && !errdefs.IsNotFound(err) {
+			return fmt.Errorf("failed to delete task: %w", err)
+		}
+	} else if !errdefs.IsNotFound(err) {
+		return err
+	}
+	if err := container.Delete(ctx, containerd.WithSnapshotCleanup); err != nil {
+		return err
+	}
+	if err := os.Remove(c.logFile(id)); err != nil && !os.IsNotExist(err) {
+		c.Logger.Debug().Err(err).Str("container_id", id).Msg("Failed to remove log of container.")
+	}
+	return nil
+}
+
+// State returns the state of a container based on its task. A container without a task has not been started yet.
+func (c *Containerd) State(ctx context.Context, id string) (string, error) {
+	container, err := c.client.LoadContainer(ctx, id)
+	if err != nil {
+		return "", c.notFound(id, err)
+	}
+	task, err := container.Task(ctx, nil)
+	if errdefs.IsNotFound(err) {
+		return providers.ContainerStateCreated, nil
+	} else if err != nil {
+		return "", err
+	}
+	status, err := task.Status(ctx)
+	if err != nil {
+		return "", err
+	}
+	switch status.Status {
+	case containerd.Created:
+		return providers.ContainerStateCreated, nil
+	case containerd.Stopped:
+		return providers.ContainerStateExited, nil
+	default:
+		return providers.ContainerStateRunning, nil
+	}
+}
+
+// task loads the task of a container.
+func (c *Containerd) task(ctx context.Context, id string) (containerd.Task, error) {
+	container, err := c.client.LoadContainer(ctx, id)
+	if err != nil {
+		return nil, c.notFound(id, err)
+	}
+	task, err := container.Task(ctx, nil)
+	if err != nil {
+		return nil, c.notFound(id, err)
+	}
+	return task, nil
+}
+
+// notFound translates the not found errors of containerd.
+func (c *Containerd) notFound(id string, err error) error {
+	if errdefs.IsNotFound(err) {
+		return fmt.Errorf("container %s: %w", id, kerr.ErrNotFound)
+	}
+	return err
+}
+
+// logFile returns the location of the log of a container.
+func (c *Containerd) logFile(id string) string {
+	return filepath.Join(c.LogLocation, id+".log")
+}
+
+// cpuPeriod is the CFS period used to limit the CPUs of a container, the same one Docker uses.
+const cpuPeriod = 100000
+
+// withLimits sets the cgroup limits of a container.
+func withLimits(limits providers.ContainerLimits) oci.SpecOpts {
+	return func(ctx context.Context, client oci.Client, c *containers.Container, s *oci.Spec) error {
+		if limits.CPUs <= 0 && limits.Memory <= 0 && limits.Pids <= 0 {
+			return nil
+		}
+		if s.Linux == nil {
+			s.Linux = &specs.Linux{}
+		}
+		if s.Linux.Resources == nil {
+			s.Linux.Resources = &specs.LinuxResources{}
+		}
+		r := s.Linux.Resources
+		if limits.CPUs > 0 {
+			quota := int64(limits.CPUs * cpuPeriod)
+			period := uint64(cpuPeriod)
+			r.CPU = &specs.LinuxCPU{Quota: &quota, Period: &period}
+		}
+		if limits.Memory > 0 {
+			r.Memory = &specs.LinuxMemory{Limit: &limits.Memory}
+		}
+		if limits.Pids > 0 {
+			r.Pids = &specs.LinuxPids{Limit: limits.Pids}
+		}
+		return nil
+	}
+}
+
+// newContainerID generates a random ID in the same format as Docker's.
+func newContainerID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate container id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// followReader reads a log file which is still being written to, until the container stopped.
+type followReader struct {
+	ctx      context.Context
+	file     *os.File
+	interval time.Duration
+	running  func() bool
+}
+
+// Read returns the output which has been written so far and waits for more if there is none.
+func (f *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := f.file.Read(p)
+		if n > 0 || !errors.Is(err, io.EOF) {
+			return n, err
+		}
+		if !f.running() {
+			// Read what was written between the last read and the exit.
+			return f.file.Read(p)
+		}
+		select {
+		case <-f.ctx.Done():
+			return 0, f.ctx.Err()
+		case <-time.After(f.interval):
+		}
+	}
+}
+
+// Close closes the log file.
+func (f *followReader) Close() error {
+	return f.file.Close()
+}
@@ -0,0 +1,68 @@
+package containerruntime
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/oci"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/krok-o/krok/pkg/krok/providers"
+)
+
+func TestFollowReader(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "container.log")
+	w, err := os.Create(location)
+	require.NoError(t, err)
+	defer w.Close()
+	_, err = w.WriteString("first\n")
+	require.NoError(t, err)
+
+	r, err := os.Open(location)
+	require.NoError(t, err)
+	var running int32 = 1
+	follow := &followReader{
+		ctx:      context.Background(),
+		file:     r,
+		interval: time.Millisecond,
+		running:  func() bool { return atomic.LoadInt32(&running) == 1 },
+	}
+	defer follow.Close()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.WriteString("second\n")
+		atomic.StoreInt32(&running, 0)
+	}()
+	content, err := ioutil.ReadAll(follow)
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n", string(content))
+}
+
+func TestWithLimits(t *testing.T) {
+	spec := &oci.Spec{}
+	err := withLimits(providers.ContainerLimits{
+		CPUs:   0.5,
+		Memory: 1024,
+		Pids:   10,
+	})(context.Background(), nil, nil, spec)
+	require.NoError(t, err)
+	require.NotNil(t, spec.Linux)
+	resources := spec.Linux.Resources
+	assert.Equal(t, int64(50000), *resources.CPU.Quota)
+	assert.Equal(t, uint64(100000), *resources.CPU.Period)
+	assert.Equal(t, int64(1024), *resources.Memory.Limit)
+	assert.Equal(t, int64(10), resources.Pids.Limit)
+
+	// Without limits, the spec is left alone.
+	spec = &oci.Spec{}
+	err = withLimits(providers.ContainerLimits{})(context.Background(), nil, nil, spec)
+	require.NoError(t, err)
+	assert.Nil(t, spec.Linux)
+}
@@ -0,0 +1,188 @@
+package containerruntime
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/rs/zerolog"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// Dependencies defines the dependencies of the container runtimes.
+type Dependencies struct {
+	Logger zerolog.Logger
+}
+
+// Docker is a container runtime which uses a Docker daemon. The daemon is configured
+// through the usual DOCKER_HOST, DOCKER_API_VERSION, DOCKER_CERT_PATH and DOCKER_TLS_VERIFY variables.
+type Docker struct {
+	Dependencies
+
+	cli *client.Client
+}
+
+var _ providers.ContainerRuntime = &Docker{}
+
+// NewDockerRuntime creates a new container runtime for Docker.
+func NewDockerRuntime(deps Dependencies) (*Docker, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker client: %w", err)
+	}
+	return &Docker{Dependencies: deps, cli: cli}, nil
+}
+
+// Pull pulls the image of a command.
+func (d *Docker) Pull(ctx context.Context, image string, credential *models.RegistryCredential) error {
+	var opts types.ImagePullOptions
+	if credential != nil {
+		auth, err := json.Marshal(types.AuthConfig{
+			Username:      credential.Username,
+			Password:      credential.Password,
+			ServerAddress: credential.Host,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to encode registry credential: %w", err)
+		}
+		opts.RegistryAuth = base64.URLEncoding.EncodeToString(auth)
+	}
+	output, err := d.cli.ImagePull(ctx, image, opts)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+	_, err = io.Copy(os.Stdout, output)
+	return err
+}
+
+// HasImage returns if the image is in the image store of the daemon.
+func (d *Docker) HasImage(ctx context.Context, image string) (bool, error) {
+	if _, _, err := d.cli.ImageInspectWithRaw(ctx, image); client.IsErrNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Create creates the container of a command. The disk limit requires a storage driver
+// which supports the size option, like overlay2 on xfs with pquota.
+func (d *Docker) Create(ctx context.Context, cfg providers.ContainerConfig) (string, error) {
+	hostConfig := &container.HostConfig{
+		Resources: container.Resources{
+			NanoCPUs: int64(cfg.Limits.CPUs * 1e9),
+			Memory:   cfg.Limits.Memory,
+		},
+	}
+	for _, m := range cfg.Mounts {
+		hostConfig.Mounts = append(hostConfig.Mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   m.Source,
+			Target:   m.Target,
+			ReadOnly: m.ReadOnly,
+		})
+	}
+	if cfg.Limits.Pids > 0 {
+		hostConfig.PidsLimit = &cfg.Limits.Pids
+	}
+	if cfg.Limits.Disk > 0 {
+		hostConfig.StorageOpt = map[string]string{"size": strconv.FormatInt(cfg.Limits.Disk, 10)}
+	}
+	cont, err := d.cli.ContainerCreate(ctx, &container.Config{
+		AttachStdout: true,
+		AttachStderr: true,
+		Image:        cfg.Image,
+		Cmd:          cfg.Args,
+		Env:          cfg.Env,
+	}, hostConfig, nil, nil, "")
+	if err != nil {
+		d.Logger.Debug().Err(err).Strs("warnings", cont.Warnings).Msg("Failed to create container.")
+		return "", err
+	}
+	return cont.ID, nil
+}
+
+// Start starts a created container.
+func (d *Docker) Start(ctx context.Context, id string) error {
+	return d.cli.ContainerStart(ctx, id, types.ContainerStartOptions{})
+}
+
+// Wait waits for a container to stop.
+func (d *Docker) Wait(ctx context.Context, id string) (int, error) {
+	exit, errs := d.cli.ContainerWait(ctx, id, container.WaitConditionNotRunning)
+	select {
+	case err := <-errs:
+		return 0, err
+	case e := <-exit:
+		if e.Error != nil {
+			return int(e.StatusCode), errors.New(e.Error.Message)
+		}
+		return int(e.StatusCode), nil
+	}
+}
+
+// Logs returns the de-multiplexed stdout and stderr of a container.
+func (d *Docker) Logs(ctx context.Context, id string, follow bool) (io.ReadCloser, error) {
+	logs, err := d.cli.ContainerLogs(ctx, id, types.ContainerLogsOptions{
+		ShowStderr: true,
+		ShowStdout: true,
+		Follow:     follow,
+	})
+	if err != nil {
+		return nil, err
+	}
+	r, w := io.Pipe()
+	go func() {
+		defer logs.Close()
+		_, err := stdcopy.StdCopy(w, w, logs)
+		_ = w.CloseWithError(err)
+	}()
+	return r, nil
+}
+
+// Kill sends a signal to a container.
+func (d *Docker) Kill(ctx context.Context, id string, signal string) error {
+	return d.cli.ContainerKill(ctx, id, signal)
+}
+
+// Remove forcefully removes a container.
+func (d *Docker) Remove(ctx context.Context, id string) error {
+	return d.cli.ContainerRemove(ctx, id, types.ContainerRemoveOptions{
+		Force: true,
+	})
+}
+
+// State returns the state of a container.
+func (d *Docker) State(ctx context.Context, id string) (string, error) {
+	inspect, err := d.cli.ContainerInspect(ctx, id)
+	if client.IsErrNotFound(err) {
+		return "", fmt.Errorf("container %s: %w", id, kerr.ErrNotFound)
+	} else if err != nil {
+		return "", err
+	}
+	if inspect.State == nil {
+		return "", fmt.Errorf("container %s has no state", id)
+	}
+	switch inspect.State.Status {
+	case "created":
+		return providers.ContainerStateCreated, nil
+	case "running", "paused", "restarting":
+		return providers.ContainerStateRunning, nil
+	default:
+		return providers.ContainerStateExited, nil
+	}
+}
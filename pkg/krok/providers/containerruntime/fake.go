@@ -0,0 +1,285 @@
+package containerruntime
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// FakeImage defines how the containers of an image behave in the Fake runtime.
+type FakeImage struct {
+	// ExitCode is the exit code of the containers.
+	ExitCode int
+	// Output is what the containers log.
+	Output string
+	// Blocks keeps the containers running until they are killed.
+	Blocks bool
+	// TrapsSignals keeps blocking containers running on every signal except SIGKILL.
+	TrapsSignals bool
+	// PullError is returned when the image is pulled.
+	PullError error
+	// Present makes the image available without pulling it.
+	Present bool
+}
+
+// fakeContainer is a container of the Fake runtime.
+type fakeContainer struct {
+	image    FakeImage
+	state    string
+	exitCode int
+	exited   chan struct{}
+}
+
+// Fake is an in-memory container runtime which simulates containers based on their image.
+// It's meant to be used in tests.
+type Fake struct {
+	images     map[string]FakeImage
+	lock       sync.Mutex
+	containers map[string]*fakeContainer
+	created    []providers.ContainerConfig
+	// signals are kept after a container is removed.
+	signals map[string][]string
+	// pulls are the credentials every pull of an image was made with.
+	pulls  map[string][]*models.RegistryCredential
+	nextID int
+}
+
+var _ providers.ContainerRuntime = &Fake{}
+
+// NewFakeRuntime creates a fake runtime which knows the given images.
+func NewFakeRuntime(images map[string]FakeImage) *Fake {
+	return &Fake{
+		images:     images,
+		containers: make(map[string]*fakeContainer),
+		signals:    make(map[string][]string),
+		pulls:      make(map[string][]*models.RegistryCredential),
+	}
+}
+
+// Pull fails for unknown images.
+func (f *Fake) Pull(ctx context.Context, image string, credential *models.RegistryCredential) error {
+	img, ok := f.images[image]
+	if !ok {
+		return fmt.Errorf("image %s not found", image)
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.pulls[image] = append(f.pulls[image], credential)
+	return img.PullError
+}
+
+// HasImage returns if a known image is present or has been pulled successfully.
+func (f *Fake) HasImage(ctx context.Context, image string) (bool, error) {
+	img, ok := f.images[image]
+	if !ok {
+		return false, nil
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return img.Present || (len(f.pulls[image]) > 0 && img.PullError == nil), nil
+}
+
+// Create creates a container for a known image.
+func (f *Fake) Create(ctx context.Context, cfg providers.ContainerConfig) (string, error) {
+	img, ok := f.images[cfg.Image]
+	if !ok {
+		return "", fmt.Errorf("image %s not found", cfg.Image)
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.created = append(f.created, cfg)
+	return f.add(img, providers.ContainerStateCreated), nil
+}
+
+// Add adds a container in the given state, as if it had been created before. Exited containers
+// exit with the exit code of their image.
+func (f *Fake) Add(image string, state string) string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	img := f.images[image]
+	id := f.add(img, state)
+	if state == providers.ContainerStateExited {
+		f.containers[id].exitCode = img.ExitCode
+		close(f.containers[id].exited)
+	}
+	return id
+}
+
+// add adds a container of an image. The lock must be held.
+func (f *Fake) add(img FakeImage, state string) string {
+	f.nextID++
+	id := "container-" + strconv.Itoa(f.nextID)
+	f.containers[id] = &fakeContainer{
+		image:  img,
+		state:  state,
+		exited: make(chan struct{}),
+	}
+	return id
+}
+
+// Start starts a container. Unless the image blocks, it exits right away.
+func (f *Fake) Start(ctx context.Context, id string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	c, err := f.container(id)
+	if err != nil {
+		return err
+	}
+	if c.state != providers.ContainerStateCreated {
+		return fmt.Errorf("container %s is %s", id, c.state)
+	}
+	c.state = providers.ContainerStateRunning
+	if !c.image.Blocks {
+		f.exit(c, c.image.ExitCode)
+	}
+	return nil
+}
+
+// Wait waits until a container exits.
+func (f *Fake) Wait(ctx context.Context, id string) (int, error) {
+	f.lock.Lock()
+	c, err := f.container(id)
+	f.lock.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	select {
+	case <-c.exited:
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		return c.exitCode, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// Logs returns the output of the image of a container. A followed log ends when the container exits.
+func (f *Fake) Logs(ctx context.Context, id string, follow bool) (io.ReadCloser, error) {
+	f.lock.Lock()
+	c, err := f.container(id)
+	f.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if !follow {
+		return ioutil.NopCloser(strings.NewReader(c.image.Output)), nil
+	}
+	r, w := io.Pipe()
+	go func() {
+		if _, err := io.WriteString(w, c.image.Output); err != nil {
+			return
+		}
+		select {
+		case <-c.exited:
+			_ = w.Close()
+		case <-ctx.Done():
+			_ = w.CloseWithError(ctx.Err())
+		}
+	}()
+	return r, nil
+}
+
+// Kill stops a running container. It exits with 143 for SIGTERM and with 137 for every other signal.
+func (f *Fake) Kill(ctx context.Context, id string, signal string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	c, err := f.container(id)
+	if err != nil {
+		return err
+	}
+	if c.state != providers.ContainerStateRunning {
+		return fmt.Errorf("container %s is not running", id)
+	}
+	f.signals[id] = append(f.signals[id], signal)
+	if c.image.TrapsSignals && signal != "SIGKILL" {
+		return nil
+	}
+	code := 137
+	if signal == "SIGTERM" {
+		code = 143
+	}
+	f.exit(c, code)
+	return nil
+}
+
+// Remove removes a container, stopping it if it's running.
+func (f *Fake) Remove(ctx context.Context, id string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	c, err := f.container(id)
+	if err != nil {
+		return err
+	}
+	if c.state == providers.ContainerStateRunning {
+		f.exit(c, 137)
+	}
+	delete(f.containers, id)
+	return nil
+}
+
+// State returns the state of a container.
+func (f *Fake) State(ctx context.Context, id string) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	c, err := f.container(id)
+	if err != nil {
+		return "", err
+	}
+	return c.state, nil
+}
+
+// Created returns the configuration of all the containers created so far.
+func (f *Fake) Created() []providers.ContainerConfig {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]providers.ContainerConfig(nil), f.created...)
+}
+
+// Pulls returns the credentials every pull of an image was made with.
+func (f *Fake) Pulls(image string) []*models.RegistryCredential {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]*models.RegistryCredential(nil), f.pulls[image]...)
+}
+
+// Signals returns the signals a container received.
+func (f *Fake) Signals(id string) []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]string(nil), f.signals[id]...)
+}
+
+// Containers returns the IDs of the containers which have not been removed.
+func (f *Fake) Containers() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var ids []string
+	for id := range f.containers {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// container returns a container. The lock must be held.
+func (f *Fake) container(id string) (*fakeContainer, error) {
+	c, ok := f.containers[id]
+	if !ok {
+		return nil, fmt.Errorf("container %s: %w", id, kerr.ErrNotFound)
+	}
+	return c, nil
+}
+
+// exit stops a container with an exit code. The lock must be held.
+func (f *Fake) exit(c *fakeContainer, code int) {
+	c.state = providers.ContainerStateExited
+	c.exitCode = code
+	close(c.exited)
+}
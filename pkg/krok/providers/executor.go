@@ -17,4 +17,9 @@ type Executor interface {
 	// CancelRun will cancel a run and mark all commands as cancelled.
 	// The ID here is the ID of the event corresponding to this run.
 	CancelRun(ctx context.Context, id int) error
+	// CancelCommandRun will cancel a single command run and mark it as cancelled.
+	// The commands which depend on it are skipped.
+	CancelCommandRun(ctx context.Context, id int) error
+	// Queue returns the command runs which wait for a free slot, in the order they are expected to start.
+	Queue(ctx context.Context) ([]*models.QueuedRun, error)
 }
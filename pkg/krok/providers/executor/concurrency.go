@@ -0,0 +1,111 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/krok-o/krok/pkg/krok/payload"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// concurrencyGroups keeps the runs of the commands which are in the same concurrency group in the order of
+// their events, so only one of them runs at a time.
+type concurrencyGroups struct {
+	mu     sync.Mutex
+	groups map[string][]*groupMember
+}
+
+// groupMember is a command run in a concurrency group.
+type groupMember struct {
+	key string
+	// cancel stops the run if a newer run of the group cancels the runs in progress.
+	cancel context.CancelFunc
+	// older are the runs of the group which were there before this one.
+	older []*groupMember
+	// done is closed once the run left the group.
+	done chan struct{}
+}
+
+func newConcurrencyGroups() *concurrencyGroups {
+	return &concurrencyGroups{
+		groups: make(map[string][]*groupMember),
+	}
+}
+
+// concurrencyKey returns the concurrency group of a command for an event. The group only contains the runs of
+// the same command and repository. It returns an empty key if the runs of the command aren't grouped or the
+// event doesn't have a value for the group.
+func concurrencyKey(event *models.Event, command *models.Command) string {
+	if command.Concurrency == nil {
+		return ""
+	}
+	value, ok := payload.ConcurrencyGroup(command.Concurrency.Group, []byte(event.Payload))
+	if !ok || value == "" {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d/%s=%s", event.RepositoryID, command.ID, command.Concurrency.Group, value)
+}
+
+// join adds the run of a command to its concurrency group. If the command cancels the runs in progress, the
+// older runs of the group are cancelled. The run still waits for them to stop before it starts, so its group
+// never runs twice at the same time. It returns nil if the run isn't part of a group.
+func (g *concurrencyGroups) join(event *models.Event, command *models.Command, cancel context.CancelFunc) *groupMember {
+	key := concurrencyKey(event, command)
+	if key == "" {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	member := &groupMember{
+		key:    key,
+		cancel: cancel,
+		older:  append([]*groupMember(nil), g.groups[key]...),
+		done:   make(chan struct{}),
+	}
+	if command.Concurrency.CancelInProgress {
+		for _, older := range member.older {
+			older.cancel()
+		}
+	}
+	g.groups[key] = append(g.groups[key], member)
+	return member
+}
+
+// leave removes a run from its concurrency group, so the newer runs can start.
+func (g *concurrencyGroups) leave(member *groupMember) {
+	if member == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	members := g.groups[member.key]
+	for i, m := range members {
+		if m == member {
+			members = append(members[:i], members[i+1:]...)
+			break
+		}
+	}
+	if len(members) == 0 {
+		delete(g.groups, member.key)
+	} else {
+		g.groups[member.key] = members
+	}
+	close(member.done)
+}
+
+// waitForOlder waits until the older runs of the group left it. It returns the error of ctx if the run
+// got cancelled in the meantime.
+func (m *groupMember) waitForOlder(ctx context.Context) error {
+	if m == nil {
+		return nil
+	}
+	for _, older := range m.older {
+		select {
+		case <-older.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/krok-o/krok/pkg/krok/providers/containerruntime"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+func TestConcurrencyKey(t *testing.T) {
+	pr := &models.Event{RepositoryID: 1, Payload: `{"pull_request":{"number":5,"base":{"ref":"main"}}}`}
+	push := &models.Event{RepositoryID: 1, Payload: `{"ref":"refs/heads/main"}`}
+	byPullRequest := &models.Command{ID: 2, Concurrency: &models.ConcurrencyPolicy{Group: models.ConcurrencyGroupPullRequest}}
+	byBranch := &models.Command{ID: 2, Concurrency: &models.ConcurrencyPolicy{Group: models.ConcurrencyGroupBranch}}
+	assert.Equal(t, "1/2/pull_request=5", concurrencyKey(pr, byPullRequest))
+	assert.Empty(t, concurrencyKey(push, byPullRequest))
+	assert.Equal(t, "1/2/branch=main", concurrencyKey(push, byBranch))
+	assert.Equal(t, "1/2/pull_request.base.ref=main", concurrencyKey(pr, &models.Command{ID: 2, Concurrency: &models.ConcurrencyPolicy{Group: "pull_request.base.ref"}}))
+	assert.Empty(t, concurrencyKey(pr, &models.Command{ID: 2}))
+}
+
+func TestConcurrencyGroups(t *testing.T) {
+	g := newConcurrencyGroups()
+	event := &models.Event{RepositoryID: 1, Payload: `{"ref":"refs/heads/main"}`}
+	queue := &models.Command{ID: 1, Concurrency: &models.ConcurrencyPolicy{Group: models.ConcurrencyGroupBranch}}
+	first := g.join(event, queue, func() { t.Error("the first run must not be cancelled") })
+	require.NotNil(t, first)
+	assert.NoError(t, first.waitForOlder(context.Background()))
+	second := g.join(event, queue, func() {})
+	require.Len(t, second.older, 1)
+
+	// The second run waits for the first one.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, second.waitForOlder(ctx), context.DeadlineExceeded)
+	g.leave(first)
+	assert.NoError(t, second.waitForOlder(context.Background()))
+
+	// A command which cancels the runs in progress cancels the older runs.
+	cancelled := false
+	third := g.join(event, &models.Command{ID: 1, Concurrency: &models.ConcurrencyPolicy{Group: models.ConcurrencyGroupBranch, CancelInProgress: true}}, func() {})
+	assert.Len(t, third.older, 1)
+	second.cancel = func() { cancelled = true }
+	fourth := g.join(event, &models.Command{ID: 1, Concurrency: &models.ConcurrencyPolicy{Group: models.ConcurrencyGroupBranch, CancelInProgress: true}}, func() {})
+	assert.Len(t, fourth.older, 2)
+	assert.True(t, cancelled)
+
+	g.leave(second)
+	g.leave(third)
+	g.leave(fourth)
+	assert.Empty(t, g.groups)
+	assert.Nil(t, g.join(event, &models.Command{ID: 1}, func() {}))
+}
+
+func TestInMemoryExecutor_CreateRun_CancelsSupersededRun(t *testing.T) {
+	ime, mcr, fake := newFakeRun(t, Config{
+		DefaultMaximumCommandRuntime: 10,
+		MaximumParallelCommands:      10,
+	}, containerruntime.FakeImage{Blocks: true})
+	mcr.ExpectedCalls = nil
+	mcr.On("CreateRun", mock.Anything, mock.MatchedBy(func(run *models.CommandRun) bool { return run.EventID == 1 })).Return(&models.CommandRun{ID: 1, EventID: 1, CommandName: "test-command", Status: "created"}, nil).Once()
+	mcr.On("CreateRun", mock.Anything, mock.MatchedBy(func(run *models.CommandRun) bool { return run.EventID == 2 })).Return(&models.CommandRun{ID: 2, EventID: 2, CommandName: "test-command", Status: "created"}, nil).Once()
+	signalUpdated(mcr, "SIGTERM")
+	superseded := statusUpdated(mcr, "cancelled", "\"\"")
+	command := &models.Command{
+		Name:        "test-command",
+		ID:          1,
+		Image:       "test-image",
+		Enabled:     true,
+		Concurrency: &models.ConcurrencyPolicy{Group: models.ConcurrencyGroupPullRequest, CancelInProgress: true},
+	}
+	event := func(id int) *models.Event {
+		return &models.Event{ID: id, RepositoryID: 1, Payload: `{"pull_request":{"number":5}}`, VCS: models.GITHUB, EventType: "pull_request"}
+	}
+	require.NoError(t, ime.CreateRun(context.Background(), event(1), []*models.Command{command}))
+	assert.Eventually(t, func() bool { return len(fake.Containers()) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// The new event for the same pull request cancels the old run and starts once it stopped.
+	require.NoError(t, ime.CreateRun(context.Background(), event(2), []*models.Command{command}))
+	waitFor(t, superseded)
+	assert.Eventually(t, func() bool { return len(fake.Created()) == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	mcr.On("UpdateRunSignal", mock.Anything, 2, "SIGTERM").Return(nil).Once()
+	done := make(chan struct{})
+	mcr.On("UpdateRunStatus", mock.Anything, 2, "cancelled", "\"\"").Run(func(args mock.Arguments) {
+		close(done)
+	}).Return(nil).Once()
+	require.NoError(t, ime.CancelRun(context.Background(), 2))
+	waitFor(t, done)
+	mcr.AssertExpectations(t)
+}
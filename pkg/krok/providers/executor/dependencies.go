@@ -0,0 +1,93 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// plannedCommand is a command which is part of a run together with the commands it has to wait for.
+type plannedCommand struct {
+	command      *models.Command
+	input        commandInput
+	commandRunID int
+	// dependsOn are the IDs of all the dependencies of the command, including the
+	// ones which are not part of this run.
+	dependsOn []int
+	// parents are the dependencies which are part of this run.
+	parents []*plannedCommand
+	// done is closed once the command finished or has been skipped.
+	done      chan struct{}
+	succeeded bool
+}
+
+// failedParent waits for all the dependencies of the command to finish and returns the first
+// one which did not succeed, or nil if all of them succeeded.
+func (p *plannedCommand) failedParent() *plannedCommand {
+	for _, parent := range p.parents {
+		<-parent.done
+		if !parent.succeeded {
+			return parent
+		}
+	}
+	return nil
+}
+
+// orderCommands sets up the parents of every planned command and returns the commands in
+// topological order. Dependencies which are not part of the run are ignored.
+// It returns an error if the dependencies contain a cycle.
+func orderCommands(planned []*plannedCommand) ([]*plannedCommand, error) {
+	byID := make(map[int]*plannedCommand, len(planned))
+	for _, p := range planned {
+		byID[p.command.ID] = p
+	}
+	inDegree := make(map[int]int, len(planned))
+	children := make(map[int][]*plannedCommand)
+	for _, p := range planned {
+		p.parents = nil
+		for _, id := range p.dependsOn {
+			parent, ok := byID[id]
+			if !ok {
+				continue
+			}
+			p.parents = append(p.parents, parent)
+			children[id] = append(children[id], p)
+			inDegree[p.command.ID]++
+		}
+	}
+
+	var (
+		queue   []*plannedCommand
+		ordered []*plannedCommand
+	)
+	for _, p := range planned {
+		if inDegree[p.command.ID] == 0 {
+			queue = append(queue, p)
+		}
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		ordered = append(ordered, p)
+		for _, child := range children[p.command.ID] {
+			inDegree[child.command.ID]--
+			if inDegree[child.command.ID] == 0 {
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	if len(ordered) != len(planned) {
+		var names []string
+		for _, p := range planned {
+			if inDegree[p.command.ID] > 0 {
+				names = append(names, p.command.Name)
+			}
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("dependency cycle between commands: %s", strings.Join(names, ", "))
+	}
+	return ordered, nil
+}
@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/krok-o/krok/pkg/krok/providers/mocks"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+func planned(id int, name string, dependsOn ...int) *plannedCommand {
+	return &plannedCommand{
+		command:   &models.Command{ID: id, Name: name},
+		dependsOn: dependsOn,
+		done:      make(chan struct{}),
+	}
+}
+
+func names(commands []*plannedCommand) []string {
+	var result []string
+	for _, c := range commands {
+		result = append(result, c.command.Name)
+	}
+	return result
+}
+
+func TestOrderCommands(t *testing.T) {
+	publish := planned(3, "publish", 2)
+	build := planned(2, "build", 1)
+	lint := planned(1, "lint")
+	notify := planned(4, "notify", 99)
+	ordered, err := orderCommands([]*plannedCommand{publish, build, lint, notify})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"lint", "notify", "build", "publish"}, names(ordered))
+	assert.Equal(t, []*plannedCommand{build}, publish.parents)
+	// dependencies outside the run are ignored.
+	assert.Empty(t, notify.parents)
+}
+
+func TestOrderCommands_Cycle(t *testing.T) {
+	_, err := orderCommands([]*plannedCommand{
+		planned(1, "a", 2),
+		planned(2, "b", 1),
+		planned(3, "c"),
+	})
+	assert.EqualError(t, err, "dependency cycle between commands: a, b")
+}
+
+func TestInMemoryExecutor_CreateRun_Cycle(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	mcr := &mocks.CommandRunStorer{}
+	mcs := &mocks.CommandStorer{}
+	mcs.On("IsPlatformSupported", mock.Anything, mock.Anything, 1).Return(true, nil)
+	mcs.On("ListSettings", mock.Anything, mock.Anything).Return(nil, nil)
+	mcs.On("GetCommandDependencies", mock.Anything, 1).Return([]int{2}, nil)
+	mcs.On("GetCommandDependencies", mock.Anything, 2).Return([]int{1}, nil)
+	mrs := &mocks.RepositoryStorer{}
+	mrs.On("Get", mock.Anything, 1).Return(&models.Repository{ID: 1}, nil)
+	ime := NewInMemoryExecutor(Config{
+		DefaultMaximumCommandRuntime: 10,
+		MaximumParallelCommands:      10,
+	}, Dependencies{
+		Logger:           logger,
+		CommandRuns:      mcr,
+		CommandStorer:    mcs,
+		RepositoryStorer: mrs,
+	})
+	err := ime.CreateRun(context.Background(), &models.Event{
+		ID:           1,
+		RepositoryID: 1,
+		VCS:          1,
+	}, []*models.Command{
+		{ID: 1, Name: "build", Enabled: true},
+		{ID: 2, Name: "publish", Enabled: true},
+	})
+	assert.EqualError(t, err, "dependency cycle between commands: build, publish")
+	mcr.AssertNotCalled(t, "CreateRun", mock.Anything, mock.Anything)
+}
+
+func TestInMemoryExecutor_RunPlannedCommand_SkipsWhenDependencyFailed(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	mcr := &mocks.CommandRunStorer{}
+	mcr.On("UpdateRunStatus", mock.Anything, 2, "skipped", "\"dependency build did not succeed\"").Return(nil)
+	ime := NewInMemoryExecutor(Config{
+		DefaultMaximumCommandRuntime: 10,
+		MaximumParallelCommands:      10,
+	}, Dependencies{
+		Logger:      logger,
+		CommandRuns: mcr,
+	})
+	build := planned(1, "build")
+	publish := planned(2, "publish", 1)
+	publish.commandRunID = 2
+	_, err := orderCommands([]*plannedCommand{build, publish})
+	require.NoError(t, err)
+	running := ime.track(1, 1, "publish", 2)
+
+	// build failed
+	close(build.done)
+	ime.runPlannedCommand(publish, 1, running, nil)
+
+	mcr.AssertExpectations(t)
+	assert.False(t, publish.succeeded)
+	_, ok := ime.runs.Load(1)
+	assert.False(t, ok)
+	select {
+	case <-publish.done:
+	default:
+		t.Fatal("publish should be done")
+	}
+}
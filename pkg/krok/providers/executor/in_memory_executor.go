@@ -1,32 +1,89 @@
 package executor
 
 import (
-	"bytes"
 	"context"
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
-	"os"
+	"io/ioutil"
 	"strconv"
 	"sync"
 	"time"
 
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
-	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/rs/zerolog"
-	"golang.org/x/sync/semaphore"
 
+	kerr "github.com/krok-o/krok/errors"
 	"github.com/krok-o/krok/pkg/krok/providers"
 	"github.com/krok-o/krok/pkg/models"
 )
 
 // Config defines configuration for this provider
 type Config struct {
+	// DefaultMaximumCommandRuntime is the timeout of commands in seconds which don't define one.
+	// It also caps the timeouts of the commands.
 	DefaultMaximumCommandRuntime int
 	MaximumParallelCommands      int
+	// MaximumParallelCommandsPerRepository is how many commands of the same repository may run at the same time.
+	// 0 means that only MaximumParallelCommands applies.
+	MaximumParallelCommandsPerRepository int
+	// SecretsLocation is where the secret files of the commands are written on the host. It should be on tmpfs
+	// and only be accessible by Krok. Defaults to DefaultSecretsLocation.
+	SecretsLocation string
+	// ContainerUID and ContainerGID are the user and group the command containers run as. The secret files
+	// are owned by them, so nobody else can read them. 0 keeps the user or group of Krok.
+	ContainerUID int
+	ContainerGID int
+}
+
+// commandTimeout returns how long a command may run. The timeout of the command is only
+// used if it's below the configured maximum.
+func (c Config) commandTimeout(command *models.Command) time.Duration {
+	timeout := c.DefaultMaximumCommandRuntime
+	if command != nil && command.Timeout > 0 && command.Timeout < timeout {
+		timeout = command.Timeout
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+const (
+	// defaultStopSignal is sent to commands which don't define a stop signal.
+	defaultStopSignal = "SIGTERM"
+	// defaultStopGracePeriod is how long commands which don't define a grace period have to stop.
+	defaultStopGracePeriod = 10 * time.Second
+)
+
+// runPolicy defines how long a command may run and how it's stopped once it timed out or got cancelled.
+type runPolicy struct {
+	timeout         time.Duration
+	stopSignal      string
+	stopGracePeriod time.Duration
+}
+
+// runPolicy returns the run policy of a command. If the command isn't known, i.e.: because it has
+// been deleted, the defaults are used.
+func (c Config) runPolicy(command *models.Command) runPolicy {
+	policy := runPolicy{
+		timeout:         c.commandTimeout(command),
+		stopSignal:      defaultStopSignal,
+		stopGracePeriod: defaultStopGracePeriod,
+	}
+	if command != nil && command.StopSignal != "" {
+		policy.stopSignal = command.StopSignal
+	}
+	if command != nil && command.StopGracePeriod > 0 {
+		policy.stopGracePeriod = time.Duration(command.StopGracePeriod) * time.Second
+	}
+	return policy
+}
+
+// containerLimits returns the resource limits of the container of a command.
+func containerLimits(command *models.Command) providers.ContainerLimits {
+	return providers.ContainerLimits{
+		CPUs:   command.CPUs,
+		Memory: command.MemoryLimit,
+		Pids:   command.PidsLimit,
+		Disk:   command.DiskLimit,
+	}
 }
 
 // Dependencies defines dependencies for this provider
@@ -35,9 +92,25 @@ type Dependencies struct {
 	CommandRuns      providers.CommandRunStorer
 	CommandStorer    providers.CommandStorer
 	RepositoryStorer providers.RepositoryStorer
+	EventsStorer     providers.EventsStorer
 	Clock            providers.Clock
+	// LogStreamer is optional. If set, the logs of running commands are streamed to it.
+	LogStreamer providers.LogStreamer
+	// ArtifactStorer is optional. If set, all commands of an event share a workspace.
+	ArtifactStorer providers.ArtifactStorer
+	// ContainerRuntime runs the containers of the commands. It isn't used by the KubernetesExecutor.
+	ContainerRuntime providers.ContainerRuntime
+	// RegistryCredentials is optional. If set, the images of commands are pulled with the credentials of their registry.
+	RegistryCredentials providers.RegistryCredentialProvider
 }
 
+const (
+	// workspaceLocation is where the shared workspace of a run is mounted into the command containers.
+	workspaceLocation = "/krok/workspace"
+	// workspaceEnv is the environment variable which tells commands where the workspace is.
+	workspaceEnv = "KROK_WORKSPACE"
+)
+
 // InMemoryExecutor defines an Executor which runs commands
 // alongside Krok. It saves runs in a map and constantly updates it.
 // Cancelling will go over all processes belonging to that run
@@ -46,22 +119,27 @@ type InMemoryExecutor struct {
 	Config
 	Dependencies
 
-	// For each event, a list of commandName=>containerIDs.
-	// ContainerIDs are filled in as the containers are pulled and started.
+	// For each event, a list of commandName=>*runningCommand.
 	runs *sync.Map
-	sem  *semaphore.Weighted
+	// For the command run of the current attempt of every running command, its *runningCommand.
+	commandRuns *sync.Map
+	queue       *runQueue
+	groups      *concurrencyGroups
+	// persist defines if container IDs and the running status are saved for the command runs.
+	persist bool
 }
 
 // NewInMemoryExecutor creates a new InMemoryExecutor which will hold all runs in its memory.
-// In case of a crash, human intervention will be required.
-// TODO: Later, save runs in db with the process id to cancel so Krok can pick up runs again.
+// In case of a crash, human intervention will be required. Use the PersistentExecutor
+// to recover runs after a restart.
 func NewInMemoryExecutor(cfg Config, deps Dependencies) *InMemoryExecutor {
-	sem := semaphore.NewWeighted(int64(cfg.MaximumParallelCommands))
 	return &InMemoryExecutor{
 		Config:       cfg,
 		Dependencies: deps,
 		runs:         &sync.Map{},
-		sem:          sem,
+		commandRuns:  &sync.Map{},
+		queue:        newRunQueue(cfg.MaximumParallelCommands, cfg.MaximumParallelCommandsPerRepository),
+		groups:       newConcurrencyGroups(),
 	}
 }
 
@@ -75,268 +153,480 @@ func (ime *InMemoryExecutor) CreateRun(ctx context.Context, event *models.Event,
 		Int("commands", len(commands)).
 		Logger()
 
+	ordered, err := ime.planRun(ctx, log, event, commands)
+	if err != nil {
+		return err
+	}
+	running := make([]*runningCommand, 0, len(ordered))
+	for _, p := range ordered {
+		running = append(running, ime.track(event.ID, event.RepositoryID, p.command.Name, p.commandRunID))
+	}
+	// Start these here with the runner go routine. Every command waits for its dependencies first.
+	for i, p := range ordered {
+		log.Debug().Str("image", p.command.Image).Msg("Preparing to run command...")
+		member := ime.groups.join(event, p.command, running[i].cancel)
+		if member != nil && len(member.older) > 0 {
+			log.Info().Str("command", p.command.Name).Str("group", member.key).Int("older_runs", len(member.older)).Msg("Command waits for the older runs of its concurrency group.")
+		}
+		go ime.runPlannedCommand(p, event.ID, running[i], member)
+	}
+	return nil
+}
+
+// runPlannedCommand waits for the dependencies of a command to finish and runs it if all of them
+// succeeded. Otherwise, the command is skipped. If the command is part of a concurrency group, it also
+// waits for the older runs of the group.
+func (ime *InMemoryExecutor) runPlannedCommand(p *plannedCommand, eventID int, running *runningCommand, member *groupMember) {
+	defer close(p.done)
+	// we delete this command from memory once all of its attempts have been saved in the db.
+	defer ime.untrack(running)
+	defer ime.groups.leave(member)
+	if parent := p.failedParent(); parent != nil {
+		ime.Logger.Info().Str("command", p.command.Name).Str("dependency", parent.command.Name).Msg("Skipping command as a dependency did not succeed.")
+		ime.updateStatus(models.RunStatusSkipped, fmt.Sprintf("dependency %s did not succeed", parent.command.Name), p.commandRunID)
+		return
+	}
+	if err := member.waitForOlder(running.ctx); err != nil {
+		ime.notStarted(running, err.Error(), p.commandRunID)
+		return
+	}
+	p.succeeded = ime.runCommandAttempts(p.command, p.input, eventID, p.commandRunID, 1, running) == models.RunStatusSuccess
+}
+
+// runCommandAttempts runs the container of a command and retries it according to the retry policy of the command.
+func (ime *InMemoryExecutor) runCommandAttempts(command *models.Command, input commandInput, eventID, commandRunID, attempt int, running *runningCommand) string {
+	return ime.runAttempts(running.ctx, command, eventID, commandRunID, attempt, func(commandRunID int) (string, string) {
+		return ime.pullAndCreateContainer(command, input, eventID, commandRunID, running)
+	}, running.track)
+}
+
+// planRun collects the commands of an event which have to run together with their arguments,
+// orders them by their dependencies and creates a command run for each of them.
+func (d *Dependencies) planRun(ctx context.Context, log zerolog.Logger, event *models.Event, commands []*models.Command) ([]*plannedCommand, error) {
 	platform, found := models.SupportedPlatforms[event.VCS]
 	if !found {
-		return fmt.Errorf("failed to find %d in supported platforms", event.VCS)
+		return nil, fmt.Errorf("failed to find %d in supported platforms", event.VCS)
 	}
 
-	repository, err := ime.RepositoryStorer.Get(ctx, event.RepositoryID)
+	repository, err := d.RepositoryStorer.Get(ctx, event.RepositoryID)
 	if err != nil {
-		return fmt.Errorf("failed to get repository: %w", err)
+		return nil, fmt.Errorf("failed to get repository: %w", err)
 	}
 
 	log = log.With().Str("platform", platform.Name).Logger()
 
 	log.Info().Msg("Starting run")
-	containers := &sync.Map{}
-	payload := base64.StdEncoding.EncodeToString([]byte(event.Payload))
-	// Start these here with the runner go routine
+	var planned []*plannedCommand
 	for _, c := range commands {
 		if !c.Enabled {
 			log.Debug().Str("name", c.Name).Msg("Skipping as command is disabled.")
 			continue
 		}
-		if ok, err := ime.CommandStorer.IsPlatformSupported(ctx, c.ID, event.VCS); err != nil {
+		if ok, err := d.CommandStorer.IsPlatformSupported(ctx, c.ID, event.VCS); err != nil {
 			log.Debug().Err(err).Msg("Failed to get is platform is supported by command.")
-			return err
+			return nil, err
 		} else if !ok {
 			log.Debug().Str("name", c.Name).Msg("Command does not support platform.")
 			continue
 		}
 
-		settings, err := ime.CommandStorer.ListSettings(ctx, c.ID)
+		input, err := d.buildInput(ctx, platform, event, repository, c)
 		if err != nil {
 			log.Debug().Err(err).Msg("Failed to get settings for command.")
-			return err
-		}
-
-		// We aren't going to save these because it could be things like tokens which are
-		// confidential. The platform must always be the first arg.
-		args := []string{
-			fmt.Sprintf("--platform=%s", platform.Name),
-			fmt.Sprintf("--event-type=%s", event.EventType),
-			fmt.Sprintf("--payload=%s", payload),
+			return nil, err
 		}
 
-		for _, s := range settings {
-			args = append(args, fmt.Sprintf("--%s=%s", s.Key, s.Value))
+		dependsOn, err := d.CommandStorer.GetCommandDependencies(ctx, c.ID)
+		if err != nil {
+			log.Debug().Err(err).Msg("Failed to get dependencies for command.")
+			return nil, err
 		}
+		planned = append(planned, &plannedCommand{
+			command:   c,
+			input:     input,
+			dependsOn: dependsOn,
+			done:      make(chan struct{}),
+		})
+	}
 
-		if c.RequiresClone {
-			if repository.Auth.SSH != "" {
-				args = append(args, fmt.Sprintf("--repo-ssh-key=%s", repository.Auth.SSH))
-			}
-			if repository.Auth.Username != "" {
-				args = append(args, fmt.Sprintf("--repo-username=%s", repository.Auth.Username))
-			}
-			if repository.Auth.Password != "" {
-				args = append(args, fmt.Sprintf("--repo-password=%s", repository.Auth.Password))
-			}
-		}
+	ordered, err := orderCommands(planned)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to order commands.")
+		return nil, err
+	}
 
-		commandRun := &models.CommandRun{
-			EventID:     event.ID,
-			CommandName: c.Name,
-			Status:      "created",
-			Outcome:     "",
-			CreateAt:    ime.Clock.Now(),
-		}
-		commandRun, err = ime.CommandRuns.CreateRun(ctx, commandRun)
+	for _, p := range ordered {
+		commandRun, err := d.createCommandRun(ctx, event.ID, p.command, 1)
 		if err != nil {
 			log.Debug().Err(err).Msg("Failed to create run for command")
-			return err
+			return nil, err
 		}
-		log.Debug().Str("image", c.Image).Msg("Preparing to run command...")
-		containers.Store(c.Name, "")
-		go ime.pullAndCreateContainer(c.Name, c.Image, args, event.ID, commandRun.ID)
+		p.commandRunID = commandRun.ID
 	}
-	ime.runs.Store(event.ID, containers)
-	return nil
+	return ordered, nil
 }
 
-func (ime *InMemoryExecutor) pullAndCreateContainer(commandName, image string, args []string, eventID int, commandRunID int) {
-	ctx := context.Background()
-	if err := ime.sem.Acquire(ctx, 1); err != nil {
-		ime.updateStatus("failed", err.Error(), commandRunID)
-		ime.Logger.Debug().Err(err).Msg("Failed to acquire run semaphore.")
-		return
+// runningCommand is a command of a run which hasn't finished yet.
+type runningCommand struct {
+	// ctx is done once the command has been cancelled.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// repositoryID is the repository of the event, which the run queue takes turns between.
+	repositoryID int
+	eventID      int
+	commandName  string
+	// commandRuns are the tracked command runs of the executor.
+	commandRuns *sync.Map
+
+	mu sync.Mutex
+	// commandRunID is the command run of the current attempt of the command.
+	commandRunID int
+}
+
+// track makes the command run of the next attempt of the command the one it can be cancelled with.
+func (r *runningCommand) track(commandRunID int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commandRuns.Delete(r.commandRunID)
+	r.commandRunID = commandRunID
+	r.commandRuns.Store(commandRunID, r)
+}
+
+// track records a command of an event until it finished, so it can be cancelled together with its event
+// or by the ID of its command run. If the command is already tracked, the existing entry is returned.
+func (ime *InMemoryExecutor) track(eventID, repositoryID int, commandName string, commandRunID int) *runningCommand {
+	commands, _ := ime.runs.LoadOrStore(eventID, &sync.Map{})
+	ctx, cancel := context.WithCancel(context.Background())
+	running, loaded := commands.(*sync.Map).LoadOrStore(commandName, &runningCommand{
+		ctx:          ctx,
+		cancel:       cancel,
+		repositoryID: repositoryID,
+		eventID:      eventID,
+		commandName:  commandName,
+		commandRuns:  ime.commandRuns,
+	})
+	if loaded {
+		cancel()
 	}
-	defer ime.sem.Release(1)
-	cli, err := client.NewClientWithOpts(client.FromEnv)
-	if err != nil {
-		ime.updateStatus("failed", err.Error(), commandRunID)
-		ime.Logger.Debug().Err(err).Msg("Failed to create docker client.")
+	running.(*runningCommand).track(commandRunID)
+	return running.(*runningCommand)
+}
+
+// untrack removes a command and the event entry if there are no more commands left.
+func (ime *InMemoryExecutor) untrack(running *runningCommand) {
+	running.mu.Lock()
+	ime.commandRuns.Delete(running.commandRunID)
+	running.mu.Unlock()
+	event, ok := ime.runs.Load(running.eventID)
+	if !ok {
 		return
 	}
-	output, err := cli.ImagePull(context.Background(), image, types.ImagePullOptions{})
-	if err != nil {
-		ime.updateStatus("failed", fmt.Sprintf("failed to pull image: %s", err), commandRunID)
-		ime.Logger.Debug().Err(err).Msg("Failed to pull image.")
-		return
+	if running, ok := event.(*sync.Map).LoadAndDelete(running.commandName); ok {
+		running.(*runningCommand).cancel()
+	}
+	// if there are no more runs for this event, remove the event entry too.
+	empty := true
+	event.(*sync.Map).Range(func(key, value interface{}) bool {
+		empty = false
+		return false
+	})
+	if empty {
+		ime.runs.Delete(running.eventID)
 	}
-	if _, err := io.Copy(os.Stdout, output); err != nil {
-		ime.updateStatus("failed", fmt.Sprintf("failed to pull image: %s", err), commandRunID)
+}
+
+// pullAndCreateContainer pulls the image of a command, creates the container with the limits
+// of the command and runs it. It returns the final status of the command run and the failure
+// of the run a retry policy can cover, if any.
+func (ime *InMemoryExecutor) pullAndCreateContainer(command *models.Command, input commandInput, eventID int, commandRunID int, running *runningCommand) (string, string) {
+	ctx := running.ctx
+	slot := newQueuedRun(command, command.Name, eventID, running.repositoryID, commandRunID)
+	if err := ime.queue.acquire(ctx, slot); err != nil {
+		ime.Logger.Debug().Err(err).Msg("Failed to get a slot in the run queue.")
+		return ime.notStarted(running, err.Error(), commandRunID), ""
+	}
+	defer ime.queue.release(slot)
+	if err := ime.pullImage(ctx, command); err != nil {
 		ime.Logger.Debug().Err(err).Msg("Failed to pull image.")
-		return
+		status := ime.notStarted(running, fmt.Sprintf("failed to pull image: %s", err), commandRunID)
+		if status == models.RunStatusFailed {
+			return status, models.RetryOnPullFailure
+		}
+		return status, ""
+	}
+
+	cfg := providers.ContainerConfig{
+		Image:  command.Image,
+		Args:   input.args,
+		Env:    input.env,
+		Limits: containerLimits(command),
+	}
+	if ime.ArtifactStorer != nil {
+		workspace, err := ime.ArtifactStorer.Workspace(ctx, eventID)
+		if err != nil {
+			ime.Logger.Debug().Err(err).Msg("Failed to create workspace.")
+			return ime.notStarted(running, fmt.Sprintf("failed to create workspace: %s", err), commandRunID), ""
+		}
+		cfg.Env = append(cfg.Env, fmt.Sprintf("%s=%s", workspaceEnv, workspaceLocation))
+		cfg.Mounts = append(cfg.Mounts, providers.ContainerMount{
+			Source: workspace,
+			Target: workspaceLocation,
+		})
 	}
 
+	if len(input.files) > 0 {
+		folder, err := ime.writeSecrets(commandRunID, input.files)
+		if err != nil {
+			ime.Logger.Debug().Err(err).Msg("Failed to write secrets.")
+			return ime.notStarted(running, fmt.Sprintf("failed to write secrets: %s", err), commandRunID), ""
+		}
+		// The container is removed before this returns, so the secrets aren't needed anymore.
+		defer ime.removeSecretFiles(commandRunID)
+		cfg.Mounts = append(cfg.Mounts, providers.ContainerMount{
+			Source:   folder,
+			Target:   secretsLocation,
+			ReadOnly: true,
+		})
+	}
+
+	if ctx.Err() != nil {
+		return ime.notStarted(running, ctx.Err().Error(), commandRunID), ""
+	}
 	ime.Logger.Info().Msg("Creating container...")
-	cont, err := cli.ContainerCreate(context.Background(), &container.Config{
-		AttachStdout: true,
-		AttachStderr: true,
-		Image:        image,
-		Cmd:          args,
-	}, nil, nil, nil, "")
+	// The container is created regardless of a cancellation, so it's always removed again.
+	containerID, err := ime.ContainerRuntime.Create(context.Background(), cfg)
 	if err != nil {
-		ime.updateStatus("failed", err.Error(), commandRunID)
-		ime.Logger.Debug().Err(err).Strs("warnings", cont.Warnings).Msg("Failed to create container.")
-		return
+		ime.updateStatus(models.RunStatusFailed, err.Error(), commandRunID)
+		ime.Logger.Debug().Err(err).Msg("Failed to create container.")
+		return models.RunStatusFailed, ""
+	}
+	if ime.persist {
+		if err := ime.CommandRuns.UpdateRunContainer(context.Background(), commandRunID, containerID); err != nil {
+			ime.Logger.Debug().Err(err).Str("container_id", containerID).Msg("Failed to save container id of command run.")
+		}
+	}
+	return ime.startAndWaitForContainer(containerID, commandRunID, ime.runPolicy(command), input.redactor(), running)
+}
+
+// removeSecretFiles removes the secret files of a command run once its container is gone.
+func (ime *InMemoryExecutor) removeSecretFiles(commandRunID int) {
+	if err := ime.removeSecrets(commandRunID); err != nil {
+		ime.Logger.Error().Err(err).Int("command_run_id", commandRunID).Msg("Failed to remove secrets of command run.")
+	}
+}
+
+// notStarted saves the outcome of a command run which failed before its container was created.
+// If the command has been cancelled in the meantime, the run is marked as cancelled instead.
+func (ime *InMemoryExecutor) notStarted(running *runningCommand, outcome string, commandRunID int) string {
+	if running.ctx.Err() != nil {
+		ime.updateStatus(models.RunStatusCancelled, "cancelled before the command started", commandRunID)
+		return models.RunStatusCancelled
 	}
-	event, _ := ime.runs.Load(eventID)
-	event.(*sync.Map).Store(commandName, cont.ID)
-	//ime.runs[eventID].Store(commandName, cont.ID)
-	ime.startAndWaitForContainer(commandName, cont.ID, eventID, commandRunID)
+	ime.updateStatus(models.RunStatusFailed, outcome, commandRunID)
+	return models.RunStatusFailed
 }
 
 // TODO: this should return an error and we should log that.
-func (ime *InMemoryExecutor) updateStatus(status, outcome string, commandRunID int) {
+func (d *Dependencies) updateStatus(status, outcome string, commandRunID int) {
 	outcome = strconv.Quote(outcome)
-	ime.Logger.Debug().Int("command_run_id", commandRunID).Str("status", status).Str("outcome", outcome).Msg("Updating command run entry.")
-	if err := ime.CommandRuns.UpdateRunStatus(context.Background(), commandRunID, status, outcome); err != nil {
-		ime.Logger.Debug().Err(err).Msg("Updating status of command failed.")
+	d.Logger.Debug().Int("command_run_id", commandRunID).Str("status", status).Str("outcome", outcome).Msg("Updating command run entry.")
+	if err := d.CommandRuns.UpdateRunStatus(context.Background(), commandRunID, status, outcome); err != nil {
+		d.Logger.Debug().Err(err).Msg("Updating status of command failed.")
 	}
 }
 
-// runCommand takes a single command and executes it, waiting for it to finish,
-// or time out. Either way, it will update the corresponding command row.
-func (ime *InMemoryExecutor) startAndWaitForContainer(commandName, containerID string, eventID, commandRunID int) {
-	done := make(chan error, 1)
-	cli, err := client.NewClientWithOpts(client.FromEnv)
-	if err != nil {
-		ime.updateStatus("failed", err.Error(), commandRunID)
-		ime.Logger.Debug().Err(err).Msg("Failed to create docker client.")
-		return
+// updateSignal saves the signal which stopped a command run.
+func (d *Dependencies) updateSignal(signal string, commandRunID int) {
+	d.Logger.Debug().Int("command_run_id", commandRunID).Str("signal", signal).Msg("Updating signal of command run.")
+	if err := d.CommandRuns.UpdateRunSignal(context.Background(), commandRunID, signal); err != nil {
+		d.Logger.Debug().Err(err).Msg("Updating signal of command run failed.")
 	}
-	defer func() {
-		// we remove the container in a `defer` instead of autoRemove, to be able to read out the logs.
-		// If we use AutoRemove, the container is gone by the time we want to read the output.
-		// Could try streaming the logs. But this is enough for now.
-		if err := cli.ContainerRemove(context.Background(), containerID, types.ContainerRemoveOptions{
-			Force: true,
-		}); err != nil {
-			ime.Logger.Debug().Err(err).Str("container_id", containerID).Msg("Failed to remove container.")
-		}
+}
 
-		// we also delete this command run from memory since it has been saved in the db.
-		event, _ := ime.runs.Load(eventID)
-		event.(*sync.Map).Delete(commandName)
-		//ime.runs[eventID].Delete(commandName)
-		// if there are no more runs for this event, remove the event entry too.
-		empty := true
-		event.(*sync.Map).Range(func(key, value interface{}) bool {
-			empty = false
-			return false
-		})
-		if empty {
-			ime.runs.Delete(eventID)
-			//delete(ime.runs, eventID)
-		}
-	}()
+// startAndWaitForContainer takes a single created container and executes it, waiting for it to finish,
+// or time out. Either way, it will update the corresponding command row. The secrets known to the redactor
+// are masked in the saved and streamed output.
+func (ime *InMemoryExecutor) startAndWaitForContainer(containerID string, commandRunID int, policy runPolicy, redact *redactor, running *runningCommand) (string, string) {
+	defer ime.removeContainer(containerID)
 
 	ime.Logger.Info().Msg("Starting container...")
-	if err := cli.ContainerStart(context.Background(), containerID, types.ContainerStartOptions{}); err != nil {
-		ime.updateStatus("failed", err.Error(), commandRunID)
+	if err := ime.ContainerRuntime.Start(context.Background(), containerID); err != nil {
+		ime.updateStatus(models.RunStatusFailed, err.Error(), commandRunID)
+		return models.RunStatusFailed, ""
+	}
+	if ime.persist {
+		if err := ime.CommandRuns.UpdateRunStatus(context.Background(), commandRunID, models.RunStatusRunning, ""); err != nil {
+			ime.Logger.Debug().Err(err).Msg("Updating status of command failed.")
+		}
+	}
+	return ime.followAndWaitForContainer(containerID, commandRunID, policy, redact, running)
+}
+
+// followAndWaitForContainer streams the logs of a started container while waiting for it to finish.
+// The log stream is closed only after the outcome has been saved, so subscribers can always
+// fetch the final result once the stream ends.
+func (ime *InMemoryExecutor) followAndWaitForContainer(containerID string, commandRunID int, policy runPolicy, redact *redactor, running *runningCommand) (string, string) {
+	if ime.LogStreamer != nil {
+		stream := redact.writer(ime.LogStreamer.Open(commandRunID))
+		defer stream.Close()
+		go ime.followLogs(containerID, stream)
+	}
+	return ime.waitForContainer(containerID, commandRunID, policy, redact, running.ctx.Done())
+}
+
+// followLogs copies the logs of a container into the writer until the container stops.
+func (ime *InMemoryExecutor) followLogs(containerID string, w io.Writer) {
+	logs, err := ime.ContainerRuntime.Logs(context.Background(), containerID, true)
+	if err != nil {
+		ime.Logger.Debug().Err(err).Str("container_id", containerID).Msg("Failed to follow container logs.")
 		return
 	}
+	defer logs.Close()
+	if _, err := io.Copy(w, logs); err != nil {
+		ime.Logger.Debug().Err(err).Str("container_id", containerID).Msg("Failed to copy the container log.")
+	}
+}
 
+// removeContainer removes a finished container.
+func (ime *InMemoryExecutor) removeContainer(containerID string) {
+	// we remove the container in a `defer` instead of autoRemove, to be able to read out the logs.
+	// If we use AutoRemove, the container is gone by the time we want to read the output.
+	// Could try streaming the logs. But this is enough for now.
+	if err := ime.ContainerRuntime.Remove(context.Background(), containerID); err != nil {
+		ime.Logger.Debug().Err(err).Str("container_id", containerID).Msg("Failed to remove container.")
+	}
+}
+
+// waitForContainer waits for a started container to finish and saves the outcome. If the command times out
+// or is cancelled, the container is stopped according to the policy of the command.
+// It returns the final status of the command run and the failure a retry policy can cover, if any.
+func (ime *InMemoryExecutor) waitForContainer(containerID string, commandRunID int, policy runPolicy, redact *redactor, cancelled <-chan struct{}) (string, string) {
+	done := make(chan error, 1)
 	go func() {
-		exit, err := cli.ContainerWait(context.Background(), containerID, container.WaitConditionNotRunning)
-		select {
-		case e := <-err:
-			done <- e
-		case e := <-exit:
-			if e.StatusCode != 0 {
-				if e.Error != nil {
-					done <- errors.New(e.Error.Message)
-				} else {
-					done <- fmt.Errorf("status code: %d", e.StatusCode)
-				}
-			} else {
-				done <- nil
-			}
+		code, err := ime.ContainerRuntime.Wait(context.Background(), containerID)
+		if err == nil && code != 0 {
+			err = &exitError{code: code}
 		}
+		done <- err
 	}()
 
-	for {
-		select {
-		case err := <-done:
-			log, logErr := cli.ContainerLogs(context.Background(), containerID, types.ContainerLogsOptions{
-				ShowStderr: true,
-				ShowStdout: true,
-			})
-			if logErr != nil {
-				ime.updateStatus("failed", logErr.Error(), commandRunID)
-				return
-			}
-			buffer := &bytes.Buffer{}
-			logs := "no logs available"
-			if _, err := stdcopy.StdCopy(buffer, buffer, log); err != nil {
-				ime.Logger.Debug().Err(err).Msg("Failed to de-multiplex the docker log.")
-			} else {
-				logs = buffer.String()
+	var (
+		status  string
+		failure string
+		signal  string
+	)
+	select {
+	case err := <-done:
+		status = models.RunStatusSuccess
+		if err != nil {
+			ime.Logger.Debug().Err(err).Msg("Failed to run command.")
+			status = models.RunStatusFailed
+			var exitErr *exitError
+			if errors.As(err, &exitErr) {
+				failure = models.RetryOnNonZeroExit
 			}
+		}
+	case <-time.After(policy.timeout):
+		ime.Logger.Error().Str("container_id", containerID).Msg("Command timed out.")
+		status = models.RunStatusTimedOut
+		failure = models.RetryOnTimeout
+		signal = ime.stopContainer(containerID, policy, done)
+	case <-cancelled:
+		ime.Logger.Info().Str("container_id", containerID).Msg("Command was cancelled.")
+		status = models.RunStatusCancelled
+		signal = ime.stopContainer(containerID, policy, done)
+	}
 
-			if err != nil {
-				ime.updateStatus("failed", logs, commandRunID)
-				ime.Logger.Debug().Err(err).Msg("Failed to run command.")
-				return
-			}
-			ime.updateStatus("success", logs, commandRunID)
-			ime.Logger.Info().Msg("Successfully finished command.")
-			return
-		case <-time.After(time.Duration(ime.Config.DefaultMaximumCommandRuntime) * time.Second):
-			// update entry
-			ime.updateStatus("failed", "timeout", commandRunID)
-			ime.Logger.Error().Msg("Command timed out.")
-			if err := cli.ContainerKill(context.Background(), containerID, "SIGKILL"); err != nil {
-				ime.Logger.Error().Str("container_id", containerID).Msg("Failed to kill process with pid.")
+	log, logErr := ime.ContainerRuntime.Logs(context.Background(), containerID, false)
+	if logErr != nil {
+		ime.updateStatus(models.RunStatusFailed, logErr.Error(), commandRunID)
+		return models.RunStatusFailed, failure
+	}
+	logs := "no logs available"
+	if content, err := ioutil.ReadAll(log); err != nil {
+		ime.Logger.Debug().Err(err).Msg("Failed to read the container log.")
+	} else {
+		logs = redact.redact(string(content))
+	}
+	log.Close()
+
+	if signal != "" {
+		ime.updateSignal(signal, commandRunID)
+	}
+	ime.updateStatus(status, logs, commandRunID)
+	if status == models.RunStatusSuccess {
+		ime.Logger.Info().Msg("Successfully finished command.")
+	}
+	return status, failure
+}
+
+// exitError is returned by a container which exited with a non-zero exit code.
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("status code: %d", e.code)
+}
+
+// stopContainer sends the stop signal to a container and kills it if it didn't exit within the grace period.
+// It returns the signal which stopped the container.
+func (ime *InMemoryExecutor) stopContainer(containerID string, policy runPolicy, exited <-chan error) string {
+	log := ime.Logger.With().Str("container_id", containerID).Str("signal", policy.stopSignal).Logger()
+	if policy.stopSignal != "SIGKILL" {
+		if err := ime.ContainerRuntime.Kill(context.Background(), containerID, policy.stopSignal); err != nil {
+			log.Debug().Err(err).Msg("Failed to send stop signal to container.")
+		} else {
+			select {
+			case <-exited:
+				return policy.stopSignal
+			case <-time.After(policy.stopGracePeriod):
+				log.Info().Dur("grace_period", policy.stopGracePeriod).Msg("Container did not stop within the grace period, killing it.")
 			}
-			return
 		}
 	}
+	if err := ime.ContainerRuntime.Kill(context.Background(), containerID, "SIGKILL"); err != nil {
+		// The container is removed forcefully once the outcome has been saved.
+		log.Error().Err(err).Msg("Failed to kill container.")
+		return "SIGKILL"
+	}
+	<-exited
+	return "SIGKILL"
 }
 
-// CancelRun will cancel a run and mark all commands as cancelled then remove the entry from the run map.
-// If the kill was unsuccessful, the user can try running it again.
+// Queue returns the command runs which wait for a free slot.
+func (ime *InMemoryExecutor) Queue(ctx context.Context) ([]*models.QueuedRun, error) {
+	return ime.queue.pending(), nil
+}
+
+// CancelRun cancels all commands of a run then removes the entry from the run map. Commands which wait
+// for their turn won't start anymore and running containers are stopped with the stop signal of their
+// command. The command runs are marked as cancelled once their containers stopped.
 func (ime *InMemoryExecutor) CancelRun(ctx context.Context, id int) error {
-	commands, ok := ime.runs.Load(id)
+	commands, ok := ime.runs.LoadAndDelete(id)
 	if !ok {
 		ime.Logger.Error().Int("id", id).Msg("Run with ID not found")
-		return errors.New("run with ID not found")
+		return fmt.Errorf("run with ID %d: %w", id, kerr.ErrNotFound)
 	}
-	killError := false
-	cli, err := client.NewClientWithOpts(client.FromEnv)
-	if err != nil {
-		ime.Logger.Debug().Err(err).Msg("Failed to create docker client.")
-		return err
-	}
-
 	commands.(*sync.Map).Range(func(key, value interface{}) bool {
-		if value.(string) == "" {
-			ime.Logger.Debug().Str("command_name", key.(string)).Msg("Command has no container running.")
-			return true
-		}
-		if err := cli.ContainerKill(context.Background(), value.(string), "SIGKILL"); err != nil {
-			ime.Logger.Err(err).Msg("Failed to kill container.")
-			killError = true
-		}
+		ime.Logger.Debug().Str("command_name", key.(string)).Msg("Cancelling command.")
+		value.(*runningCommand).cancel()
 		return true
 	})
-	if killError {
-		return errors.New("there was an error while cancelling running commands, " +
-			"please inspect the log for more details")
+	ime.Logger.Debug().Int("id", id).Msg("All commands successfully cancelled.")
+	return nil
+}
+
+// CancelCommandRun cancels a single command run. If the command still waits for its turn, it won't start
+// anymore, and a running container is stopped with the stop signal of the command. The command run is marked
+// as cancelled and the commands which depend on it are skipped.
+func (ime *InMemoryExecutor) CancelCommandRun(ctx context.Context, id int) error {
+	running, ok := ime.commandRuns.Load(id)
+	if !ok {
+		ime.Logger.Error().Int("command_run_id", id).Msg("Command run with ID not found")
+		return fmt.Errorf("command run with ID %d: %w", id, kerr.ErrNotFound)
 	}
-	ime.runs.Delete(id)
-	ime.Logger.Debug().Msg("All commands successfully cancelled.")
+	ime.Logger.Debug().Int("command_run_id", id).Str("command_name", running.(*runningCommand).commandName).Msg("Cancelling command.")
+	running.(*runningCommand).cancel()
 	return nil
 }
@@ -2,29 +2,34 @@ package executor
 
 import (
 	"context"
+	"errors"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
-	"github.com/docker/docker/client"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/krok/providers/containerruntime"
 	"github.com/krok-o/krok/pkg/krok/providers/mocks"
 	"github.com/krok-o/krok/pkg/models"
 )
 
 func TestInMemoryExecutor_CreateRun(t *testing.T) {
-	if _, err := client.NewClientWithOpts(client.FromEnv); err != nil {
-		t.Skip("Could not run test. This test requires Docker to be accessible.")
-	}
 	logger := zerolog.New(os.Stderr)
 	mcr := &mocks.CommandRunStorer{}
 	mcr.On("CreateRun", mock.Anything, &models.CommandRun{
 		EventID:     1,
 		CommandName: "test-command",
+		CommandID:   1,
+		Attempt:     1,
 		Status:      "created",
 		Outcome:     "",
 		CreateAt:    time.Date(1981, 1, 1, 1, 1, 1, 1, time.UTC),
@@ -40,6 +45,7 @@ func TestInMemoryExecutor_CreateRun(t *testing.T) {
 	mcs := &mocks.CommandStorer{}
 	mcs.On("IsPlatformSupported", mock.Anything, 1, 1).Return(true, nil)
 	mcs.On("ListSettings", mock.Anything, 1).Return(nil, nil)
+	mcs.On("GetCommandDependencies", mock.Anything, 1).Return(nil, nil)
 	mrs := &mocks.RepositoryStorer{}
 	mrs.On("Get", mock.Anything, 1).Return(&models.Repository{
 		ID: 1,
@@ -50,6 +56,10 @@ func TestInMemoryExecutor_CreateRun(t *testing.T) {
 	}, nil)
 	mt := &mocks.Clock{}
 	mt.On("Now").Return(time.Date(1981, 1, 1, 1, 1, 1, 1, time.UTC))
+	// The integration test command prints the arguments it received.
+	fake := containerruntime.NewFakeRuntime(map[string]containerruntime.FakeImage{
+		"krokhook/integration-test-command:v0.0.2": {Output: "platform: github,event-type: push,payload: e30=,repo-ssh-key: "},
+	})
 	ime := NewInMemoryExecutor(Config{
 		DefaultMaximumCommandRuntime: 10,
 		MaximumParallelCommands:      10,
@@ -59,6 +69,7 @@ func TestInMemoryExecutor_CreateRun(t *testing.T) {
 		CommandStorer:    mcs,
 		RepositoryStorer: mrs,
 		Clock:            mt,
+		ContainerRuntime: fake,
 	})
 	err := ime.CreateRun(context.Background(), &models.Event{
 		ID:           1,
@@ -93,12 +104,6 @@ func TestInMemoryExecutor_CreateRun(t *testing.T) {
 		},
 	})
 	assert.NoError(t, err)
-	empty := true
-	ime.runs.Range(func(key, value interface{}) bool {
-		empty = false
-		return false
-	})
-	assert.False(t, empty)
 	assert.Eventually(t, func() bool {
 		empty := true
 		ime.runs.Range(func(key, value interface{}) bool {
@@ -106,7 +111,12 @@ func TestInMemoryExecutor_CreateRun(t *testing.T) {
 			return false
 		})
 		return empty
-	}, 20*time.Second, 5*time.Second)
+	}, 5*time.Second, 10*time.Millisecond)
+	mcr.AssertExpectations(t)
+	created := fake.Created()
+	require.Len(t, created, 1)
+	assert.Equal(t, []string{"--platform=github", "--event-type=push", "--payload=e30="}, created[0].Args)
+	assert.Empty(t, fake.Containers())
 }
 
 func TestInMemoryExecutor_CancelRun_NonExistent(t *testing.T) {
@@ -124,7 +134,7 @@ func TestInMemoryExecutor_CancelRun_NonExistent(t *testing.T) {
 		Clock:         mt,
 	})
 	err := ime.CancelRun(context.Background(), 88)
-	assert.EqualError(t, err, "run with ID not found")
+	assert.ErrorIs(t, err, kerr.ErrNotFound)
 }
 
 func TestInMemoryExecutor_CancelRun_WithNoCommandsDeletesEventEntry(t *testing.T) {
@@ -149,14 +159,13 @@ func TestInMemoryExecutor_CancelRun_WithNoCommandsDeletesEventEntry(t *testing.T
 }
 
 func TestInMemoryExecutor_NormaliseRepositorySettings(t *testing.T) {
-	if _, err := client.NewClientWithOpts(client.FromEnv); err != nil {
-		t.Skip("Could not run test. This test requires Docker to be accessible.")
-	}
 	logger := zerolog.New(os.Stderr)
 	mcr := &mocks.CommandRunStorer{}
 	mcr.On("CreateRun", mock.Anything, &models.CommandRun{
 		EventID:     1,
 		CommandName: "test-command",
+		CommandID:   1,
+		Attempt:     1,
 		Status:      "created",
 		Outcome:     "",
 		CreateAt:    time.Date(1981, 1, 1, 1, 1, 1, 1, time.UTC),
@@ -168,31 +177,42 @@ func TestInMemoryExecutor_NormaliseRepositorySettings(t *testing.T) {
 		Outcome:     "",
 		CreateAt:    time.Date(1981, 1, 1, 1, 1, 1, 1, time.UTC),
 	}, nil)
-	mcr.On("UpdateRunStatus", mock.Anything, 1, "success", "\"platform: github,event-type: push,payload: e30=,repo-ssh-key: ssh-key\"").Return(nil)
+	// The ssh key printed by the command is masked.
+	mcr.On("UpdateRunStatus", mock.Anything, 1, "success", "\"platform: github,event-type: push,payload: e30=,repo-ssh-key: [redacted]\"").Return(nil)
 	mcs := &mocks.CommandStorer{}
 	mcs.On("IsPlatformSupported", mock.Anything, 1, 1).Return(true, nil)
 	mcs.On("ListSettings", mock.Anything, 1).Return(nil, nil)
+	mcs.On("GetCommandDependencies", mock.Anything, 1).Return(nil, nil)
 	mrs := &mocks.RepositoryStorer{}
 	mrs.On("Get", mock.Anything, 1).Return(&models.Repository{
 		ID: 1,
 		Auth: &models.Auth{
 			Secret: "secret",
-			SSH:    "ssh-key",
+			SSH:    "private-ssh-key",
 		},
 	}, nil)
 	mt := &mocks.Clock{}
 	mt.On("Now").Return(time.Date(1981, 1, 1, 1, 1, 1, 1, time.UTC))
+	// The integration test command prints the arguments it received.
+	fake := containerruntime.NewFakeRuntime(map[string]containerruntime.FakeImage{
+		"krokhook/integration-test-command:v0.0.2": {Output: "platform: github,event-type: push,payload: e30=,repo-ssh-key: private-ssh-key"},
+	})
+	secrets, err := ioutil.TempDir("", "TestInMemoryExecutor_NormaliseRepositorySettings")
+	require.NoError(t, err)
+	defer os.RemoveAll(secrets)
 	ime := NewInMemoryExecutor(Config{
 		DefaultMaximumCommandRuntime: 10,
 		MaximumParallelCommands:      10,
+		SecretsLocation:              secrets,
 	}, Dependencies{
 		Logger:           logger,
 		CommandRuns:      mcr,
 		CommandStorer:    mcs,
 		RepositoryStorer: mrs,
 		Clock:            mt,
+		ContainerRuntime: fake,
 	})
-	err := ime.CreateRun(context.Background(), &models.Event{
+	err = ime.CreateRun(context.Background(), &models.Event{
 		ID:           1,
 		EventID:      "id",
 		CreateAt:     time.Date(1981, 1, 1, 1, 1, 1, 1, time.UTC),
@@ -225,12 +245,6 @@ func TestInMemoryExecutor_NormaliseRepositorySettings(t *testing.T) {
 		},
 	})
 	assert.NoError(t, err)
-	empty := true
-	ime.runs.Range(func(key, value interface{}) bool {
-		empty = false
-		return false
-	})
-	assert.False(t, empty)
 	assert.Eventually(t, func() bool {
 		empty := true
 		ime.runs.Range(func(key, value interface{}) bool {
@@ -238,5 +252,274 @@ func TestInMemoryExecutor_NormaliseRepositorySettings(t *testing.T) {
 			return false
 		})
 		return empty
-	}, 20*time.Second, 5*time.Second)
+	}, 5*time.Second, 10*time.Millisecond)
+	mcr.AssertExpectations(t)
+	created := fake.Created()
+	require.Len(t, created, 1)
+	// The ssh key is only passed as a file.
+	assert.Equal(t, []string{"--platform=github", "--event-type=push", "--payload=e30=", "--repo-ssh-key-file=/krok/secrets/repo-ssh-key"}, created[0].Args)
+	assert.Equal(t, []providers.ContainerMount{{Source: filepath.Join(secrets, "1"), Target: "/krok/secrets", ReadOnly: true}}, created[0].Mounts)
+	assert.Empty(t, fake.Containers())
+	assert.NoDirExists(t, filepath.Join(secrets, "1"))
+}
+
+// newFakeRun creates an executor with a fake container runtime and the mocks for a run of a single command.
+func newFakeRun(t *testing.T, cfg Config, image containerruntime.FakeImage) (*InMemoryExecutor, *mocks.CommandRunStorer, *containerruntime.Fake) {
+	mcr := &mocks.CommandRunStorer{}
+	mcr.On("CreateRun", mock.Anything, mock.Anything).Return(&models.CommandRun{
+		ID:          1,
+		EventID:     1,
+		CommandName: "test-command",
+		Status:      "created",
+	}, nil)
+	mcs := &mocks.CommandStorer{}
+	mcs.On("IsPlatformSupported", mock.Anything, 1, 1).Return(true, nil)
+	mcs.On("ListSettings", mock.Anything, 1).Return(nil, nil)
+	mcs.On("GetCommandDependencies", mock.Anything, 1).Return(nil, nil)
+	mrs := &mocks.RepositoryStorer{}
+	mrs.On("Get", mock.Anything, 1).Return(&models.Repository{ID: 1}, nil)
+	mt := &mocks.Clock{}
+	mt.On("Now").Return(time.Date(1981, 1, 1, 1, 1, 1, 1, time.UTC))
+	fake := containerruntime.NewFakeRuntime(map[string]containerruntime.FakeImage{
+		"test-image": image,
+	})
+	ime := NewInMemoryExecutor(cfg, Dependencies{
+		Logger:           zerolog.New(os.Stderr),
+		CommandRuns:      mcr,
+		CommandStorer:    mcs,
+		RepositoryStorer: mrs,
+		Clock:            mt,
+		ContainerRuntime: fake,
+	})
+	return ime, mcr, fake
+}
+
+// startFakeRun runs a single command with the test image.
+func startFakeRun(t *testing.T, ime *InMemoryExecutor) {
+	startFakeCommand(t, ime, &models.Command{
+		Name:    "test-command",
+		ID:      1,
+		Image:   "test-image",
+		Enabled: true,
+	})
+}
+
+func startFakeCommand(t *testing.T, ime *InMemoryExecutor, command *models.Command) {
+	err := ime.CreateRun(context.Background(), &models.Event{
+		ID:           1,
+		RepositoryID: 1,
+		Payload:      "{}",
+		VCS:          models.GITHUB,
+		EventType:    "push",
+	}, []*models.Command{command})
+	require.NoError(t, err)
+}
+
+// statusUpdated returns a channel which is closed once the command run got the status and outcome.
+func statusUpdated(mcr *mocks.CommandRunStorer, status, outcome string) chan struct{} {
+	done := make(chan struct{})
+	mcr.On("UpdateRunStatus", mock.Anything, 1, status, outcome).Run(func(args mock.Arguments) {
+		close(done)
+	}).Return(nil).Once()
+	return done
+}
+
+// signalUpdated expects the command run to be stopped with the signal.
+func signalUpdated(mcr *mocks.CommandRunStorer, signal string) {
+	mcr.On("UpdateRunSignal", mock.Anything, 1, signal).Return(nil).Once()
+}
+
+func waitFor(t *testing.T, done chan struct{}) {
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("command run did not get the expected status")
+	}
+}
+
+func TestInMemoryExecutor_CreateRun_NonZeroExit(t *testing.T) {
+	ime, mcr, fake := newFakeRun(t, Config{
+		DefaultMaximumCommandRuntime: 10,
+		MaximumParallelCommands:      10,
+	}, containerruntime.FakeImage{ExitCode: 1, Output: "boom"})
+	done := statusUpdated(mcr, "failed", "\"boom\"")
+	startFakeRun(t, ime)
+	waitFor(t, done)
+	assert.Eventually(t, func() bool { return len(fake.Containers()) == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestInMemoryExecutor_CreateRun_PullFails(t *testing.T) {
+	ime, mcr, fake := newFakeRun(t, Config{
+		DefaultMaximumCommandRuntime: 10,
+		MaximumParallelCommands:      10,
+	}, containerruntime.FakeImage{PullError: errors.New("unauthorized")})
+	done := statusUpdated(mcr, "failed", "\"failed to pull image: unauthorized\"")
+	startFakeRun(t, ime)
+	waitFor(t, done)
+	assert.Empty(t, fake.Created())
+}
+
+func TestInMemoryExecutor_CreateRun_RetriesPullFailure(t *testing.T) {
+	ime, mcr, fake := newFakeRun(t, Config{
+		DefaultMaximumCommandRuntime: 10,
+		MaximumParallelCommands:      10,
+	}, containerruntime.FakeImage{PullError: errors.New("connection reset by peer")})
+	// every attempt gets its own command run.
+	mcr.ExpectedCalls = nil
+	attempt := func(n int) interface{} {
+		return mock.MatchedBy(func(run *models.CommandRun) bool { return run.Attempt == n })
+	}
+	mcr.On("CreateRun", mock.Anything, attempt(1)).Return(&models.CommandRun{ID: 1, EventID: 1, CommandName: "test-command", Attempt: 1, Status: "created"}, nil).Once()
+	mcr.On("CreateRun", mock.Anything, attempt(2)).Return(&models.CommandRun{ID: 2, EventID: 1, CommandName: "test-command", Attempt: 2, Status: "created"}, nil).Once()
+	statusUpdated(mcr, "failed", "\"failed to pull image: connection reset by peer\"")
+	done := make(chan struct{})
+	mcr.On("UpdateRunStatus", mock.Anything, 2, "failed", "\"failed to pull image: connection reset by peer\"").Run(func(args mock.Arguments) {
+		close(done)
+	}).Return(nil).Once()
+	startFakeCommand(t, ime, &models.Command{
+		Name:    "test-command",
+		ID:      1,
+		Image:   "test-image",
+		Enabled: true,
+		Retry: &models.RetryPolicy{
+			MaxAttempts: 2,
+			RetryOn:     []string{models.RetryOnPullFailure},
+		},
+	})
+	waitFor(t, done)
+	assert.Eventually(t, func() bool {
+		_, ok := ime.runs.Load(1)
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
+	mcr.AssertExpectations(t)
+	assert.Empty(t, fake.Created())
+}
+
+func TestInMemoryExecutor_CreateRun_Timeout(t *testing.T) {
+	ime, mcr, fake := newFakeRun(t, Config{
+		DefaultMaximumCommandRuntime: 1,
+		MaximumParallelCommands:      10,
+	}, containerruntime.FakeImage{Blocks: true, Output: "cleaning up"})
+	signalUpdated(mcr, "SIGTERM")
+	done := statusUpdated(mcr, "timed_out", "\"cleaning up\"")
+	startFakeRun(t, ime)
+	waitFor(t, done)
+	assert.Eventually(t, func() bool { return len(fake.Containers()) == 0 }, 5*time.Second, 10*time.Millisecond)
+	mcr.AssertExpectations(t)
+}
+
+func TestInMemoryExecutor_CreateRun_StopGracePeriod(t *testing.T) {
+	ime, mcr, fake := newFakeRun(t, Config{
+		DefaultMaximumCommandRuntime: 1,
+		MaximumParallelCommands:      10,
+	}, containerruntime.FakeImage{Blocks: true, TrapsSignals: true})
+	signalUpdated(mcr, "SIGKILL")
+	done := statusUpdated(mcr, "timed_out", "\"\"")
+	startFakeCommand(t, ime, &models.Command{
+		Name:            "test-command",
+		ID:              1,
+		Image:           "test-image",
+		Enabled:         true,
+		StopSignal:      "SIGINT",
+		StopGracePeriod: 1,
+	})
+	waitFor(t, done)
+	// The container ignored the stop signal, so it got killed after the grace period.
+	assert.Equal(t, []string{"SIGINT", "SIGKILL"}, fake.Signals("container-1"))
+	mcr.AssertExpectations(t)
+}
+
+func TestInMemoryExecutor_CreateRun_CommandLimits(t *testing.T) {
+	ime, mcr, fake := newFakeRun(t, Config{
+		DefaultMaximumCommandRuntime: 10,
+		MaximumParallelCommands:      10,
+	}, containerruntime.FakeImage{Blocks: true})
+	signalUpdated(mcr, "SIGTERM")
+	done := statusUpdated(mcr, "timed_out", "\"\"")
+	start := time.Now()
+	startFakeCommand(t, ime, &models.Command{
+		Name:        "test-command",
+		ID:          1,
+		Image:       "test-image",
+		Enabled:     true,
+		Timeout:     1,
+		CPUs:        0.5,
+		MemoryLimit: 1024,
+		PidsLimit:   10,
+		DiskLimit:   2048,
+	})
+	waitFor(t, done)
+	// The timeout of the command is used instead of the default one.
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+	created := fake.Created()
+	require.Len(t, created, 1)
+	assert.Equal(t, providers.ContainerLimits{CPUs: 0.5, Memory: 1024, Pids: 10, Disk: 2048}, created[0].Limits)
+}
+
+func TestConfig_CommandTimeout(t *testing.T) {
+	cfg := Config{DefaultMaximumCommandRuntime: 120}
+	assert.Equal(t, 120*time.Second, cfg.commandTimeout(nil))
+	assert.Equal(t, 120*time.Second, cfg.commandTimeout(&models.Command{}))
+	assert.Equal(t, 30*time.Second, cfg.commandTimeout(&models.Command{Timeout: 30}))
+	// The configured maximum caps the timeout of a command.
+	assert.Equal(t, 120*time.Second, cfg.commandTimeout(&models.Command{Timeout: 600}))
+}
+
+func TestConfig_RunPolicy(t *testing.T) {
+	cfg := Config{DefaultMaximumCommandRuntime: 120}
+	assert.Equal(t, runPolicy{
+		timeout:         120 * time.Second,
+		stopSignal:      "SIGTERM",
+		stopGracePeriod: 10 * time.Second,
+	}, cfg.runPolicy(nil))
+	assert.Equal(t, runPolicy{
+		timeout:         60 * time.Second,
+		stopSignal:      "SIGINT",
+		stopGracePeriod: 30 * time.Second,
+	}, cfg.runPolicy(&models.Command{Timeout: 60, StopSignal: "SIGINT", StopGracePeriod: 30}))
+}
+
+func TestInMemoryExecutor_CancelRun(t *testing.T) {
+	ime, mcr, fake := newFakeRun(t, Config{
+		DefaultMaximumCommandRuntime: 10,
+		MaximumParallelCommands:      10,
+	}, containerruntime.FakeImage{Blocks: true, Output: "working"})
+	signalUpdated(mcr, "SIGTERM")
+	done := statusUpdated(mcr, "cancelled", "\"working\"")
+	startFakeRun(t, ime)
+	var id string
+	require.Eventually(t, func() bool {
+		ids := fake.Containers()
+		if len(ids) != 1 {
+			return false
+		}
+		id = ids[0]
+		state, err := fake.State(context.Background(), id)
+		return err == nil && state == "running"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, ime.CancelRun(context.Background(), 1))
+	waitFor(t, done)
+	assert.Equal(t, []string{"SIGTERM"}, fake.Signals(id))
+	mcr.AssertExpectations(t)
+}
+
+func TestInMemoryExecutor_CancelCommandRun(t *testing.T) {
+	ime, mcr, fake := newFakeRun(t, Config{
+		DefaultMaximumCommandRuntime: 10,
+		MaximumParallelCommands:      10,
+	}, containerruntime.FakeImage{Blocks: true, Output: "working"})
+	signalUpdated(mcr, "SIGTERM")
+	done := statusUpdated(mcr, "cancelled", "\"working\"")
+	startFakeRun(t, ime)
+	require.Eventually(t, func() bool {
+		return len(fake.Containers()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, ime.CancelCommandRun(context.Background(), 1))
+	waitFor(t, done)
+	mcr.AssertExpectations(t)
+	assert.Eventually(t, func() bool {
+		_, tracked := ime.commandRuns.Load(1)
+		return !tracked
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.ErrorIs(t, ime.CancelCommandRun(context.Background(), 1), kerr.ErrNotFound)
 }
@@ -0,0 +1,165 @@
+package executor
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/krok-o/krok/pkg/models"
+)
+
+const (
+	// secretsLocation is where the secret files of a command are mounted into its container.
+	secretsLocation = "/krok/secrets"
+	// DefaultSecretsLocation is where the secret files of commands are written on the host. It's on tmpfs on
+	// most Linux distributions, so the secrets never touch a disk.
+	DefaultSecretsLocation = "/dev/shm/krok-secrets"
+)
+
+// commandInput is everything a command gets from Krok besides its image.
+type commandInput struct {
+	args []string
+	env  []string
+	// files are written into the secrets location and mounted into the container. The key is the name of the file.
+	files map[string]string
+	// secrets are the values which are masked in the output of the command.
+	secrets []string
+}
+
+// redactor returns the redactor which masks the secrets of the input.
+func (in commandInput) redactor() *redactor {
+	return newRedactor(in.secrets)
+}
+
+// add hands a setting to the command the way its delivery defines.
+func (in *commandInput) add(key, value, delivery string) {
+	switch delivery {
+	case models.DeliveryEnv:
+		in.env = append(in.env, fmt.Sprintf("%s=%s", envName(key), value))
+	case models.DeliveryFile:
+		if in.files == nil {
+			in.files = make(map[string]string)
+		}
+		in.files[key] = value
+		in.args = append(in.args, fmt.Sprintf("--%s-file=%s", key, path.Join(secretsLocation, key)))
+	default:
+		in.args = append(in.args, fmt.Sprintf("--%s=%s", key, value))
+	}
+}
+
+// envName returns the name of the environment variable of a setting, i.e.: slack-token becomes SLACK_TOKEN.
+func envName(key string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+}
+
+// buildInput constructs the input of a command with the settings of the command from the store.
+func (d *Dependencies) buildInput(ctx context.Context, platform models.Platform, event *models.Event, repository *models.Repository, c *models.Command) (commandInput, error) {
+	settings, err := d.CommandStorer.ListSettings(ctx, c.ID)
+	if err != nil {
+		return commandInput{}, err
+	}
+	return newCommandInput(platform, event, repository, c, settings), nil
+}
+
+// newCommandInput constructs the arguments, environment and secret files which are passed to the container of a
+// command. The secrets of the repository are always delivered as files. The values of the settings in the vault
+// and the credentials of the repository are masked in the output of the command.
+func newCommandInput(platform models.Platform, event *models.Event, repository *models.Repository, c *models.Command, settings []*models.CommandSetting) commandInput {
+	// We aren't going to save these because it could be things like tokens which are
+	// confidential. The platform must always be the first arg.
+	input := commandInput{
+		args: []string{
+			fmt.Sprintf("--platform=%s", platform.Name),
+			fmt.Sprintf("--event-type=%s", event.EventType),
+			fmt.Sprintf("--payload=%s", base64.StdEncoding.EncodeToString([]byte(event.Payload))),
+		},
+	}
+
+	for _, s := range settings {
+		input.add(s.Key, s.Value, s.DeliveryMode())
+		if s.InVault {
+			input.secrets = append(input.secrets, s.Value)
+		}
+	}
+
+	if c.RequiresClone && repository.Auth != nil {
+		if repository.Auth.SSH != "" {
+			input.add("repo-ssh-key", repository.Auth.SSH, models.DeliveryFile)
+		}
+		if repository.Auth.Username != "" {
+			input.add("repo-username", repository.Auth.Username, models.DeliveryArg)
+		}
+		if repository.Auth.Password != "" {
+			input.add("repo-password", repository.Auth.Password, models.DeliveryFile)
+		}
+		input.secrets = append(input.secrets, repository.Auth.SSH, repository.Auth.Username, repository.Auth.Password)
+	}
+	return input
+}
+
+// secretsFolder returns the folder on the host with the secret files of a command run.
+func (c Config) secretsFolder(commandRunID int) string {
+	location := c.SecretsLocation
+	if location == "" {
+		location = DefaultSecretsLocation
+	}
+	return filepath.Join(location, strconv.Itoa(commandRunID))
+}
+
+// writeSecrets writes the secret files of a command run into its own folder and returns the folder.
+// The folder and the files are only accessible by the user of the container.
+func (c Config) writeSecrets(commandRunID int, files map[string]string) (string, error) {
+	folder := c.secretsFolder(commandRunID)
+	if err := os.MkdirAll(filepath.Dir(folder), 0711); err != nil {
+		return "", fmt.Errorf("failed to create secrets location: %w", err)
+	}
+	// A retried attempt never reuses the folder, but one from before a restart might still be there.
+	if err := os.RemoveAll(folder); err != nil {
+		return "", err
+	}
+	if err := os.Mkdir(folder, 0700); err != nil {
+		return "", fmt.Errorf("failed to create secrets folder: %w", err)
+	}
+	if err := c.chownSecret(folder); err != nil {
+		_ = os.RemoveAll(folder)
+		return "", fmt.Errorf("failed to change owner of secrets folder: %w", err)
+	}
+	for name, value := range files {
+		file := filepath.Join(folder, name)
+		if err := ioutil.WriteFile(file, []byte(value), 0400); err != nil {
+			_ = os.RemoveAll(folder)
+			return "", fmt.Errorf("failed to write secret %s: %w", name, err)
+		}
+		if err := c.chownSecret(file); err != nil {
+			_ = os.RemoveAll(folder)
+			return "", fmt.Errorf("failed to change owner of secret %s: %w", name, err)
+		}
+	}
+	return folder, nil
+}
+
+// chownSecret hands a secret file or folder to the user and group of the container.
+func (c Config) chownSecret(name string) error {
+	if c.ContainerUID == 0 && c.ContainerGID == 0 {
+		return nil
+	}
+	uid, gid := -1, -1
+	if c.ContainerUID != 0 {
+		uid = c.ContainerUID
+	}
+	if c.ContainerGID != 0 {
+		gid = c.ContainerGID
+	}
+	return os.Chown(name, uid, gid)
+}
+
+// removeSecrets removes the secret files of a command run.
+func (c Config) removeSecrets(commandRunID int) error {
+	return os.RemoveAll(c.secretsFolder(commandRunID))
+}
@@ -0,0 +1,78 @@
+package executor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/krok-o/krok/pkg/models"
+)
+
+func TestCommandInput_Add(t *testing.T) {
+	var input commandInput
+	input.add("channel", "#general", models.DeliveryArg)
+	input.add("slack-token", "token", models.DeliveryEnv)
+	input.add("deploy.key", "key", models.DeliveryFile)
+	assert.Equal(t, []string{"--channel=#general", "--deploy.key-file=/krok/secrets/deploy.key"}, input.args)
+	assert.Equal(t, []string{"SLACK_TOKEN=token"}, input.env)
+	assert.Equal(t, map[string]string{"deploy.key": "key"}, input.files)
+}
+
+func TestConfig_WriteSecrets(t *testing.T) {
+	location, err := ioutil.TempDir("", "TestConfig_WriteSecrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(location)
+	cfg := Config{SecretsLocation: filepath.Join(location, "secrets")}
+
+	folder, err := cfg.writeSecrets(1, map[string]string{"token": "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(location, "secrets", "1"), folder)
+	content, err := ioutil.ReadFile(filepath.Join(folder, "token"))
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(content))
+	// Only the user of the container can read the secrets.
+	info, err := os.Stat(folder)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+	info, err = os.Stat(filepath.Join(folder, "token"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0400), info.Mode().Perm())
+
+	// Files of a previous attempt are replaced.
+	_, err = cfg.writeSecrets(1, map[string]string{"other": "secret"})
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(folder, "token"))
+	assert.FileExists(t, filepath.Join(folder, "other"))
+
+	require.NoError(t, cfg.removeSecrets(1))
+	assert.NoDirExists(t, folder)
+}
+
+func TestConfig_WriteSecrets_ContainerUser(t *testing.T) {
+	location, err := ioutil.TempDir("", "TestConfig_WriteSecrets_ContainerUser")
+	require.NoError(t, err)
+	defer os.RemoveAll(location)
+	// Only root can hand files to other users.
+	uid, gid := os.Getuid(), os.Getgid()
+	if uid == 0 {
+		uid, gid = 65534, 65534
+	}
+	cfg := Config{SecretsLocation: location, ContainerUID: uid, ContainerGID: gid}
+
+	folder, err := cfg.writeSecrets(1, map[string]string{"token": "secret"})
+	require.NoError(t, err)
+	for _, name := range []string{folder, filepath.Join(folder, "token")} {
+		info, err := os.Stat(name)
+		require.NoError(t, err)
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		require.True(t, ok)
+		assert.Equal(t, uint32(uid), stat.Uid)
+		assert.Equal(t, uint32(gid), stat.Gid)
+	}
+	require.NoError(t, cfg.removeSecrets(1))
+}
@@ -0,0 +1,735 @@
+package executor
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+const (
+	// eventIDLabel is the label of a Job which holds the ID of the event it runs a command for.
+	eventIDLabel = "krok.io/event-id"
+	// commandRunIDLabel is the label of a Job which holds the ID of the command run it belongs to.
+	commandRunIDLabel = "krok.io/command-run-id"
+	// commandContainerName is the name of the container which runs the command in the pod of a Job.
+	commandContainerName = "command"
+	// workspaceVolumeName is the name of the volume of the shared workspace.
+	workspaceVolumeName = "workspace"
+	// secretsVolumeName is the name of the volume with the secret files of a command.
+	secretsVolumeName = "secrets"
+	// kubernetesStopSignal is the signal the kubelet stops containers with. After the termination
+	// grace period of the pod, they are killed.
+	kubernetesStopSignal = "SIGTERM"
+)
+
+// KubernetesConfig defines configuration for the Kubernetes executor.
+type KubernetesConfig struct {
+	Config
+	// Namespace is where the Jobs of the commands are created.
+	Namespace string
+	// ServiceAccountName is the service account of the pods of the Jobs. If empty, the default is used.
+	ServiceAccountName string
+	// WorkspaceClaimName is the PersistentVolumeClaim which holds the workspaces. If it's empty, the
+	// commands don't get a shared workspace even if an ArtifactStorer is set.
+	WorkspaceClaimName string
+	// WorkspaceClaimPath is the path where the WorkspaceClaimName is mounted into Krok. The workspaces
+	// of the ArtifactStorer must be under this path.
+	WorkspaceClaimPath string
+}
+
+// KubernetesDependencies defines dependencies for the Kubernetes executor.
+type KubernetesDependencies struct {
+	Dependencies
+	Client kubernetes.Interface
+}
+
+// KubernetesExecutor defines an Executor which runs every command as a batch/v1 Job
+// in a Kubernetes cluster instead of talking to a local container runtime.
+// The Jobs are labeled with the event they belong to, so cancelling a run
+// works even if Krok restarted in the meantime.
+type KubernetesExecutor struct {
+	KubernetesConfig
+	KubernetesDependencies
+
+	// For each event, the function which cancels the commands of the run that are still waiting or running.
+	runs *sync.Map
+	// For the command run of the current attempt of every command which hasn't finished yet, the function
+	// which cancels the command.
+	commandRuns *sync.Map
+	queue       *runQueue
+	groups      *concurrencyGroups
+	// pollInterval is how often the pod of a Job is looked up before its logs can be followed.
+	pollInterval time.Duration
+}
+
+var _ providers.Executor = &KubernetesExecutor{}
+
+// NewKubernetesExecutor creates a new KubernetesExecutor.
+func NewKubernetesExecutor(cfg KubernetesConfig, deps KubernetesDependencies) *KubernetesExecutor {
+	if cfg.Namespace == "" {
+		cfg.Namespace = metav1.NamespaceDefault
+	}
+	return &KubernetesExecutor{
+		KubernetesConfig:       cfg,
+		KubernetesDependencies: deps,
+		runs:                   &sync.Map{},
+		commandRuns:            &sync.Map{},
+		queue:                  newRunQueue(cfg.MaximumParallelCommands, cfg.MaximumParallelCommandsPerRepository),
+		groups:                 newConcurrencyGroups(),
+		pollInterval:           time.Second,
+	}
+}
+
+// CreateRun creates a run for an event. Every command gets its own Job once its dependencies succeeded.
+func (ke *KubernetesExecutor) CreateRun(ctx context.Context, event *models.Event, commands []*models.Command) error {
+	log := ke.Logger.
+		With().
+		Int("event_id", event.ID).
+		Int("repository_id", event.RepositoryID).
+		Int("platform_id", event.VCS).
+		Int("commands", len(commands)).
+		Logger()
+
+	ordered, err := ke.planRun(ctx, log, event, commands)
+	if err != nil {
+		return err
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	ke.runs.Store(event.ID, cancel)
+	var wg sync.WaitGroup
+	for _, p := range ordered {
+		log.Debug().Str("image", p.command.Image).Msg("Preparing to run command...")
+		// Every command can be cancelled on its own by a newer run of its concurrency group.
+		commandCtx, cancelCommand := context.WithCancel(runCtx)
+		member := ke.groups.join(event, p.command, cancelCommand)
+		tracked := trackCancel(ke.commandRuns, p.commandRunID, cancelCommand)
+		wg.Add(1)
+		go func(p *plannedCommand) {
+			defer wg.Done()
+			defer cancelCommand()
+			defer ke.groups.leave(member)
+			defer tracked.untrack()
+			ke.runPlannedCommand(commandCtx, p, event, member, tracked)
+		}(p)
+	}
+	go func() {
+		wg.Wait()
+		ke.runs.Delete(event.ID)
+		cancel()
+	}()
+	return nil
+}
+
+// runPlannedCommand waits for the dependencies of a command to finish and runs its Job if all of them
+// succeeded. Otherwise, the command is skipped. If the command is part of a concurrency group, it also
+// waits for the older runs of the group.
+func (ke *KubernetesExecutor) runPlannedCommand(ctx context.Context, p *plannedCommand, event *models.Event, member *groupMember, tracked *trackedCancel) {
+	defer close(p.done)
+	if parent := p.failedParent(); parent != nil {
+		ke.Logger.Info().Str("command", p.command.Name).Str("dependency", parent.command.Name).Msg("Skipping command as a dependency did not succeed.")
+		ke.updateStatus(models.RunStatusSkipped, fmt.Sprintf("dependency %s did not succeed", parent.command.Name), p.commandRunID)
+		return
+	}
+	if err := member.waitForOlder(ctx); err != nil {
+		status, outcome := failedStatus(ctx, err)
+		ke.updateStatus(status, outcome, p.commandRunID)
+		return
+	}
+	// Every attempt gets its own command run and with that, its own Job. The command is cancelled through the
+	// command run of its current attempt.
+	p.succeeded = ke.runAttempts(ctx, p.command, event.ID, p.commandRunID, 1, func(commandRunID int) (string, string) {
+		p.commandRunID = commandRunID
+		return ke.runJob(ctx, p, event)
+	}, tracked.retrack) == models.RunStatusSuccess
+}
+
+// runJob creates the Job of a command and waits for it to finish. It returns the final status of the command run
+// and the failure a retry policy can cover, if any.
+func (ke *KubernetesExecutor) runJob(ctx context.Context, p *plannedCommand, event *models.Event) (string, string) {
+	log := ke.Logger.With().Str("command", p.command.Name).Int("command_run_id", p.commandRunID).Logger()
+	slot := newQueuedRun(p.command, p.command.Name, event.ID, event.RepositoryID, p.commandRunID)
+	if err := ke.queue.acquire(ctx, slot); err != nil {
+		status, outcome := failedStatus(ctx, err)
+		ke.updateStatus(status, outcome, p.commandRunID)
+		log.Debug().Err(err).Msg("Failed to get a slot in the run queue.")
+		return status, ""
+	}
+	defer ke.queue.release(slot)
+
+	job, err := ke.job(ctx, p, event.ID)
+	if err != nil {
+		ke.updateStatus(models.RunStatusFailed, err.Error(), p.commandRunID)
+		log.Debug().Err(err).Msg("Failed to construct job.")
+		return models.RunStatusFailed, ""
+	}
+	if secret := jobSecret(job, p.input); secret != nil {
+		if _, err := ke.Client.CoreV1().Secrets(ke.Namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+			status, outcome := failedStatus(ctx, fmt.Errorf("failed to create secret: %w", err))
+			ke.updateStatus(status, outcome, p.commandRunID)
+			log.Debug().Err(err).Msg("Failed to create secret.")
+			return status, ""
+		}
+		defer ke.deleteSecret(secret.Name)
+	}
+	credential, err := ke.registryCredential(ctx, p.command)
+	if err != nil {
+		ke.updateStatus(models.RunStatusFailed, err.Error(), p.commandRunID)
+		log.Debug().Err(err).Msg("Failed to get registry credential.")
+		return models.RunStatusFailed, ""
+	}
+	if credential != nil {
+		secret, err := registrySecret(job, credential)
+		if err != nil {
+			ke.updateStatus(models.RunStatusFailed, err.Error(), p.commandRunID)
+			log.Debug().Err(err).Msg("Failed to construct registry secret.")
+			return models.RunStatusFailed, ""
+		}
+		if _, err := ke.Client.CoreV1().Secrets(ke.Namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+			status, outcome := failedStatus(ctx, fmt.Errorf("failed to create registry secret: %w", err))
+			ke.updateStatus(status, outcome, p.commandRunID)
+			log.Debug().Err(err).Msg("Failed to create registry secret.")
+			return status, ""
+		}
+		defer ke.deleteSecret(secret.Name)
+		job.Spec.Template.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: secret.Name}}
+	}
+	log.Info().Str("job", job.Name).Msg("Creating job...")
+	jobs := ke.Client.BatchV1().Jobs(ke.Namespace)
+	job, err = jobs.Create(ctx, job, metav1.CreateOptions{})
+	if err != nil {
+		status, outcome := failedStatus(ctx, fmt.Errorf("failed to create job: %w", err))
+		ke.updateStatus(status, outcome, p.commandRunID)
+		log.Debug().Err(err).Msg("Failed to create job.")
+		return status, ""
+	}
+	// The name of the Job takes the place of the container for the command run.
+	if err := ke.CommandRuns.UpdateRunContainer(context.Background(), p.commandRunID, job.Name); err != nil {
+		log.Debug().Err(err).Str("job", job.Name).Msg("Failed to save job name of command run.")
+	}
+	if err := ke.CommandRuns.UpdateRunStatus(context.Background(), p.commandRunID, models.RunStatusRunning, ""); err != nil {
+		log.Debug().Err(err).Msg("Updating status of command failed.")
+	}
+	defer ke.deleteJob(job.Name)
+
+	if ke.LogStreamer != nil {
+		stream := p.input.redactor().writer(ke.LogStreamer.Open(p.commandRunID))
+		defer stream.Close()
+		followCtx, stop := context.WithCancel(ctx)
+		defer stop()
+		go ke.followLogs(followCtx, job.Name, stream)
+	}
+	return ke.waitForJob(ctx, job.Name, p.commandRunID, ke.commandTimeout(p.command), p.input.redactor())
+}
+
+// job constructs the Job which runs a command.
+func (ke *KubernetesExecutor) job(ctx context.Context, p *plannedCommand, eventID int) (*batchv1.Job, error) {
+	var backoffLimit int32
+	container := corev1.Container{
+		Name:            commandContainerName,
+		Image:           p.command.Image,
+		ImagePullPolicy: kubernetesPullPolicy(p.command.PullPolicy),
+		Args:            p.input.args,
+		Resources:       jobResources(containerLimits(p.command)),
+	}
+	name := fmt.Sprintf("krok-%d-%d", eventID, p.commandRunID)
+	if p.command.PidsLimit > 0 {
+		ke.Logger.Warn().Str("command", p.command.Name).Msg("The pids limit can only be set for a whole node in Kubernetes and is ignored.")
+	}
+	policy := ke.runPolicy(p.command)
+	if policy.stopSignal != kubernetesStopSignal {
+		ke.Logger.Warn().Str("command", p.command.Name).Str("signal", policy.stopSignal).Msg("Kubernetes always stops containers with SIGTERM, the stop signal is ignored.")
+	}
+	gracePeriod := int64(policy.stopGracePeriod.Seconds())
+	podSpec := corev1.PodSpec{
+		RestartPolicy:                 corev1.RestartPolicyNever,
+		ServiceAccountName:            ke.ServiceAccountName,
+		TerminationGracePeriodSeconds: &gracePeriod,
+	}
+	if ke.ArtifactStorer != nil && ke.WorkspaceClaimName != "" {
+		workspace, err := ke.ArtifactStorer.Workspace(ctx, eventID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create workspace: %w", err)
+		}
+		subPath, err := filepath.Rel(ke.WorkspaceClaimPath, workspace)
+		if err != nil || strings.HasPrefix(subPath, "..") {
+			return nil, fmt.Errorf("workspace %s is not on the workspace claim mounted at %s", workspace, ke.WorkspaceClaimPath)
+		}
+		container.Env = append(container.Env, corev1.EnvVar{Name: workspaceEnv, Value: workspaceLocation})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      workspaceVolumeName,
+			MountPath: workspaceLocation,
+			SubPath:   subPath,
+		})
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: workspaceVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: ke.WorkspaceClaimName,
+				},
+			},
+		})
+	}
+	// The settings which aren't arguments are in the Secret of the Job, which has the same name.
+	for _, env := range p.input.env {
+		key := strings.SplitN(env, "=", 2)[0]
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name: key,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: name},
+					Key:                  envSecretKey(key),
+				},
+			},
+		})
+	}
+	if len(p.input.files) > 0 {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      secretsVolumeName,
+			MountPath: secretsLocation,
+			ReadOnly:  true,
+		})
+		mode := int32(0444)
+		var items []corev1.KeyToPath
+		for file := range p.input.files {
+			items = append(items, corev1.KeyToPath{Key: fileSecretKey(file), Path: file})
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].Path < items[j].Path })
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: secretsVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  name,
+					Items:       items,
+					DefaultMode: &mode,
+				},
+			},
+		})
+	}
+	podSpec.Containers = []corev1.Container{container}
+	labels := map[string]string{
+		eventIDLabel:      strconv.Itoa(eventID),
+		commandRunIDLabel: strconv.Itoa(p.commandRunID),
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ke.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			// Commands are not retried by Kubernetes.
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: podSpec,
+			},
+		},
+	}
+	if timeout := ke.commandTimeout(p.command); timeout > 0 {
+		deadline := int64(timeout.Seconds())
+		job.Spec.ActiveDeadlineSeconds = &deadline
+	}
+	return job, nil
+}
+
+// jobSecret constructs the Secret with the settings of a command which are delivered as environment variables
+// or files. It returns nil if there are none.
+func jobSecret(job *batchv1.Job, input commandInput) *corev1.Secret {
+	if len(input.env) == 0 && len(input.files) == 0 {
+		return nil
+	}
+	data := make(map[string][]byte, len(input.env)+len(input.files))
+	for _, env := range input.env {
+		kv := strings.SplitN(env, "=", 2)
+		data[envSecretKey(kv[0])] = []byte(kv[1])
+	}
+	for file, value := range input.files {
+		data[fileSecretKey(file)] = []byte(value)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      job.Name,
+			Namespace: job.Namespace,
+			Labels:    job.Labels,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+}
+
+// registrySecret constructs the Secret the image of a Job is pulled with.
+func registrySecret(job *batchv1.Job, credential *models.RegistryCredential) (*corev1.Secret, error) {
+	host := credential.Host
+	// The kubelet only matches images without a registry with the legacy key of Docker Hub.
+	if host == "docker.io" {
+		host = "https://index.docker.io/v1/"
+	}
+	auth := base64.StdEncoding.EncodeToString([]byte(credential.Username + ":" + credential.Password))
+	config, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			host: map[string]string{
+				"username": credential.Username,
+				"password": credential.Password,
+				"auth":     auth,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode registry credential: %w", err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      registrySecretName(job.Name),
+			Namespace: job.Namespace,
+			Labels:    job.Labels,
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{corev1.DockerConfigJsonKey: config},
+	}, nil
+}
+
+// registrySecretName is the name of the Secret a Job pulls its image with.
+func registrySecretName(job string) string {
+	return job + "-registry"
+}
+
+// kubernetesPullPolicy returns the image pull policy of a container for the pull policy of a command.
+// Without a policy, the default of Kubernetes applies.
+func kubernetesPullPolicy(policy string) corev1.PullPolicy {
+	switch policy {
+	case models.PullPolicyAlways:
+		return corev1.PullAlways
+	case models.PullPolicyIfNotPresent:
+		return corev1.PullIfNotPresent
+	case models.PullPolicyNever:
+		return corev1.PullNever
+	}
+	return ""
+}
+
+// envSecretKey is the key of the Secret of a Job which holds the value of an environment variable.
+func envSecretKey(name string) string {
+	return "env." + name
+}
+
+// fileSecretKey is the key of the Secret of a Job which holds the content of a secret file.
+func fileSecretKey(name string) string {
+	return "file." + name
+}
+
+// jobResources converts the limits of a command into the resource limits of its Job container.
+func jobResources(limits providers.ContainerLimits) corev1.ResourceRequirements {
+	resources := corev1.ResourceList{}
+	if limits.CPUs > 0 {
+		resources[corev1.ResourceCPU] = *resource.NewMilliQuantity(int64(limits.CPUs*1000), resource.DecimalSI)
+	}
+	if limits.Memory > 0 {
+		resources[corev1.ResourceMemory] = *resource.NewQuantity(limits.Memory, resource.BinarySI)
+	}
+	if limits.Disk > 0 {
+		resources[corev1.ResourceEphemeralStorage] = *resource.NewQuantity(limits.Disk, resource.BinarySI)
+	}
+	if len(resources) == 0 {
+		return corev1.ResourceRequirements{}
+	}
+	return corev1.ResourceRequirements{Limits: resources}
+}
+
+// waitForJob watches a Job until it finished, timed out or the run got cancelled and saves the outcome with the
+// secrets known to the redactor masked. It returns the final status of the command run and the failure a retry policy can cover, if any.
+func (ke *KubernetesExecutor) waitForJob(ctx context.Context, name string, commandRunID int, runtime time.Duration, redact *redactor) (string, string) {
+	log := ke.Logger.With().Str("job", name).Int("command_run_id", commandRunID).Logger()
+	timeout := time.After(runtime)
+	jobs := ke.Client.BatchV1().Jobs(ke.Namespace)
+	for {
+		w, err := jobs.Watch(context.Background(), metav1.ListOptions{
+			FieldSelector: "metadata.name=" + name,
+		})
+		if err != nil {
+			ke.updateStatus(models.RunStatusFailed, fmt.Sprintf("failed to watch job: %s", err), commandRunID)
+			log.Debug().Err(err).Msg("Failed to watch job.")
+			return models.RunStatusFailed, ""
+		}
+		// The job might have finished before the watch was set up.
+		if job, err := jobs.Get(context.Background(), name, metav1.GetOptions{}); err == nil {
+			if status, failure, finished := ke.jobFinished(job, commandRunID, redact); finished {
+				w.Stop()
+				return status, failure
+			}
+		}
+		status, failure, finished := ke.watchJob(ctx, w, name, commandRunID, timeout, redact)
+		w.Stop()
+		if finished {
+			return status, failure
+		}
+		log.Debug().Msg("Watch of job closed, watching it again.")
+	}
+}
+
+// watchJob handles the events of a Job watch. It returns false if the watch closed before the Job finished.
+func (ke *KubernetesExecutor) watchJob(ctx context.Context, w watch.Interface, name string, commandRunID int, timeout <-chan time.Time, redact *redactor) (string, string, bool) {
+	for {
+		select {
+		case e, ok := <-w.ResultChan():
+			if !ok {
+				return "", "", false
+			}
+			job, ok := e.Object.(*batchv1.Job)
+			if !ok || job.Name != name {
+				continue
+			}
+			if e.Type == watch.Deleted {
+				ke.updateSignal(kubernetesStopSignal, commandRunID)
+				ke.updateStatus(models.RunStatusCancelled, "cancelled", commandRunID)
+				ke.Logger.Info().Str("job", name).Msg("Job of command was deleted.")
+				return models.RunStatusCancelled, "", true
+			}
+			if status, failure, finished := ke.jobFinished(job, commandRunID, redact); finished {
+				return status, failure, true
+			}
+		case <-ctx.Done():
+			// The Job is deleted and its pod stopped once the outcome has been saved.
+			ke.updateSignal(kubernetesStopSignal, commandRunID)
+			ke.updateStatus(models.RunStatusCancelled, "cancelled", commandRunID)
+			ke.Logger.Info().Str("job", name).Msg("Command was cancelled.")
+			return models.RunStatusCancelled, "", true
+		case <-timeout:
+			ke.updateSignal(kubernetesStopSignal, commandRunID)
+			ke.updateStatus(models.RunStatusTimedOut, "timeout", commandRunID)
+			ke.Logger.Error().Str("job", name).Msg("Command timed out.")
+			return models.RunStatusTimedOut, models.RetryOnTimeout, true
+		}
+	}
+}
+
+// jobFinished saves the outcome of a Job if it has finished. The outcome is the log of its pod.
+// It also returns the failure of the Job a retry policy can cover, if any.
+func (ke *KubernetesExecutor) jobFinished(job *batchv1.Job, commandRunID int, redact *redactor) (string, string, bool) {
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			ke.updateStatus(models.RunStatusSuccess, ke.jobLogs(job.Name, redact), commandRunID)
+			ke.Logger.Info().Str("job", job.Name).Msg("Successfully finished command.")
+			return models.RunStatusSuccess, "", true
+		case batchv1.JobFailed:
+			if c.Reason == "DeadlineExceeded" {
+				ke.updateSignal(kubernetesStopSignal, commandRunID)
+				ke.updateStatus(models.RunStatusTimedOut, ke.jobLogs(job.Name, redact), commandRunID)
+				ke.Logger.Error().Str("job", job.Name).Msg("Command timed out.")
+				return models.RunStatusTimedOut, models.RetryOnTimeout, true
+			}
+			ke.updateStatus(models.RunStatusFailed, ke.jobLogs(job.Name, redact), commandRunID)
+			ke.Logger.Debug().Str("job", job.Name).Str("reason", c.Reason).Str("message", c.Message).Msg("Failed to run command.")
+			return models.RunStatusFailed, models.RetryOnNonZeroExit, true
+		}
+	}
+	return "", "", false
+}
+
+// jobPod returns the pod which was created for a Job.
+func (ke *KubernetesExecutor) jobPod(ctx context.Context, name string) (*corev1.Pod, error) {
+	pods, err := ke.Client.CoreV1().Pods(ke.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "job-name=" + name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(pods.Items) == 0 {
+		return nil, fmt.Errorf("no pod found for job %s", name)
+	}
+	return &pods.Items[0], nil
+}
+
+// jobLogs returns the logs of the pod of a finished Job with the secrets known to the redactor masked.
+func (ke *KubernetesExecutor) jobLogs(name string, redact *redactor) string {
+	pod, err := ke.jobPod(context.Background(), name)
+	if err != nil {
+		ke.Logger.Debug().Err(err).Str("job", name).Msg("Failed to find pod of job.")
+		return "no logs available"
+	}
+	logs, err := ke.Client.CoreV1().Pods(ke.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container: commandContainerName,
+	}).DoRaw(context.Background())
+	if err != nil {
+		ke.Logger.Debug().Err(err).Str("pod", pod.Name).Msg("Failed to get logs of pod.")
+		return "no logs available"
+	}
+	return redact.redact(string(logs))
+}
+
+// followLogs waits for the pod of a Job to start and copies its logs into the writer until it stops.
+func (ke *KubernetesExecutor) followLogs(ctx context.Context, name string, w io.Writer) {
+	ticker := time.NewTicker(ke.pollInterval)
+	defer ticker.Stop()
+	for {
+		pod, err := ke.jobPod(ctx, name)
+		if err == nil && pod.Status.Phase != corev1.PodPending {
+			logs, err := ke.Client.CoreV1().Pods(ke.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+				Container: commandContainerName,
+				Follow:    true,
+			}).Stream(ctx)
+			if err != nil {
+				ke.Logger.Debug().Err(err).Str("pod", pod.Name).Msg("Failed to follow pod logs.")
+				return
+			}
+			defer logs.Close()
+			if _, err := io.Copy(w, logs); err != nil && ctx.Err() == nil {
+				ke.Logger.Debug().Err(err).Str("pod", pod.Name).Msg("Failed to copy pod logs.")
+			}
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deleteJob deletes a finished Job together with its pods.
+func (ke *KubernetesExecutor) deleteJob(name string) {
+	propagation := metav1.DeletePropagationBackground
+	if err := ke.Client.BatchV1().Jobs(ke.Namespace).Delete(context.Background(), name, metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+	}); err != nil && !apierrors.IsNotFound(err) {
+		ke.Logger.Debug().Err(err).Str("job", name).Msg("Failed to delete job.")
+	}
+}
+
+// deleteSecret deletes the Secret of a finished Job.
+func (ke *KubernetesExecutor) deleteSecret(name string) {
+	if err := ke.Client.CoreV1().Secrets(ke.Namespace).Delete(context.Background(), name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		ke.Logger.Debug().Err(err).Str("secret", name).Msg("Failed to delete secret.")
+	}
+}
+
+// failedStatus returns the status and outcome of a command run which failed with err, taking into
+// account that the run might have been cancelled.
+func failedStatus(ctx context.Context, err error) (string, string) {
+	if ctx.Err() != nil {
+		return models.RunStatusCancelled, "cancelled"
+	}
+	return models.RunStatusFailed, err.Error()
+}
+
+// Queue returns the command runs which wait for a free slot before their Job is created.
+func (ke *KubernetesExecutor) Queue(ctx context.Context) ([]*models.QueuedRun, error) {
+	return ke.queue.pending(), nil
+}
+
+// CancelRun will cancel a run by stopping the commands which are still waiting and deleting all
+// the Jobs of the run. The command runs of the deleted Jobs are marked as cancelled.
+func (ke *KubernetesExecutor) CancelRun(ctx context.Context, id int) error {
+	log := ke.Logger.With().Int("id", id).Logger()
+	cancel, tracked := ke.runs.LoadAndDelete(id)
+	if tracked {
+		cancel.(context.CancelFunc)()
+	}
+
+	jobs := ke.Client.BatchV1().Jobs(ke.Namespace)
+	list, err := jobs.List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%d", eventIDLabel, id),
+	})
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to list jobs of run.")
+		return err
+	}
+	if !tracked && len(list.Items) == 0 {
+		log.Error().Msg("Run with ID not found")
+		return fmt.Errorf("run with ID %d: %w", id, kerr.ErrNotFound)
+	}
+
+	deleteError := false
+	propagation := metav1.DeletePropagationBackground
+	for _, job := range list.Items {
+		if err := jobs.Delete(ctx, job.Name, metav1.DeleteOptions{
+			PropagationPolicy: &propagation,
+		}); err != nil && !apierrors.IsNotFound(err) {
+			log.Err(err).Str("job", job.Name).Msg("Failed to delete job.")
+			deleteError = true
+			continue
+		}
+		// Nobody watches the Jobs of runs from before a restart, so mark them here.
+		if !tracked {
+			ke.deleteSecret(job.Name)
+			ke.deleteSecret(registrySecretName(job.Name))
+			if commandRunID, err := strconv.Atoi(job.Labels[commandRunIDLabel]); err == nil {
+				ke.updateSignal(kubernetesStopSignal, commandRunID)
+				ke.updateStatus(models.RunStatusCancelled, "cancelled", commandRunID)
+			}
+		}
+	}
+	if deleteError {
+		return errors.New("there was an error while cancelling running commands, " +
+			"please inspect the log for more details")
+	}
+	log.Debug().Msg("All commands successfully cancelled.")
+	return nil
+}
+
+// CancelCommandRun cancels a single command run. A command which still waits won't start anymore and the Job
+// of a running command is deleted. If the command run isn't tracked because Krok restarted in the meantime,
+// its Job is looked up by its label, deleted and the command run is marked as cancelled.
+func (ke *KubernetesExecutor) CancelCommandRun(ctx context.Context, id int) error {
+	log := ke.Logger.With().Int("command_run_id", id).Logger()
+	if cancel, ok := ke.commandRuns.Load(id); ok {
+		log.Debug().Msg("Cancelling command.")
+		cancel.(context.CancelFunc)()
+		return nil
+	}
+
+	jobs := ke.Client.BatchV1().Jobs(ke.Namespace)
+	list, err := jobs.List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%d", commandRunIDLabel, id),
+	})
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to list jobs of command run.")
+		return err
+	}
+	if len(list.Items) == 0 {
+		log.Error().Msg("Command run with ID not found")
+		return fmt.Errorf("command run with ID %d: %w", id, kerr.ErrNotFound)
+	}
+	propagation := metav1.DeletePropagationBackground
+	for _, job := range list.Items {
+		if err := jobs.Delete(ctx, job.Name, metav1.DeleteOptions{
+			PropagationPolicy: &propagation,
+		}); err != nil && !apierrors.IsNotFound(err) {
+			log.Debug().Err(err).Str("job", job.Name).Msg("Failed to delete job.")
+			return err
+		}
+		ke.deleteSecret(job.Name)
+		ke.deleteSecret(registrySecretName(job.Name))
+	}
+	ke.updateSignal(kubernetesStopSignal, id)
+	ke.updateStatus(models.RunStatusCancelled, "cancelled", id)
+	log.Debug().Msg("Command successfully cancelled.")
+	return nil
+}
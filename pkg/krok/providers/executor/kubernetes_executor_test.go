@@ -0,0 +1,398 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers/mocks"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+func newKubernetesExecutor(t *testing.T, mcr *mocks.CommandRunStorer) (*KubernetesExecutor, *fake.Clientset) {
+	mcs := &mocks.CommandStorer{}
+	mcs.On("IsPlatformSupported", mock.Anything, 1, 1).Return(true, nil)
+	mcs.On("ListSettings", mock.Anything, 1).Return(nil, nil)
+	mcs.On("GetCommandDependencies", mock.Anything, 1).Return(nil, nil)
+	mrs := &mocks.RepositoryStorer{}
+	mrs.On("Get", mock.Anything, 1).Return(&models.Repository{ID: 1}, nil)
+	mt := &mocks.Clock{}
+	mt.On("Now").Return(time.Date(1981, 1, 1, 1, 1, 1, 1, time.UTC))
+	client := fake.NewSimpleClientset()
+	ke := NewKubernetesExecutor(KubernetesConfig{
+		Config: Config{
+			DefaultMaximumCommandRuntime: 10,
+			MaximumParallelCommands:      10,
+		},
+		Namespace: "krok",
+	}, KubernetesDependencies{
+		Dependencies: Dependencies{
+			Logger:           zerolog.New(os.Stderr),
+			CommandRuns:      mcr,
+			CommandStorer:    mcs,
+			RepositoryStorer: mrs,
+			Clock:            mt,
+		},
+		Client: client,
+	})
+	return ke, client
+}
+
+func newCommandRunStorer() *mocks.CommandRunStorer {
+	mcr := &mocks.CommandRunStorer{}
+	mcr.On("CreateRun", mock.Anything, mock.Anything).Return(&models.CommandRun{
+		ID:          1,
+		EventID:     1,
+		CommandName: "test-command",
+		Status:      "created",
+	}, nil)
+	mcr.On("UpdateRunContainer", mock.Anything, 1, "krok-1-1").Return(nil)
+	mcr.On("UpdateRunStatus", mock.Anything, 1, "running", "").Return(nil)
+	return mcr
+}
+
+func createTestRun(t *testing.T, ke *KubernetesExecutor) {
+	err := ke.CreateRun(context.Background(), &models.Event{
+		ID:           1,
+		RepositoryID: 1,
+		Payload:      "{}",
+		VCS:          models.GITHUB,
+		EventType:    "push",
+	}, []*models.Command{
+		{
+			Name:    "test-command",
+			ID:      1,
+			Image:   "krokhook/slack-notification:v0.0.1",
+			Enabled: true,
+		},
+	})
+	require.NoError(t, err)
+}
+
+// waitForJob waits until the job of the test run has been created.
+func waitForJob(t *testing.T, client *fake.Clientset) *batchv1.Job {
+	var job *batchv1.Job
+	require.Eventually(t, func() bool {
+		j, err := client.BatchV1().Jobs("krok").Get(context.Background(), "krok-1-1", metav1.GetOptions{})
+		if err != nil {
+			return false
+		}
+		job = j
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+// finishJob creates the pod of a job and sets the final condition of the job.
+func finishJob(t *testing.T, client *fake.Clientset, job *batchv1.Job, condition batchv1.JobConditionType, reason string) {
+	_, err := client.CoreV1().Pods("krok").Create(context.Background(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      job.Name + "-abcde",
+			Namespace: "krok",
+			Labels:    map[string]string{"job-name": job.Name},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodSucceeded},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+		Type:   condition,
+		Status: corev1.ConditionTrue,
+		Reason: reason,
+	})
+	_, err = client.BatchV1().Jobs("krok").UpdateStatus(context.Background(), job, metav1.UpdateOptions{})
+	require.NoError(t, err)
+}
+
+func TestKubernetesExecutor_CreateRun(t *testing.T) {
+	mcr := newCommandRunStorer()
+	done := make(chan struct{})
+	mcr.On("UpdateRunStatus", mock.Anything, 1, "success", "\"fake logs\"").Run(func(args mock.Arguments) {
+		close(done)
+	}).Return(nil)
+	ke, client := newKubernetesExecutor(t, mcr)
+	createTestRun(t, ke)
+
+	job := waitForJob(t, client)
+	assert.Equal(t, map[string]string{eventIDLabel: "1", commandRunIDLabel: "1"}, job.Labels)
+	assert.Equal(t, int32(0), *job.Spec.BackoffLimit)
+	assert.Equal(t, int64(10), *job.Spec.ActiveDeadlineSeconds)
+	containers := job.Spec.Template.Spec.Containers
+	require.Len(t, containers, 1)
+	assert.Equal(t, "krokhook/slack-notification:v0.0.1", containers[0].Image)
+	assert.Equal(t, []string{"--platform=github", "--event-type=push", "--payload=e30="}, containers[0].Args)
+	assert.Equal(t, corev1.RestartPolicyNever, job.Spec.Template.Spec.RestartPolicy)
+	assert.Equal(t, int64(10), *job.Spec.Template.Spec.TerminationGracePeriodSeconds)
+
+	finishJob(t, client, job, batchv1.JobComplete, "")
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("command run was not marked as successful")
+	}
+	// The finished job is cleaned up.
+	assert.Eventually(t, func() bool {
+		jobs, err := client.BatchV1().Jobs("krok").List(context.Background(), metav1.ListOptions{})
+		return err == nil && len(jobs.Items) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	mcr.AssertExpectations(t)
+}
+
+func TestKubernetesExecutor_Job_CommandLimits(t *testing.T) {
+	ke, _ := newKubernetesExecutor(t, &mocks.CommandRunStorer{})
+	job, err := ke.job(context.Background(), &plannedCommand{
+		command: &models.Command{
+			Name:        "test-command",
+			Image:       "krokhook/slack-notification:v0.0.1",
+			Timeout:     5,
+			CPUs:        0.5,
+			MemoryLimit: 256 * 1024 * 1024,
+			DiskLimit:   1024 * 1024 * 1024,
+		},
+		commandRunID: 1,
+	}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), *job.Spec.ActiveDeadlineSeconds)
+	limits := job.Spec.Template.Spec.Containers[0].Resources.Limits
+	assert.Equal(t, "500m", quantity(limits, corev1.ResourceCPU))
+	assert.Equal(t, "256Mi", quantity(limits, corev1.ResourceMemory))
+	assert.Equal(t, "1Gi", quantity(limits, corev1.ResourceEphemeralStorage))
+
+	// The timeout of a command can't exceed the configured maximum.
+	job, err = ke.job(context.Background(), &plannedCommand{
+		command:      &models.Command{Name: "test-command", Timeout: 600},
+		commandRunID: 1,
+	}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), *job.Spec.ActiveDeadlineSeconds)
+	assert.Empty(t, job.Spec.Template.Spec.Containers[0].Resources.Limits)
+
+	// The stop grace period of a command becomes the termination grace period of its pod.
+	job, err = ke.job(context.Background(), &plannedCommand{
+		command:      &models.Command{Name: "test-command", StopGracePeriod: 30},
+		commandRunID: 1,
+	}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), *job.Spec.Template.Spec.TerminationGracePeriodSeconds)
+}
+
+func TestKubernetesExecutor_Job_Secrets(t *testing.T) {
+	ke, _ := newKubernetesExecutor(t, &mocks.CommandRunStorer{})
+	var input commandInput
+	input.add("channel", "#general", models.DeliveryArg)
+	input.add("slack-token", "token", models.DeliveryEnv)
+	input.add("repo-ssh-key", "ssh-key", models.DeliveryFile)
+	job, err := ke.job(context.Background(), &plannedCommand{
+		command:      &models.Command{Name: "test-command"},
+		input:        input,
+		commandRunID: 1,
+	}, 1)
+	require.NoError(t, err)
+	container := job.Spec.Template.Spec.Containers[0]
+	// Only the arguments contain values, the rest is referenced from the Secret of the Job.
+	assert.Equal(t, []string{"--channel=#general", "--repo-ssh-key-file=/krok/secrets/repo-ssh-key"}, container.Args)
+	require.Len(t, container.Env, 1)
+	assert.Equal(t, "SLACK_TOKEN", container.Env[0].Name)
+	assert.Empty(t, container.Env[0].Value)
+	assert.Equal(t, "krok-1-1", container.Env[0].ValueFrom.SecretKeyRef.Name)
+	assert.Equal(t, []corev1.VolumeMount{{Name: "secrets", MountPath: "/krok/secrets", ReadOnly: true}}, container.VolumeMounts)
+	volumes := job.Spec.Template.Spec.Volumes
+	require.Len(t, volumes, 1)
+	assert.Equal(t, "krok-1-1", volumes[0].Secret.SecretName)
+
+	secret := jobSecret(job, input)
+	require.NotNil(t, secret)
+	assert.Equal(t, "krok-1-1", secret.Name)
+	assert.Equal(t, map[string][]byte{
+		container.Env[0].ValueFrom.SecretKeyRef.Key: []byte("token"),
+		volumes[0].Secret.Items[0].Key:              []byte("ssh-key"),
+	}, secret.Data)
+	assert.Nil(t, jobSecret(job, commandInput{args: []string{"--channel=#general"}}))
+}
+
+// quantity returns a resource of a list as string.
+func quantity(list corev1.ResourceList, name corev1.ResourceName) string {
+	q := list[name]
+	return q.String()
+}
+
+func TestKubernetesExecutor_CreateRun_Timeout(t *testing.T) {
+	mcr := newCommandRunStorer()
+	done := make(chan struct{})
+	mcr.On("UpdateRunSignal", mock.Anything, 1, "SIGTERM").Return(nil)
+	mcr.On("UpdateRunStatus", mock.Anything, 1, "timed_out", "\"fake logs\"").Run(func(args mock.Arguments) {
+		close(done)
+	}).Return(nil)
+	ke, client := newKubernetesExecutor(t, mcr)
+	createTestRun(t, ke)
+
+	job := waitForJob(t, client)
+	finishJob(t, client, job, batchv1.JobFailed, "DeadlineExceeded")
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("command run was not marked as timed out")
+	}
+}
+
+func TestKubernetesExecutor_CancelRun(t *testing.T) {
+	mcr := newCommandRunStorer()
+	done := make(chan struct{})
+	mcr.On("UpdateRunSignal", mock.Anything, 1, "SIGTERM").Return(nil).Once()
+	mcr.On("UpdateRunStatus", mock.Anything, 1, "cancelled", "\"cancelled\"").Run(func(args mock.Arguments) {
+		close(done)
+	}).Return(nil).Once()
+	ke, client := newKubernetesExecutor(t, mcr)
+	createTestRun(t, ke)
+	waitForJob(t, client)
+
+	err := ke.CancelRun(context.Background(), 1)
+	require.NoError(t, err)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("command run was not marked as cancelled")
+	}
+	jobs, err := client.BatchV1().Jobs("krok").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, jobs.Items)
+}
+
+func TestKubernetesExecutor_CancelCommandRun(t *testing.T) {
+	mcr := newCommandRunStorer()
+	done := make(chan struct{})
+	mcr.On("UpdateRunSignal", mock.Anything, 1, "SIGTERM").Return(nil).Once()
+	mcr.On("UpdateRunStatus", mock.Anything, 1, "cancelled", "\"cancelled\"").Run(func(args mock.Arguments) {
+		close(done)
+	}).Return(nil).Once()
+	ke, client := newKubernetesExecutor(t, mcr)
+	createTestRun(t, ke)
+	waitForJob(t, client)
+
+	err := ke.CancelCommandRun(context.Background(), 1)
+	require.NoError(t, err)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("command run was not marked as cancelled")
+	}
+	assert.Eventually(t, func() bool {
+		_, tracked := ke.commandRuns.Load(1)
+		return !tracked
+	}, 5*time.Second, 10*time.Millisecond)
+	jobs, err := client.BatchV1().Jobs("krok").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, jobs.Items)
+}
+
+func TestKubernetesExecutor_CancelCommandRun_DuringRetryBackoff(t *testing.T) {
+	mcr := &mocks.CommandRunStorer{}
+	mcr.On("CreateRun", mock.Anything, mock.Anything).Return(&models.CommandRun{ID: 1, EventID: 1, Attempt: 1}, nil).Once()
+	mcr.On("CreateRun", mock.Anything, mock.Anything).Return(&models.CommandRun{ID: 2, EventID: 1, Attempt: 2}, nil).Once()
+	mcr.On("UpdateRunContainer", mock.Anything, 1, "krok-1-1").Return(nil)
+	mcr.On("UpdateRunStatus", mock.Anything, 1, "running", "").Return(nil)
+	mcr.On("UpdateRunStatus", mock.Anything, 1, "failed", mock.Anything).Return(nil)
+	done := make(chan struct{})
+	mcr.On("UpdateRunStatus", mock.Anything, 2, "cancelled", "\"cancelled before the command started\"").Run(func(args mock.Arguments) {
+		close(done)
+	}).Return(nil)
+	ke, client := newKubernetesExecutor(t, mcr)
+	err := ke.CreateRun(context.Background(), &models.Event{ID: 1, RepositoryID: 1, Payload: "{}", VCS: models.GITHUB, EventType: "push"}, []*models.Command{
+		{
+			Name:    "test-command",
+			ID:      1,
+			Image:   "krokhook/slack-notification:v0.0.1",
+			Enabled: true,
+			Retry: &models.RetryPolicy{
+				MaxAttempts: 2,
+				Backoff:     60,
+				RetryOn:     []string{models.RetryOnNonZeroExit},
+			},
+		},
+	})
+	require.NoError(t, err)
+	finishJob(t, client, waitForJob(t, client), batchv1.JobFailed, "BackoffLimitExceeded")
+	require.Eventually(t, func() bool {
+		_, tracked := ke.commandRuns.Load(2)
+		return tracked
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, ke.CancelCommandRun(context.Background(), 2))
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("command run was not marked as cancelled")
+	}
+	// Neither attempt is tracked anymore once the command finished.
+	assert.Eventually(t, func() bool {
+		empty := true
+		ke.commandRuns.Range(func(key, value interface{}) bool {
+			empty = false
+			return false
+		})
+		return empty
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestKubernetesExecutor_CancelCommandRun_AfterRestart(t *testing.T) {
+	mcr := &mocks.CommandRunStorer{}
+	mcr.On("UpdateRunSignal", mock.Anything, 3, "SIGTERM").Return(nil)
+	mcr.On("UpdateRunStatus", mock.Anything, 3, "cancelled", "\"cancelled\"").Return(nil)
+	ke, client := newKubernetesExecutor(t, mcr)
+	for _, name := range []string{"krok-2-3", "krok-2-4"} {
+		_, err := client.BatchV1().Jobs("krok").Create(context.Background(), &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "krok",
+				Labels:    map[string]string{eventIDLabel: "2", commandRunIDLabel: name[len("krok-2-"):]},
+			},
+		}, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	err := ke.CancelCommandRun(context.Background(), 3)
+	require.NoError(t, err)
+	mcr.AssertExpectations(t)
+	_, err = client.BatchV1().Jobs("krok").Get(context.Background(), "krok-2-3", metav1.GetOptions{})
+	assert.Error(t, err)
+	// The other command of the event keeps running.
+	_, err = client.BatchV1().Jobs("krok").Get(context.Background(), "krok-2-4", metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	err = ke.CancelCommandRun(context.Background(), 3)
+	assert.ErrorIs(t, err, kerr.ErrNotFound)
+}
+
+func TestKubernetesExecutor_CancelRun_AfterRestart(t *testing.T) {
+	mcr := &mocks.CommandRunStorer{}
+	mcr.On("UpdateRunSignal", mock.Anything, 3, "SIGTERM").Return(nil)
+	mcr.On("UpdateRunStatus", mock.Anything, 3, "cancelled", "\"cancelled\"").Return(nil)
+	ke, client := newKubernetesExecutor(t, mcr)
+	_, err := client.BatchV1().Jobs("krok").Create(context.Background(), &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "krok-2-3",
+			Namespace: "krok",
+			Labels:    map[string]string{eventIDLabel: "2", commandRunIDLabel: "3"},
+		},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	err = ke.CancelRun(context.Background(), 2)
+	require.NoError(t, err)
+	mcr.AssertExpectations(t)
+	_, err = client.BatchV1().Jobs("krok").Get(context.Background(), "krok-2-3", metav1.GetOptions{})
+	assert.Error(t, err)
+
+	err = ke.CancelRun(context.Background(), 2)
+	assert.ErrorIs(t, err, kerr.ErrNotFound)
+}
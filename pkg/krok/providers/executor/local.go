@@ -0,0 +1,173 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// LocalRun defines a single command which runs once on the local container runtime, i.e. to try out a command
+// image without a Krok server.
+type LocalRun struct {
+	Command *models.Command
+	// Event provides the platform, the event type and the payload the command gets.
+	Event    *models.Event
+	Settings []*models.CommandSetting
+}
+
+// LocalResult is the outcome of a local run.
+type LocalResult struct {
+	// Args are the arguments the container of the command got.
+	Args []string
+	// Runs are the command runs of all attempts of the command. Their outcome is quoted the way it's saved.
+	Runs []*models.CommandRun
+}
+
+// RunLocal runs a command the same way the InMemoryExecutor runs it for an event. The container gets the same
+// arguments, environment and secret files, and the pull, retry and stop policies of the command apply. Nothing
+// is saved, and the command gets neither a workspace nor the credentials of a repository. The command is
+// cancelled once ctx is done. Only the Logger, Clock, ContainerRuntime and RegistryCredentials of deps are used.
+func RunLocal(ctx context.Context, cfg Config, deps Dependencies, run LocalRun) (*LocalResult, error) {
+	platform, found := models.SupportedPlatforms[run.Event.VCS]
+	if !found {
+		return nil, fmt.Errorf("failed to find %d in supported platforms", run.Event.VCS)
+	}
+	input := newCommandInput(platform, run.Event, &models.Repository{}, run.Command, run.Settings)
+
+	runs := &localCommandRuns{}
+	deps.CommandRuns = runs
+	if deps.Clock == nil {
+		deps.Clock = providers.NewClock()
+	}
+	deps.CommandStorer = nil
+	deps.RepositoryStorer = nil
+	deps.EventsStorer = nil
+	deps.ArtifactStorer = nil
+	deps.LogStreamer = nil
+	ime := NewInMemoryExecutor(cfg, deps)
+
+	commandRun, err := ime.createCommandRun(ctx, run.Event.ID, run.Command, 1)
+	if err != nil {
+		return nil, err
+	}
+	running := ime.track(run.Event.ID, run.Event.RepositoryID, run.Command.Name, commandRun.ID)
+	defer ime.untrack(running)
+	go cancelWhenDone(ctx, running)
+	ime.runCommandAttempts(run.Command, input, run.Event.ID, commandRun.ID, 1, running)
+	return &LocalResult{
+		Args: input.args,
+		Runs: runs.list(),
+	}, nil
+}
+
+// cancelWhenDone cancels a running command once ctx is done.
+func cancelWhenDone(ctx context.Context, running *runningCommand) {
+	select {
+	case <-ctx.Done():
+		running.cancel()
+	case <-running.ctx.Done():
+	}
+}
+
+// localCommandRuns keeps the command runs of a local run in memory.
+type localCommandRuns struct {
+	mu sync.Mutex
+	// firstID is the ID of the first command run. Defaults to 1.
+	firstID int
+	runs    []*models.CommandRun
+}
+
+var _ providers.CommandRunStorer = &localCommandRuns{}
+
+// CreateRun adds a command run with the next ID.
+func (l *localCommandRuns) CreateRun(ctx context.Context, run *models.CommandRun) (*models.CommandRun, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	created := *run
+	created.ID = l.first() + len(l.runs)
+	l.runs = append(l.runs, &created)
+	return &created, nil
+}
+
+// UpdateRunStatus sets the status and outcome of a command run.
+func (l *localCommandRuns) UpdateRunStatus(ctx context.Context, id int, status string, outcome string) error {
+	return l.update(id, func(run *models.CommandRun) {
+		run.Status = status
+		run.Outcome = outcome
+	})
+}
+
+// Get returns a command run.
+func (l *localCommandRuns) Get(ctx context.Context, id int) (*models.CommandRun, error) {
+	var result models.CommandRun
+	if err := l.update(id, func(run *models.CommandRun) {
+		result = *run
+	}); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UpdateRunContainer sets the container of a command run.
+func (l *localCommandRuns) UpdateRunContainer(ctx context.Context, id int, containerID string) error {
+	return l.update(id, func(run *models.CommandRun) {
+		run.ContainerID = containerID
+	})
+}
+
+// UpdateRunSignal sets the signal which stopped a command run.
+func (l *localCommandRuns) UpdateRunSignal(ctx context.Context, id int, signal string) error {
+	return l.update(id, func(run *models.CommandRun) {
+		run.Signal = signal
+	})
+}
+
+// ListRunsWithStatus returns the command runs which are in any of the given statuses.
+func (l *localCommandRuns) ListRunsWithStatus(ctx context.Context, statuses ...string) ([]*models.CommandRun, error) {
+	var result []*models.CommandRun
+	for _, run := range l.list() {
+		for _, status := range statuses {
+			if run.Status == status {
+				result = append(result, run)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// update changes a command run while holding the lock.
+func (l *localCommandRuns) update(id int, f func(run *models.CommandRun)) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	index := id - l.first()
+	if index < 0 || index >= len(l.runs) {
+		return kerr.ErrNotFound
+	}
+	f(l.runs[index])
+	return nil
+}
+
+// first returns the ID of the first command run.
+func (l *localCommandRuns) first() int {
+	if l.firstID == 0 {
+		return 1
+	}
+	return l.firstID
+}
+
+// list returns copies of all command runs.
+func (l *localCommandRuns) list() []*models.CommandRun {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	result := make([]*models.CommandRun, 0, len(l.runs))
+	for _, run := range l.runs {
+		r := *run
+		result = append(result, &r)
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/krok-o/krok/pkg/krok/providers/containerruntime"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+func TestRunLocal(t *testing.T) {
+	fake := containerruntime.NewFakeRuntime(map[string]containerruntime.FakeImage{
+		"test-image": {Output: "token is s3cr3t-token\n"},
+	})
+	result, err := RunLocal(context.Background(), Config{
+		DefaultMaximumCommandRuntime: 10,
+		SecretsLocation:              t.TempDir(),
+	}, Dependencies{
+		Logger:           zerolog.New(os.Stderr),
+		ContainerRuntime: fake,
+	}, LocalRun{
+		Command: &models.Command{Name: "test-command", Image: "test-image"},
+		Event: &models.Event{
+			VCS:       models.GITHUB,
+			EventType: "push",
+			Payload:   "{}",
+		},
+		Settings: []*models.CommandSetting{
+			{Key: "channel", Value: "builds"},
+			{Key: "token", Value: "s3cr3t-token", InVault: true, Delivery: models.DeliveryEnv},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--platform=github", "--event-type=push", "--payload=e30=", "--channel=builds"}, result.Args)
+	require.Len(t, result.Runs, 1)
+	assert.Equal(t, models.RunStatusSuccess, result.Runs[0].Status)
+	assert.Equal(t, `"token is [redacted]\n"`, result.Runs[0].Outcome)
+	created := fake.Created()
+	require.Len(t, created, 1)
+	assert.Equal(t, result.Args, created[0].Args)
+	assert.Equal(t, []string{"TOKEN=s3cr3t-token"}, created[0].Env)
+	assert.Empty(t, fake.Containers())
+}
+
+func TestRunLocal_Cancelled(t *testing.T) {
+	fake := containerruntime.NewFakeRuntime(map[string]containerruntime.FakeImage{
+		"test-image": {Blocks: true},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := RunLocal(ctx, Config{
+		DefaultMaximumCommandRuntime: 10,
+	}, Dependencies{
+		Logger:           zerolog.New(os.Stderr),
+		ContainerRuntime: fake,
+	}, LocalRun{
+		Command: &models.Command{Name: "test-command", Image: "test-image"},
+		Event:   &models.Event{VCS: models.GITHUB, EventType: "push", Payload: "{}"},
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Runs, 1)
+	assert.Equal(t, models.RunStatusCancelled, result.Runs[0].Status)
+}
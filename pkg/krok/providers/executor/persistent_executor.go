@@ -0,0 +1,270 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// PersistentExecutor defines an Executor which runs commands alongside Krok, like
+// the InMemoryExecutor, but also saves the container of every command run in the database.
+// After a restart, Reconcile picks up all the runs which have not finished yet.
+type PersistentExecutor struct {
+	*InMemoryExecutor
+}
+
+// NewPersistentExecutor creates a new PersistentExecutor.
+func NewPersistentExecutor(cfg Config, deps Dependencies) *PersistentExecutor {
+	ime := NewInMemoryExecutor(cfg, deps)
+	ime.persist = true
+	return &PersistentExecutor{
+		InMemoryExecutor: ime,
+	}
+}
+
+// Reconcile goes over all command runs which are still created or running and
+// compares them with the state of their containers.
+// Runs which never got a container are queued again. Runs with a container which is still
+// there are re-attached to and finished runs are collected. If the container is gone, the
+// run is marked as failed. Like in a regular run, commands wait for their dependencies
+// and are skipped if one of them did not succeed.
+func (pe *PersistentExecutor) Reconcile(ctx context.Context) error {
+	runs, err := pe.CommandRuns.ListRunsWithStatus(ctx, models.RunStatusCreated, models.RunStatusRunning)
+	if err != nil {
+		return fmt.Errorf("failed to list unfinished command runs: %w", err)
+	}
+	if len(runs) == 0 {
+		return nil
+	}
+	pe.Logger.Info().Int("runs", len(runs)).Msg("Reconciling unfinished command runs...")
+	var eventIDs []int
+	byEvent := make(map[int][]*models.CommandRun)
+	for _, run := range runs {
+		if _, ok := byEvent[run.EventID]; !ok {
+			eventIDs = append(eventIDs, run.EventID)
+		}
+		byEvent[run.EventID] = append(byEvent[run.EventID], run)
+	}
+	for _, eventID := range eventIDs {
+		pe.reconcileEvent(ctx, eventID, byEvent[eventID])
+	}
+	return nil
+}
+
+// reconcileEvent picks up the unfinished command runs of an event in the order of their dependencies.
+func (pe *PersistentExecutor) reconcileEvent(ctx context.Context, eventID int, runs []*models.CommandRun) {
+	log := pe.Logger.With().Int("event_id", eventID).Logger()
+	fail := func(err error) {
+		log.Error().Err(err).Msg("Failed to order the unfinished command runs of event.")
+		for _, run := range runs {
+			pe.updateStatus(models.RunStatusFailed, fmt.Sprintf("failed to restart command run: %s", err), run.ID)
+		}
+	}
+	event, err := pe.EventsStorer.GetEvent(ctx, eventID)
+	if err != nil {
+		fail(fmt.Errorf("failed to get event: %w", err))
+		return
+	}
+	planned, err := pe.planReconcile(ctx, event, runs)
+	if err != nil {
+		fail(err)
+		return
+	}
+	// Every command is tracked before any of them starts, so the whole run can be cancelled.
+	running := make([]*runningCommand, len(runs))
+	for i, run := range runs {
+		running[i] = pe.track(eventID, event.RepositoryID, run.CommandName, run.ID)
+	}
+	for i, run := range runs {
+		go pe.resume(ctx, planned[i], run, running[i])
+	}
+}
+
+// planReconcile creates a planned command for every unfinished command run of an event. Their parents are the
+// commands of the same event they depend on. Parents which finished before the restart are done already and
+// have succeeded if their last attempt did.
+func (pe *PersistentExecutor) planReconcile(ctx context.Context, event *models.Event, runs []*models.CommandRun) ([]*plannedCommand, error) {
+	unfinished := make(map[int]struct{}, len(runs))
+	var all, result []*plannedCommand
+	for _, run := range runs {
+		var dependsOn []int
+		if run.CommandID != 0 {
+			deps, err := pe.CommandStorer.GetCommandDependencies(ctx, run.CommandID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get dependencies of command %s: %w", run.CommandName, err)
+			}
+			dependsOn = deps
+			unfinished[run.CommandID] = struct{}{}
+		}
+		p := &plannedCommand{
+			command:      &models.Command{ID: run.CommandID, Name: run.CommandName},
+			commandRunID: run.ID,
+			dependsOn:    dependsOn,
+			done:         make(chan struct{}),
+		}
+		all = append(all, p)
+		result = append(result, p)
+	}
+
+	last := make(map[int]*models.CommandRun)
+	for _, run := range event.CommandRuns {
+		if _, ok := unfinished[run.CommandID]; ok || run.CommandID == 0 {
+			continue
+		}
+		if previous, ok := last[run.CommandID]; !ok || run.Attempt > previous.Attempt {
+			last[run.CommandID] = run
+		}
+	}
+	for _, run := range last {
+		p := &plannedCommand{
+			command:   &models.Command{ID: run.CommandID, Name: run.CommandName},
+			done:      make(chan struct{}),
+			succeeded: run.Status == models.RunStatusSuccess,
+		}
+		close(p.done)
+		all = append(all, p)
+	}
+	if _, err := orderCommands(all); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// resume waits for the dependencies of an unfinished command run and continues it where it stopped.
+// If a dependency did not succeed, the run is skipped.
+func (pe *PersistentExecutor) resume(ctx context.Context, p *plannedCommand, run *models.CommandRun, running *runningCommand) {
+	defer close(p.done)
+	defer pe.untrack(running)
+	log := pe.Logger.With().Int("command_run_id", run.ID).Str("container_id", run.ContainerID).Logger()
+	if parent := p.failedParent(); parent != nil {
+		log.Info().Str("dependency", parent.command.Name).Msg("Skipping command run as a dependency did not succeed.")
+		pe.updateStatus(models.RunStatusSkipped, fmt.Sprintf("dependency %s did not succeed", parent.command.Name), run.ID)
+		return
+	}
+	if run.ContainerID == "" {
+		status, err := pe.requeue(ctx, run, running)
+		if err != nil {
+			log.Debug().Err(err).Msg("Failed to queue command run again.")
+			pe.updateStatus(models.RunStatusFailed, fmt.Sprintf("failed to restart command run: %s", err), run.ID)
+			return
+		}
+		p.succeeded = status == models.RunStatusSuccess
+		return
+	}
+	state, err := pe.ContainerRuntime.State(ctx, run.ContainerID)
+	if errors.Is(err, kerr.ErrNotFound) {
+		log.Info().Msg("Container of command run is gone, marking it as failed.")
+		pe.updateStatus(models.RunStatusFailed, "container of command run not found after restart", run.ID)
+		return
+	} else if err != nil {
+		log.Error().Err(err).Msg("Failed to inspect container of command run.")
+		return
+	}
+	if state == providers.ContainerStateCreated {
+		log.Debug().Msg("Starting container of command run.")
+		p.succeeded = pe.continueAttempts(ctx, run, running, pe.restart) == models.RunStatusSuccess
+		return
+	}
+	// Waiting on an exited container returns immediately, so it is collected the same way as a running one.
+	log.Debug().Msg("Re-attaching to container of command run.")
+	p.succeeded = pe.continueAttempts(ctx, run, running, pe.reattach) == models.RunStatusSuccess
+}
+
+// requeue creates the container of a command run which never got one and runs it.
+// It returns the final status of the command run.
+func (pe *PersistentExecutor) requeue(ctx context.Context, run *models.CommandRun, running *runningCommand) (string, error) {
+	command, input, err := pe.commandAndInput(ctx, run)
+	if err != nil {
+		return "", err
+	}
+	return pe.runCommandAttempts(command, input, run.EventID, run.ID, runAttempt(run), running), nil
+}
+
+// continueAttempts finishes the attempt of a command run which already has a container with resume and
+// runs the next attempts according to the retry policy of the command. If the command can't be found,
+// only the current attempt is finished with the default timeout and stop policy and its secrets can't be masked.
+func (pe *PersistentExecutor) continueAttempts(ctx context.Context, run *models.CommandRun, running *runningCommand, resume resumeFunc) string {
+	command, input, err := pe.commandAndInput(ctx, run)
+	if err != nil {
+		pe.Logger.Debug().Err(err).Int("command_run_id", run.ID).Msg("Failed to get command of run, using the defaults without retries.")
+		status, _ := resume(run, nil, commandInput{}, running)
+		return status
+	}
+	return pe.runAttempts(running.ctx, command, run.EventID, run.ID, runAttempt(run), func(commandRunID int) (string, string) {
+		if commandRunID == run.ID {
+			return resume(run, command, input, running)
+		}
+		return pe.pullAndCreateContainer(command, input, run.EventID, commandRunID, running)
+	}, running.track)
+}
+
+// resumeFunc finishes the attempt of a command run whose container was created before the restart.
+// The command is nil if it can't be found anymore.
+type resumeFunc func(run *models.CommandRun, command *models.Command, input commandInput, running *runningCommand) (status string, failure string)
+
+// restart starts the container of a command run which was created but never started.
+func (pe *PersistentExecutor) restart(run *models.CommandRun, command *models.Command, input commandInput, running *runningCommand) (string, string) {
+	slot := newQueuedRun(command, run.CommandName, run.EventID, running.repositoryID, run.ID)
+	if err := pe.queue.acquire(context.Background(), slot); err != nil {
+		pe.updateStatus(models.RunStatusFailed, err.Error(), run.ID)
+		pe.Logger.Debug().Err(err).Msg("Failed to get a slot in the run queue.")
+		return models.RunStatusFailed, ""
+	}
+	defer pe.queue.release(slot)
+	defer pe.removeSecretFiles(run.ID)
+	return pe.startAndWaitForContainer(run.ContainerID, run.ID, pe.runPolicy(command), input.redactor(), running)
+}
+
+// reattach waits for the container of a command run which was started before the restart.
+func (pe *PersistentExecutor) reattach(run *models.CommandRun, command *models.Command, input commandInput, running *runningCommand) (string, string) {
+	slot := newQueuedRun(command, run.CommandName, run.EventID, running.repositoryID, run.ID)
+	if err := pe.queue.acquire(context.Background(), slot); err != nil {
+		pe.updateStatus(models.RunStatusFailed, err.Error(), run.ID)
+		pe.Logger.Debug().Err(err).Msg("Failed to get a slot in the run queue.")
+		return models.RunStatusFailed, ""
+	}
+	defer pe.queue.release(slot)
+	defer pe.removeSecretFiles(run.ID)
+	defer pe.removeContainer(run.ContainerID)
+	return pe.followAndWaitForContainer(run.ContainerID, run.ID, pe.runPolicy(command), input.redactor(), running)
+}
+
+// commandAndInput fetches the command of a run and builds its input from the event of the run again.
+func (pe *PersistentExecutor) commandAndInput(ctx context.Context, run *models.CommandRun) (*models.Command, commandInput, error) {
+	if run.CommandID == 0 {
+		return nil, commandInput{}, fmt.Errorf("command run %d has no command", run.ID)
+	}
+	event, err := pe.EventsStorer.GetEvent(ctx, run.EventID)
+	if err != nil {
+		return nil, commandInput{}, fmt.Errorf("failed to get event: %w", err)
+	}
+	platform, found := models.SupportedPlatforms[event.VCS]
+	if !found {
+		return nil, commandInput{}, fmt.Errorf("failed to find %d in supported platforms", event.VCS)
+	}
+	repository, err := pe.RepositoryStorer.Get(ctx, event.RepositoryID)
+	if err != nil {
+		return nil, commandInput{}, fmt.Errorf("failed to get repository: %w", err)
+	}
+	command, err := pe.CommandStorer.Get(ctx, run.CommandID)
+	if err != nil {
+		return nil, commandInput{}, fmt.Errorf("failed to get command: %w", err)
+	}
+	input, err := pe.buildInput(ctx, platform, event, repository, command)
+	if err != nil {
+		return nil, commandInput{}, fmt.Errorf("failed to get settings for command: %w", err)
+	}
+	return command, input, nil
+}
+
+// runAttempt returns the attempt of a command run. Runs saved before attempts were counted are the first one.
+func runAttempt(run *models.CommandRun) int {
+	if run.Attempt == 0 {
+		return 1
+	}
+	return run.Attempt
+}
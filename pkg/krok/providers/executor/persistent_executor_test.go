@@ -0,0 +1,232 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/krok-o/krok/pkg/krok/providers/containerruntime"
+	"github.com/krok-o/krok/pkg/krok/providers/mocks"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+func TestPersistentExecutor_Reconcile_NothingToDo(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	mcr := &mocks.CommandRunStorer{}
+	mcr.On("ListRunsWithStatus", mock.Anything, "created", "running").Return([]*models.CommandRun{}, nil)
+	pe := NewPersistentExecutor(Config{
+		DefaultMaximumCommandRuntime: 10,
+		MaximumParallelCommands:      10,
+	}, Dependencies{
+		Logger:      logger,
+		CommandRuns: mcr,
+	})
+	err := pe.Reconcile(context.Background())
+	assert.NoError(t, err)
+	mcr.AssertNotCalled(t, "UpdateRunStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPersistentExecutor_Reconcile_ListFails(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	mcr := &mocks.CommandRunStorer{}
+	mcr.On("ListRunsWithStatus", mock.Anything, "created", "running").Return(nil, errors.New("nope"))
+	pe := NewPersistentExecutor(Config{
+		DefaultMaximumCommandRuntime: 10,
+		MaximumParallelCommands:      10,
+	}, Dependencies{
+		Logger:      logger,
+		CommandRuns: mcr,
+	})
+	err := pe.Reconcile(context.Background())
+	assert.EqualError(t, err, "failed to list unfinished command runs: nope")
+}
+
+func TestPersistentExecutor_Reconcile_RequeueFailsMarksRunFailed(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	mcr := &mocks.CommandRunStorer{}
+	mcr.On("ListRunsWithStatus", mock.Anything, "created", "running").Return([]*models.CommandRun{
+		{ID: 1, EventID: 1, CommandName: "no-command-id", Status: "created"},
+		{ID: 2, EventID: 1, CommandName: "deleted", CommandID: 5, Status: "created"},
+	}, nil)
+	mcr.On("UpdateRunStatus", mock.Anything, 1, "failed", "\"failed to restart command run: command run 1 has no command\"").Return(nil)
+	mcr.On("UpdateRunStatus", mock.Anything, 2, "failed", "\"failed to restart command run: failed to get command: not found\"").Return(nil)
+	mes := &mocks.EventsStorer{}
+	mes.On("GetEvent", mock.Anything, 1).Return(&models.Event{ID: 1, RepositoryID: 1, VCS: models.GITHUB}, nil)
+	mrs := &mocks.RepositoryStorer{}
+	mrs.On("Get", mock.Anything, 1).Return(&models.Repository{ID: 1}, nil)
+	mcs := &mocks.CommandStorer{}
+	mcs.On("Get", mock.Anything, 5).Return(nil, errors.New("not found"))
+	mcs.On("GetCommandDependencies", mock.Anything, 5).Return(nil, nil)
+	pe := NewPersistentExecutor(Config{
+		DefaultMaximumCommandRuntime: 10,
+		MaximumParallelCommands:      10,
+	}, Dependencies{
+		Logger:           logger,
+		CommandRuns:      mcr,
+		CommandStorer:    mcs,
+		RepositoryStorer: mrs,
+		EventsStorer:     mes,
+	})
+	err := pe.Reconcile(context.Background())
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, ok := pe.runs.Load(1)
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
+	mcr.AssertExpectations(t)
+}
+
+func TestPersistentExecutor_Reconcile_Containers(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	fake := containerruntime.NewFakeRuntime(map[string]containerruntime.FakeImage{
+		"finished": {Output: "all done"},
+	})
+	exited := fake.Add("finished", "exited")
+	mcr := &mocks.CommandRunStorer{}
+	mcr.On("ListRunsWithStatus", mock.Anything, "created", "running").Return([]*models.CommandRun{
+		{ID: 1, EventID: 1, CommandName: "exited", CommandID: 1, Status: "running", ContainerID: exited},
+		{ID: 2, EventID: 1, CommandName: "gone", CommandID: 2, Status: "running", ContainerID: "gone"},
+	}, nil)
+	done := make(chan struct{})
+	mcr.On("UpdateRunStatus", mock.Anything, 1, "success", "\"all done\"").Run(func(args mock.Arguments) {
+		close(done)
+	}).Return(nil)
+	mcr.On("UpdateRunStatus", mock.Anything, 2, "failed", "\"container of command run not found after restart\"").Return(nil)
+	mcs := &mocks.CommandStorer{}
+	mcs.On("Get", mock.Anything, 1).Return(&models.Command{ID: 1, Name: "exited", Timeout: 5}, nil)
+	mcs.On("GetCommandDependencies", mock.Anything, mock.Anything).Return(nil, nil)
+	mes := &mocks.EventsStorer{}
+	mes.On("GetEvent", mock.Anything, 1).Return(&models.Event{ID: 1}, nil)
+	pe := NewPersistentExecutor(Config{
+		DefaultMaximumCommandRuntime: 10,
+		MaximumParallelCommands:      10,
+	}, Dependencies{
+		Logger:           logger,
+		CommandRuns:      mcr,
+		CommandStorer:    mcs,
+		EventsStorer:     mes,
+		ContainerRuntime: fake,
+	})
+	err := pe.Reconcile(context.Background())
+	assert.NoError(t, err)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("finished container was not collected")
+	}
+	assert.Eventually(t, func() bool { return len(fake.Containers()) == 0 }, 5*time.Second, 10*time.Millisecond)
+	mcr.AssertExpectations(t)
+}
+
+func TestPersistentExecutor_Reconcile_SkipsWhenDependencyFailed(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	mcr := &mocks.CommandRunStorer{}
+	mcr.On("ListRunsWithStatus", mock.Anything, "created", "running").Return([]*models.CommandRun{
+		{ID: 2, EventID: 1, CommandName: "publish", CommandID: 2, Attempt: 1, Status: "created"},
+	}, nil)
+	done := make(chan struct{})
+	mcr.On("UpdateRunStatus", mock.Anything, 2, "skipped", "\"dependency build did not succeed\"").Run(func(args mock.Arguments) {
+		close(done)
+	}).Return(nil)
+	mcs := &mocks.CommandStorer{}
+	mcs.On("GetCommandDependencies", mock.Anything, 2).Return([]int{1}, nil)
+	mes := &mocks.EventsStorer{}
+	// build failed before the restart. Its last attempt decides if it succeeded.
+	mes.On("GetEvent", mock.Anything, 1).Return(&models.Event{ID: 1, CommandRuns: []*models.CommandRun{
+		{ID: 1, EventID: 1, CommandName: "build", CommandID: 1, Attempt: 1, Status: "success"},
+		{ID: 3, EventID: 1, CommandName: "build", CommandID: 1, Attempt: 2, Status: "failed"},
+		{ID: 2, EventID: 1, CommandName: "publish", CommandID: 2, Attempt: 1, Status: "created"},
+	}}, nil)
+	pe := NewPersistentExecutor(Config{
+		DefaultMaximumCommandRuntime: 10,
+		MaximumParallelCommands:      10,
+	}, Dependencies{
+		Logger:        logger,
+		CommandRuns:   mcr,
+		CommandStorer: mcs,
+		EventsStorer:  mes,
+	})
+	err := pe.Reconcile(context.Background())
+	assert.NoError(t, err)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dependent command run was not skipped")
+	}
+	assert.Eventually(t, func() bool {
+		_, ok := pe.runs.Load(1)
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
+	mcr.AssertExpectations(t)
+}
+
+func TestPersistentExecutor_Reconcile_RetriesReattachedRun(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	fake := containerruntime.NewFakeRuntime(map[string]containerruntime.FakeImage{
+		"flaky": {ExitCode: 1, Output: "oh no"},
+	})
+	exited := fake.Add("flaky", "exited")
+	mcr := &mocks.CommandRunStorer{}
+	mcr.On("ListRunsWithStatus", mock.Anything, "created", "running").Return([]*models.CommandRun{
+		{ID: 1, EventID: 1, CommandName: "flaky", CommandID: 1, Attempt: 1, Status: "running", ContainerID: exited},
+	}, nil)
+	mcr.On("UpdateRunStatus", mock.Anything, 1, "failed", mock.Anything).Return(nil)
+	// the failed attempt before the restart is retried with the next attempt.
+	mcr.On("CreateRun", mock.Anything, mock.MatchedBy(func(run *models.CommandRun) bool {
+		return run.CommandID == 1 && run.Attempt == 2
+	})).Return(&models.CommandRun{ID: 2, EventID: 1, CommandName: "flaky", CommandID: 1, Attempt: 2, Status: "created"}, nil).Once()
+	mcr.On("UpdateRunContainer", mock.Anything, 2, mock.Anything).Return(nil)
+	mcr.On("UpdateRunStatus", mock.Anything, 2, "running", "").Return(nil)
+	done := make(chan struct{})
+	mcr.On("UpdateRunStatus", mock.Anything, 2, "failed", mock.Anything).Run(func(args mock.Arguments) {
+		close(done)
+	}).Return(nil)
+	mcs := &mocks.CommandStorer{}
+	mcs.On("Get", mock.Anything, 1).Return(&models.Command{
+		ID:    1,
+		Name:  "flaky",
+		Image: "flaky",
+		Retry: &models.RetryPolicy{
+			MaxAttempts: 2,
+			RetryOn:     []string{models.RetryOnNonZeroExit},
+		},
+	}, nil)
+	mcs.On("GetCommandDependencies", mock.Anything, 1).Return(nil, nil)
+	mcs.On("ListSettings", mock.Anything, 1).Return(nil, nil)
+	mes := &mocks.EventsStorer{}
+	mes.On("GetEvent", mock.Anything, 1).Return(&models.Event{ID: 1, RepositoryID: 1, VCS: models.GITHUB}, nil)
+	mrs := &mocks.RepositoryStorer{}
+	mrs.On("Get", mock.Anything, 1).Return(&models.Repository{ID: 1}, nil)
+	mt := &mocks.Clock{}
+	mt.On("Now").Return(time.Now())
+	pe := NewPersistentExecutor(Config{
+		DefaultMaximumCommandRuntime: 10,
+		MaximumParallelCommands:      1,
+	}, Dependencies{
+		Logger:           logger,
+		CommandRuns:      mcr,
+		CommandStorer:    mcs,
+		EventsStorer:     mes,
+		RepositoryStorer: mrs,
+		ContainerRuntime: fake,
+		Clock:            mt,
+	})
+	err := pe.Reconcile(context.Background())
+	assert.NoError(t, err)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reattached command run was not retried")
+	}
+	assert.Eventually(t, func() bool {
+		_, ok := pe.runs.Load(1)
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
+	mcr.AssertExpectations(t)
+}
@@ -0,0 +1,237 @@
+package executor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// queuedRun is a command run which waits for or holds a slot in the run queue.
+type queuedRun struct {
+	commandRunID int
+	eventID      int
+	repositoryID int
+	commandID    int
+	commandName  string
+	priority     int
+	// maxParallel is how many runs of the command may hold a slot at the same time. 0 means no limit.
+	maxParallel int
+
+	since time.Time
+	// seq is the order in which the runs entered the queue.
+	seq uint64
+	// granted is closed once the run got a slot.
+	granted chan struct{}
+}
+
+// newQueuedRun creates the queue entry of a command run. The command might be nil if it has been deleted,
+// in which case the run only counts towards the limits of its repository.
+func newQueuedRun(command *models.Command, commandName string, eventID, repositoryID, commandRunID int) *queuedRun {
+	r := &queuedRun{
+		commandRunID: commandRunID,
+		eventID:      eventID,
+		repositoryID: repositoryID,
+		commandName:  commandName,
+		granted:      make(chan struct{}),
+	}
+	if command != nil {
+		r.commandID = command.ID
+		r.commandName = command.Name
+		r.priority = command.Priority
+		r.maxParallel = command.MaxParallelRuns
+	}
+	return r
+}
+
+// runQueue hands out the slots for running commands. Runs with a higher priority go first. Runs with the same
+// priority take turns between repositories, so a single busy repository can't starve the others, and are
+// first in, first out within a repository. Runs which would exceed the limit of their repository or command
+// wait without blocking the runs behind them.
+type runQueue struct {
+	// limit is the number of slots. 0 means no limit.
+	limit int
+	// repositoryLimit is the number of slots the runs of a single repository can hold. 0 means no limit.
+	repositoryLimit int
+	now             func() time.Time
+
+	mu           sync.Mutex
+	seq          uint64
+	waiting      []*queuedRun
+	running      int
+	byRepository map[int]int
+	byCommand    map[int]int
+	// served is the turn in which a repository got its last slot. Repositories without waiting or running runs
+	// are forgotten, so they are served like new ones once they queue runs again.
+	served map[int]uint64
+	turn   uint64
+}
+
+// newRunQueue creates a run queue with the given limits.
+func newRunQueue(limit, repositoryLimit int) *runQueue {
+	return &runQueue{
+		limit:           limit,
+		repositoryLimit: repositoryLimit,
+		now:             time.Now,
+		byRepository:    make(map[int]int),
+		byCommand:       make(map[int]int),
+		served:          make(map[int]uint64),
+	}
+}
+
+// acquire waits until the run got a slot. If ctx is done before that, the run leaves the queue and
+// the error of ctx is returned.
+func (q *runQueue) acquire(ctx context.Context, r *queuedRun) error {
+	q.mu.Lock()
+	q.seq++
+	r.seq = q.seq
+	r.since = q.now()
+	q.waiting = append(q.waiting, r)
+	q.dispatch()
+	q.mu.Unlock()
+
+	select {
+	case <-r.granted:
+		return nil
+	case <-ctx.Done():
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case <-r.granted:
+		// The slot was handed out in the meantime, so it's given to the next run.
+		q.free(r)
+	default:
+		q.remove(r)
+		q.forget(r.repositoryID)
+	}
+	return ctx.Err()
+}
+
+// release frees the slot of a run and hands it to the next one.
+func (q *runQueue) release(r *queuedRun) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.free(r)
+}
+
+// free gives back the slot of a run. The caller must hold the lock.
+func (q *runQueue) free(r *queuedRun) {
+	q.running--
+	q.byRepository[r.repositoryID]--
+	if r.maxParallel > 0 {
+		q.byCommand[r.commandID]--
+		if q.byCommand[r.commandID] <= 0 {
+			delete(q.byCommand, r.commandID)
+		}
+	}
+	q.dispatch()
+	q.forget(r.repositoryID)
+}
+
+// forget drops the entries of a repository once it has neither waiting nor running runs, so they don't pile
+// up for every repository which ever ran a command. The caller must hold the lock.
+func (q *runQueue) forget(repositoryID int) {
+	if q.byRepository[repositoryID] > 0 {
+		return
+	}
+	for _, w := range q.waiting {
+		if w.repositoryID == repositoryID {
+			return
+		}
+	}
+	delete(q.byRepository, repositoryID)
+	delete(q.served, repositoryID)
+}
+
+// remove takes a waiting run out of the queue. The caller must hold the lock.
+func (q *runQueue) remove(r *queuedRun) {
+	for i, w := range q.waiting {
+		if w == r {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return
+		}
+	}
+}
+
+// dispatch hands out the free slots to the waiting runs. The caller must hold the lock.
+func (q *runQueue) dispatch() {
+	for q.limit <= 0 || q.running < q.limit {
+		r := next(q.waiting, q.served, q.allowed)
+		if r == nil {
+			return
+		}
+		q.remove(r)
+		q.running++
+		q.byRepository[r.repositoryID]++
+		if r.maxParallel > 0 {
+			q.byCommand[r.commandID]++
+		}
+		q.turn++
+		q.served[r.repositoryID] = q.turn
+		close(r.granted)
+	}
+}
+
+// allowed returns if a run would stay within the limits of its repository and command.
+func (q *runQueue) allowed(r *queuedRun) bool {
+	if q.repositoryLimit > 0 && q.byRepository[r.repositoryID] >= q.repositoryLimit {
+		return false
+	}
+	return r.maxParallel <= 0 || q.byCommand[r.commandID] < r.maxParallel
+}
+
+// next returns the run which gets the next slot out of the allowed ones: the one with the highest priority,
+// then the one of the repository which has waited the longest for its turn, then the oldest one.
+func next(waiting []*queuedRun, served map[int]uint64, allowed func(*queuedRun) bool) *queuedRun {
+	var best *queuedRun
+	for _, r := range waiting {
+		if !allowed(r) {
+			continue
+		}
+		switch {
+		case best == nil,
+			r.priority > best.priority,
+			r.priority == best.priority && served[r.repositoryID] < served[best.repositoryID]:
+			best = r
+		}
+	}
+	return best
+}
+
+// pending returns the waiting runs in the order in which they would get a slot if their repositories and
+// commands weren't at their limits.
+func (q *runQueue) pending() []*models.QueuedRun {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	waiting := append([]*queuedRun(nil), q.waiting...)
+	served := make(map[int]uint64, len(q.served))
+	for id, turn := range q.served {
+		served[id] = turn
+	}
+	turn := q.turn
+	always := func(*queuedRun) bool { return true }
+	result := make([]*models.QueuedRun, 0, len(waiting))
+	for len(waiting) > 0 {
+		r := next(waiting, served, always)
+		for i, w := range waiting {
+			if w == r {
+				waiting = append(waiting[:i], waiting[i+1:]...)
+				break
+			}
+		}
+		turn++
+		served[r.repositoryID] = turn
+		result = append(result, &models.QueuedRun{
+			CommandRunID: r.commandRunID,
+			EventID:      r.eventID,
+			RepositoryID: r.repositoryID,
+			CommandName:  r.commandName,
+			Priority:     r.priority,
+			Position:     len(result) + 1,
+			WaitingSince: r.since,
+		})
+	}
+	return result
+}
@@ -0,0 +1,159 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// enqueue adds a run to the queue without waiting for it.
+func enqueue(t *testing.T, q *runQueue, r *queuedRun) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- q.acquire(context.Background(), r)
+	}()
+	require.Eventually(t, func() bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return r.seq != 0
+	}, time.Second, time.Millisecond)
+	return result
+}
+
+// granted returns if a run got a slot.
+func granted(r *queuedRun) bool {
+	select {
+	case <-r.granted:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestRunQueue_TakesTurnsBetweenRepositories(t *testing.T) {
+	q := newRunQueue(1, 0)
+	first := newQueuedRun(&models.Command{ID: 1, Name: "build"}, "", 1, 1, 1)
+	require.NoError(t, q.acquire(context.Background(), first))
+
+	// A busy repository queues a lot of runs before another one queues a single run.
+	busy1 := newQueuedRun(&models.Command{ID: 1, Name: "build"}, "", 2, 1, 2)
+	busy2 := newQueuedRun(&models.Command{ID: 1, Name: "build"}, "", 3, 1, 3)
+	other := newQueuedRun(&models.Command{ID: 1, Name: "build"}, "", 4, 2, 4)
+	enqueue(t, q, busy1)
+	enqueue(t, q, busy2)
+	enqueue(t, q, other)
+	pending := q.pending()
+	require.Len(t, pending, 3)
+	assert.Equal(t, []int{4, 2, 3}, []int{pending[0].CommandRunID, pending[1].CommandRunID, pending[2].CommandRunID})
+	assert.Equal(t, 1, pending[0].Position)
+	assert.Equal(t, 2, pending[0].RepositoryID)
+	assert.False(t, pending[0].WaitingSince.IsZero())
+
+	q.release(first)
+	assert.True(t, granted(other))
+	assert.False(t, granted(busy1))
+	q.release(other)
+	assert.True(t, granted(busy1))
+	assert.False(t, granted(busy2))
+}
+
+func TestRunQueue_Priority(t *testing.T) {
+	q := newRunQueue(1, 0)
+	first := newQueuedRun(nil, "build", 1, 1, 1)
+	require.NoError(t, q.acquire(context.Background(), first))
+	low := newQueuedRun(&models.Command{ID: 1, Name: "lint"}, "", 2, 2, 2)
+	high := newQueuedRun(&models.Command{ID: 2, Name: "deploy", Priority: 10}, "", 3, 1, 3)
+	enqueue(t, q, low)
+	enqueue(t, q, high)
+	q.release(first)
+	assert.True(t, granted(high))
+	assert.False(t, granted(low))
+}
+
+func TestRunQueue_Limits(t *testing.T) {
+	q := newRunQueue(0, 1)
+	build := &models.Command{ID: 1, Name: "build", MaxParallelRuns: 1}
+	first := newQueuedRun(build, "", 1, 1, 1)
+	require.NoError(t, q.acquire(context.Background(), first))
+
+	// The repository is at its limit, but the other repository isn't held up by it.
+	sameRepository := newQueuedRun(&models.Command{ID: 2, Name: "lint"}, "", 1, 1, 2)
+	enqueue(t, q, sameRepository)
+	sameCommand := newQueuedRun(build, "", 2, 2, 3)
+	enqueue(t, q, sameCommand)
+	otherCommand := newQueuedRun(&models.Command{ID: 2, Name: "lint"}, "", 2, 2, 4)
+	enqueue(t, q, otherCommand)
+	assert.True(t, granted(otherCommand))
+	assert.False(t, granted(sameRepository))
+	assert.False(t, granted(sameCommand))
+
+	q.release(first)
+	assert.True(t, granted(sameRepository))
+	// The second repository is at its limit now.
+	assert.False(t, granted(sameCommand))
+	q.release(otherCommand)
+	assert.True(t, granted(sameCommand))
+}
+
+func TestRunQueue_Cancel(t *testing.T) {
+	q := newRunQueue(1, 0)
+	first := newQueuedRun(nil, "build", 1, 1, 1)
+	require.NoError(t, q.acquire(context.Background(), first))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := newQueuedRun(nil, "build", 2, 1, 2)
+	result := make(chan error, 1)
+	go func() {
+		result <- q.acquire(ctx, cancelled)
+	}()
+	assert.Eventually(t, func() bool {
+		return len(q.pending()) == 1
+	}, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-result, context.Canceled)
+	assert.Empty(t, q.pending())
+
+	q.release(first)
+	next := newQueuedRun(nil, "build", 3, 1, 3)
+	require.NoError(t, q.acquire(context.Background(), next))
+}
+
+func TestRunQueue_ForgetsIdleRepositories(t *testing.T) {
+	q := newRunQueue(1, 1)
+	build := &models.Command{ID: 1, Name: "build", MaxParallelRuns: 1}
+	first := newQueuedRun(build, "", 1, 1, 1)
+	require.NoError(t, q.acquire(context.Background(), first))
+	waiting := newQueuedRun(build, "", 2, 1, 2)
+	enqueue(t, q, waiting)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := newQueuedRun(nil, "lint", 3, 2, 3)
+	result := make(chan error, 1)
+	go func() {
+		result <- q.acquire(ctx, cancelled)
+	}()
+	assert.Eventually(t, func() bool {
+		return len(q.pending()) == 2
+	}, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-result, context.Canceled)
+
+	// The repository still has a waiting run after its first one finished.
+	q.release(first)
+	assert.True(t, granted(waiting))
+	q.mu.Lock()
+	assert.Equal(t, map[int]int{1: 1}, q.byRepository)
+	assert.Contains(t, q.served, 1)
+	assert.NotContains(t, q.served, 2)
+	q.mu.Unlock()
+
+	q.release(waiting)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	assert.Empty(t, q.byRepository)
+	assert.Empty(t, q.byCommand)
+	assert.Empty(t, q.served)
+}
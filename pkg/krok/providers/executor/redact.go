@@ -0,0 +1,167 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// redactedValue replaces the secrets in the output of commands.
+	redactedValue = "[redacted]"
+	// minimumSecretLength is the length below which values aren't redacted. Shorter values are part of almost
+	// any output, so masking them would only make the output unreadable.
+	minimumSecretLength = 4
+	// maximumBufferedOutput is how much output without a line break is kept back before it's written anyway.
+	maximumBufferedOutput = 16 * 1024
+)
+
+// redactor masks the secrets which were handed to a command in its output. A nil redactor doesn't mask anything.
+type redactor struct {
+	replacer *strings.Replacer
+	// variants are the masked values, longest first.
+	variants []string
+}
+
+// newRedactor creates a redactor for the given secrets. Besides the values themselves, their base64 and URL
+// encoded variants and every line of a multi-line value are masked, so they are caught even if the output is
+// processed line by line.
+func newRedactor(secrets []string) *redactor {
+	seen := make(map[string]struct{})
+	var variants []string
+	add := func(v string) {
+		if _, ok := seen[v]; ok {
+			return
+		}
+		seen[v] = struct{}{}
+		variants = append(variants, v)
+	}
+	for _, secret := range secrets {
+		for _, v := range []string{secret, strings.TrimSpace(secret)} {
+			if len(v) < minimumSecretLength {
+				continue
+			}
+			add(v)
+			add(base64.StdEncoding.EncodeToString([]byte(v)))
+			add(base64.RawStdEncoding.EncodeToString([]byte(v)))
+			add(base64.URLEncoding.EncodeToString([]byte(v)))
+			add(base64.RawURLEncoding.EncodeToString([]byte(v)))
+			add(url.QueryEscape(v))
+			add(url.PathEscape(v))
+		}
+		for _, line := range strings.Split(secret, "\n") {
+			if line = strings.TrimSpace(line); len(line) >= minimumSecretLength {
+				add(line)
+			}
+		}
+	}
+	if len(variants) == 0 {
+		return nil
+	}
+	// The replacer tries the values in order, so longer values have to come first. Otherwise, a secret which is
+	// part of another one would only mask a part of it.
+	sort.SliceStable(variants, func(i, j int) bool {
+		return len(variants[i]) > len(variants[j])
+	})
+	pairs := make([]string, 0, 2*len(variants))
+	for _, v := range variants {
+		pairs = append(pairs, v, redactedValue)
+	}
+	return &redactor{replacer: strings.NewReplacer(pairs...), variants: variants}
+}
+
+// redact masks the secrets in s.
+func (r *redactor) redact(s string) string {
+	if r == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// writer masks the secrets of everything written to w. The output is masked line by line, so a secret can't
+// be split between two writes. Lines end with a line feed or a carriage return. Long output without them is
+// written in parts which never split a secret. Close writes what is left and closes w.
+func (r *redactor) writer(w io.WriteCloser) io.WriteCloser {
+	if r == nil {
+		return w
+	}
+	return &redactingWriter{redactor: r, w: w}
+}
+
+// redactingWriter buffers the output until a line is complete and masks it before writing it.
+type redactingWriter struct {
+	*redactor
+	w io.WriteCloser
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Write writes the complete lines of p and keeps the rest until the line is finished or gets too long.
+func (rw *redactingWriter) Write(p []byte) (int, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.buf = append(rw.buf, p...)
+	n := bytes.LastIndexAny(rw.buf, "\n\r") + 1
+	if n == 0 && len(rw.buf) > rw.maximumBuffered() {
+		n = rw.cut(rw.buf)
+	}
+	if n == 0 {
+		return len(p), nil
+	}
+	out := rw.redact(string(rw.buf[:n]))
+	rw.buf = append(rw.buf[:0], rw.buf[n:]...)
+	if _, err := io.WriteString(rw.w, out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// maximumBuffered is how much output is kept back at most. It's large enough to hold the longest secret twice.
+func (r *redactor) maximumBuffered() int {
+	if longest := 2 * len(r.variants[0]); longest > maximumBufferedOutput {
+		return longest
+	}
+	return maximumBufferedOutput
+}
+
+// cut returns how much of buf can be masked and written on its own. Enough is kept back for a secret which
+// only begins at the end of buf, and no secret in buf is split.
+func (r *redactor) cut(buf []byte) int {
+	n := len(buf) - len(r.variants[0]) + 1
+	for moved := true; moved; {
+		moved = false
+		for _, v := range r.variants {
+			start := n - len(v) + 1
+			if start < 0 {
+				start = 0
+			}
+			for i := start; i < n; i++ {
+				if bytes.HasPrefix(buf[i:], []byte(v)) {
+					n, moved = i, true
+					break
+				}
+			}
+		}
+	}
+	return n
+}
+
+// Close writes the unfinished line and closes the underlying writer.
+func (rw *redactingWriter) Close() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	var err error
+	if len(rw.buf) > 0 {
+		_, err = io.WriteString(rw.w, rw.redact(string(rw.buf)))
+		rw.buf = nil
+	}
+	if closeErr := rw.w.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
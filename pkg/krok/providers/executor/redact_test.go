@@ -0,0 +1,79 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactor_Redact(t *testing.T) {
+	r := newRedactor([]string{"s3cr3t/token", "", "abc", "-----BEGIN KEY-----\nline-one\nline-two\n-----END KEY-----\n"})
+	require.NotNil(t, r)
+	assert.Equal(t, "token: [redacted]", r.redact("token: s3cr3t/token"))
+	assert.Equal(t, "[redacted]", r.redact(base64.StdEncoding.EncodeToString([]byte("s3cr3t/token"))))
+	assert.Equal(t, "[redacted]", r.redact(base64.RawURLEncoding.EncodeToString([]byte("s3cr3t/token"))))
+	assert.Equal(t, "?t=[redacted]", r.redact("?t="+url.QueryEscape("s3cr3t/token")))
+	// Values which are too short are left alone.
+	assert.Equal(t, "abc", r.redact("abc"))
+	assert.Equal(t, "key:\n[redacted]", r.redact("key:\n-----BEGIN KEY-----\nline-one\nline-two\n-----END KEY-----\n"))
+	assert.Equal(t, "[redacted]", r.redact("line-two"))
+
+	assert.Nil(t, newRedactor([]string{"", "abc"}))
+	var none *redactor
+	assert.Equal(t, "s3cr3t/token", none.redact("s3cr3t/token"))
+}
+
+type nopWriteCloser struct {
+	*bytes.Buffer
+	closed bool
+}
+
+func (w *nopWriteCloser) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestRedactor_Writer(t *testing.T) {
+	r := newRedactor([]string{"s3cr3t"})
+	out := &nopWriteCloser{Buffer: &bytes.Buffer{}}
+	w := r.writer(out)
+	// A secret split between writes is still masked.
+	_, err := w.Write([]byte("first s3c"))
+	require.NoError(t, err)
+	assert.Empty(t, out.String())
+	_, err = w.Write([]byte("r3t\nsecond s3cr3t"))
+	require.NoError(t, err)
+	assert.Equal(t, "first [redacted]\n", out.String())
+	require.NoError(t, w.Close())
+	assert.Equal(t, "first [redacted]\nsecond [redacted]", out.String())
+	assert.True(t, out.closed)
+}
+
+func TestRedactor_Writer_WithoutLineBreaks(t *testing.T) {
+	r := newRedactor([]string{"s3cr3t"})
+	out := &nopWriteCloser{Buffer: &bytes.Buffer{}}
+	w := r.writer(out)
+	// Progress bars end their lines with carriage returns.
+	_, err := w.Write([]byte("10% s3cr3t\r20%"))
+	require.NoError(t, err)
+	assert.Equal(t, "10% [redacted]\r", out.String())
+	out.Reset()
+
+	// Long output without any line break is written in parts, without splitting a secret.
+	long := bytes.Repeat([]byte("."), maximumBufferedOutput-4)
+	_, err = w.Write(append(long, []byte("s3cr3t.")...))
+	require.NoError(t, err)
+	assert.Equal(t, "20%"+string(long), out.String())
+	for i := 0; i < 3; i++ {
+		_, err = w.Write(bytes.Repeat([]byte("s3cr3t"), maximumBufferedOutput/6))
+		require.NoError(t, err)
+	}
+	assert.LessOrEqual(t, len(w.(*redactingWriter).buf), maximumBufferedOutput)
+	require.NoError(t, w.Close())
+	assert.NotContains(t, out.String(), "s3c")
+	assert.Equal(t, 3*(maximumBufferedOutput/6)*len("[redacted]")+len("20%")+len(long)+len("[redacted]")+1, out.Len())
+}
@@ -0,0 +1,57 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/containerd/containerd/reference/docker"
+
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// imageHost returns the registry an image is pulled from, i.e.: docker.io for images without a registry.
+func imageHost(image string) (string, error) {
+	named, err := docker.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image %s: %w", image, err)
+	}
+	return docker.Domain(named), nil
+}
+
+// registryCredential returns the credential the image of a command is pulled with. It returns nil if
+// the image is pulled without one.
+func (d *Dependencies) registryCredential(ctx context.Context, command *models.Command) (*models.RegistryCredential, error) {
+	if d.RegistryCredentials == nil {
+		return nil, nil
+	}
+	host, err := imageHost(command.Image)
+	if err != nil {
+		return nil, err
+	}
+	credential, err := d.RegistryCredentials.GetRegistryCredential(ctx, command.ID, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registry credential: %w", err)
+	}
+	return credential, nil
+}
+
+// pullImage makes sure that the image of a command is available the way its pull policy defines.
+func (ime *InMemoryExecutor) pullImage(ctx context.Context, command *models.Command) error {
+	if command.PullPolicy == models.PullPolicyIfNotPresent || command.PullPolicy == models.PullPolicyNever {
+		present, err := ime.ContainerRuntime.HasImage(ctx, command.Image)
+		if err != nil {
+			return err
+		}
+		if present {
+			return nil
+		}
+		if command.PullPolicy == models.PullPolicyNever {
+			return fmt.Errorf("image %s is not present and the pull policy is never", command.Image)
+		}
+	}
+	credential, err := ime.registryCredential(ctx, command)
+	if err != nil {
+		return err
+	}
+	return ime.ContainerRuntime.Pull(ctx, command.Image, credential)
+}
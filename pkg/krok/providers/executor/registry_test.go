@@ -0,0 +1,113 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/krok-o/krok/pkg/krok/providers/containerruntime"
+	"github.com/krok-o/krok/pkg/krok/providers/mocks"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+func TestImageHost(t *testing.T) {
+	for image, host := range map[string]string{
+		"alpine":                           "docker.io",
+		"krokhook/slack-notification:v0.1": "docker.io",
+		"ghcr.io/krok-o/command:latest":    "ghcr.io",
+		"registry.example.com:5000/build":  "registry.example.com:5000",
+	} {
+		h, err := imageHost(image)
+		assert.NoError(t, err)
+		assert.Equal(t, host, h, image)
+	}
+	_, err := imageHost("Invalid Image")
+	assert.Error(t, err)
+}
+
+func TestInMemoryExecutor_CreateRun_RegistryCredential(t *testing.T) {
+	ime, mcr, fake := newFakeRun(t, Config{
+		DefaultMaximumCommandRuntime: 10,
+		MaximumParallelCommands:      10,
+	}, containerruntime.FakeImage{Output: "done"})
+	credential := &models.RegistryCredential{Host: "docker.io", Username: "krok", Password: "registry-token"}
+	mrc := &mocks.RegistryCredentialProvider{}
+	mrc.On("GetRegistryCredential", mock.Anything, 1, "docker.io").Return(credential, nil)
+	ime.RegistryCredentials = mrc
+	done := statusUpdated(mcr, "success", "\"done\"")
+	startFakeRun(t, ime)
+	waitFor(t, done)
+	assert.Equal(t, []*models.RegistryCredential{credential}, fake.Pulls("test-image"))
+}
+
+func TestInMemoryExecutor_CreateRun_PullPolicy(t *testing.T) {
+	command := func(policy string) *models.Command {
+		return &models.Command{Name: "test-command", ID: 1, Image: "test-image", Enabled: true, PullPolicy: policy}
+	}
+
+	t.Run("never without image", func(tt *testing.T) {
+		ime, mcr, fake := newFakeRun(tt, Config{
+			DefaultMaximumCommandRuntime: 10,
+			MaximumParallelCommands:      10,
+		}, containerruntime.FakeImage{})
+		done := statusUpdated(mcr, "failed", "\"failed to pull image: image test-image is not present and the pull policy is never\"")
+		startFakeCommand(tt, ime, command(models.PullPolicyNever))
+		waitFor(tt, done)
+		assert.Empty(tt, fake.Pulls("test-image"))
+		assert.Empty(tt, fake.Created())
+	})
+
+	t.Run("if not present with image", func(tt *testing.T) {
+		ime, mcr, fake := newFakeRun(tt, Config{
+			DefaultMaximumCommandRuntime: 10,
+			MaximumParallelCommands:      10,
+		}, containerruntime.FakeImage{Present: true, Output: "done"})
+		done := statusUpdated(mcr, "success", "\"done\"")
+		startFakeCommand(tt, ime, command(models.PullPolicyIfNotPresent))
+		waitFor(tt, done)
+		assert.Empty(tt, fake.Pulls("test-image"))
+	})
+
+	t.Run("always with image", func(tt *testing.T) {
+		ime, mcr, fake := newFakeRun(tt, Config{
+			DefaultMaximumCommandRuntime: 10,
+			MaximumParallelCommands:      10,
+		}, containerruntime.FakeImage{Present: true, Output: "done"})
+		done := statusUpdated(mcr, "success", "\"done\"")
+		startFakeCommand(tt, ime, command(models.PullPolicyAlways))
+		waitFor(tt, done)
+		assert.Len(tt, fake.Pulls("test-image"), 1)
+	})
+}
+
+func TestKubernetesExecutor_Job_PullPolicy(t *testing.T) {
+	ke, _ := newKubernetesExecutor(t, &mocks.CommandRunStorer{})
+	job, err := ke.job(context.Background(), &plannedCommand{
+		command:      &models.Command{Name: "test-command", PullPolicy: models.PullPolicyNever},
+		commandRunID: 1,
+	}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, corev1.PullNever, job.Spec.Template.Spec.Containers[0].ImagePullPolicy)
+
+	secret, err := registrySecret(job, &models.RegistryCredential{Host: "docker.io", Username: "krok", Password: "registry-token"})
+	require.NoError(t, err)
+	assert.Equal(t, "krok-1-1-registry", secret.Name)
+	assert.Equal(t, corev1.SecretTypeDockerConfigJson, secret.Type)
+	var config struct {
+		Auths map[string]struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Auth     string `json:"auth"`
+		} `json:"auths"`
+	}
+	require.NoError(t, json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], &config))
+	auth := config.Auths["https://index.docker.io/v1/"]
+	assert.Equal(t, "krok", auth.Username)
+	assert.Equal(t, "registry-token", auth.Password)
+	assert.Equal(t, "a3JvazpyZWdpc3RyeS10b2tlbg==", auth.Auth)
+}
@@ -0,0 +1,467 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// defaultRunnerTimeout is how long a runner may go without contacting Krok if none is configured.
+const defaultRunnerTimeout = time.Minute
+
+// RemoteConfig defines configuration for the remote executor.
+type RemoteConfig struct {
+	Config
+	// RunnerTimeout is how long a runner may go without contacting Krok. After that, the command runs of the
+	// runner fail and the runner has to register again. Defaults to a minute.
+	RunnerTimeout time.Duration
+}
+
+// RemoteDependencies defines dependencies for the remote executor.
+type RemoteDependencies struct {
+	Dependencies
+	UUIDGenerator providers.UUIDGenerator
+}
+
+// RemoteExecutor defines an Executor which doesn't run any containers itself. The commands wait until
+// a remote runner with the runner labels of the command claims them. The runner runs the container
+// with its own container runtime and reports the logs and the outcome back. That way, the machines
+// which run the commands don't have to be reachable for the hooks of the platforms.
+type RemoteExecutor struct {
+	RemoteConfig
+	RemoteDependencies
+
+	// For each event, the function which cancels the commands of the run that are still waiting or running.
+	runs *sync.Map
+	// For the command run of the current attempt of every command which hasn't finished yet, the function
+	// which cancels the command.
+	commandRuns *sync.Map
+	queue       *runQueue
+	groups      *concurrencyGroups
+	// checkInterval is how often is checked whether the runner of a command run still contacts Krok.
+	checkInterval time.Duration
+
+	mu      sync.Mutex
+	runners map[string]*models.Runner
+	// pending are the jobs which wait for a runner in the order they were queued.
+	pending []*remoteJob
+	// claimed are the jobs which a runner runs by their command run.
+	claimed map[int]*remoteJob
+	// changed is closed and replaced whenever a job is queued, to wake up the runners waiting for one.
+	changed chan struct{}
+}
+
+// remoteJob is a command run which waits for a runner or is run by one.
+type remoteJob struct {
+	job    *models.RunnerJob
+	labels []string
+	// ctx is done once the command run is cancelled.
+	ctx context.Context
+	// claimed is closed once a runner claimed the job.
+	claimed chan struct{}
+	result  chan *models.RunnerJobResult
+
+	// The fields below are guarded by the mutex of the executor.
+	runner   models.Runner
+	lastSeen time.Time
+	stream   io.WriteCloser
+}
+
+var (
+	_ providers.Executor          = &RemoteExecutor{}
+	_ providers.RunnerCoordinator = &RemoteExecutor{}
+)
+
+// NewRemoteExecutor creates a new RemoteExecutor.
+func NewRemoteExecutor(cfg RemoteConfig, deps RemoteDependencies) *RemoteExecutor {
+	if cfg.RunnerTimeout <= 0 {
+		cfg.RunnerTimeout = defaultRunnerTimeout
+	}
+	return &RemoteExecutor{
+		RemoteConfig:       cfg,
+		RemoteDependencies: deps,
+		runs:               &sync.Map{},
+		commandRuns:        &sync.Map{},
+		queue:              newRunQueue(cfg.MaximumParallelCommands, cfg.MaximumParallelCommandsPerRepository),
+		groups:             newConcurrencyGroups(),
+		checkInterval:      time.Second,
+		runners:            make(map[string]*models.Runner),
+		claimed:            make(map[int]*remoteJob),
+		changed:            make(chan struct{}),
+	}
+}
+
+// CreateRun creates a run for an event. Every command waits for a runner once its dependencies succeeded.
+func (re *RemoteExecutor) CreateRun(ctx context.Context, event *models.Event, commands []*models.Command) error {
+	log := re.Logger.
+		With().
+		Int("event_id", event.ID).
+		Int("repository_id", event.RepositoryID).
+		Int("platform_id", event.VCS).
+		Int("commands", len(commands)).
+		Logger()
+
+	ordered, err := re.planRun(ctx, log, event, commands)
+	if err != nil {
+		return err
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	re.runs.Store(event.ID, cancel)
+	var wg sync.WaitGroup
+	for _, p := range ordered {
+		log.Debug().Str("image", p.command.Image).Msg("Preparing to run command...")
+		// Every command can be cancelled on its own by a newer run of its concurrency group.
+		commandCtx, cancelCommand := context.WithCancel(runCtx)
+		member := re.groups.join(event, p.command, cancelCommand)
+		tracked := trackCancel(re.commandRuns, p.commandRunID, cancelCommand)
+		wg.Add(1)
+		go func(p *plannedCommand) {
+			defer wg.Done()
+			defer cancelCommand()
+			defer re.groups.leave(member)
+			defer tracked.untrack()
+			re.runPlannedCommand(commandCtx, p, event, member, tracked)
+		}(p)
+	}
+	go func() {
+		wg.Wait()
+		re.runs.Delete(event.ID)
+		cancel()
+	}()
+	return nil
+}
+
+// runPlannedCommand waits for the dependencies of a command to finish and hands it to a runner if all of them
+// succeeded. Otherwise, the command is skipped. If the command is part of a concurrency group, it also
+// waits for the older runs of the group.
+func (re *RemoteExecutor) runPlannedCommand(ctx context.Context, p *plannedCommand, event *models.Event, member *groupMember, tracked *trackedCancel) {
+	defer close(p.done)
+	if parent := p.failedParent(); parent != nil {
+		re.Logger.Info().Str("command", p.command.Name).Str("dependency", parent.command.Name).Msg("Skipping command as a dependency did not succeed.")
+		re.updateStatus(models.RunStatusSkipped, fmt.Sprintf("dependency %s did not succeed", parent.command.Name), p.commandRunID)
+		return
+	}
+	if err := member.waitForOlder(ctx); err != nil {
+		status, outcome := failedStatus(ctx, err)
+		re.updateStatus(status, outcome, p.commandRunID)
+		return
+	}
+	// Every attempt gets its own command run, which might be claimed by another runner. The command is cancelled
+	// through the command run of its current attempt.
+	p.succeeded = re.runAttempts(ctx, p.command, event.ID, p.commandRunID, 1, func(commandRunID int) (string, string) {
+		p.commandRunID = commandRunID
+		return re.runJob(ctx, p, event)
+	}, tracked.retrack) == models.RunStatusSuccess
+}
+
+// runJob queues a command for the runners and waits for the runner which claimed it to finish. It returns the final
+// status of the command run and the failure a retry policy can cover, if any.
+func (re *RemoteExecutor) runJob(ctx context.Context, p *plannedCommand, event *models.Event) (string, string) {
+	log := re.Logger.With().Str("command", p.command.Name).Int("command_run_id", p.commandRunID).Logger()
+	slot := newQueuedRun(p.command, p.command.Name, event.ID, event.RepositoryID, p.commandRunID)
+	if err := re.queue.acquire(ctx, slot); err != nil {
+		status, outcome := failedStatus(ctx, err)
+		re.updateStatus(status, outcome, p.commandRunID)
+		log.Debug().Err(err).Msg("Failed to get a slot in the run queue.")
+		return status, ""
+	}
+	defer re.queue.release(slot)
+
+	credential, err := re.registryCredential(ctx, p.command)
+	if err != nil {
+		re.updateStatus(models.RunStatusFailed, err.Error(), p.commandRunID)
+		log.Debug().Err(err).Msg("Failed to get registry credential.")
+		return models.RunStatusFailed, ""
+	}
+	j := &remoteJob{
+		job: &models.RunnerJob{
+			CommandRunID:       p.commandRunID,
+			EventID:            event.ID,
+			Command:            p.command,
+			Timeout:            int(re.commandTimeout(p.command).Seconds()),
+			Args:               p.input.args,
+			Env:                p.input.env,
+			Files:              p.input.files,
+			Secrets:            p.input.secrets,
+			RegistryCredential: credential,
+		},
+		labels:  p.command.RunnerLabels,
+		ctx:     ctx,
+		claimed: make(chan struct{}),
+		result:  make(chan *models.RunnerJobResult, 1),
+	}
+	log.Info().Strs("labels", j.labels).Msg("Waiting for a runner...")
+	re.post(j)
+	select {
+	case <-j.claimed:
+	case <-ctx.Done():
+		if re.withdraw(j) {
+			re.updateStatus(models.RunStatusCancelled, "cancelled before the command started", p.commandRunID)
+			return models.RunStatusCancelled, ""
+		}
+		// A runner claimed the job in the meantime, so it has to stop the command.
+	}
+	defer re.release(j)
+
+	re.mu.Lock()
+	runner := j.runner
+	re.mu.Unlock()
+	log = log.With().Str("runner", runner.Name).Logger()
+	log.Info().Msg("Runner claimed command.")
+	// The runner takes the place of the container for the command run.
+	if err := re.CommandRuns.UpdateRunContainer(context.Background(), p.commandRunID, runner.Name); err != nil {
+		log.Debug().Err(err).Msg("Failed to save runner of command run.")
+	}
+	if err := re.CommandRuns.UpdateRunStatus(context.Background(), p.commandRunID, models.RunStatusRunning, ""); err != nil {
+		log.Debug().Err(err).Msg("Updating status of command failed.")
+	}
+	return re.waitForRunner(ctx, j, runner)
+}
+
+// waitForRunner waits for the runner of a claimed job to report the outcome of the command. If the runner doesn't
+// contact Krok for longer than the RunnerTimeout, the command run fails.
+func (re *RemoteExecutor) waitForRunner(ctx context.Context, j *remoteJob, runner models.Runner) (string, string) {
+	ticker := time.NewTicker(re.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case result := <-j.result:
+			if result.Signal != "" {
+				re.updateSignal(result.Signal, j.job.CommandRunID)
+			}
+			re.updateStatus(result.Status, result.Outcome, j.job.CommandRunID)
+			return result.Status, result.Failure
+		case <-ticker.C:
+			if !re.lost(j) {
+				continue
+			}
+			re.Logger.Warn().Str("runner", runner.Name).Int("command_run_id", j.job.CommandRunID).Msg("Runner stopped responding.")
+			status, outcome := failedStatus(ctx, fmt.Errorf("runner %s stopped responding", runner.Name))
+			re.updateStatus(status, outcome, j.job.CommandRunID)
+			return status, ""
+		}
+	}
+}
+
+// post queues a job and wakes up the runners waiting for one.
+func (re *RemoteExecutor) post(j *remoteJob) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.pending = append(re.pending, j)
+	close(re.changed)
+	re.changed = make(chan struct{})
+}
+
+// withdraw removes a job which hasn't been claimed yet. It returns false if a runner claimed the job already.
+func (re *RemoteExecutor) withdraw(j *remoteJob) bool {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	for i, pending := range re.pending {
+		if pending == j {
+			re.pending = append(re.pending[:i:i], re.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// release forgets a claimed job once its outcome has been saved and ends its log stream.
+func (re *RemoteExecutor) release(j *remoteJob) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	delete(re.claimed, j.job.CommandRunID)
+	if j.stream != nil {
+		_ = j.stream.Close()
+	}
+}
+
+// lost returns true if the runner of a claimed job didn't contact Krok for longer than the RunnerTimeout.
+func (re *RemoteExecutor) lost(j *remoteJob) bool {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	return re.Clock.Now().Sub(j.lastSeen) > re.RunnerTimeout
+}
+
+// canRun returns true if a runner has all the given runner labels.
+func canRun(runner *models.Runner, labels []string) bool {
+	for _, label := range labels {
+		found := false
+		for _, l := range runner.Labels {
+			if l == label {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// forgetLostRunners removes the runners which didn't contact Krok for longer than the RunnerTimeout.
+// The caller must hold the mutex.
+func (re *RemoteExecutor) forgetLostRunners() {
+	now := re.Clock.Now()
+	for id, runner := range re.runners {
+		if now.Sub(runner.LastSeen) > re.RunnerTimeout {
+			re.Logger.Info().Str("runner", runner.Name).Msg("Forgetting runner which stopped responding.")
+			delete(re.runners, id)
+		}
+	}
+}
+
+// RegisterRunner adds a runner with a generated ID.
+func (re *RemoteExecutor) RegisterRunner(ctx context.Context, registration *models.RunnerRegistration) (*models.Runner, error) {
+	id, err := re.UUIDGenerator.Generate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate runner ID: %w", err)
+	}
+	runner := &models.Runner{
+		ID:       id,
+		Name:     registration.Name,
+		Labels:   registration.Labels,
+		LastSeen: re.Clock.Now(),
+	}
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.forgetLostRunners()
+	re.runners[id] = runner
+	re.Logger.Info().Str("runner", runner.Name).Strs("labels", runner.Labels).Msg("Runner registered.")
+	result := *runner
+	return &result, nil
+}
+
+// ListRunners returns the runners which contacted Krok within the RunnerTimeout.
+func (re *RemoteExecutor) ListRunners(ctx context.Context) ([]*models.Runner, error) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.forgetLostRunners()
+	result := make([]*models.Runner, 0, len(re.runners))
+	for _, runner := range re.runners {
+		r := *runner
+		result = append(result, &r)
+	}
+	return result, nil
+}
+
+// ClaimJob hands the oldest job the runner can run to it. If there is none, it waits until one is queued or ctx is done.
+func (re *RemoteExecutor) ClaimJob(ctx context.Context, runnerID string) (*models.RunnerJob, error) {
+	for {
+		re.mu.Lock()
+		runner, ok := re.runners[runnerID]
+		if !ok {
+			re.mu.Unlock()
+			return nil, fmt.Errorf("runner with ID %s: %w", runnerID, kerr.ErrNotFound)
+		}
+		runner.LastSeen = re.Clock.Now()
+		for i, j := range re.pending {
+			if !canRun(runner, j.labels) {
+				continue
+			}
+			re.pending = append(re.pending[:i:i], re.pending[i+1:]...)
+			j.runner = *runner
+			j.lastSeen = runner.LastSeen
+			if re.LogStreamer != nil {
+				j.stream = re.LogStreamer.Open(j.job.CommandRunID)
+			}
+			re.claimed[j.job.CommandRunID] = j
+			close(j.claimed)
+			re.mu.Unlock()
+			return j.job, nil
+		}
+		changed := re.changed
+		re.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-changed:
+		}
+	}
+}
+
+// claimedJob returns a job the runner claimed and notes that the runner is still there. The caller must hold the mutex.
+func (re *RemoteExecutor) claimedJob(runnerID string, commandRunID int) (*remoteJob, error) {
+	j, ok := re.claimed[commandRunID]
+	if !ok || j.runner.ID != runnerID {
+		return nil, fmt.Errorf("command run with ID %d of runner %s: %w", commandRunID, runnerID, kerr.ErrNotFound)
+	}
+	now := re.Clock.Now()
+	j.lastSeen = now
+	if runner, ok := re.runners[runnerID]; ok {
+		runner.LastSeen = now
+	}
+	return j, nil
+}
+
+// AppendLogs writes the output of a claimed command run to its log stream. The runners also use it to learn
+// whether the command run has been cancelled.
+func (re *RemoteExecutor) AppendLogs(ctx context.Context, runnerID string, commandRunID int, logs []byte) (bool, error) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	j, err := re.claimedJob(runnerID, commandRunID)
+	if err != nil {
+		return false, err
+	}
+	if j.stream != nil && len(logs) > 0 {
+		if _, err := j.stream.Write(logs); err != nil {
+			re.Logger.Debug().Err(err).Int("command_run_id", commandRunID).Msg("Failed to write logs of command run.")
+		}
+	}
+	return j.ctx.Err() != nil, nil
+}
+
+// FinishJob hands the outcome of a claimed command run to the command which waits for it.
+func (re *RemoteExecutor) FinishJob(ctx context.Context, runnerID string, commandRunID int, result *models.RunnerJobResult) error {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	j, err := re.claimedJob(runnerID, commandRunID)
+	if err != nil {
+		return err
+	}
+	select {
+	case j.result <- result:
+	default:
+		// The outcome has already been reported.
+	}
+	return nil
+}
+
+// Queue returns the command runs which wait for a free slot before they are handed to the runners.
+func (re *RemoteExecutor) Queue(ctx context.Context) ([]*models.QueuedRun, error) {
+	return re.queue.pending(), nil
+}
+
+// CancelRun cancels a run. The commands which wait for a runner won't run anymore and the runners
+// of the running commands stop them with their stop signal.
+func (re *RemoteExecutor) CancelRun(ctx context.Context, id int) error {
+	cancel, ok := re.runs.LoadAndDelete(id)
+	if !ok {
+		re.Logger.Error().Int("id", id).Msg("Run with ID not found")
+		return fmt.Errorf("run with ID %d: %w", id, kerr.ErrNotFound)
+	}
+	cancel.(context.CancelFunc)()
+	re.Logger.Debug().Int("id", id).Msg("All commands successfully cancelled.")
+	return nil
+}
+
+// CancelCommandRun cancels a single command run. A command which still waits won't run anymore and the runner
+// of a running command stops it with its stop signal.
+func (re *RemoteExecutor) CancelCommandRun(ctx context.Context, id int) error {
+	cancel, ok := re.commandRuns.Load(id)
+	if !ok {
+		re.Logger.Error().Int("command_run_id", id).Msg("Command run with ID not found")
+		return fmt.Errorf("command run with ID %d: %w", id, kerr.ErrNotFound)
+	}
+	re.Logger.Debug().Int("command_run_id", id).Msg("Cancelling command.")
+	cancel.(context.CancelFunc)()
+	return nil
+}
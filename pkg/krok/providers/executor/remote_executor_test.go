@@ -0,0 +1,265 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/krok/providers/logstream"
+	"github.com/krok-o/krok/pkg/krok/providers/mocks"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+func newRemoteExecutor(t *testing.T, mcr *mocks.CommandRunStorer, clock providers.Clock) *RemoteExecutor {
+	mcs := &mocks.CommandStorer{}
+	mcs.On("IsPlatformSupported", mock.Anything, 1, 1).Return(true, nil)
+	mcs.On("ListSettings", mock.Anything, 1).Return(nil, nil)
+	mcs.On("GetCommandDependencies", mock.Anything, 1).Return(nil, nil)
+	mrs := &mocks.RepositoryStorer{}
+	mrs.On("Get", mock.Anything, 1).Return(&models.Repository{ID: 1}, nil)
+	uuids := &mocks.UUIDGenerator{}
+	uuids.On("Generate").Return("runner-1", nil).Once()
+	uuids.On("Generate").Return("runner-2", nil).Once()
+	re := NewRemoteExecutor(RemoteConfig{
+		Config: Config{
+			DefaultMaximumCommandRuntime: 10,
+			MaximumParallelCommands:      10,
+		},
+	}, RemoteDependencies{
+		Dependencies: Dependencies{
+			Logger:           zerolog.New(os.Stderr),
+			CommandRuns:      mcr,
+			CommandStorer:    mcs,
+			RepositoryStorer: mrs,
+			Clock:            clock,
+			LogStreamer:      logstream.NewBroker(logstream.Config{BacklogSize: 1024}, logstream.Dependencies{Logger: zerolog.New(os.Stderr)}),
+		},
+		UUIDGenerator: uuids,
+	})
+	re.checkInterval = 10 * time.Millisecond
+	return re
+}
+
+func newRemoteCommandRunStorer() *mocks.CommandRunStorer {
+	mcr := &mocks.CommandRunStorer{}
+	mcr.On("CreateRun", mock.Anything, mock.Anything).Return(&models.CommandRun{
+		ID:          1,
+		EventID:     1,
+		CommandName: "test-command",
+		Status:      "created",
+	}, nil)
+	mcr.On("UpdateRunContainer", mock.Anything, 1, "arm-runner").Return(nil)
+	mcr.On("UpdateRunStatus", mock.Anything, 1, "running", "").Return(nil)
+	return mcr
+}
+
+func createRemoteTestRun(t *testing.T, re *RemoteExecutor, labels []string) {
+	err := re.CreateRun(context.Background(), &models.Event{
+		ID:           1,
+		RepositoryID: 1,
+		Payload:      "{}",
+		VCS:          models.GITHUB,
+		EventType:    "push",
+	}, []*models.Command{
+		{
+			Name:         "test-command",
+			ID:           1,
+			Image:        "krokhook/slack-notification:v0.0.1",
+			Enabled:      true,
+			RunnerLabels: labels,
+		},
+	})
+	require.NoError(t, err)
+}
+
+// claimJob claims the job of the test run.
+func claimJob(t *testing.T, re *RemoteExecutor, runnerID string) *models.RunnerJob {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job, err := re.ClaimJob(ctx, runnerID)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	return job
+}
+
+// waitForDone waits until done is closed.
+func waitForDone(t *testing.T, done chan struct{}) {
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("command run did not finish")
+	}
+}
+
+func TestRemoteExecutor_CreateRun(t *testing.T) {
+	mcr := newRemoteCommandRunStorer()
+	done := make(chan struct{})
+	mcr.On("UpdateRunStatus", mock.Anything, 1, "success", "\"done\\n\"").Run(func(args mock.Arguments) {
+		close(done)
+	}).Return(nil)
+	re := newRemoteExecutor(t, mcr, providers.NewClock())
+	runner, err := re.RegisterRunner(context.Background(), &models.RunnerRegistration{Name: "arm-runner", Labels: []string{"arm64", "gpu"}})
+	require.NoError(t, err)
+	other, err := re.RegisterRunner(context.Background(), &models.RunnerRegistration{Name: "amd-runner"})
+	require.NoError(t, err)
+	createRemoteTestRun(t, re, []string{"arm64"})
+
+	// The runner without the label doesn't get the command.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	job, err := re.ClaimJob(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Nil(t, job)
+
+	job = claimJob(t, re, runner.ID)
+	assert.Equal(t, 1, job.CommandRunID)
+	assert.Equal(t, 1, job.EventID)
+	assert.Equal(t, 10, job.Timeout)
+	assert.Equal(t, "krokhook/slack-notification:v0.0.1", job.Command.Image)
+	assert.Equal(t, []string{"--platform=github", "--event-type=push", "--payload=e30="}, job.Args)
+
+	_, err = re.AppendLogs(context.Background(), other.ID, 1, []byte("done\n"))
+	assert.ErrorIs(t, err, kerr.ErrNotFound)
+	cancelled, err := re.AppendLogs(context.Background(), runner.ID, 1, []byte("done\n"))
+	require.NoError(t, err)
+	assert.False(t, cancelled)
+	logs, _, unsubscribe, err := re.LogStreamer.Subscribe(1)
+	require.NoError(t, err)
+	unsubscribe()
+	assert.Equal(t, "done\n", string(logs))
+
+	err = re.FinishJob(context.Background(), runner.ID, 1, &models.RunnerJobResult{Status: models.RunStatusSuccess, Outcome: "done\n"})
+	require.NoError(t, err)
+	waitForDone(t, done)
+
+	runners, err := re.ListRunners(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, runners, 2)
+}
+
+func TestRemoteExecutor_CancelCommandRun_Waiting(t *testing.T) {
+	mcr := newRemoteCommandRunStorer()
+	done := make(chan struct{})
+	mcr.On("UpdateRunStatus", mock.Anything, 1, "cancelled", "\"cancelled before the command started\"").Run(func(args mock.Arguments) {
+		close(done)
+	}).Return(nil)
+	re := newRemoteExecutor(t, mcr, providers.NewClock())
+	createRemoteTestRun(t, re, nil)
+	require.Eventually(t, func() bool {
+		re.mu.Lock()
+		defer re.mu.Unlock()
+		return len(re.pending) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, re.CancelCommandRun(context.Background(), 1))
+	waitForDone(t, done)
+	assert.Empty(t, re.pending)
+}
+
+func TestRemoteExecutor_CancelRun_Claimed(t *testing.T) {
+	mcr := newRemoteCommandRunStorer()
+	done := make(chan struct{})
+	mcr.On("UpdateRunSignal", mock.Anything, 1, "SIGTERM").Return(nil)
+	mcr.On("UpdateRunStatus", mock.Anything, 1, "cancelled", "\"stopped\"").Run(func(args mock.Arguments) {
+		close(done)
+	}).Return(nil)
+	re := newRemoteExecutor(t, mcr, providers.NewClock())
+	runner, err := re.RegisterRunner(context.Background(), &models.RunnerRegistration{Name: "arm-runner"})
+	require.NoError(t, err)
+	createRemoteTestRun(t, re, nil)
+	claimJob(t, re, runner.ID)
+
+	require.NoError(t, re.CancelRun(context.Background(), 1))
+	cancelled, err := re.AppendLogs(context.Background(), runner.ID, 1, nil)
+	require.NoError(t, err)
+	assert.True(t, cancelled)
+	err = re.FinishJob(context.Background(), runner.ID, 1, &models.RunnerJobResult{Status: models.RunStatusCancelled, Outcome: "stopped", Signal: "SIGTERM"})
+	require.NoError(t, err)
+	waitForDone(t, done)
+	mcr.AssertCalled(t, "UpdateRunSignal", mock.Anything, 1, "SIGTERM")
+}
+
+func TestRemoteExecutor_RunnerStoppedResponding(t *testing.T) {
+	mcr := newRemoteCommandRunStorer()
+	done := make(chan struct{})
+	mcr.On("UpdateRunStatus", mock.Anything, 1, "failed", "\"runner arm-runner stopped responding\"").Run(func(args mock.Arguments) {
+		close(done)
+	}).Return(nil)
+	re := newRemoteExecutor(t, mcr, providers.NewClock())
+	re.RunnerTimeout = 50 * time.Millisecond
+	runner, err := re.RegisterRunner(context.Background(), &models.RunnerRegistration{Name: "arm-runner"})
+	require.NoError(t, err)
+	createRemoteTestRun(t, re, nil)
+	claimJob(t, re, runner.ID)
+
+	waitForDone(t, done)
+	// The runner is forgotten and has to register again.
+	runners, err := re.ListRunners(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, runners)
+	_, err = re.ClaimJob(context.Background(), runner.ID)
+	assert.ErrorIs(t, err, kerr.ErrNotFound)
+}
+
+func TestRemoteExecutor_CancelCommandRun_DuringRetryBackoff(t *testing.T) {
+	mcr := &mocks.CommandRunStorer{}
+	mcr.On("CreateRun", mock.Anything, mock.Anything).Return(&models.CommandRun{ID: 1, EventID: 1, Attempt: 1}, nil).Once()
+	mcr.On("CreateRun", mock.Anything, mock.Anything).Return(&models.CommandRun{ID: 2, EventID: 1, Attempt: 2}, nil).Once()
+	mcr.On("UpdateRunContainer", mock.Anything, 1, "arm-runner").Return(nil)
+	mcr.On("UpdateRunStatus", mock.Anything, 1, "running", "").Return(nil)
+	mcr.On("UpdateRunStatus", mock.Anything, 1, "failed", "\"exit code 1\"").Return(nil)
+	done := make(chan struct{})
+	mcr.On("UpdateRunStatus", mock.Anything, 2, "cancelled", "\"cancelled before the command started\"").Run(func(args mock.Arguments) {
+		close(done)
+	}).Return(nil)
+	re := newRemoteExecutor(t, mcr, providers.NewClock())
+	runner, err := re.RegisterRunner(context.Background(), &models.RunnerRegistration{Name: "arm-runner"})
+	require.NoError(t, err)
+	err = re.CreateRun(context.Background(), &models.Event{ID: 1, RepositoryID: 1, Payload: "{}", VCS: models.GITHUB, EventType: "push"}, []*models.Command{
+		{
+			Name:    "test-command",
+			ID:      1,
+			Image:   "krokhook/slack-notification:v0.0.1",
+			Enabled: true,
+			Retry: &models.RetryPolicy{
+				MaxAttempts: 2,
+				Backoff:     60,
+				RetryOn:     []string{models.RetryOnNonZeroExit},
+			},
+		},
+	})
+	require.NoError(t, err)
+	claimJob(t, re, runner.ID)
+	err = re.FinishJob(context.Background(), runner.ID, 1, &models.RunnerJobResult{Status: models.RunStatusFailed, Outcome: "exit code 1", Failure: models.RetryOnNonZeroExit})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_, tracked := re.commandRuns.Load(2)
+		return tracked
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, re.CancelCommandRun(context.Background(), 2))
+	waitForDone(t, done)
+	// Neither attempt is tracked anymore once the command finished.
+	assert.Eventually(t, func() bool {
+		empty := true
+		re.commandRuns.Range(func(key, value interface{}) bool {
+			empty = false
+			return false
+		})
+		return empty
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRemoteExecutor_CancelRunNotFound(t *testing.T) {
+	re := newRemoteExecutor(t, &mocks.CommandRunStorer{}, providers.NewClock())
+	assert.ErrorIs(t, re.CancelRun(context.Background(), 1), kerr.ErrNotFound)
+	assert.ErrorIs(t, re.CancelCommandRun(context.Background(), 1), kerr.ErrNotFound)
+}
@@ -0,0 +1,123 @@
+package executor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// maxRetryDelay caps the backoff between two attempts of a command.
+const maxRetryDelay = time.Hour
+
+// attemptFunc runs a single attempt of a command for the given command run. It returns the final status
+// of the command run and, if the attempt failed in a way a retry policy can cover, the failure.
+type attemptFunc func(commandRunID int) (status string, failure string)
+
+// retryDelay returns how long to wait before the next attempt of a command whose attempt failed with failure.
+// It returns false if the retry policy doesn't allow another attempt.
+func retryDelay(policy *models.RetryPolicy, attempt int, failure string) (time.Duration, bool) {
+	if policy == nil || failure == "" || attempt >= policy.MaxAttempts {
+		return 0, false
+	}
+	retryable := false
+	for _, f := range policy.RetryOn {
+		if f == failure {
+			retryable = true
+			break
+		}
+	}
+	if !retryable {
+		return 0, false
+	}
+	delay := time.Duration(policy.Backoff) * time.Second
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay, true
+}
+
+// runAttempts runs a command and runs it again for every failure its retry policy covers until the policy
+// is exhausted or ctx is cancelled. Every retry gets a new command run with the next attempt number, which
+// is handed to track before the backoff. It returns the final status of the last attempt.
+func (d *Dependencies) runAttempts(ctx context.Context, command *models.Command, eventID, commandRunID, attempt int, run attemptFunc, track func(commandRunID int)) string {
+	log := d.Logger.With().Str("command", command.Name).Int("event_id", eventID).Logger()
+	for {
+		status, failure := run(commandRunID)
+		delay, ok := retryDelay(command.Retry, attempt, failure)
+		if !ok || ctx.Err() != nil {
+			return status
+		}
+		next, err := d.createCommandRun(context.Background(), eventID, command, attempt+1)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to create command run for the next attempt.")
+			return status
+		}
+		log.Info().Str("failure", failure).Int("attempt", next.Attempt).Dur("backoff", delay).Msg("Retrying command.")
+		track(next.ID)
+		if !waitForRetry(ctx, delay) {
+			d.updateStatus(models.RunStatusCancelled, "cancelled before the command started", next.ID)
+			return models.RunStatusCancelled
+		}
+		commandRunID, attempt = next.ID, next.Attempt
+	}
+}
+
+// createCommandRun creates the command run of an attempt of a command.
+func (d *Dependencies) createCommandRun(ctx context.Context, eventID int, command *models.Command, attempt int) (*models.CommandRun, error) {
+	return d.CommandRuns.CreateRun(ctx, &models.CommandRun{
+		EventID:     eventID,
+		CommandName: command.Name,
+		CommandID:   command.ID,
+		Attempt:     attempt,
+		Status:      models.RunStatusCreated,
+		Outcome:     "",
+		CreateAt:    d.Clock.Now(),
+	})
+}
+
+// waitForRetry waits for the backoff before the next attempt. It returns false if ctx got cancelled in the meantime.
+func waitForRetry(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// trackedCancel is the cancel func of a command, which is tracked by the command run of its current attempt
+// so the command can be cancelled by its ID. It's used by the goroutine running the command only.
+type trackedCancel struct {
+	commandRuns  *sync.Map
+	commandRunID int
+	cancel       context.CancelFunc
+}
+
+// trackCancel tracks the cancel func of a command by the command run of its first attempt.
+func trackCancel(commandRuns *sync.Map, commandRunID int, cancel context.CancelFunc) *trackedCancel {
+	commandRuns.Store(commandRunID, cancel)
+	return &trackedCancel{
+		commandRuns:  commandRuns,
+		commandRunID: commandRunID,
+		cancel:       cancel,
+	}
+}
+
+// retrack makes the command run of the next attempt of the command the one it can be cancelled with.
+func (t *trackedCancel) retrack(commandRunID int) {
+	t.commandRuns.Delete(t.commandRunID)
+	t.commandRunID = commandRunID
+	t.commandRuns.Store(commandRunID, t.cancel)
+}
+
+// untrack removes the command run the command is currently tracked by.
+func (t *trackedCancel) untrack() {
+	t.commandRuns.Delete(t.commandRunID)
+}
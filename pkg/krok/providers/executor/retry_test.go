@@ -0,0 +1,48 @@
+package executor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krok-o/krok/pkg/models"
+)
+
+func TestRetryDelay(t *testing.T) {
+	policy := &models.RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     5,
+		RetryOn:     []string{models.RetryOnPullFailure, models.RetryOnTimeout},
+	}
+	tests := []struct {
+		name    string
+		policy  *models.RetryPolicy
+		attempt int
+		failure string
+		delay   time.Duration
+		retry   bool
+	}{
+		{name: "no policy", attempt: 1, failure: models.RetryOnPullFailure},
+		{name: "not retryable", policy: policy, attempt: 1},
+		{name: "failure not covered", policy: policy, attempt: 1, failure: models.RetryOnNonZeroExit},
+		{name: "first retry", policy: policy, attempt: 1, failure: models.RetryOnPullFailure, delay: 5 * time.Second, retry: true},
+		{name: "backoff doubles", policy: policy, attempt: 2, failure: models.RetryOnTimeout, delay: 10 * time.Second, retry: true},
+		{name: "attempts exhausted", policy: policy, attempt: 3, failure: models.RetryOnTimeout},
+		{
+			name:    "backoff is capped",
+			policy:  &models.RetryPolicy{MaxAttempts: 10, Backoff: 3600, RetryOn: []string{models.RetryOnTimeout}},
+			attempt: 9,
+			failure: models.RetryOnTimeout,
+			delay:   maxRetryDelay,
+			retry:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, retry := retryDelay(tt.policy, tt.attempt, tt.failure)
+			assert.Equal(t, tt.retry, retry)
+			assert.Equal(t, tt.delay, delay)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// RunJob runs a command run which a remote runner claimed from the RemoteExecutor with the container runtime of the
+// runner. The image is pulled and the container is created, limited and stopped the same way the InMemoryExecutor does
+// it. The command only runs once though, since Krok retries it with a new command run. The output is written to the
+// log stream which the LogStreamer of deps opens for the command run. The command is cancelled once ctx is done. Only
+// the Logger, Clock, LogStreamer and ContainerRuntime of deps are used.
+func RunJob(ctx context.Context, cfg Config, deps Dependencies, job *models.RunnerJob) *models.RunnerJobResult {
+	if job.Command == nil {
+		return &models.RunnerJobResult{Status: models.RunStatusFailed, Outcome: "job without a command"}
+	}
+	runs := &localCommandRuns{firstID: job.CommandRunID}
+	deps.CommandRuns = runs
+	if deps.Clock == nil {
+		deps.Clock = providers.NewClock()
+	}
+	deps.CommandStorer = nil
+	deps.RepositoryStorer = nil
+	deps.EventsStorer = nil
+	deps.ArtifactStorer = nil
+	deps.RegistryCredentials = nil
+	if job.RegistryCredential != nil {
+		deps.RegistryCredentials = &jobRegistryCredential{credential: job.RegistryCredential}
+	}
+	// Krok capped the timeout of the command already. The runner limits how many commands it runs by itself.
+	cfg.DefaultMaximumCommandRuntime = job.Timeout
+	cfg.MaximumParallelCommands = 0
+	cfg.MaximumParallelCommandsPerRepository = 0
+	ime := NewInMemoryExecutor(cfg, deps)
+
+	command := *job.Command
+	if _, err := ime.createCommandRun(ctx, job.EventID, &command, 1); err != nil {
+		return &models.RunnerJobResult{Status: models.RunStatusFailed, Outcome: err.Error()}
+	}
+	running := ime.track(job.EventID, 0, command.Name, job.CommandRunID)
+	defer ime.untrack(running)
+	go cancelWhenDone(ctx, running)
+	input := commandInput{
+		args:    job.Args,
+		env:     job.Env,
+		files:   job.Files,
+		secrets: job.Secrets,
+	}
+	status, failure := ime.pullAndCreateContainer(&command, input, job.EventID, job.CommandRunID, running)
+
+	result := &models.RunnerJobResult{
+		Status:  status,
+		Failure: failure,
+	}
+	if run, err := runs.Get(ctx, job.CommandRunID); err == nil {
+		// The outcome is saved quoted, Krok quotes it again.
+		outcome, err := strconv.Unquote(run.Outcome)
+		if err != nil {
+			outcome = run.Outcome
+		}
+		result.Outcome = outcome
+		result.Signal = run.Signal
+	}
+	return result
+}
+
+// jobRegistryCredential hands out the registry credential which Krok sent along with a job.
+type jobRegistryCredential struct {
+	credential *models.RegistryCredential
+}
+
+var _ providers.RegistryCredentialProvider = &jobRegistryCredential{}
+
+// GetRegistryCredential returns the credential of the job.
+func (j *jobRegistryCredential) GetRegistryCredential(ctx context.Context, commandID int, host string) (*models.RegistryCredential, error) {
+	return j.credential, nil
+}
+
+// SaveRegistryCredential isn't supported by runners.
+func (j *jobRegistryCredential) SaveRegistryCredential(ctx context.Context, credential *models.RegistryCredential) error {
+	return errors.New("registry credentials can't be saved by runners")
+}
+
+// DeleteRegistryCredential isn't supported by runners.
+func (j *jobRegistryCredential) DeleteRegistryCredential(ctx context.Context, commandID int, host string) error {
+	return errors.New("registry credentials can't be deleted by runners")
+}
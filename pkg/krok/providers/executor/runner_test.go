@@ -0,0 +1,62 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krok-o/krok/pkg/krok/providers/containerruntime"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+func TestRunJob(t *testing.T) {
+	fake := containerruntime.NewFakeRuntime(map[string]containerruntime.FakeImage{
+		"test-image": {Output: "token is s3cr3t-token\n"},
+	})
+	result := RunJob(context.Background(), Config{
+		SecretsLocation: t.TempDir(),
+	}, Dependencies{
+		Logger:           zerolog.New(os.Stderr),
+		ContainerRuntime: fake,
+	}, &models.RunnerJob{
+		CommandRunID: 42,
+		EventID:      1,
+		Command:      &models.Command{Name: "test-command", Image: "test-image"},
+		Timeout:      10,
+		Args:         []string{"--platform=github", "--token-file=/krok/secrets/token"},
+		Env:          []string{"CHANNEL=builds"},
+		Files:        map[string]string{"token": "s3cr3t-token"},
+		Secrets:      []string{"s3cr3t-token"},
+	})
+	assert.Equal(t, &models.RunnerJobResult{
+		Status:  models.RunStatusSuccess,
+		Outcome: "token is [redacted]\n",
+	}, result)
+	created := fake.Created()
+	if assert.Len(t, created, 1) {
+		assert.Equal(t, []string{"--platform=github", "--token-file=/krok/secrets/token"}, created[0].Args)
+		assert.Equal(t, []string{"CHANNEL=builds"}, created[0].Env)
+		assert.Len(t, created[0].Mounts, 1)
+	}
+	assert.Empty(t, fake.Containers())
+}
+
+func TestRunJob_Cancelled(t *testing.T) {
+	fake := containerruntime.NewFakeRuntime(map[string]containerruntime.FakeImage{
+		"test-image": {Blocks: true},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := RunJob(ctx, Config{}, Dependencies{
+		Logger:           zerolog.New(os.Stderr),
+		ContainerRuntime: fake,
+	}, &models.RunnerJob{
+		CommandRunID: 42,
+		Command:      &models.Command{Name: "test-command", Image: "test-image"},
+		Timeout:      10,
+	})
+	assert.Equal(t, models.RunStatusCancelled, result.Status)
+}
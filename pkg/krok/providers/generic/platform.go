@@ -0,0 +1,193 @@
+package generic
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/krok-o/krok/pkg/krok/payload"
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// Dependencies defines the dependencies for the plugin provider.
+type Dependencies struct {
+	Logger           zerolog.Logger
+	AuthProvider     providers.RepositoryAuth
+	RepositoryStorer providers.RepositoryStorer
+	UUIDGenerator    providers.UUIDGenerator
+}
+
+// Generic is a platform implementation for arbitrary HTTP senders. How a request is
+// validated and where the type of the event is found is defined by the repository.
+type Generic struct {
+	Dependencies
+}
+
+// NewGenericPlatformProvider creates a new hook platform provider for arbitrary senders.
+func NewGenericPlatformProvider(deps Dependencies) *Generic {
+	return &Generic{Dependencies: deps}
+}
+
+var _ providers.Platform = &Generic{}
+
+// CreateHook doesn't create anything, since there is no API to call. The sender has to be
+// configured to call the unique url of the repository. It only validates the settings.
+func (g *Generic) CreateHook(ctx context.Context, repo *models.Repository) error {
+	if err := repo.Generic.Validate(); err != nil {
+		return err
+	}
+	if repo.UniqueURL == "" {
+		return errors.New("unique callback url is empty")
+	}
+	g.Logger.Info().Str("repo", repo.Name).Str("unique_url", repo.UniqueURL).Msg("Configure the sender to send hooks to this url.")
+	return nil
+}
+
+// UpdateHook only validates the settings, the sender has to be reconfigured manually.
+func (g *Generic) UpdateHook(ctx context.Context, repo *models.Repository) error {
+	if repo.Generic == nil {
+		return nil
+	}
+	return repo.Generic.Validate()
+}
+
+// DeleteHook doesn't delete anything, since there is nothing registered remotely.
+func (g *Generic) DeleteHook(ctx context.Context, repo *models.Repository) error {
+	return nil
+}
+
+// ValidateRequest checks the signature of the request with the settings of the repository.
+func (g *Generic) ValidateRequest(ctx context.Context, req *http.Request, repoID int) error {
+	log := g.Logger.With().Int("repository_id", repoID).Logger()
+	settings, err := g.settings(ctx, repoID)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get generic settings.")
+		return err
+	}
+	repoAuth, err := g.AuthProvider.GetRepositoryAuth(ctx, repoID)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get Repository Auth information.")
+		return err
+	}
+	if repoAuth == nil || repoAuth.Secret == "" {
+		log.Debug().Msg("Auth is not present.")
+		return errors.New("no auth specified")
+	}
+
+	header := req.Header.Get(settings.SignatureHeader)
+	if header == "" {
+		return fmt.Errorf("missing signature header %s", settings.SignatureHeader)
+	}
+	body, err := readBody(req)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to read request body.")
+		return err
+	}
+
+	var expected string
+	if settings.SignatureAlgorithm == models.SignatureToken {
+		expected = repoAuth.Secret
+	} else {
+		var h func() hash.Hash
+		switch settings.SignatureAlgorithm {
+		case models.SignatureSHA1:
+			h = sha1.New
+		case models.SignatureSHA256:
+			h = sha256.New
+		case models.SignatureSHA512:
+			h = sha512.New
+		default:
+			return fmt.Errorf("unsupported signature algorithm %q", settings.SignatureAlgorithm)
+		}
+		mac := hmac.New(h, []byte(repoAuth.Secret))
+		_, _ = mac.Write(body)
+		expected = hex.EncodeToString(mac.Sum(nil))
+	}
+	// Some senders, like PagerDuty, send more than one signature while rotating secrets.
+	for _, signature := range strings.Split(header, ",") {
+		signature = strings.TrimPrefix(strings.TrimSpace(signature), settings.SignaturePrefix)
+		if subtle.ConstantTimeCompare([]byte(signature), []byte(expected)) == 1 {
+			return nil
+		}
+	}
+	log.Debug().Msg("Signature of the request does not match.")
+	return errors.New("invalid signature")
+}
+
+// GetEventID returns the ID of the event from the configured header or generates one.
+func (g *Generic) GetEventID(ctx context.Context, r *http.Request) (string, error) {
+	if repo, ok := providers.RepositoryFromContext(ctx); ok && repo.Generic != nil && repo.Generic.EventIDHeader != "" {
+		if id := r.Header.Get(repo.Generic.EventIDHeader); id != "" {
+			return id, nil
+		}
+	}
+	return g.UUIDGenerator.Generate()
+}
+
+// GetEventType returns the type of the event from the configured header or the configured
+// location in the payload.
+func (g *Generic) GetEventType(ctx context.Context, r *http.Request) (string, error) {
+	repo, ok := providers.RepositoryFromContext(ctx)
+	if !ok || repo.Generic == nil {
+		return "", errors.New("no generic settings found for request")
+	}
+	settings := repo.Generic
+	if settings.EventTypeHeader != "" {
+		if event := r.Header.Get(settings.EventTypeHeader); event != "" {
+			return event, nil
+		}
+	}
+	if settings.EventTypePath != "" {
+		body, err := readBody(r)
+		if err != nil {
+			return "", err
+		}
+		if event, ok := payload.Lookup(body, settings.EventTypePath); ok && event != "" {
+			return event, nil
+		}
+	}
+	return "", fmt.Errorf("failed to get event type")
+}
+
+// settings returns the generic settings of the repository. The repository in the context is
+// used if it's the right one, otherwise it is loaded.
+func (g *Generic) settings(ctx context.Context, repoID int) (*models.Generic, error) {
+	repo, ok := providers.RepositoryFromContext(ctx)
+	if !ok || repo.ID != repoID {
+		var err error
+		if repo, err = g.RepositoryStorer.Get(ctx, repoID); err != nil {
+			return nil, err
+		}
+	}
+	if err := repo.Generic.Validate(); err != nil {
+		return nil, err
+	}
+	return repo.Generic, nil
+}
+
+// readBody reads the body of the request and puts it back, so it can be read again.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, errors.New("empty payload")
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+	return body, nil
+}
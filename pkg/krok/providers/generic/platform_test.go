@@ -0,0 +1,166 @@
+package generic
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/krok/providers/mocks"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+func newProvider(repo *models.Repository) *Generic {
+	auth := &mocks.RepositoryAuth{}
+	auth.On("GetRepositoryAuth", mock.Anything, repo.ID).Return(&models.Auth{Secret: "secret"}, nil)
+	rs := &mocks.RepositoryStorer{}
+	rs.On("Get", mock.Anything, repo.ID).Return(repo, nil)
+	uuid := &mocks.UUIDGenerator{}
+	uuid.On("Generate").Return("generated", nil)
+	return NewGenericPlatformProvider(Dependencies{
+		Logger:           zerolog.New(os.Stderr),
+		AuthProvider:     auth,
+		RepositoryStorer: rs,
+		UUIDGenerator:    uuid,
+	})
+}
+
+func sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte("secret"))
+	_, _ = mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestGeneric_ValidateRequest(t *testing.T) {
+	repo := &models.Repository{
+		ID:  1,
+		VCS: models.GENERIC,
+		Generic: &models.Generic{
+			SignatureHeader:    "X-Signature",
+			SignatureAlgorithm: models.SignatureSHA256,
+			SignaturePrefix:    "v1=",
+			EventTypePath:      "event.type",
+		},
+	}
+	npp := newProvider(repo)
+	body := []byte(`{"event": {"type": "incident.triggered"}}`)
+	newRequest := func(signature string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/hooks/1/6/callback", bytes.NewReader(body))
+		req.Header.Set("X-Signature", signature)
+		return req
+	}
+
+	err := npp.ValidateRequest(context.Background(), newRequest("v1="+sign(body)), 1)
+	assert.NoError(t, err)
+
+	err = npp.ValidateRequest(context.Background(), newRequest("v1=old, v1="+sign(body)), 1)
+	assert.NoError(t, err)
+
+	err = npp.ValidateRequest(context.Background(), newRequest("v1=invalid"), 1)
+	assert.EqualError(t, err, "invalid signature")
+
+	err = npp.ValidateRequest(context.Background(), newRequest(""), 1)
+	assert.EqualError(t, err, "missing signature header X-Signature")
+
+	// The body is still readable for the event type.
+	req := newRequest("v1=" + sign(body))
+	ctx := providers.WithRepository(context.Background(), repo)
+	assert.NoError(t, npp.ValidateRequest(ctx, req, 1))
+	event, err := npp.GetEventType(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "incident.triggered", event)
+}
+
+func TestGeneric_ValidateRequest_Token(t *testing.T) {
+	repo := &models.Repository{
+		ID:  1,
+		VCS: models.GENERIC,
+		Generic: &models.Generic{
+			SignatureHeader:    "X-Token",
+			SignatureAlgorithm: models.SignatureToken,
+			EventTypeHeader:    "X-Event",
+		},
+	}
+	npp := newProvider(repo)
+	req := httptest.NewRequest(http.MethodPost, "/hooks/1/6/callback", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("X-Token", "secret")
+	assert.NoError(t, npp.ValidateRequest(context.Background(), req, 1))
+
+	req.Header.Set("X-Token", "not-secret")
+	assert.EqualError(t, npp.ValidateRequest(context.Background(), req, 1), "invalid signature")
+}
+
+func TestGeneric_ValidateRequest_NoSettings(t *testing.T) {
+	npp := newProvider(&models.Repository{ID: 1, VCS: models.GENERIC})
+	req := httptest.NewRequest(http.MethodPost, "/hooks/1/6/callback", bytes.NewReader([]byte(`{}`)))
+	assert.EqualError(t, npp.ValidateRequest(context.Background(), req, 1), "generic settings must be defined")
+}
+
+func TestGeneric_GetEventIDAndType(t *testing.T) {
+	repo := &models.Repository{
+		ID:  1,
+		VCS: models.GENERIC,
+		Generic: &models.Generic{
+			SignatureHeader:    "X-Signature",
+			SignatureAlgorithm: models.SignatureSHA256,
+			EventTypeHeader:    "X-Event",
+			EventIDHeader:      "X-Delivery",
+		},
+	}
+	npp := newProvider(repo)
+	ctx := providers.WithRepository(context.Background(), repo)
+	header := http.Header{}
+	header.Set("X-Event", "build")
+	header.Set("X-Delivery", "ID")
+	req := &http.Request{Header: header}
+
+	id, err := npp.GetEventID(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "ID", id)
+	event, err := npp.GetEventType(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "build", event)
+
+	id, err = npp.GetEventID(ctx, &http.Request{Header: http.Header{}})
+	assert.NoError(t, err)
+	assert.Equal(t, "generated", id)
+	_, err = npp.GetEventType(context.Background(), req)
+	assert.EqualError(t, err, "no generic settings found for request")
+}
+
+func TestGeneric_CreateHook(t *testing.T) {
+	npp := newProvider(&models.Repository{ID: 1})
+	err := npp.CreateHook(context.Background(), &models.Repository{
+		Name:      "sentry",
+		VCS:       models.GENERIC,
+		UniqueURL: "https://krok.com/hooks/1/6/callback",
+		Generic: &models.Generic{
+			SignatureHeader:    "Sentry-Hook-Signature",
+			SignatureAlgorithm: models.SignatureSHA256,
+			EventTypeHeader:    "Sentry-Hook-Resource",
+		},
+	})
+	assert.NoError(t, err)
+
+	err = npp.CreateHook(context.Background(), &models.Repository{
+		Name:      "sentry",
+		VCS:       models.GENERIC,
+		UniqueURL: "https://krok.com/hooks/1/6/callback",
+		Generic: &models.Generic{
+			SignatureHeader:    "Sentry-Hook-Signature",
+			SignatureAlgorithm: "md5",
+			EventTypeHeader:    "Sentry-Hook-Resource",
+		},
+	})
+	assert.EqualError(t, err, `unsupported signature algorithm "md5"`)
+}
@@ -0,0 +1,303 @@
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/krok/providers/platformapi"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+const (
+	signatureHeader = "X-Gitea-Signature"
+	deliveryHeader  = "X-Gitea-Delivery"
+	eventHeader     = "X-Gitea-Event"
+)
+
+// Config has the configuration options for the gitea platform provider.
+type Config struct {
+	// BaseURL is the address of the Gitea instance, i.e.: https://gitea.com.
+	// If it's empty, the address is taken from the URL of the repository.
+	BaseURL string
+}
+
+// Dependencies defines the dependencies for the plugin provider.
+type Dependencies struct {
+	Logger                zerolog.Logger
+	PlatformTokenProvider providers.PlatformTokenProvider
+	AuthProvider          providers.RepositoryAuth
+}
+
+// Gitea is a Gitea based platform implementation.
+type Gitea struct {
+	Config
+	Dependencies
+
+	api *platformapi.Client
+}
+
+// NewGiteaPlatformProvider creates a new hook platform provider for Gitea.
+func NewGiteaPlatformProvider(cfg Config, deps Dependencies) *Gitea {
+	return &Gitea{Config: cfg, Dependencies: deps, api: platformapi.NewClient("gitea", func(req *http.Request, token string) {
+		req.Header.Set("Authorization", "token "+token)
+	}, deps.Logger)}
+}
+
+var _ providers.Platform = &Gitea{}
+
+// ValidateRequest will take a hook and verify it being a valid hook request according to
+// Gitea's rules. Gitea signs the payload with the secret of the hook using HMAC-SHA256.
+func (g *Gitea) ValidateRequest(ctx context.Context, req *http.Request, repoID int) error {
+	repoAuth, err := g.AuthProvider.GetRepositoryAuth(ctx, repoID)
+	if err != nil {
+		g.Logger.Debug().Err(err).Msg("Failed to get Repository Auth information.")
+		return err
+	}
+
+	if repoAuth == nil {
+		g.Logger.Debug().Msg("Auth is not present.")
+		return errors.New("no auth specified")
+	}
+
+	if req.Method != http.MethodPost {
+		return errors.New("invalid http method")
+	}
+	if req.Header.Get(eventHeader) == "" {
+		return errors.New("missing gitea event header")
+	}
+	signature := req.Header.Get(signatureHeader)
+	if signature == "" {
+		return errors.New("missing gitea signature header")
+	}
+	if req.Body == nil {
+		return errors.New("empty payload")
+	}
+	payload, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		g.Logger.Debug().Err(err).Msg("Failed to read request body.")
+		return err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewBuffer(payload))
+
+	mac := hmac.New(sha256.New, []byte(repoAuth.Secret))
+	_, _ = mac.Write(payload)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		g.Logger.Debug().Msg("Signature of the request does not match.")
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// GetEventID Based on the platform, retrieve the ID of the event.
+func (g *Gitea) GetEventID(ctx context.Context, r *http.Request) (string, error) {
+	id := r.Header.Get(deliveryHeader)
+	if id == "" {
+		return "", errors.New("event id not found for request")
+	}
+	return id, nil
+}
+
+// GetEventType Based on the platform, retrieve the Type of the event.
+func (g *Gitea) GetEventType(ctx context.Context, r *http.Request) (string, error) {
+	event := r.Header.Get(eventHeader)
+	if len(event) == 0 {
+		return "", fmt.Errorf("failed to get event type")
+	}
+	return event, nil
+}
+
+// createHookOption is the body of the create hook call of the Gitea API.
+type createHookOption struct {
+	Type   string            `json:"type"`
+	Config map[string]string `json:"config"`
+	Events []string          `json:"events"`
+	Active bool              `json:"active"`
+}
+
+// editHookOption is the body of the edit hook call of the Gitea API.
+type editHookOption struct {
+	Config map[string]string `json:"config"`
+	Events []string          `json:"events,omitempty"`
+	Active bool              `json:"active"`
+}
+
+// hook is the part of the Gitea hook response which krok cares about.
+type hook struct {
+	ID int64 `json:"id"`
+}
+
+// scpURLRegex matches scp like git urls, i.e.: git@gitea.com:owner/repo.git.
+var scpURLRegex = regexp.MustCompile("^[^@/]+@([^/:]+):(.+)$")
+
+// parseRepoURL returns the address of the Gitea instance, the owner and the name of
+// the repository from the URL of the repository.
+func parseRepoURL(raw string) (string, string, string, error) {
+	var base, p string
+	if m := scpURLRegex.FindStringSubmatch(raw); m != nil {
+		base, p = "https://"+m[1], m[2]
+	} else {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return "", "", "", err
+		}
+		if u.Host == "" {
+			return "", "", "", fmt.Errorf("no host in url %q", raw)
+		}
+		scheme := u.Scheme
+		if scheme != "http" {
+			scheme = "https"
+		}
+		base, p = scheme+"://"+u.Host, u.Path
+	}
+	parts := strings.Split(strings.Trim(strings.TrimSuffix(p, ".git"), "/"), "/")
+	if len(parts) < 2 {
+		return "", "", "", fmt.Errorf("failed to find owner and repository in %q", raw)
+	}
+	// Gitea might not be hosted at the root of the domain.
+	if len(parts) > 2 {
+		base += "/" + strings.Join(parts[:len(parts)-2], "/")
+	}
+	return base, parts[len(parts)-2], parts[len(parts)-1], nil
+}
+
+// CreateHook can create a hook for the Gitea platform.
+func (g *Gitea) CreateHook(ctx context.Context, repo *models.Repository) error {
+	log := g.Logger.With().Str("unique_url", repo.UniqueURL).Str("repo", repo.Name).Strs("events", repo.Events).Logger()
+	token, err := g.PlatformTokenProvider.GetTokenForPlatform(repo.VCS)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get platform token.")
+		return err
+	}
+	if repo.Auth == nil {
+		log.Error().Msg("No auth provided for the repository.")
+		return errors.New("no auth provided with the repository")
+	}
+	if repo.Auth.Secret == "" {
+		log.Error().Msg("No secret provided for the repository.")
+		return errors.New("no secret provided to create a hook")
+	}
+	if len(repo.Events) == 0 {
+		log.Error().Msg("No events provided to subscribe to.")
+		return errors.New("no events provided to subscribe to")
+	}
+	if repo.UniqueURL == "" {
+		log.Error().Msg("Unique callback url is empty.")
+		return errors.New("unique callback url is empty")
+	}
+	hooksURL, err := g.hooksURL(repo.URL)
+	if err != nil {
+		log.Debug().Err(err).Str("url", repo.URL).Msg("Failed to extract url parameters.")
+		return errors.New("failed to extract url parameters from git url")
+	}
+
+	var h hook
+	if _, err := g.api.Do(ctx, "hook creation", http.MethodPost, hooksURL, token, createHookOption{
+		Type:   "gitea",
+		Config: hookConfig(repo),
+		Events: repo.Events,
+		Active: true,
+	}, &h); err != nil {
+		log.Debug().Err(err).Msg("CreateHook failed.")
+		return err
+	}
+	repo.HookID = strconv.FormatInt(h.ID, 10)
+	log.Debug().Str("hook_id", repo.HookID).Msg("Successfully created hook for gitea!")
+	return nil
+}
+
+// UpdateHook updates the events, secret and url of the hook of the repository.
+func (g *Gitea) UpdateHook(ctx context.Context, repo *models.Repository) error {
+	log := g.Logger.With().Str("unique_url", repo.UniqueURL).Str("repo", repo.Name).Str("hook_id", repo.HookID).Logger()
+	if repo.HookID == "" {
+		return errors.New("repository has no hook id")
+	}
+	if repo.Auth == nil || repo.Auth.Secret == "" {
+		log.Error().Msg("No secret provided for the repository.")
+		return errors.New("no secret provided to update a hook")
+	}
+	token, err := g.PlatformTokenProvider.GetTokenForPlatform(repo.VCS)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get platform token.")
+		return err
+	}
+	hooksURL, err := g.hooksURL(repo.URL)
+	if err != nil {
+		log.Debug().Err(err).Str("url", repo.URL).Msg("Failed to extract url parameters.")
+		return errors.New("failed to extract url parameters from git url")
+	}
+	if _, err := g.api.Do(ctx, "hook update", http.MethodPatch, hooksURL+"/"+url.PathEscape(repo.HookID), token, editHookOption{
+		Config: hookConfig(repo),
+		Events: repo.Events,
+		Active: true,
+	}, nil); err != nil {
+		log.Debug().Err(err).Msg("UpdateHook failed.")
+		return err
+	}
+	log.Debug().Msg("Successfully updated hook for gitea!")
+	return nil
+}
+
+// DeleteHook deletes the hook of the repository.
+func (g *Gitea) DeleteHook(ctx context.Context, repo *models.Repository) error {
+	log := g.Logger.With().Str("repo", repo.Name).Str("hook_id", repo.HookID).Logger()
+	if repo.HookID == "" {
+		return errors.New("repository has no hook id")
+	}
+	token, err := g.PlatformTokenProvider.GetTokenForPlatform(repo.VCS)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get platform token.")
+		return err
+	}
+	hooksURL, err := g.hooksURL(repo.URL)
+	if err != nil {
+		log.Debug().Err(err).Str("url", repo.URL).Msg("Failed to extract url parameters.")
+		return errors.New("failed to extract url parameters from git url")
+	}
+	code, err := g.api.Do(ctx, "hook deletion", http.MethodDelete, hooksURL+"/"+url.PathEscape(repo.HookID), token, nil, nil)
+	if code == http.StatusNotFound {
+		log.Debug().Msg("Hook is already gone.")
+		return nil
+	}
+	if err != nil {
+		log.Debug().Err(err).Msg("DeleteHook failed.")
+		return err
+	}
+	log.Debug().Msg("Successfully deleted hook for gitea!")
+	return nil
+}
+
+// hookConfig returns the configuration of the hook of a repository.
+func hookConfig(repo *models.Repository) map[string]string {
+	return map[string]string{
+		"url":          repo.UniqueURL,
+		"content_type": "json",
+		"secret":       repo.Auth.Secret,
+	}
+}
+
+// hooksURL returns the api url of the hooks of a repository.
+func (g *Gitea) hooksURL(repoURL string) (string, error) {
+	baseURL, owner, repoName, err := parseRepoURL(repoURL)
+	if err != nil {
+		return "", err
+	}
+	if g.BaseURL != "" {
+		baseURL = g.BaseURL
+	}
+	return fmt.Sprintf("%s/api/v1/repos/%s/%s/hooks", strings.TrimSuffix(baseURL, "/"), url.PathEscape(owner), url.PathEscape(repoName)), nil
+}
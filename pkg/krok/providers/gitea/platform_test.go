@@ -0,0 +1,230 @@
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+type mockAuthProvider struct {
+	providers.RepositoryAuth
+	getAuth *models.Auth
+}
+
+func (mp *mockAuthProvider) GetRepositoryAuth(ctx context.Context, id int) (*models.Auth, error) {
+	return mp.getAuth, nil
+}
+
+type mockPlatformTokenProvider struct {
+	providers.PlatformTokenProvider
+}
+
+func (mptp *mockPlatformTokenProvider) GetTokenForPlatform(vcs int) (string, error) {
+	return "token", nil
+}
+
+func newProvider(cfg Config) *Gitea {
+	return NewGiteaPlatformProvider(cfg, Dependencies{
+		Logger:                zerolog.New(os.Stderr),
+		PlatformTokenProvider: &mockPlatformTokenProvider{},
+		AuthProvider:          &mockAuthProvider{getAuth: &models.Auth{Secret: "secret"}},
+	})
+}
+
+func TestGitea_CreateHook(t *testing.T) {
+	var got createHookOption
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/v1/repos/krok-o/krok/hooks", r.URL.Path)
+		assert.Equal(t, "token token", r.Header.Get("Authorization"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": 1, "type": "gitea", "active": true}`))
+	}))
+	defer ts.Close()
+
+	npp := newProvider(Config{})
+	err := npp.CreateHook(context.Background(), &models.Repository{
+		Name:      "test",
+		URL:       ts.URL + "/krok-o/krok.git",
+		VCS:       models.GITEA,
+		Auth:      &models.Auth{Secret: "secret"},
+		UniqueURL: "https://krok.com/hooks/0/3/callback",
+		Events:    []string{"push"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, createHookOption{
+		Type: "gitea",
+		Config: map[string]string{
+			"url":          "https://krok.com/hooks/0/3/callback",
+			"content_type": "json",
+			"secret":       "secret",
+		},
+		Events: []string{"push"},
+		Active: true,
+	}, got)
+}
+
+func TestGitea_CreateHook_BaseURL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/repos/krok-o/krok/hooks", r.URL.Path)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": 1}`))
+	}))
+	defer ts.Close()
+
+	npp := newProvider(Config{BaseURL: ts.URL})
+	err := npp.CreateHook(context.Background(), &models.Repository{
+		Name:      "test",
+		URL:       "git@gitea.com:krok-o/krok.git",
+		VCS:       models.GITEA,
+		Auth:      &models.Auth{Secret: "secret"},
+		UniqueURL: "https://krok.com/hooks/0/3/callback",
+		Events:    []string{"push"},
+	})
+	assert.NoError(t, err)
+}
+
+func TestGitea_CreateHook_Errors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"message": "not found"}`))
+	}))
+	defer ts.Close()
+
+	npp := newProvider(Config{})
+	repo := &models.Repository{
+		Name:      "test",
+		URL:       ts.URL + "/krok-o/krok",
+		VCS:       models.GITEA,
+		Auth:      &models.Auth{Secret: "secret"},
+		UniqueURL: "https://krok.com/hooks/0/3/callback",
+		Events:    []string{"push"},
+	}
+	err := npp.CreateHook(context.Background(), repo)
+	assert.EqualError(t, err, "invalid status code 404 received from hook creation")
+
+	repo.URL = "https://gitea.com/krok-o"
+	err = npp.CreateHook(context.Background(), repo)
+	assert.EqualError(t, err, "failed to extract url parameters from git url")
+
+	repo.Auth = &models.Auth{}
+	err = npp.CreateHook(context.Background(), repo)
+	assert.EqualError(t, err, "no secret provided to create a hook")
+}
+
+func TestGitea_ValidateRequest(t *testing.T) {
+	payload := []byte(`{"ref": "refs/heads/main"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	_, _ = mac.Write(payload)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	newRequest := func(signature string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/hooks/1/3/callback", bytes.NewReader(payload))
+		req.Header.Set("X-Gitea-Event", "push")
+		req.Header.Set("X-Gitea-Signature", signature)
+		return req
+	}
+
+	npp := newProvider(Config{})
+	err := npp.ValidateRequest(context.Background(), newRequest(signature), 1)
+	assert.NoError(t, err)
+
+	err = npp.ValidateRequest(context.Background(), newRequest("invalid"), 1)
+	assert.EqualError(t, err, "invalid signature")
+
+	err = npp.ValidateRequest(context.Background(), newRequest(""), 1)
+	assert.EqualError(t, err, "missing gitea signature header")
+}
+
+func TestGitea_GetEventID(t *testing.T) {
+	npp := newProvider(Config{})
+	header := http.Header{}
+	header.Add("X-Gitea-Delivery", "ID")
+	id, err := npp.GetEventID(context.Background(), &http.Request{
+		Header: header,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "ID", id)
+
+	_, err = npp.GetEventID(context.Background(), &http.Request{})
+	assert.EqualError(t, err, "event id not found for request")
+}
+
+func TestGitea_GetEventType(t *testing.T) {
+	npp := newProvider(Config{})
+	header := http.Header{}
+	header.Add("X-Gitea-Event", "push")
+	event, err := npp.GetEventType(context.Background(), &http.Request{
+		Header: header,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "push", event)
+
+	_, err = npp.GetEventType(context.Background(), &http.Request{Header: http.Header{}})
+	assert.Error(t, err)
+}
+
+func TestGitea_UpdateAndDeleteHook(t *testing.T) {
+	var (
+		method string
+		path   string
+		got    editHookOption
+	)
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		if r.Method == http.MethodPatch {
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"id": 1}`))
+	}))
+	defer ts.Close()
+
+	npp := newProvider(Config{})
+	repo := &models.Repository{
+		Name:      "test",
+		URL:       ts.URL + "/krok-o/krok",
+		VCS:       models.GITEA,
+		Auth:      &models.Auth{Secret: "new-secret"},
+		UniqueURL: "https://krok.com/hooks/0/3/callback",
+		Events:    []string{"push", "pull_request"},
+		HookID:    "1",
+	}
+	err := npp.UpdateHook(context.Background(), repo)
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPatch, method)
+	assert.Equal(t, "/api/v1/repos/krok-o/krok/hooks/1", path)
+	assert.Equal(t, []string{"push", "pull_request"}, got.Events)
+	assert.Equal(t, "new-secret", got.Config["secret"])
+
+	status = http.StatusNoContent
+	err = npp.DeleteHook(context.Background(), repo)
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodDelete, method)
+	assert.Equal(t, "/api/v1/repos/krok-o/krok/hooks/1", path)
+
+	// A hook which is already gone is not an error.
+	status = http.StatusNotFound
+	err = npp.DeleteHook(context.Background(), repo)
+	assert.NoError(t, err)
+
+	status = http.StatusInternalServerError
+	err = npp.DeleteHook(context.Background(), repo)
+	assert.EqualError(t, err, "invalid status code 500 received from hook deletion")
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"path"
 	"regexp"
+	"strconv"
 	"strings"
 
 	ggithub "github.com/google/go-github/github"
@@ -109,6 +110,8 @@ func (g *Github) ValidateRequest(ctx context.Context, req *http.Request, repoID
 // needed to test the GitHub client.
 type GoogleGithubRepoService interface {
 	CreateHook(ctx context.Context, owner, repo string, hook *ggithub.Hook) (*ggithub.Hook, *ggithub.Response, error)
+	EditHook(ctx context.Context, owner, repo string, id int64, hook *ggithub.Hook) (*ggithub.Hook, *ggithub.Response, error)
+	DeleteHook(ctx context.Context, owner, repo string, id int64) (*ggithub.Response, error)
 }
 
 // GoogleGithubClient is a client that has the ability to replace the actual
@@ -174,36 +177,17 @@ func (g *Github) CreateHook(ctx context.Context, repo *models.Repository) error
 		log.Error().Msg("Unique callback url is empty.")
 		return errors.New("unique callback url is empty")
 	}
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: token},
-	)
-	tc := oauth2.NewClient(ctx, ts)
-	config := make(map[string]interface{})
-	config["url"] = repo.UniqueURL
-	config["secret"] = repo.Auth.Secret
-	config["content_type"] = "json"
-
-	// figure out a way to mock this nicely later on.
-	githubClient := NewGoogleGithubClient(tc, g.repoMock)
-	repoName := path.Base(repo.URL)
-	repoName = strings.TrimSuffix(repoName, ".git")
-	// var repoLocation string
-	re := regexp.MustCompile("^(https|git)(://|@)([^/:]+)[/:]([^/:]+)/(.+)$")
-	m := re.FindAllStringSubmatch(repo.URL, -1)
-	if m == nil {
-		log.Debug().Str("repo_name", repoName).Str("url", repo.URL).Msg("Failed to extract url parameters.")
-		return errors.New("failed to extract url parameters from git url")
-	}
-	if len(m[0]) < 5 {
-		log.Debug().Str("repo_name", repoName).Str("url", repo.URL).Msg("Couldn't find the repo user from the URL.")
-		return errors.New("failed to extract repo user from the url")
+	repoUser, repoName, err := ownerAndName(repo.URL)
+	if err != nil {
+		log.Debug().Err(err).Str("url", repo.URL).Msg("Failed to extract url parameters.")
+		return err
 	}
-	repoUser := m[0][4]
+	githubClient := g.client(ctx, token)
 	hook, resp, err := githubClient.Repositories.CreateHook(context.Background(), repoUser, repoName, &ggithub.Hook{
 		Events: repo.Events,
 		Name:   ggithub.String("web"),
 		Active: ggithub.Bool(true),
-		Config: config,
+		Config: hookConfig(repo),
 	})
 	if err != nil {
 		log.Debug().Err(err).Msg("CreateHook failed.")
@@ -213,6 +197,112 @@ func (g *Github) CreateHook(ctx context.Context, repo *models.Repository) error
 		log.Error().Msg("invalid status code")
 		return fmt.Errorf("invalid status code %d received from hook creation", resp.StatusCode)
 	}
-	g.Logger.Debug().Str("name", *hook.Name).Msg("Hook with name successfully created.")
+	if hook.ID != nil {
+		repo.HookID = strconv.FormatInt(*hook.ID, 10)
+	}
+	g.Logger.Debug().Str("name", hook.GetName()).Str("hook_id", repo.HookID).Msg("Hook with name successfully created.")
+	return nil
+}
+
+// UpdateHook updates the events, secret and url of the hook of the repository.
+func (g *Github) UpdateHook(ctx context.Context, repo *models.Repository) error {
+	log := g.Logger.With().Str("unique_url", repo.UniqueURL).Str("repo", repo.Name).Str("hook_id", repo.HookID).Logger()
+	id, err := strconv.ParseInt(repo.HookID, 10, 64)
+	if err != nil {
+		log.Debug().Err(err).Msg("Invalid hook id.")
+		return fmt.Errorf("invalid hook id %q: %w", repo.HookID, err)
+	}
+	if repo.Auth == nil || repo.Auth.Secret == "" {
+		log.Error().Msg("No secret provided for the repository.")
+		return errors.New("no secret provided to update a hook")
+	}
+	token, err := g.PlatformTokenProvider.GetTokenForPlatform(repo.VCS)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get platform token.")
+		return err
+	}
+	owner, name, err := ownerAndName(repo.URL)
+	if err != nil {
+		log.Debug().Err(err).Str("url", repo.URL).Msg("Failed to extract url parameters.")
+		return err
+	}
+	hook := &ggithub.Hook{
+		Active: ggithub.Bool(true),
+		Config: hookConfig(repo),
+	}
+	if len(repo.Events) > 0 {
+		hook.Events = repo.Events
+	}
+	if _, _, err := g.client(ctx, token).Repositories.EditHook(ctx, owner, name, id, hook); err != nil {
+		log.Debug().Err(err).Msg("EditHook failed.")
+		return err
+	}
+	log.Debug().Msg("Hook successfully updated.")
 	return nil
 }
+
+// DeleteHook deletes the hook of the repository.
+func (g *Github) DeleteHook(ctx context.Context, repo *models.Repository) error {
+	log := g.Logger.With().Str("repo", repo.Name).Str("hook_id", repo.HookID).Logger()
+	id, err := strconv.ParseInt(repo.HookID, 10, 64)
+	if err != nil {
+		log.Debug().Err(err).Msg("Invalid hook id.")
+		return fmt.Errorf("invalid hook id %q: %w", repo.HookID, err)
+	}
+	token, err := g.PlatformTokenProvider.GetTokenForPlatform(repo.VCS)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get platform token.")
+		return err
+	}
+	owner, name, err := ownerAndName(repo.URL)
+	if err != nil {
+		log.Debug().Err(err).Str("url", repo.URL).Msg("Failed to extract url parameters.")
+		return err
+	}
+	resp, err := g.client(ctx, token).Repositories.DeleteHook(ctx, owner, name, id)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			log.Debug().Msg("Hook is already gone.")
+			return nil
+		}
+		log.Debug().Err(err).Msg("DeleteHook failed.")
+		return err
+	}
+	log.Debug().Msg("Hook successfully deleted.")
+	return nil
+}
+
+// client returns a GitHub client authenticated with the given token.
+func (g *Github) client(ctx context.Context, token string) GoogleGithubClient {
+	ts := oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: token},
+	)
+	tc := oauth2.NewClient(ctx, ts)
+	// figure out a way to mock this nicely later on.
+	return NewGoogleGithubClient(tc, g.repoMock)
+}
+
+// hookConfig returns the configuration of the hook of a repository.
+func hookConfig(repo *models.Repository) map[string]interface{} {
+	config := make(map[string]interface{})
+	config["url"] = repo.UniqueURL
+	config["secret"] = repo.Auth.Secret
+	config["content_type"] = "json"
+	return config
+}
+
+var repoURLRegex = regexp.MustCompile("^(https|git)(://|@)([^/:]+)[/:]([^/:]+)/(.+)$")
+
+// ownerAndName returns the owner and the name of a repository from its url.
+func ownerAndName(url string) (string, string, error) {
+	repoName := path.Base(url)
+	repoName = strings.TrimSuffix(repoName, ".git")
+	m := repoURLRegex.FindAllStringSubmatch(url, -1)
+	if m == nil {
+		return "", "", errors.New("failed to extract url parameters from git url")
+	}
+	if len(m[0]) < 5 {
+		return "", "", errors.New("failed to extract repo user from the url")
+	}
+	return m[0][4], repoName, nil
+}
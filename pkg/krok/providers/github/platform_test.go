@@ -38,6 +38,7 @@ type mockGithubRepositoryService struct {
 	Error    error
 	Owner    string
 	Repo     string
+	ID       int64
 }
 
 func (mgc *mockGithubRepositoryService) CreateHook(ctx context.Context, owner, repo string, hook *github.Hook) (*github.Hook, *github.Response, error) {
@@ -50,6 +51,21 @@ func (mgc *mockGithubRepositoryService) CreateHook(ctx context.Context, owner, r
 	return mgc.Hook, mgc.Response, mgc.Error
 }
 
+func (mgc *mockGithubRepositoryService) EditHook(ctx context.Context, owner, repo string, id int64, hook *github.Hook) (*github.Hook, *github.Response, error) {
+	if id != mgc.ID {
+		return nil, nil, errors.New("id did not equal expected id")
+	}
+	mgc.Hook = hook
+	return hook, mgc.Response, mgc.Error
+}
+
+func (mgc *mockGithubRepositoryService) DeleteHook(ctx context.Context, owner, repo string, id int64) (*github.Response, error) {
+	if owner != mgc.Owner || repo != mgc.Repo || id != mgc.ID {
+		return nil, errors.New("unexpected hook")
+	}
+	return mgc.Response, mgc.Error
+}
+
 func TestGithub_CreateHook(t *testing.T) {
 	cliLogger := zerolog.New(os.Stderr)
 	mp := &mockAuthProvider{
@@ -68,6 +84,7 @@ func TestGithub_CreateHook(t *testing.T) {
 	})
 	mock := &mockGithubRepositoryService{}
 	mock.Hook = &github.Hook{
+		ID:   github.Int64(44321286),
 		Name: github.String("test hook"),
 		URL:  github.String("https://api.github.com/repos/krok-o/krok/hooks/44321286/test"),
 	}
@@ -79,7 +96,7 @@ func TestGithub_CreateHook(t *testing.T) {
 	mock.Owner = "krok-o"
 	mock.Repo = "krok"
 	npp.repoMock = mock
-	err := npp.CreateHook(context.Background(), &models.Repository{
+	repo := &models.Repository{
 		Name:      "test",
 		ID:        0,
 		URL:       "https://github.com/krok-o/krok",
@@ -87,8 +104,52 @@ func TestGithub_CreateHook(t *testing.T) {
 		Auth:      &models.Auth{Secret: "secret"},
 		UniqueURL: "https://krok.com/hooks/0/0/callback",
 		Events:    []string{"push"},
+	}
+	err := npp.CreateHook(context.Background(), repo)
+	assert.NoError(t, err)
+	assert.Equal(t, "44321286", repo.HookID)
+}
+
+func TestGithub_UpdateAndDeleteHook(t *testing.T) {
+	npp := NewGithubPlatformProvider(Dependencies{
+		Logger:                zerolog.New(os.Stderr),
+		PlatformTokenProvider: &mockPlatformTokenProvider{},
 	})
+	mock := &mockGithubRepositoryService{
+		Owner: "krok-o",
+		Repo:  "krok",
+		ID:    44321286,
+		Response: &github.Response{
+			Response: &http.Response{StatusCode: http.StatusOK},
+		},
+	}
+	npp.repoMock = mock
+	repo := &models.Repository{
+		Name:      "test",
+		URL:       "https://github.com/krok-o/krok",
+		VCS:       models.GITHUB,
+		Auth:      &models.Auth{Secret: "new-secret"},
+		UniqueURL: "https://krok.com/hooks/0/1/callback",
+		Events:    []string{"push", "pull_request"},
+		HookID:    "44321286",
+	}
+	err := npp.UpdateHook(context.Background(), repo)
 	assert.NoError(t, err)
+	assert.Equal(t, []string{"push", "pull_request"}, mock.Hook.Events)
+	assert.Equal(t, "new-secret", mock.Hook.Config["secret"])
+
+	err = npp.DeleteHook(context.Background(), repo)
+	assert.NoError(t, err)
+
+	// A hook which is already gone is not an error.
+	mock.Response = &github.Response{Response: &http.Response{StatusCode: http.StatusNotFound}}
+	mock.Error = errors.New("not found")
+	err = npp.DeleteHook(context.Background(), repo)
+	assert.NoError(t, err)
+
+	repo.HookID = ""
+	err = npp.DeleteHook(context.Background(), repo)
+	assert.Error(t, err)
 }
 
 func TestGithub_CreateHook_InvalidURL(t *testing.T) {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 
 	"github.com/rs/zerolog"
 	ggitlab "github.com/xanzy/go-gitlab"
@@ -114,15 +115,112 @@ func (g *Gitlab) CreateHook(ctx context.Context, repo *models.Repository) error
 		log.Error().Msg("Project ID must not be empty for a gitlab repository.")
 	}
 
-	var opts []ggitlab.ClientOptionFunc
-	if g.httpClient != nil {
-		opts = append(opts, ggitlab.WithHTTPClient(g.httpClient))
+	git, err := g.client(token)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create gitlab client.")
+		return errors.New("failed to create gitlab client")
+	}
+	hookOpts, err := hookOptions(repo)
+	if err != nil {
+		return err
+	}
+	// TODO: Project ID can either be a name or an ID. Consider trying to match that. I can store
+	// an integer serialized to bytes in the DB. https://golang.org/pkg/encoding/binary/#example_Write
+	pid := repo.GitLab.GetProjectID()
+	hook, response, err := git.Projects.AddProjectHook(pid, hookOpts)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to create hook.")
+		log.Debug().Int("code", response.StatusCode).Msg("Status code of the response.")
+		body, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			log.Debug().Err(err).Msg("Failed to read response body.")
+			return err
+		}
+		log.Debug().Str("body", string(body)).Msg("The body of the response.")
+		return err
+	}
+	repo.HookID = strconv.Itoa(hook.ID)
+	log.Debug().Str("url", hook.URL).Str("hook_id", repo.HookID).Msg("Successfully created hook for gitlab!")
+	return nil
+}
+
+// UpdateHook updates the events, secret and url of the hook of the repository.
+func (g *Gitlab) UpdateHook(ctx context.Context, repo *models.Repository) error {
+	log := g.Logger.With().Str("unique_url", repo.UniqueURL).Str("repo", repo.Name).Str("hook_id", repo.HookID).Logger()
+	id, err := strconv.Atoi(repo.HookID)
+	if err != nil {
+		log.Debug().Err(err).Msg("Invalid hook id.")
+		return fmt.Errorf("invalid hook id %q: %w", repo.HookID, err)
+	}
+	if repo.Auth == nil || repo.Auth.Secret == "" {
+		log.Error().Msg("No secret provided for the repository.")
+		return errors.New("no secret provided to update a hook")
+	}
+	token, err := g.PlatformTokenProvider.GetTokenForPlatform(repo.VCS)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get platform token.")
+		return err
+	}
+	git, err := g.client(token)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create gitlab client.")
+		return errors.New("failed to create gitlab client")
+	}
+	hookOpts, err := hookOptions(repo)
+	if err != nil {
+		return err
+	}
+	editOpts := ggitlab.EditProjectHookOptions(*hookOpts)
+	if _, _, err := git.Projects.EditProjectHook(repo.GitLab.GetProjectID(), id, &editOpts); err != nil {
+		log.Debug().Err(err).Msg("Failed to update hook.")
+		return err
 	}
-	git, err := ggitlab.NewClient(token, opts...)
+	log.Debug().Msg("Successfully updated hook for gitlab!")
+	return nil
+}
+
+// DeleteHook deletes the hook of the repository.
+func (g *Gitlab) DeleteHook(ctx context.Context, repo *models.Repository) error {
+	log := g.Logger.With().Str("repo", repo.Name).Str("hook_id", repo.HookID).Logger()
+	id, err := strconv.Atoi(repo.HookID)
+	if err != nil {
+		log.Debug().Err(err).Msg("Invalid hook id.")
+		return fmt.Errorf("invalid hook id %q: %w", repo.HookID, err)
+	}
+	token, err := g.PlatformTokenProvider.GetTokenForPlatform(repo.VCS)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to get platform token.")
+		return err
+	}
+	git, err := g.client(token)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create gitlab client.")
 		return errors.New("failed to create gitlab client")
 	}
+	response, err := git.Projects.DeleteProjectHook(repo.GitLab.GetProjectID(), id)
+	if err != nil {
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			log.Debug().Msg("Hook is already gone.")
+			return nil
+		}
+		log.Debug().Err(err).Msg("Failed to delete hook.")
+		return err
+	}
+	log.Debug().Msg("Successfully deleted hook for gitlab!")
+	return nil
+}
+
+// client returns a gitlab client authenticated with the given token.
+func (g *Gitlab) client(token string) (*ggitlab.Client, error) {
+	var opts []ggitlab.ClientOptionFunc
+	if g.httpClient != nil {
+		opts = append(opts, ggitlab.WithHTTPClient(g.httpClient))
+	}
+	return ggitlab.NewClient(token, opts...)
+}
+
+// hookOptions returns the options of the hook for the repository.
+func hookOptions(repo *models.Repository) (*ggitlab.AddProjectHookOptions, error) {
 	hookOpts := &ggitlab.AddProjectHookOptions{
 		Token: &repo.Auth.Secret,
 		URL:   &repo.UniqueURL,
@@ -155,24 +253,8 @@ func (g *Gitlab) CreateHook(ctx context.Context, repo *models.Repository) error
 		case "ReleasesEvents":
 			hookOpts.ReleasesEvents = ggitlab.Bool(true)
 		default:
-			return fmt.Errorf("invalid event type %q", event)
+			return nil, fmt.Errorf("invalid event type %q", event)
 		}
 	}
-	// TODO: Project ID can either be a name or an ID. Consider trying to match that. I can store
-	// an integer serialized to bytes in the DB. https://golang.org/pkg/encoding/binary/#example_Write
-	pid := repo.GitLab.GetProjectID()
-	hook, response, err := git.Projects.AddProjectHook(pid, hookOpts)
-	if err != nil {
-		log.Debug().Err(err).Msg("Failed to create hook.")
-		log.Debug().Int("code", response.StatusCode).Msg("Status code of the response.")
-		body, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			log.Debug().Err(err).Msg("Failed to read response body.")
-			return err
-		}
-		log.Debug().Str("body", string(body)).Msg("The body of the response.")
-		return err
-	}
-	log.Debug().Str("url", hook.URL).Msg("Successfully created hook for gitlab!")
-	return nil
+	return hookOpts, nil
 }
@@ -0,0 +1,108 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+type mockPlatformTokenProvider struct {
+	providers.PlatformTokenProvider
+}
+
+func (mptp *mockPlatformTokenProvider) GetTokenForPlatform(vcs int) (string, error) {
+	return "token", nil
+}
+
+// redirect sends all requests of the gitlab client to the test server.
+type redirect struct {
+	target *url.URL
+}
+
+func (r *redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = r.target.Scheme
+	req.URL.Host = r.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newProvider(t *testing.T, handler http.HandlerFunc) *Gitlab {
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	target, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+	g := NewGitlabPlatformProvider(Dependencies{
+		Logger:                zerolog.New(os.Stderr),
+		PlatformTokenProvider: &mockPlatformTokenProvider{},
+	})
+	g.httpClient = &http.Client{Transport: &redirect{target: target}}
+	return g
+}
+
+func TestGitlab_HookLifecycle(t *testing.T) {
+	var (
+		method string
+		path   string
+		body   map[string]interface{}
+	)
+	g := newProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		method, path, body = r.Method, r.URL.Path, nil
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch r.Method {
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id": 42, "url": "https://krok.com/hooks/1/2/callback"}`))
+		}
+	})
+	repo := &models.Repository{
+		Name:      "test",
+		ID:        1,
+		VCS:       models.GITLAB,
+		GitLab:    &models.GitLab{ProjectID: 10},
+		Auth:      &models.Auth{Secret: "secret"},
+		UniqueURL: "https://krok.com/hooks/1/2/callback",
+		Events:    []string{"PushEvents"},
+	}
+
+	err := g.CreateHook(context.Background(), repo)
+	require.NoError(t, err)
+	assert.Equal(t, "42", repo.HookID)
+	assert.Equal(t, http.MethodPost, method)
+	assert.Equal(t, "/api/v4/projects/10/hooks", path)
+
+	repo.Events = []string{"PushEvents", "TagPushEvents"}
+	err = g.UpdateHook(context.Background(), repo)
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/api/v4/projects/10/hooks/42", path)
+	assert.Equal(t, true, body["tag_push_events"])
+
+	err = g.DeleteHook(context.Background(), repo)
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodDelete, method)
+	assert.Equal(t, "/api/v4/projects/10/hooks/42", path)
+}
+
+func TestGitlab_DeleteHook_AlreadyGone(t *testing.T) {
+	g := newProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	err := g.DeleteHook(context.Background(), &models.Repository{
+		VCS:    models.GITLAB,
+		GitLab: &models.GitLab{ProjectID: 10},
+		HookID: "42",
+	})
+	assert.NoError(t, err)
+}
@@ -29,11 +29,18 @@ type CommandHandler interface {
 	AddCommandRelForRepository() echo.HandlerFunc
 	// RemoveCommandRelForRepository remove a relation to a repository for a command.
 	RemoveCommandRelForRepository() echo.HandlerFunc
+	// UpdateCommandRelForRepository updates the filter of a relation to a repository for a command.
+	UpdateCommandRelForRepository() echo.HandlerFunc
 
 	// AddCommandRelForPlatform adds an entry for this command id to the given platform id.
 	AddCommandRelForPlatform() echo.HandlerFunc
 	// RemoveCommandRelForPlatform remove a relation to a platform for a command.
 	RemoveCommandRelForPlatform() echo.HandlerFunc
+
+	// AddCommandDependency defines that a command depends on another command.
+	AddCommandDependency() echo.HandlerFunc
+	// RemoveCommandDependency removes a dependency of a command.
+	RemoveCommandDependency() echo.HandlerFunc
 }
 
 // APIKeysHandler provides functions which define operations on api key pairs.
@@ -54,11 +61,22 @@ type VCSTokenHandler interface {
 	Create() echo.HandlerFunc
 }
 
+// RegistryCredentialHandler provides operations to manage the credentials of container registries.
+type RegistryCredentialHandler interface {
+	Create() echo.HandlerFunc
+	Delete() echo.HandlerFunc
+}
+
 // UserMiddleware provides UserMiddleware authentication capabilities.
 type UserMiddleware interface {
 	JWT() echo.MiddlewareFunc
 }
 
+// RunnerMiddleware authenticates remote runners.
+type RunnerMiddleware interface {
+	Token() echo.MiddlewareFunc
+}
+
 // AuthHandler provides the handler functions for the authentication flow.
 type AuthHandler interface {
 	OAuthLogin() echo.HandlerFunc
@@ -90,6 +108,11 @@ type SupportedPlatformListHandler interface {
 type EventHandler interface {
 	List() echo.HandlerFunc
 	Get() echo.HandlerFunc
+	ListArtifacts() echo.HandlerFunc
+	GetArtifact() echo.HandlerFunc
+	CancelRun() echo.HandlerFunc
+	Rerun() echo.HandlerFunc
+	Trigger() echo.HandlerFunc
 }
 
 // VaultHandler defines operations for the secure vault.
@@ -113,6 +136,19 @@ type UserHandler interface {
 // CommandRunHandler deals with command run details.
 type CommandRunHandler interface {
 	GetCommandRun() echo.HandlerFunc
+	StreamCommandRunLogs() echo.HandlerFunc
+	ListQueue() echo.HandlerFunc
+	GetQueuedRun() echo.HandlerFunc
+	CancelCommandRun() echo.HandlerFunc
+}
+
+// RunnerHandler provides the operations remote runners pull command runs with.
+type RunnerHandler interface {
+	Register() echo.HandlerFunc
+	List() echo.HandlerFunc
+	Claim() echo.HandlerFunc
+	AppendLogs() echo.HandlerFunc
+	Finish() echo.HandlerFunc
 }
 
 // ReadyHandler provides a ready handler for the ready provider.
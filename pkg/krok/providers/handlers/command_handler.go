@@ -1,14 +1,18 @@
 package handlers
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
 
 	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/payload"
 	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/krok/providers/scheduler"
 	"github.com/krok-o/krok/pkg/models"
 )
 
@@ -217,6 +221,26 @@ func (ch *CommandsHandler) Create() echo.HandlerFunc {
 		if command.Image == "" {
 			return c.JSON(http.StatusBadRequest, kerr.APIError("image must be defined", http.StatusBadRequest, errors.New("image must be defined")))
 		}
+		if command.Schedule != "" {
+			if err := scheduler.ValidateSchedule(command.Schedule); err != nil {
+				return c.JSON(http.StatusBadRequest, kerr.APIError("invalid schedule", http.StatusBadRequest, err))
+			}
+		}
+		if err := validateLimits(command); err != nil {
+			return c.JSON(http.StatusBadRequest, kerr.APIError("invalid limits", http.StatusBadRequest, err))
+		}
+		if err := validateStopPolicy(command); err != nil {
+			return c.JSON(http.StatusBadRequest, kerr.APIError("invalid stop policy", http.StatusBadRequest, err))
+		}
+		if err := validateRetryPolicy(command.Retry); err != nil {
+			return c.JSON(http.StatusBadRequest, kerr.APIError("invalid retry policy", http.StatusBadRequest, err))
+		}
+		if err := validateConcurrencyPolicy(command.Concurrency); err != nil {
+			return c.JSON(http.StatusBadRequest, kerr.APIError("invalid concurrency policy", http.StatusBadRequest, err))
+		}
+		if err := validatePullPolicy(command.PullPolicy); err != nil {
+			return c.JSON(http.StatusBadRequest, kerr.APIError("invalid pull policy", http.StatusBadRequest, err))
+		}
 		// check if name is already taken:
 		if _, err := ch.CommandStorer.GetByName(c.Request().Context(), command.Name); err == nil {
 			return c.JSON(http.StatusBadRequest, kerr.APIError("command with name already taken", http.StatusBadRequest, err))
@@ -264,6 +288,26 @@ func (ch *CommandsHandler) Update() echo.HandlerFunc {
 			ch.Logger.Debug().Err(err).Msg("Failed to bind command.")
 			return c.JSON(http.StatusBadRequest, kerr.APIError("failed to bind command", http.StatusBadRequest, err))
 		}
+		if command.Schedule != "" {
+			if err := scheduler.ValidateSchedule(command.Schedule); err != nil {
+				return c.JSON(http.StatusBadRequest, kerr.APIError("invalid schedule", http.StatusBadRequest, err))
+			}
+		}
+		if err := validateLimits(command); err != nil {
+			return c.JSON(http.StatusBadRequest, kerr.APIError("invalid limits", http.StatusBadRequest, err))
+		}
+		if err := validateStopPolicy(command); err != nil {
+			return c.JSON(http.StatusBadRequest, kerr.APIError("invalid stop policy", http.StatusBadRequest, err))
+		}
+		if err := validateRetryPolicy(command.Retry); err != nil {
+			return c.JSON(http.StatusBadRequest, kerr.APIError("invalid retry policy", http.StatusBadRequest, err))
+		}
+		if err := validateConcurrencyPolicy(command.Concurrency); err != nil {
+			return c.JSON(http.StatusBadRequest, kerr.APIError("invalid concurrency policy", http.StatusBadRequest, err))
+		}
+		if err := validatePullPolicy(command.PullPolicy); err != nil {
+			return c.JSON(http.StatusBadRequest, kerr.APIError("invalid pull policy", http.StatusBadRequest, err))
+		}
 
 		ctx := c.Request().Context()
 
@@ -280,6 +324,96 @@ func (ch *CommandsHandler) Update() echo.HandlerFunc {
 	}
 }
 
+// validateLimits makes sure that none of the limits of a command are negative. A limit of 0 means
+// that the command isn't limited, or in case of the timeout, that the default is used.
+func validateLimits(command *models.Command) error {
+	switch {
+	case command.Timeout < 0:
+		return errors.New("timeout must not be negative")
+	case command.CPUs < 0:
+		return errors.New("cpus must not be negative")
+	case command.MemoryLimit < 0:
+		return errors.New("memory limit must not be negative")
+	case command.PidsLimit < 0:
+		return errors.New("pids limit must not be negative")
+	case command.DiskLimit < 0:
+		return errors.New("disk limit must not be negative")
+	case command.MaxParallelRuns < 0:
+		return errors.New("max parallel runs must not be negative")
+	}
+	return nil
+}
+
+// stopSignals are the signals a command can be stopped with.
+var stopSignals = map[string]bool{
+	"SIGTERM": true,
+	"SIGINT":  true,
+	"SIGQUIT": true,
+	"SIGHUP":  true,
+	"SIGUSR1": true,
+	"SIGUSR2": true,
+	"SIGKILL": true,
+}
+
+// validateStopPolicy makes sure that a command is stopped with a known signal. Empty values mean the defaults are used.
+func validateStopPolicy(command *models.Command) error {
+	if command.StopSignal != "" && !stopSignals[command.StopSignal] {
+		return fmt.Errorf("unsupported stop signal %s", command.StopSignal)
+	}
+	if command.StopGracePeriod < 0 {
+		return errors.New("stop grace period must not be negative")
+	}
+	return nil
+}
+
+// maxRetryAttempts is the maximum number of attempts a retry policy can define.
+const maxRetryAttempts = 10
+
+// retryFailures are the failures a command can be retried for.
+var retryFailures = map[string]bool{
+	models.RetryOnPullFailure: true,
+	models.RetryOnNonZeroExit: true,
+	models.RetryOnTimeout:     true,
+}
+
+// validateRetryPolicy makes sure that a command is retried a limited number of times for known failures.
+func validateRetryPolicy(policy *models.RetryPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	switch {
+	case policy.MaxAttempts < 0:
+		return errors.New("max attempts must not be negative")
+	case policy.MaxAttempts > maxRetryAttempts:
+		return fmt.Errorf("max attempts must not be above %d", maxRetryAttempts)
+	case policy.Backoff < 0:
+		return errors.New("backoff must not be negative")
+	}
+	for _, failure := range policy.RetryOn {
+		if !retryFailures[failure] {
+			return fmt.Errorf("unsupported retry failure %s", failure)
+		}
+	}
+	return nil
+}
+
+// validateConcurrencyPolicy makes sure that the runs of a command are grouped by something.
+func validateConcurrencyPolicy(policy *models.ConcurrencyPolicy) error {
+	if policy != nil && policy.Group == "" {
+		return errors.New("concurrency group must not be empty")
+	}
+	return nil
+}
+
+// validatePullPolicy makes sure that the pull policy of a command is known. An empty policy means always.
+func validatePullPolicy(policy string) error {
+	switch policy {
+	case "", models.PullPolicyAlways, models.PullPolicyIfNotPresent, models.PullPolicyNever:
+		return nil
+	}
+	return fmt.Errorf("unknown pull policy %s", policy)
+}
+
 // AddCommandRelForRepository adds a command relationship to a repository.
 // swagger:operation POST /command/add-command-rel-for-repository/{cmdid}/{repoid} addCommandRelForRepositoryCommand
 // Add a connection to a repository. This will make this command to be executed for events for that repository.
@@ -328,6 +462,70 @@ func (ch *CommandsHandler) AddCommandRelForRepository() echo.HandlerFunc {
 	}
 }
 
+// UpdateCommandRelForRepository updates the filter of a command relationship to a repository.
+// swagger:operation POST /command/update-command-rel-for-repository/{cmdid}/{repoid} updateCommandRelForRepositoryCommand
+// Restrict the events of a repository for which this command is executed. An empty filter runs the command for every event.
+// ---
+// consumes:
+// - application/json
+// parameters:
+// - name: cmdid
+//   in: path
+//   required: true
+//   type: integer
+//   format: int
+// - name: repoid
+//   in: path
+//   required: true
+//   type: integer
+//   format: int
+// - name: filter
+//   in: body
+//   required: true
+//   schema:
+//     "$ref": "#/definitions/CommandFilter"
+// responses:
+//   '200':
+//     description: 'successfully updated relationship'
+//   '400':
+//     description: 'invalid ids or filter'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '500':
+//     description: 'failed to update relationship'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (ch *CommandsHandler) UpdateCommandRelForRepository() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		cn, err := GetParamAsInt("cmdid", c)
+		if err != nil {
+			apiError := kerr.APIError("invalid command id", http.StatusBadRequest, nil)
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+		rn, err := GetParamAsInt("repoid", c)
+		if err != nil {
+			apiError := kerr.APIError("invalid repo id", http.StatusBadRequest, nil)
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+		filter := &models.CommandFilter{}
+		if err := c.Bind(filter); err != nil {
+			apiError := kerr.APIError("failed to bind filter", http.StatusBadRequest, err)
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+		if err := payload.ValidateFilter(filter); err != nil {
+			apiError := kerr.APIError("invalid filter", http.StatusBadRequest, err)
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+		ctx := c.Request().Context()
+
+		if err := ch.CommandStorer.UpdateCommandRelForRepository(ctx, cn, rn, filter); err != nil {
+			ch.Logger.Debug().Err(err).Msg("UpdateCommandRelForRepository failed.")
+			return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to update command relationship to repository", http.StatusInternalServerError, err))
+		}
+		return c.NoContent(http.StatusOK)
+	}
+}
+
 // RemoveCommandRelForRepository removes a relationship of a command from a repository.
 // swagger:operation POST /command/remove-command-rel-for-repository/{cmdid}/{repoid} removeCommandRelForRepositoryCommand
 // Remove a relationship to a repository. This command will no longer be running for that repository events.
@@ -483,3 +681,139 @@ func (ch *CommandsHandler) RemoveCommandRelForPlatform() echo.HandlerFunc {
 		return c.NoContent(http.StatusOK)
 	}
 }
+
+// AddCommandDependency adds a dependency to a command.
+// swagger:operation POST /command/add-command-dependency/{cmdid}/{depid} addCommandDependency
+// Defines that a command depends on another command. If both commands are part of the same run,
+// the command only runs after the dependency succeeded. If the dependency fails, the command is skipped.
+// ---
+// parameters:
+// - name: cmdid
+//   in: path
+//   required: true
+//   type: integer
+//   format: int
+// - name: depid
+//   in: path
+//   required: true
+//   type: integer
+//   format: int
+// responses:
+//   '200':
+//     description: 'successfully added dependency'
+//   '400':
+//     description: 'invalid ids or the dependency would create a cycle'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '500':
+//     description: 'failed to add command dependency'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (ch *CommandsHandler) AddCommandDependency() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		cn, err := GetParamAsInt("cmdid", c)
+		if err != nil {
+			apiError := kerr.APIError("invalid command id", http.StatusBadRequest, nil)
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+
+		dn, err := GetParamAsInt("depid", c)
+		if err != nil {
+			apiError := kerr.APIError("invalid dependency id", http.StatusBadRequest, nil)
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+
+		ctx := c.Request().Context()
+
+		cycle, err := ch.dependsOn(ctx, dn, cn)
+		if err != nil {
+			ch.Logger.Debug().Err(err).Msg("Failed to check dependencies.")
+			return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to add command dependency", http.StatusInternalServerError, err))
+		}
+		if cycle {
+			apiError := kerr.APIError("dependency would create a cycle", http.StatusBadRequest, nil)
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+
+		if err := ch.CommandStorer.AddCommandDependency(ctx, cn, dn); err != nil {
+			ch.Logger.Debug().Err(err).Msg("AddCommandDependency failed.")
+			return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to add command dependency", http.StatusInternalServerError, err))
+		}
+
+		return c.NoContent(http.StatusOK)
+	}
+}
+
+// dependsOn returns true if the command, or any of its dependencies, depends on target.
+// A command is considered to depend on itself.
+func (ch *CommandsHandler) dependsOn(ctx context.Context, commandID, target int) (bool, error) {
+	visited := make(map[int]struct{})
+	queue := []int{commandID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == target {
+			return true, nil
+		}
+		if _, ok := visited[id]; ok {
+			continue
+		}
+		visited[id] = struct{}{}
+		deps, err := ch.CommandStorer.GetCommandDependencies(ctx, id)
+		if err != nil {
+			return false, err
+		}
+		queue = append(queue, deps...)
+	}
+	return false, nil
+}
+
+// RemoveCommandDependency removes a dependency of a command.
+// swagger:operation POST /command/remove-command-dependency/{cmdid}/{depid} removeCommandDependency
+// Removes a dependency of a command.
+// ---
+// parameters:
+// - name: cmdid
+//   in: path
+//   required: true
+//   type: integer
+//   format: int
+// - name: depid
+//   in: path
+//   required: true
+//   type: integer
+//   format: int
+// responses:
+//   '200':
+//     description: 'successfully removed dependency'
+//   '400':
+//     description: 'invalid ids'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '500':
+//     description: 'failed to remove command dependency'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (ch *CommandsHandler) RemoveCommandDependency() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		cn, err := GetParamAsInt("cmdid", c)
+		if err != nil {
+			apiError := kerr.APIError("invalid command id", http.StatusBadRequest, nil)
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+		dn, err := GetParamAsInt("depid", c)
+		if err != nil {
+			apiError := kerr.APIError("invalid dependency id", http.StatusBadRequest, nil)
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+
+		ctx := c.Request().Context()
+
+		if err := ch.CommandStorer.RemoveCommandDependency(ctx, cn, dn); err != nil {
+			ch.Logger.Debug().Err(err).Msg("RemoveCommandDependency failed.")
+			return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to remove command dependency", http.StatusInternalServerError, err))
+		}
+
+		return c.NoContent(http.StatusOK)
+	}
+}
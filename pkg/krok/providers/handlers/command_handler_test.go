@@ -297,6 +297,131 @@ func TestCommandsHandler_UpdateCommand(t *testing.T) {
 		assert.Equal(tt, commandExpected, rec.Body.String())
 	})
 
+	t.Run("update invalid schedule", func(tt *testing.T) {
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		commandPost := `{"name":"test-command1","id":0,"schedule":"not a schedule","image":"krokhook/slack-notification:v0.0.1","enabled":true,"requires_clone":false}`
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/command/update", strings.NewReader(commandPost))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		err = ch.Update()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("update limits", func(tt *testing.T) {
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		commandPost := `{"name":"test-command1","id":0,"image":"krokhook/slack-notification:v0.0.1","enabled":true,"requires_clone":false,"timeout":60,"cpus":0.5,"memory_limit":268435456,"pids_limit":100,"disk_limit":1073741824}`
+		commandExpected := `{"name":"test-command1","id":0,"image":"krokhook/slack-notification:v0.0.1","enabled":true,"requires_clone":false,"timeout":60,"cpus":0.5,"memory_limit":268435456,"pids_limit":100,"disk_limit":1073741824}
+`
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/command/update", strings.NewReader(commandPost))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		err = ch.Update()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusOK, rec.Code)
+		assert.Equal(tt, commandExpected, rec.Body.String())
+	})
+
+	t.Run("update negative limit", func(tt *testing.T) {
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		commandPost := `{"name":"test-command1","id":0,"image":"krokhook/slack-notification:v0.0.1","enabled":true,"memory_limit":-1}`
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/command/update", strings.NewReader(commandPost))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		err = ch.Update()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusBadRequest, rec.Code)
+		assert.Equal(tt, `{"code":400,"message":"invalid limits","error":"memory limit must not be negative"}
+`, rec.Body.String())
+	})
+
+	t.Run("update unsupported stop signal", func(tt *testing.T) {
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		commandPost := `{"name":"test-command1","id":0,"image":"krokhook/slack-notification:v0.0.1","enabled":true,"stop_signal":"SIGSTOP"}`
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/command/update", strings.NewReader(commandPost))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		err = ch.Update()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusBadRequest, rec.Code)
+		assert.Equal(tt, `{"code":400,"message":"invalid stop policy","error":"unsupported stop signal SIGSTOP"}
+`, rec.Body.String())
+	})
+
+	t.Run("update unsupported retry failure", func(tt *testing.T) {
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		commandPost := `{"name":"test-command1","id":0,"image":"krokhook/slack-notification:v0.0.1","enabled":true,"retry":{"max_attempts":3,"retry_on":["oom"]}}`
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/command/update", strings.NewReader(commandPost))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		err = ch.Update()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusBadRequest, rec.Code)
+		assert.Equal(tt, `{"code":400,"message":"invalid retry policy","error":"unsupported retry failure oom"}
+`, rec.Body.String())
+	})
+
+	t.Run("update concurrency policy without group", func(tt *testing.T) {
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		commandPost := `{"name":"test-command1","id":0,"image":"krokhook/slack-notification:v0.0.1","enabled":true,"concurrency":{"cancel_in_progress":true}}`
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/command/update", strings.NewReader(commandPost))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		err = ch.Update()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusBadRequest, rec.Code)
+		assert.Equal(tt, `{"code":400,"message":"invalid concurrency policy","error":"concurrency group must not be empty"}
+`, rec.Body.String())
+	})
+
+	t.Run("update unknown pull policy", func(tt *testing.T) {
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		commandPost := `{"name":"test-command1","id":0,"image":"krokhook/slack-notification:v0.0.1","enabled":true,"pull_policy":"sometimes"}`
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/command/update", strings.NewReader(commandPost))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		err = ch.Update()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusBadRequest, rec.Code)
+		assert.Equal(tt, `{"code":400,"message":"invalid pull policy","error":"unknown pull policy sometimes"}
+`, rec.Body.String())
+	})
+
 	t.Run("update invalid syntax on body", func(tt *testing.T) {
 		token, err := generateTestToken("test@email.com")
 		assert.NoError(tt, err)
@@ -768,3 +893,161 @@ func TestCommandsHandler_CreateCommand(t *testing.T) {
 `, string(body))
 	})
 }
+
+func TestCommandsHandler_AddCommandDependency(t *testing.T) {
+	mcs := &mocks.CommandStorer{}
+	// 1 -> 2 -> 3
+	mcs.On("GetCommandDependencies", mock.Anything, 1).Return([]int{2}, nil)
+	mcs.On("GetCommandDependencies", mock.Anything, 2).Return([]int{3}, nil)
+	mcs.On("GetCommandDependencies", mock.Anything, 3).Return(nil, nil)
+	mcs.On("GetCommandDependencies", mock.Anything, 4).Return(nil, nil)
+	mcs.On("AddCommandDependency", mock.Anything, 4, 1).Return(nil)
+	logger := zerolog.New(os.Stderr)
+	ch := NewCommandsHandler(CommandsHandlerDependencies{
+		Logger:        logger,
+		CommandStorer: mcs,
+	})
+
+	t.Run("add dependency happy path", func(tt *testing.T) {
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		rec := httptest.NewRecorder()
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		c := e.NewContext(req, rec)
+		c.SetPath("/command/add-command-dependency/:cmdid/:depid")
+		c.SetParamNames("cmdid", "depid")
+		c.SetParamValues("4", "1")
+		err = ch.AddCommandDependency()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusOK, rec.Code)
+		mcs.AssertCalled(tt, "AddCommandDependency", mock.Anything, 4, 1)
+	})
+
+	t.Run("add dependency which would create a cycle", func(tt *testing.T) {
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		rec := httptest.NewRecorder()
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		c := e.NewContext(req, rec)
+		c.SetPath("/command/add-command-dependency/:cmdid/:depid")
+		c.SetParamNames("cmdid", "depid")
+		c.SetParamValues("3", "1")
+		err = ch.AddCommandDependency()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusBadRequest, rec.Code)
+		mcs.AssertNotCalled(tt, "AddCommandDependency", mock.Anything, 3, 1)
+	})
+
+	t.Run("add dependency on itself", func(tt *testing.T) {
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		rec := httptest.NewRecorder()
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		c := e.NewContext(req, rec)
+		c.SetPath("/command/add-command-dependency/:cmdid/:depid")
+		c.SetParamNames("cmdid", "depid")
+		c.SetParamValues("4", "4")
+		err = ch.AddCommandDependency()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("add dependency invalid dependency id", func(tt *testing.T) {
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		rec := httptest.NewRecorder()
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		c := e.NewContext(req, rec)
+		c.SetPath("/command/add-command-dependency/:cmdid/:depid")
+		c.SetParamNames("cmdid", "depid")
+		c.SetParamValues("4", "invalid")
+		err = ch.AddCommandDependency()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestCommandsHandler_RemoveCommandDependency(t *testing.T) {
+	mcs := &mocks.CommandStorer{}
+	mcs.On("RemoveCommandDependency", mock.Anything, 1, 2).Return(nil)
+	logger := zerolog.New(os.Stderr)
+	ch := NewCommandsHandler(CommandsHandlerDependencies{
+		Logger:        logger,
+		CommandStorer: mcs,
+	})
+	token, err := generateTestToken("test@email.com")
+	assert.NoError(t, err)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	rec := httptest.NewRecorder()
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	c := e.NewContext(req, rec)
+	c.SetPath("/command/remove-command-dependency/:cmdid/:depid")
+	c.SetParamNames("cmdid", "depid")
+	c.SetParamValues("1", "2")
+	err = ch.RemoveCommandDependency()(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestCommandsHandler_UpdateCommandRelForRepository(t *testing.T) {
+	mcs := &mocks.CommandStorer{}
+	mcs.On("UpdateCommandRelForRepository", mock.Anything, 1, 2, &models.CommandFilter{
+		EventTypes: []string{"push", "pull_request"},
+		Branch:     "release/*",
+	}).Return(nil)
+	logger := zerolog.New(os.Stderr)
+	ch := NewCommandsHandler(CommandsHandlerDependencies{
+		Logger:        logger,
+		CommandStorer: mcs,
+	})
+
+	t.Run("update relation happy path", func(tt *testing.T) {
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"event_types":["push","pull_request"],"branch":"release/*"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		c := e.NewContext(req, rec)
+		c.SetPath("/command/update-command-rel-for-repository/:cmdid/:repoid")
+		c.SetParamNames("cmdid", "repoid")
+		c.SetParamValues("1", "2")
+		err = ch.UpdateCommandRelForRepository()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusOK, rec.Code)
+	})
+
+	t.Run("update relation invalid branch pattern", func(tt *testing.T) {
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"branch":"release/["}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		c := e.NewContext(req, rec)
+		c.SetPath("/command/update-command-rel-for-repository/:cmdid/:repoid")
+		c.SetParamNames("cmdid", "repoid")
+		c.SetParamValues("1", "2")
+		err = ch.UpdateCommandRelForRepository()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	kerr "github.com/krok-o/krok/errors"
 	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/models"
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
 )
@@ -14,11 +20,16 @@ import (
 type CommandRunHandlerDependencies struct {
 	Logger           zerolog.Logger
 	CommandRunStorer providers.CommandRunStorer
+	LogStreamer      providers.LogStreamer
+	Executor         providers.Executor
 }
 
 // CommandRunHandler is a handler taking care of commands related api calls.
 type CommandRunHandler struct {
 	CommandRunHandlerDependencies
+
+	// pollInterval is how often a command run which hasn't started yet is checked for a log stream.
+	pollInterval time.Duration
 }
 
 var _ providers.CommandRunHandler = &CommandRunHandler{}
@@ -27,6 +38,7 @@ var _ providers.CommandRunHandler = &CommandRunHandler{}
 func NewCommandRunHandler(deps CommandRunHandlerDependencies) *CommandRunHandler {
 	return &CommandRunHandler{
 		CommandRunHandlerDependencies: deps,
+		pollInterval:                  time.Second,
 	}
 }
 
@@ -75,3 +87,269 @@ func (cm *CommandRunHandler) GetCommandRun() echo.HandlerFunc {
 		return c.JSON(http.StatusOK, cr)
 	}
 }
+
+// CancelCommandRun cancels a single command run.
+// swagger:operation POST /command/run/{id}/cancel cancelCommandRun
+// Cancels a command run which is still waiting or running and marks it as cancelled.
+// The commands which depend on it are skipped.
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   type: integer
+//   format: int
+//   required: true
+// responses:
+//   '200':
+//     description: 'the command run has been cancelled'
+//   '400':
+//     description: 'invalid command run id'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '404':
+//     description: 'command run is not running'
+//   '500':
+//     description: 'failed to cancel command run'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (cm *CommandRunHandler) CancelCommandRun() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		n, err := GetParamAsInt("id", c)
+		if err != nil {
+			kapiErr := kerr.APIError("failed to parse parameter", http.StatusBadRequest, err)
+			return c.JSON(http.StatusBadRequest, kapiErr)
+		}
+		if err := cm.Executor.CancelCommandRun(c.Request().Context(), n); err != nil {
+			if errors.Is(err, kerr.ErrNotFound) {
+				kapiErr := kerr.APIError("command run is not running", http.StatusNotFound, err)
+				return c.JSON(http.StatusNotFound, kapiErr)
+			}
+			cm.Logger.Debug().Err(err).Int("id", n).Msg("Failed to cancel command run.")
+			kapiErr := kerr.APIError("failed to cancel command run", http.StatusInternalServerError, err)
+			return c.JSON(http.StatusInternalServerError, kapiErr)
+		}
+		return c.NoContent(http.StatusOK)
+	}
+}
+
+// ListQueue returns the command runs which wait for a free slot.
+// swagger:operation GET /command/runs/queue listQueue
+// Returns the command runs which wait for a free slot in the order they are expected to start.
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     schema:
+//       type: array
+//       items:
+//         "$ref": "#/definitions/QueuedRun"
+//   '500':
+//     description: 'failed to get queue'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (cm *CommandRunHandler) ListQueue() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		queue, err := cm.Executor.Queue(c.Request().Context())
+		if err != nil {
+			cm.Logger.Debug().Err(err).Msg("Failed to get queue.")
+			kapiErr := kerr.APIError("failed to get queue", http.StatusInternalServerError, err)
+			return c.JSON(http.StatusInternalServerError, kapiErr)
+		}
+		return c.JSON(http.StatusOK, queue)
+	}
+}
+
+// GetQueuedRun returns the place of a command run in the queue.
+// swagger:operation GET /command/run/{id}/queue getQueuedRun
+// Returns the position of a command run in the queue and since when it's waiting.
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   type: integer
+//   format: int
+//   required: true
+// responses:
+//   '200':
+//     schema:
+//       "$ref": "#/definitions/QueuedRun"
+//   '400':
+//     description: 'invalid command run id'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '404':
+//     description: 'command run is not queued'
+//   '500':
+//     description: 'failed to get queue'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (cm *CommandRunHandler) GetQueuedRun() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		n, err := GetParamAsInt("id", c)
+		if err != nil {
+			kapiErr := kerr.APIError("failed to parse parameter", http.StatusBadRequest, err)
+			return c.JSON(http.StatusBadRequest, kapiErr)
+		}
+		queue, err := cm.Executor.Queue(c.Request().Context())
+		if err != nil {
+			cm.Logger.Debug().Err(err).Msg("Failed to get queue.")
+			kapiErr := kerr.APIError("failed to get queue", http.StatusInternalServerError, err)
+			return c.JSON(http.StatusInternalServerError, kapiErr)
+		}
+		for _, run := range queue {
+			if run.CommandRunID == n {
+				return c.JSON(http.StatusOK, run)
+			}
+		}
+		kapiErr := kerr.APIError("command run is not queued", http.StatusNotFound, kerr.ErrNotFound)
+		return c.JSON(http.StatusNotFound, kapiErr)
+	}
+}
+
+// StreamCommandRunLogs streams the logs of a command run as server-sent events.
+// swagger:operation GET /command/run/{id}/logs streamCommandRunLogs
+// Streams the logs of a command run. The logs written so far are sent first, followed by
+// the live output of the command. Once the command finishes, a final `done` event is sent
+// with the status of the run as data. Runs which haven't started yet are waited for and
+// for finished runs only the saved outcome is sent.
+// ---
+// produces:
+// - text/event-stream
+// parameters:
+// - name: id
+//   in: path
+//   type: integer
+//   format: int
+//   required: true
+// responses:
+//   '200':
+//     description: 'the log stream of the command run'
+//   '400':
+//     description: 'invalid command run id'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '404':
+//     description: 'command run not found'
+//   '500':
+//     description: 'failed to get command run'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (cm *CommandRunHandler) StreamCommandRunLogs() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		n, err := GetParamAsInt("id", c)
+		if err != nil {
+			kapiErr := kerr.APIError("failed to parse parameter", http.StatusBadRequest, err)
+			return c.JSON(http.StatusBadRequest, kapiErr)
+		}
+		ctx := c.Request().Context()
+		cr, err := cm.CommandRunStorer.Get(ctx, n)
+		if err != nil {
+			if errors.Is(err, kerr.ErrNotFound) {
+				kapiErr := kerr.APIError("command run not found", http.StatusNotFound, err)
+				return c.JSON(http.StatusNotFound, kapiErr)
+			}
+			kapiErr := kerr.APIError("failed to get command run", http.StatusInternalServerError, err)
+			return c.JSON(http.StatusInternalServerError, kapiErr)
+		}
+
+		backlog, logs, unsubscribe, err := cm.LogStreamer.Subscribe(n)
+		if err != nil && !errors.Is(err, kerr.ErrNotFound) {
+			kapiErr := kerr.APIError("failed to subscribe to logs", http.StatusInternalServerError, err)
+			return c.JSON(http.StatusInternalServerError, kapiErr)
+		}
+
+		resp := c.Response()
+		resp.Header().Set(echo.HeaderContentType, "text/event-stream")
+		resp.Header().Set(echo.HeaderCacheControl, "no-cache")
+		resp.Header().Set(echo.HeaderConnection, "keep-alive")
+		resp.WriteHeader(http.StatusOK)
+
+		for errors.Is(err, kerr.ErrNotFound) {
+			if !unfinished(cr.Status) {
+				// The command isn't running anymore, send what has been saved.
+				outcome, uerr := strconv.Unquote(cr.Outcome)
+				if uerr != nil {
+					outcome = cr.Outcome
+				}
+				writeEvent(resp, "", []byte(outcome))
+				writeEvent(resp, "done", []byte(cr.Status))
+				resp.Flush()
+				return nil
+			}
+			// The command is waiting for its turn or its image, so there is no log stream yet.
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(cm.pollInterval):
+			}
+			backlog, logs, unsubscribe, err = cm.LogStreamer.Subscribe(n)
+			if err != nil && !errors.Is(err, kerr.ErrNotFound) {
+				cm.Logger.Debug().Err(err).Int("id", n).Msg("Failed to subscribe to logs.")
+				writeEvent(resp, "done", []byte(cm.finalStatus(ctx, n)))
+				resp.Flush()
+				return nil
+			}
+			if errors.Is(err, kerr.ErrNotFound) {
+				if cr, err = cm.CommandRunStorer.Get(ctx, n); err != nil {
+					cm.Logger.Debug().Err(err).Int("id", n).Msg("Failed to get command run.")
+					writeEvent(resp, "done", []byte("unknown"))
+					resp.Flush()
+					return nil
+				}
+				err = kerr.ErrNotFound
+			}
+		}
+		defer unsubscribe()
+
+		writeEvent(resp, "", backlog)
+		resp.Flush()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case chunk, ok := <-logs:
+				if !ok {
+					writeEvent(resp, "done", []byte(cm.finalStatus(ctx, n)))
+					resp.Flush()
+					return nil
+				}
+				writeEvent(resp, "", chunk)
+				resp.Flush()
+			}
+		}
+	}
+}
+
+// unfinished returns true if a command run with the status might still produce logs.
+func unfinished(status string) bool {
+	return status == models.RunStatusCreated || status == models.RunStatusRunning
+}
+
+// finalStatus returns the status of a command run after its log stream ended.
+func (cm *CommandRunHandler) finalStatus(ctx context.Context, id int) string {
+	cr, err := cm.CommandRunStorer.Get(ctx, id)
+	if err != nil {
+		cm.Logger.Debug().Err(err).Int("id", id).Msg("Failed to get command run.")
+		return "unknown"
+	}
+	return cr.Status
+}
+
+// writeEvent writes a server-sent event. Every line of data is sent as a separate data field.
+func writeEvent(w *echo.Response, event string, data []byte) {
+	if len(data) == 0 && event == "" {
+		return
+	}
+	if event != "" {
+		_, _ = fmt.Fprintf(w, "event: %s\n", event)
+	}
+	for _, line := range bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")) {
+		_, _ = fmt.Fprintf(w, "data: %s\n", line)
+	}
+	_, _ = fmt.Fprint(w, "\n")
+}
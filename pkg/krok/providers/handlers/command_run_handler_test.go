@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -28,6 +29,7 @@ func TestCommandRunHandler_GetCommandRun(t *testing.T) {
 			ID:          1,
 			EventID:     1,
 			CommandName: "echo",
+			Attempt:     2,
 			Status:      "success",
 			Outcome:     "this is echoed",
 			CreateAt:    time.Date(1981, 1, 1, 1, 1, 1, 1, time.UTC),
@@ -38,7 +40,7 @@ func TestCommandRunHandler_GetCommandRun(t *testing.T) {
 		})
 		token, err := generateTestToken("test@email.com")
 		assert.NoError(tt, err)
-		commandRunExpected := `{"id":1,"event_id":1,"command_name":"echo","status":"success","outcome":"this is echoed","create_at":"1981-01-01T01:01:01.000000001Z"}
+		commandRunExpected := `{"id":1,"event_id":1,"command_name":"echo","attempt":2,"status":"success","outcome":"this is echoed","create_at":"1981-01-01T01:01:01.000000001Z"}
 `
 		e := echo.New()
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -119,3 +121,294 @@ func TestCommandRunHandler_GetCommandRun(t *testing.T) {
 		mcrs.AssertNotCalled(tt, "Get", mock.Anything, 1)
 	})
 }
+
+func TestCommandRunHandler_StreamCommandRunLogs(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+
+	t.Run("when the command is running the backlog and the live logs are streamed", func(tt *testing.T) {
+		mcrs := &mocks.CommandRunStorer{}
+		mcrs.On("Get", mock.Anything, 1).Return(&models.CommandRun{
+			ID:          1,
+			EventID:     1,
+			CommandName: "echo",
+			Status:      "running",
+		}, nil).Once()
+		mcrs.On("Get", mock.Anything, 1).Return(&models.CommandRun{
+			ID:          1,
+			EventID:     1,
+			CommandName: "echo",
+			Status:      "success",
+		}, nil).Once()
+		logs := make(chan []byte, 2)
+		logs <- []byte("line 2\nline 3\n")
+		close(logs)
+		mls := &mocks.LogStreamer{}
+		mls.On("Subscribe", 1).Return([]byte("line 1\n"), (<-chan []byte)(logs), func() {}, nil)
+		ch := NewCommandRunHandler(CommandRunHandlerDependencies{
+			Logger:           logger,
+			CommandRunStorer: mcrs,
+			LogStreamer:      mls,
+		})
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/command/run/:id/logs")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		err := ch.StreamCommandRunLogs()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusOK, rec.Code)
+		assert.Equal(tt, "text/event-stream", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(tt, "data: line 1\n\ndata: line 2\ndata: line 3\n\nevent: done\ndata: success\n\n", rec.Body.String())
+	})
+
+	t.Run("when the command has finished the saved outcome is sent", func(tt *testing.T) {
+		mcrs := &mocks.CommandRunStorer{}
+		mcrs.On("Get", mock.Anything, 1).Return(&models.CommandRun{
+			ID:          1,
+			EventID:     1,
+			CommandName: "echo",
+			Status:      "failed",
+			Outcome:     "\"oh no\\n\"",
+		}, nil)
+		mls := &mocks.LogStreamer{}
+		mls.On("Subscribe", 1).Return(nil, nil, nil, kerr.ErrNotFound)
+		ch := NewCommandRunHandler(CommandRunHandlerDependencies{
+			Logger:           logger,
+			CommandRunStorer: mcrs,
+			LogStreamer:      mls,
+		})
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/command/run/:id/logs")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		err := ch.StreamCommandRunLogs()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, "data: oh no\n\nevent: done\ndata: failed\n\n", rec.Body.String())
+	})
+
+	t.Run("when the command has not started yet its log stream is waited for", func(tt *testing.T) {
+		mcrs := &mocks.CommandRunStorer{}
+		mcrs.On("Get", mock.Anything, 1).Return(&models.CommandRun{
+			ID:          1,
+			EventID:     1,
+			CommandName: "echo",
+			Status:      "created",
+		}, nil).Twice()
+		mcrs.On("Get", mock.Anything, 1).Return(&models.CommandRun{
+			ID:          1,
+			EventID:     1,
+			CommandName: "echo",
+			Status:      "success",
+		}, nil).Once()
+		logs := make(chan []byte)
+		close(logs)
+		mls := &mocks.LogStreamer{}
+		mls.On("Subscribe", 1).Return(nil, nil, nil, kerr.ErrNotFound).Twice()
+		mls.On("Subscribe", 1).Return([]byte("started\n"), (<-chan []byte)(logs), func() {}, nil).Once()
+		ch := NewCommandRunHandler(CommandRunHandlerDependencies{
+			Logger:           logger,
+			CommandRunStorer: mcrs,
+			LogStreamer:      mls,
+		})
+		ch.pollInterval = time.Millisecond
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/command/run/:id/logs")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		err := ch.StreamCommandRunLogs()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, "data: started\n\nevent: done\ndata: success\n\n", rec.Body.String())
+		mcrs.AssertExpectations(tt)
+		mls.AssertExpectations(tt)
+	})
+
+	t.Run("when the command is cancelled before it started the saved outcome is sent", func(tt *testing.T) {
+		mcrs := &mocks.CommandRunStorer{}
+		mcrs.On("Get", mock.Anything, 1).Return(&models.CommandRun{
+			ID:          1,
+			EventID:     1,
+			CommandName: "echo",
+			Status:      "created",
+		}, nil).Once()
+		mcrs.On("Get", mock.Anything, 1).Return(&models.CommandRun{
+			ID:          1,
+			EventID:     1,
+			CommandName: "echo",
+			Status:      "cancelled",
+			Outcome:     "\"cancelled before the command started\"",
+		}, nil).Once()
+		mls := &mocks.LogStreamer{}
+		mls.On("Subscribe", 1).Return(nil, nil, nil, kerr.ErrNotFound)
+		ch := NewCommandRunHandler(CommandRunHandlerDependencies{
+			Logger:           logger,
+			CommandRunStorer: mcrs,
+			LogStreamer:      mls,
+		})
+		ch.pollInterval = time.Millisecond
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/command/run/:id/logs")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		err := ch.StreamCommandRunLogs()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, "data: cancelled before the command started\n\nevent: done\ndata: cancelled\n\n", rec.Body.String())
+	})
+
+	t.Run("when the command run does not exist", func(tt *testing.T) {
+		mcrs := &mocks.CommandRunStorer{}
+		mcrs.On("Get", mock.Anything, 1).Return(nil, kerr.ErrNotFound)
+		mls := &mocks.LogStreamer{}
+		ch := NewCommandRunHandler(CommandRunHandlerDependencies{
+			Logger:           logger,
+			CommandRunStorer: mcrs,
+			LogStreamer:      mls,
+		})
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/command/run/:id/logs")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		err := ch.StreamCommandRunLogs()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusNotFound, rec.Code)
+		mls.AssertNotCalled(tt, "Subscribe", 1)
+	})
+}
+
+func TestCommandRunHandler_Queue(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	queue := []*models.QueuedRun{
+		{
+			CommandRunID: 2,
+			EventID:      1,
+			RepositoryID: 1,
+			CommandName:  "echo",
+			Position:     1,
+			WaitingSince: time.Date(1981, 1, 1, 1, 1, 1, 1, time.UTC),
+		},
+	}
+
+	t.Run("the queue is listed", func(tt *testing.T) {
+		mex := &mocks.Executor{}
+		mex.On("Queue", mock.Anything).Return(queue, nil)
+		ch := NewCommandRunHandler(CommandRunHandlerDependencies{
+			Logger:   logger,
+			Executor: mex,
+		})
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/command/runs/queue")
+		err := ch.ListQueue()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusOK, rec.Code)
+		assert.Equal(tt, `[{"command_run_id":2,"event_id":1,"repository_id":1,"command_name":"echo","priority":0,"position":1,"waiting_since":"1981-01-01T01:01:01.000000001Z"}]
+`, rec.Body.String())
+	})
+
+	t.Run("the position of a queued command run is returned", func(tt *testing.T) {
+		mex := &mocks.Executor{}
+		mex.On("Queue", mock.Anything).Return(queue, nil)
+		ch := NewCommandRunHandler(CommandRunHandlerDependencies{
+			Logger:   logger,
+			Executor: mex,
+		})
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/command/run/:id/queue")
+		c.SetParamNames("id")
+		c.SetParamValues("2")
+		err := ch.GetQueuedRun()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusOK, rec.Code)
+		assert.Contains(tt, rec.Body.String(), `"position":1`)
+	})
+
+	t.Run("a command run which is not queued is not found", func(tt *testing.T) {
+		mex := &mocks.Executor{}
+		mex.On("Queue", mock.Anything).Return(queue, nil)
+		ch := NewCommandRunHandler(CommandRunHandlerDependencies{
+			Logger:   logger,
+			Executor: mex,
+		})
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/command/run/:id/queue")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		err := ch.GetQueuedRun()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("when the queue can't be listed", func(tt *testing.T) {
+		mex := &mocks.Executor{}
+		mex.On("Queue", mock.Anything).Return(nil, errors.New("nope"))
+		ch := NewCommandRunHandler(CommandRunHandlerDependencies{
+			Logger:   logger,
+			Executor: mex,
+		})
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/command/runs/queue")
+		err := ch.ListQueue()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+func TestCommandRunHandler_CancelCommandRun(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	mex := &mocks.Executor{}
+	mex.On("CancelCommandRun", mock.Anything, 1).Return(nil)
+	mex.On("CancelCommandRun", mock.Anything, 2).Return(fmt.Errorf("command run with ID 2: %w", kerr.ErrNotFound))
+	mex.On("CancelCommandRun", mock.Anything, 3).Return(errors.New("nope"))
+	ch := NewCommandRunHandler(CommandRunHandlerDependencies{
+		Logger:   logger,
+		Executor: mex,
+	})
+	for _, tc := range []struct {
+		name string
+		id   string
+		code int
+	}{
+		{name: "a running command run is cancelled", id: "1", code: http.StatusOK},
+		{name: "a command run which is not running is not found", id: "2", code: http.StatusNotFound},
+		{name: "when the command run can't be cancelled", id: "3", code: http.StatusInternalServerError},
+		{name: "when the command run id is invalid", id: "invalid", code: http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(tt *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/command/run/:id/cancel")
+			c.SetParamNames("id")
+			c.SetParamValues(tc.id)
+			err := ch.CancelCommandRun()(c)
+			assert.NoError(tt, err)
+			assert.Equal(tt, tc.code, rec.Code)
+		})
+	}
+	mex.AssertExpectations(t)
+}
@@ -192,7 +192,7 @@ func (ch *CommandSettingsHandler) Get() echo.HandlerFunc {
 //   '200':
 //     description: 'successfully updated command setting'
 //   '400':
-//     description: 'binding error'
+//     description: 'binding error or invalid delivery'
 //     schema:
 //       "$ref": "#/responses/Message"
 //   '500':
@@ -208,6 +208,22 @@ func (ch *CommandSettingsHandler) Update() echo.HandlerFunc {
 		}
 
 		ctx := c.Request().Context()
+		// Only the value and the delivery can be updated, so the delivery is validated against the stored setting.
+		if setting.Delivery != "" {
+			stored, err := ch.CommandStorer.GetSetting(ctx, setting.ID)
+			if err != nil {
+				if errors.Is(err, kerr.ErrNotFound) {
+					return c.JSON(http.StatusNotFound, kerr.APIError("command setting not found", http.StatusNotFound, err))
+				}
+				ch.Logger.Debug().Err(err).Msg("GetSetting failed.")
+				return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to get command setting", http.StatusInternalServerError, err))
+			}
+			stored.Delivery = setting.Delivery
+			if err := stored.Validate(); err != nil {
+				ch.Logger.Debug().Err(err).Msg("Command setting validation failed.")
+				return c.JSON(http.StatusBadRequest, kerr.APIError("command setting validation failed", http.StatusBadRequest, err))
+			}
+		}
 		if err := ch.CommandStorer.UpdateSetting(ctx, setting); err != nil {
 			ch.Logger.Debug().Err(err).Msg("Command setting update failed.")
 			return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to update command setting", http.StatusInternalServerError, err))
@@ -235,7 +251,7 @@ func (ch *CommandSettingsHandler) Update() echo.HandlerFunc {
 //   '200':
 //     description: 'successfully created command setting'
 //   '400':
-//     description: 'binding error'
+//     description: 'binding error or invalid setting'
 //     schema:
 //       "$ref": "#/responses/Message"
 //   '500':
@@ -250,6 +266,11 @@ func (ch *CommandSettingsHandler) Create() echo.HandlerFunc {
 			return c.JSON(http.StatusBadRequest, kerr.APIError("failed to bind command setting", http.StatusBadRequest, err))
 		}
 
+		if err := setting.Validate(); err != nil {
+			ch.Logger.Debug().Err(err).Msg("Command setting validation failed.")
+			return c.JSON(http.StatusBadRequest, kerr.APIError("command setting validation failed", http.StatusBadRequest, err))
+		}
+
 		ctx := c.Request().Context()
 		setting, err := ch.CommandStorer.CreateSetting(ctx, setting)
 		if err != nil {
@@ -53,6 +53,44 @@ func TestCommandSettingsHandler_BasicFlow(t *testing.T) {
 		assert.Equal(tt, http.StatusCreated, rec.Code)
 		assert.Equal(tt, commandSettingsReturned, rec.Body.String())
 	})
+	t.Run("create vault setting as argument", func(tt *testing.T) {
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		commandSettingsPost := `{"command_id" : 1, "key" : "token", "value": "secret", "in_vault": true, "delivery": "arg"}`
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/commands/settings", strings.NewReader(commandSettingsPost))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		err = csh.Create()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusBadRequest, rec.Code)
+	})
+	t.Run("update delivery of vault setting to argument", func(tt *testing.T) {
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+		cs.On("GetSetting", mock.Anything, 2).Return(&models.CommandSetting{
+			ID:        2,
+			CommandID: 1,
+			Key:       "token",
+			Value:     "secret",
+			InVault:   true,
+		}, nil)
+
+		commandSettingsPost := `{"id": 2, "command_id" : 1, "key" : "token", "value": "secret", "delivery": "arg"}`
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/command/settings/update", strings.NewReader(commandSettingsPost))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		err = csh.Update()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusBadRequest, rec.Code)
+		cs.AssertNotCalled(tt, "UpdateSetting", mock.Anything, mock.MatchedBy(func(s *models.CommandSetting) bool { return s.ID == 2 }))
+	})
 	t.Run("delete normal flow", func(tt *testing.T) {
 		token, err := generateTestToken("test@email.com")
 		assert.NoError(tt, err)
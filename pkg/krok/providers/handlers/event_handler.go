@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"path"
 
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
@@ -10,12 +12,19 @@ import (
 	kerr "github.com/krok-o/krok/errors"
 	"github.com/krok-o/krok/pkg/krok/providers"
 	"github.com/krok-o/krok/pkg/models"
+	krokmiddleware "github.com/krok-o/krok/pkg/server/middleware"
 )
 
 // EventHandlerDependencies defines the dependencies for the vcs token handler provider.
 type EventHandlerDependencies struct {
-	Logger       zerolog.Logger
-	EventsStorer providers.EventsStorer
+	Logger         zerolog.Logger
+	EventsStorer   providers.EventsStorer
+	ArtifactStorer providers.ArtifactStorer
+	Executor       providers.Executor
+	// RepositoryStorer, Clock and UUIDGenerator are needed to create events for reruns and manual triggers.
+	RepositoryStorer providers.RepositoryStorer
+	Clock            providers.Clock
+	UUIDGenerator    providers.UUIDGenerator
 }
 
 // EventHandler is a handler taking care of vcs token related api calls.
@@ -135,3 +144,422 @@ func (r *EventHandler) Get() echo.HandlerFunc {
 		return c.JSON(http.StatusOK, event)
 	}
 }
+
+// CancelRun cancels the run of an event.
+// swagger:operation POST /event/{id}/cancel cancelEventRun
+// Cancels all commands of the run of an event which are still waiting or running. They are marked as cancelled.
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: 'The ID of the event to cancel the run of'
+//   required: true
+//   type: integer
+//   format: int
+// responses:
+//   '200':
+//     description: 'the run has been cancelled'
+//   '400':
+//     description: 'invalid event id'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '404':
+//     description: 'run not found'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '500':
+//     description: 'failed to cancel run'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (r *EventHandler) CancelRun() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		n, err := GetParamAsInt("id", c)
+		if err != nil {
+			apiError := kerr.APIError("invalid id", http.StatusBadRequest, nil)
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+
+		if err := r.Executor.CancelRun(c.Request().Context(), n); err != nil {
+			if errors.Is(err, kerr.ErrNotFound) {
+				return c.JSON(http.StatusNotFound, kerr.APIError("run not found", http.StatusNotFound, err))
+			}
+			r.Logger.Debug().Err(err).Int("id", n).Msg("Failed to cancel run.")
+			apiError := kerr.APIError("failed to cancel run", http.StatusInternalServerError, err)
+			return c.JSON(http.StatusInternalServerError, apiError)
+		}
+
+		return c.NoContent(http.StatusOK)
+	}
+}
+
+// Rerun runs the commands of an event again.
+// swagger:operation POST /event/{id}/rerun rerunEvent
+// Runs the commands of an event again with its payload. The new run gets its own event which refers to
+// the original one with rerun_of, so the runs show up side by side in the history of the repository.
+// Without options, the commands which ran for the event run again with their current settings. The new event
+// is recorded with the rerun source and the user who asked for the rerun.
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: 'The ID of the event to run again'
+//   required: true
+//   type: integer
+//   format: int
+// - name: rerunOptions
+//   in: body
+//   required: false
+//   schema:
+//     "$ref": "#/definitions/RerunOptions"
+// responses:
+//   '201':
+//     schema:
+//       "$ref": "#/definitions/Event"
+//   '400':
+//     description: 'invalid event id or no commands to rerun'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '404':
+//     description: 'event not found'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '500':
+//     description: 'failed to rerun event'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (r *EventHandler) Rerun() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		uc, err := krokmiddleware.GetUserContext(c)
+		if err != nil {
+			apiError := kerr.APIError("failed to get user context", http.StatusInternalServerError, nil)
+			return c.JSON(http.StatusInternalServerError, apiError)
+		}
+		n, err := GetParamAsInt("id", c)
+		if err != nil {
+			apiError := kerr.APIError("invalid id", http.StatusBadRequest, nil)
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+		options := &models.RerunOptions{}
+		if err := c.Bind(options); err != nil {
+			apiError := kerr.APIError("invalid rerun options", http.StatusBadRequest, err)
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+		log := r.Logger.With().Int("id", n).Int("user_id", uc.UserID).Logger()
+		ctx := c.Request().Context()
+
+		event, err := r.EventsStorer.GetEvent(ctx, n)
+		if err != nil {
+			if errors.Is(err, kerr.ErrNotFound) {
+				return c.JSON(http.StatusNotFound, kerr.APIError("event not found", http.StatusNotFound, err))
+			}
+			apiError := kerr.APIError("failed to get event", http.StatusInternalServerError, err)
+			return c.JSON(http.StatusInternalServerError, apiError)
+		}
+		repo, err := r.RepositoryStorer.Get(ctx, event.RepositoryID)
+		if err != nil {
+			if errors.Is(err, kerr.ErrNotFound) {
+				return c.JSON(http.StatusNotFound, kerr.APIError("repository not found", http.StatusNotFound, err))
+			}
+			apiError := kerr.APIError("failed to get repository", http.StatusInternalServerError, err)
+			return c.JSON(http.StatusInternalServerError, apiError)
+		}
+		commands := rerunCommands(repo.Commands, event.CommandRuns, options)
+		if len(commands) == 0 {
+			apiError := kerr.APIError("no commands to rerun", http.StatusBadRequest, nil)
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+
+		rerun, err := r.EventsStorer.Create(ctx, &models.Event{
+			EventID:      event.EventID,
+			CreateAt:     r.Clock.Now(),
+			RepositoryID: event.RepositoryID,
+			Payload:      event.Payload,
+			VCS:          event.VCS,
+			EventType:    event.EventType,
+			RerunOf:      event.ID,
+			Source:       models.EventSourceRerun,
+			TriggeredBy:  uc.UserID,
+		})
+		if err != nil {
+			log.Debug().Err(err).Msg("Failed to store event.")
+			apiError := kerr.APIError("failed to store event", http.StatusInternalServerError, err)
+			return c.JSON(http.StatusInternalServerError, apiError)
+		}
+		if err := r.Executor.CreateRun(ctx, rerun, commands); err != nil {
+			log.Debug().Err(err).Int("rerun_id", rerun.ID).Msg("Failed to start run for event.")
+			apiError := kerr.APIError("failed to start run for event", http.StatusInternalServerError, err)
+			return c.JSON(http.StatusInternalServerError, apiError)
+		}
+		log.Info().Int("rerun_id", rerun.ID).Int("commands", len(commands)).Msg("Event is running again.")
+		return c.JSON(http.StatusCreated, rerun)
+	}
+}
+
+// Trigger runs the commands of a repository for a manual event.
+// swagger:operation POST /events/{repoid}/trigger triggerEvent
+// Runs a command of a repository, or all commands of the repository which match the event, with the given
+// event type and payload. The event is recorded with the manual source and the user who triggered it.
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// parameters:
+// - name: repoid
+//   in: path
+//   description: 'The ID of the repository to trigger the commands of.'
+//   required: true
+//   type: integer
+//   format: int
+// - name: triggerOptions
+//   in: body
+//   required: true
+//   schema:
+//     "$ref": "#/definitions/TriggerOptions"
+// responses:
+//   '201':
+//     schema:
+//       "$ref": "#/definitions/Event"
+//   '400':
+//     description: 'invalid repository id, trigger options or no commands to run'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '404':
+//     description: 'repository or command not found'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '500':
+//     description: 'failed to trigger event'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (r *EventHandler) Trigger() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		uc, err := krokmiddleware.GetUserContext(c)
+		if err != nil {
+			apiError := kerr.APIError("failed to get user context", http.StatusInternalServerError, nil)
+			return c.JSON(http.StatusInternalServerError, apiError)
+		}
+		repoID, err := GetParamAsInt("repoid", c)
+		if err != nil {
+			apiError := kerr.APIError("invalid repository id", http.StatusBadRequest, err)
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+		options := &models.TriggerOptions{}
+		if err := c.Bind(options); err != nil {
+			apiError := kerr.APIError("invalid trigger options", http.StatusBadRequest, err)
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+		if options.EventType == "" {
+			apiError := kerr.APIError("invalid trigger options", http.StatusBadRequest, errors.New("event type is required"))
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+		body := []byte(options.Payload)
+		if len(body) == 0 {
+			body = []byte("{}")
+		}
+		log := r.Logger.With().Int("repository_id", repoID).Int("user_id", uc.UserID).Str("event_type", options.EventType).Logger()
+		ctx := c.Request().Context()
+
+		repo, err := r.RepositoryStorer.Get(ctx, repoID)
+		if err != nil {
+			if errors.Is(err, kerr.ErrNotFound) {
+				return c.JSON(http.StatusNotFound, kerr.APIError("repository not found", http.StatusNotFound, err))
+			}
+			apiError := kerr.APIError("failed to get repository", http.StatusInternalServerError, err)
+			return c.JSON(http.StatusInternalServerError, apiError)
+		}
+		var commands []*models.Command
+		if options.CommandID != 0 {
+			// A single command runs regardless of its filter.
+			for _, command := range repo.Commands {
+				if command.ID == options.CommandID {
+					commands = append(commands, command)
+				}
+			}
+			if len(commands) == 0 {
+				err := fmt.Errorf("command with id %d is not attached to the repository", options.CommandID)
+				return c.JSON(http.StatusNotFound, kerr.APIError("command not found", http.StatusNotFound, err))
+			}
+		} else {
+			commands = matchingCommands(log, repo.Commands, options.EventType, body)
+			if len(commands) == 0 {
+				apiError := kerr.APIError("no commands match the event", http.StatusBadRequest, nil)
+				return c.JSON(http.StatusBadRequest, apiError)
+			}
+		}
+
+		id, err := r.UUIDGenerator.Generate()
+		if err != nil {
+			log.Debug().Err(err).Msg("Failed to generate event id.")
+			apiError := kerr.APIError("failed to generate event id", http.StatusInternalServerError, err)
+			return c.JSON(http.StatusInternalServerError, apiError)
+		}
+		event, err := r.EventsStorer.Create(ctx, &models.Event{
+			EventID:      id,
+			CreateAt:     r.Clock.Now(),
+			RepositoryID: repo.ID,
+			Payload:      string(body),
+			VCS:          repo.VCS,
+			EventType:    options.EventType,
+			Source:       models.EventSourceManual,
+			TriggeredBy:  uc.UserID,
+		})
+		if err != nil {
+			log.Debug().Err(err).Msg("Failed to store event.")
+			apiError := kerr.APIError("failed to store event", http.StatusInternalServerError, err)
+			return c.JSON(http.StatusInternalServerError, apiError)
+		}
+		if err := r.Executor.CreateRun(ctx, event, commands); err != nil {
+			log.Debug().Err(err).Int("event_id", event.ID).Msg("Failed to start run for event.")
+			apiError := kerr.APIError("failed to start run for event", http.StatusInternalServerError, err)
+			return c.JSON(http.StatusInternalServerError, apiError)
+		}
+		log.Info().Int("event_id", event.ID).Int("commands", len(commands)).Msg("Manual event triggered.")
+		return c.JSON(http.StatusCreated, event)
+	}
+}
+
+// rerunCommands returns the commands of the repository which run again for an event. Selected commands run
+// even if they didn't run for the event before. Otherwise, the commands which ran for the event are taken
+// and with FailedOnly, only those of them whose last attempt didn't succeed.
+func rerunCommands(commands []*models.Command, runs []*models.CommandRun, options *models.RerunOptions) []*models.Command {
+	// last is the last attempt of every command which ran for the event.
+	last := make(map[int]*models.CommandRun)
+	for _, run := range runs {
+		if l, ok := last[run.CommandID]; !ok || run.Attempt > l.Attempt || run.Attempt == l.Attempt && run.ID > l.ID {
+			last[run.CommandID] = run
+		}
+	}
+	selected := make(map[string]bool, len(options.Commands))
+	for _, name := range options.Commands {
+		selected[name] = true
+	}
+	var result []*models.Command
+	for _, command := range commands {
+		run, ran := last[command.ID]
+		switch {
+		case len(selected) > 0 && !selected[command.Name]:
+			continue
+		case len(selected) == 0 && !ran:
+			continue
+		case options.FailedOnly && (!ran || run.Status == models.RunStatusSuccess || unfinished(run.Status)):
+			continue
+		}
+		result = append(result, command)
+	}
+	return result
+}
+
+// ListArtifacts lists the artifacts which the commands of an event produced.
+// swagger:operation GET /event/{id}/artifacts listArtifacts
+// List the artifacts which the commands of an event left in their shared workspace.
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: 'The ID of the event'
+//   required: true
+//   type: integer
+//   format: int
+// responses:
+//   '200':
+//     schema:
+//       type: array
+//       items:
+//         "$ref": "#/definitions/Artifact"
+//   '400':
+//     description: 'invalid event id'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '500':
+//     description: 'failed to list artifacts'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (r *EventHandler) ListArtifacts() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		n, err := GetParamAsInt("id", c)
+		if err != nil {
+			apiError := kerr.APIError("invalid id", http.StatusBadRequest, nil)
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+		ctx := c.Request().Context()
+
+		list, err := r.ArtifactStorer.List(ctx, n)
+		if err != nil {
+			r.Logger.Debug().Err(err).Msg("Artifact List failed.")
+			return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to list artifacts", http.StatusInternalServerError, err))
+		}
+
+		return c.JSON(http.StatusOK, list)
+	}
+}
+
+// GetArtifact downloads an artifact of an event.
+// swagger:operation GET /event/{id}/artifacts/{name} getArtifact
+// Download an artifact which the commands of an event produced.
+// ---
+// produces:
+// - application/octet-stream
+// parameters:
+// - name: id
+//   in: path
+//   description: 'The ID of the event'
+//   required: true
+//   type: integer
+//   format: int
+// - name: name
+//   in: path
+//   description: 'The name of the artifact as returned by listArtifacts'
+//   required: true
+//   type: string
+// responses:
+//   '200':
+//     description: 'the content of the artifact'
+//   '400':
+//     description: 'invalid event id or artifact name'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '404':
+//     description: 'artifact not found'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '500':
+//     description: 'failed to get artifact'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (r *EventHandler) GetArtifact() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		n, err := GetParamAsInt("id", c)
+		if err != nil {
+			apiError := kerr.APIError("invalid id", http.StatusBadRequest, nil)
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+		name := c.Param("*")
+		if name == "" {
+			apiError := kerr.APIError("invalid artifact name", http.StatusBadRequest, nil)
+			return c.JSON(http.StatusBadRequest, apiError)
+		}
+		ctx := c.Request().Context()
+
+		artifact, err := r.ArtifactStorer.Open(ctx, n, name)
+		if err != nil {
+			if errors.Is(err, kerr.ErrNotFound) {
+				return c.JSON(http.StatusNotFound, kerr.APIError("artifact not found", http.StatusNotFound, err))
+			}
+			r.Logger.Debug().Err(err).Str("name", name).Msg("Failed to open artifact.")
+			return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to get artifact", http.StatusInternalServerError, err))
+		}
+		defer artifact.Close()
+
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", path.Base(name)))
+		return c.Stream(http.StatusOK, echo.MIMEOctetStream, artifact)
+	}
+}
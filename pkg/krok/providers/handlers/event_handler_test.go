@@ -1,14 +1,20 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	kerr "github.com/krok-o/krok/errors"
 	"github.com/krok-o/krok/pkg/krok/providers/mocks"
 	"github.com/krok-o/krok/pkg/models"
+	"github.com/krok-o/krok/pkg/server/middleware"
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
@@ -111,3 +117,356 @@ func TestEventHandler_Get(t *testing.T) {
 		assert.Equal(tt, repositoryExpected, rec.Body.String())
 	})
 }
+
+func TestEventHandler_ListArtifacts(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	as := &mocks.ArtifactStorer{}
+	as.On("List", mock.Anything, 1).Return([]*models.Artifact{
+		{
+			Name:       "public/index.html",
+			Size:       7,
+			ModifiedAt: time.Date(1981, 1, 1, 1, 1, 1, 1, time.UTC),
+		},
+	}, nil)
+	eh := NewEventHandler(EventHandlerDependencies{
+		Logger:         logger,
+		ArtifactStorer: as,
+	})
+
+	t.Run("can list artifacts", func(tt *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/event/:id/artifacts")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		err := eh.ListArtifacts()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusOK, rec.Code)
+		assert.Equal(tt, `[{"name":"public/index.html","size":7,"modified_at":"1981-01-01T01:01:01.000000001Z"}]
+`, rec.Body.String())
+	})
+
+	t.Run("invalid id", func(tt *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/event/:id/artifacts")
+		c.SetParamNames("id")
+		c.SetParamValues("invalid")
+		err := eh.ListArtifacts()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestEventHandler_GetArtifact(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	as := &mocks.ArtifactStorer{}
+	as.On("Open", mock.Anything, 1, "public/index.html").Return(ioutil.NopCloser(strings.NewReader("<html/>")), nil)
+	as.On("Open", mock.Anything, 1, "missing").Return(nil, kerr.ErrNotFound)
+	eh := NewEventHandler(EventHandlerDependencies{
+		Logger:         logger,
+		ArtifactStorer: as,
+	})
+
+	t.Run("can download an artifact", func(tt *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/event/:id/artifacts/*")
+		c.SetParamNames("id", "*")
+		c.SetParamValues("1", "public/index.html")
+		err := eh.GetArtifact()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusOK, rec.Code)
+		assert.Equal(tt, `attachment; filename="index.html"`, rec.Header().Get(echo.HeaderContentDisposition))
+		assert.Equal(tt, "<html/>", rec.Body.String())
+	})
+
+	t.Run("artifact not found", func(tt *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/event/:id/artifacts/*")
+		c.SetParamNames("id", "*")
+		c.SetParamValues("1", "missing")
+		err := eh.GetArtifact()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestEventHandler_CancelRun(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	mex := &mocks.Executor{}
+	mex.On("CancelRun", mock.Anything, 1).Return(nil)
+	mex.On("CancelRun", mock.Anything, 2).Return(fmt.Errorf("run with ID 2: %w", kerr.ErrNotFound))
+	mex.On("CancelRun", mock.Anything, 3).Return(errors.New("nope"))
+	eh := NewEventHandler(EventHandlerDependencies{
+		Logger:   logger,
+		Executor: mex,
+	})
+	for _, tc := range []struct {
+		name string
+		id   string
+		code int
+	}{
+		{name: "can cancel a run", id: "1", code: http.StatusOK},
+		{name: "run not found", id: "2", code: http.StatusNotFound},
+		{name: "failed to cancel run", id: "3", code: http.StatusInternalServerError},
+		{name: "invalid id", id: "invalid", code: http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(tt *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/event/:id/cancel")
+			c.SetParamNames("id")
+			c.SetParamValues(tc.id)
+			err := eh.CancelRun()(c)
+			assert.NoError(tt, err)
+			assert.Equal(tt, tc.code, rec.Code)
+		})
+	}
+	mex.AssertExpectations(t)
+}
+
+func TestEventHandler_Rerun(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	build := &models.Command{ID: 1, Name: "build"}
+	publish := &models.Command{ID: 2, Name: "publish"}
+	notify := &models.Command{ID: 3, Name: "notify"}
+	event := &models.Event{
+		ID:           1,
+		EventID:      "uuid",
+		RepositoryID: 1,
+		Payload:      `{"ref":"refs/heads/main"}`,
+		VCS:          models.GITHUB,
+		EventType:    "push",
+		Source:       models.EventSourceManual,
+		TriggeredBy:  1,
+		CommandRuns: []*models.CommandRun{
+			{ID: 1, CommandID: 1, CommandName: "build", Attempt: 1, Status: models.RunStatusFailed},
+			{ID: 2, CommandID: 1, CommandName: "build", Attempt: 2, Status: models.RunStatusSuccess},
+			{ID: 3, CommandID: 2, CommandName: "publish", Attempt: 1, Status: models.RunStatusTimedOut},
+		},
+	}
+	now := time.Date(1981, 1, 1, 1, 1, 1, 1, time.UTC)
+
+	for _, tc := range []struct {
+		name     string
+		body     string
+		commands []*models.Command
+	}{
+		{name: "the commands which ran for the event run again", commands: []*models.Command{build, publish}},
+		{name: "without selected commands the commands which ran for the event run again", body: `{"commands":[]}`, commands: []*models.Command{build, publish}},
+		{name: "only the failed commands run again", body: `{"failed_only":true}`, commands: []*models.Command{publish}},
+		{name: "selected commands run again", body: `{"commands":["build","notify"]}`, commands: []*models.Command{build, notify}},
+	} {
+		t.Run(tc.name, func(tt *testing.T) {
+			es := &mocks.EventsStorer{}
+			es.On("GetEvent", mock.Anything, 1).Return(event, nil)
+			rerun := &models.Event{
+				EventID:      "uuid",
+				CreateAt:     now,
+				RepositoryID: 1,
+				Payload:      event.Payload,
+				VCS:          models.GITHUB,
+				EventType:    "push",
+				RerunOf:      1,
+				Source:       models.EventSourceRerun,
+				TriggeredBy:  2,
+			}
+			es.On("Create", mock.Anything, rerun).Return(&models.Event{
+				ID:           2,
+				EventID:      "uuid",
+				CreateAt:     now,
+				RepositoryID: 1,
+				Payload:      event.Payload,
+				VCS:          models.GITHUB,
+				EventType:    "push",
+				RerunOf:      1,
+				Source:       models.EventSourceRerun,
+				TriggeredBy:  2,
+			}, nil)
+			rs := &mocks.RepositoryStorer{}
+			rs.On("Get", mock.Anything, 1).Return(&models.Repository{ID: 1, Commands: []*models.Command{build, publish, notify}}, nil)
+			mex := &mocks.Executor{}
+			mex.On("CreateRun", mock.Anything, mock.MatchedBy(func(e *models.Event) bool {
+				return e.ID == 2
+			}), tc.commands).Return(nil)
+			mc := &mocks.Clock{}
+			mc.On("Now").Return(now)
+			eh := NewEventHandler(EventHandlerDependencies{
+				Logger:           logger,
+				EventsStorer:     es,
+				Executor:         mex,
+				RepositoryStorer: rs,
+				Clock:            mc,
+			})
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.Set("user", &middleware.UserContext{UserID: 2})
+			c.SetPath("/event/:id/rerun")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			err := eh.Rerun()(c)
+			assert.NoError(tt, err)
+			assert.Equal(tt, http.StatusCreated, rec.Code)
+			assert.Contains(tt, rec.Body.String(), `"rerun_of":1,"source":"rerun","triggered_by":2`)
+			mex.AssertExpectations(tt)
+		})
+	}
+
+	t.Run("when there are no commands to rerun", func(tt *testing.T) {
+		es := &mocks.EventsStorer{}
+		es.On("GetEvent", mock.Anything, 1).Return(event, nil)
+		rs := &mocks.RepositoryStorer{}
+		rs.On("Get", mock.Anything, 1).Return(&models.Repository{ID: 1, Commands: []*models.Command{notify}}, nil)
+		eh := NewEventHandler(EventHandlerDependencies{
+			Logger:           logger,
+			EventsStorer:     es,
+			RepositoryStorer: rs,
+		})
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("user", &middleware.UserContext{UserID: 2})
+		c.SetPath("/event/:id/rerun")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		err := eh.Rerun()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("event not found", func(tt *testing.T) {
+		es := &mocks.EventsStorer{}
+		es.On("GetEvent", mock.Anything, 2).Return(nil, kerr.ErrNotFound)
+		eh := NewEventHandler(EventHandlerDependencies{
+			Logger:       logger,
+			EventsStorer: es,
+		})
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("user", &middleware.UserContext{UserID: 2})
+		c.SetPath("/event/:id/rerun")
+		c.SetParamNames("id")
+		c.SetParamValues("2")
+		err := eh.Rerun()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestEventHandler_Trigger(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	now := time.Date(1981, 1, 1, 1, 1, 1, 1, time.UTC)
+	deploy := &models.Command{ID: 1, Name: "deploy", Filter: &models.CommandFilter{EventTypes: []string{"deployment"}}}
+	build := &models.Command{ID: 2, Name: "build"}
+	repo := &models.Repository{ID: 1, VCS: models.GITHUB, Commands: []*models.Command{deploy, build}}
+
+	for _, tc := range []struct {
+		name     string
+		body     string
+		payload  string
+		commands []*models.Command
+	}{
+		{name: "a single command runs regardless of its filter", body: `{"command_id":1,"event_type":"push","payload":{"ref":"refs/heads/main"}}`, payload: `{"ref":"refs/heads/main"}`, commands: []*models.Command{deploy}},
+		{name: "all matching commands run with an empty payload", body: `{"event_type":"push"}`, payload: `{}`, commands: []*models.Command{build}},
+	} {
+		t.Run(tc.name, func(tt *testing.T) {
+			event := &models.Event{
+				EventID:      "uuid",
+				CreateAt:     now,
+				RepositoryID: 1,
+				Payload:      tc.payload,
+				VCS:          models.GITHUB,
+				EventType:    "push",
+				Source:       models.EventSourceManual,
+				TriggeredBy:  1,
+			}
+			es := &mocks.EventsStorer{}
+			es.On("Create", mock.Anything, event).Return(event, nil)
+			rs := &mocks.RepositoryStorer{}
+			rs.On("Get", mock.Anything, 1).Return(repo, nil)
+			mex := &mocks.Executor{}
+			mex.On("CreateRun", mock.Anything, event, tc.commands).Return(nil)
+			mc := &mocks.Clock{}
+			mc.On("Now").Return(now)
+			mu := &mocks.UUIDGenerator{}
+			mu.On("Generate").Return("uuid", nil)
+			eh := NewEventHandler(EventHandlerDependencies{
+				Logger:           logger,
+				EventsStorer:     es,
+				Executor:         mex,
+				RepositoryStorer: rs,
+				Clock:            mc,
+				UUIDGenerator:    mu,
+			})
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.Set("user", &middleware.UserContext{UserID: 1})
+			c.SetPath("/events/:repoid/trigger")
+			c.SetParamNames("repoid")
+			c.SetParamValues("1")
+			err := eh.Trigger()(c)
+			assert.NoError(tt, err)
+			assert.Equal(tt, http.StatusCreated, rec.Code)
+			assert.Contains(tt, rec.Body.String(), `"source":"manual","triggered_by":1`)
+			mex.AssertExpectations(tt)
+		})
+	}
+
+	for _, tc := range []struct {
+		name string
+		body string
+		code int
+	}{
+		{name: "the event type is required", body: `{"payload":{}}`, code: http.StatusBadRequest},
+		{name: "the payload has to be json", body: `{"event_type":"push","payload":nope}`, code: http.StatusBadRequest},
+		{name: "the command has to be attached to the repository", body: `{"command_id":3,"event_type":"push"}`, code: http.StatusNotFound},
+		{name: "when no command matches the event", body: `{"event_type":"ping"}`, code: http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(tt *testing.T) {
+			rs := &mocks.RepositoryStorer{}
+			rs.On("Get", mock.Anything, 1).Return(&models.Repository{ID: 1, Commands: []*models.Command{deploy}}, nil)
+			eh := NewEventHandler(EventHandlerDependencies{
+				Logger:           logger,
+				RepositoryStorer: rs,
+			})
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.Set("user", &middleware.UserContext{UserID: 1})
+			c.SetPath("/events/:repoid/trigger")
+			c.SetParamNames("repoid")
+			c.SetParamValues("1")
+			err := eh.Trigger()(c)
+			assert.NoError(tt, err)
+			assert.Equal(tt, tc.code, rec.Code)
+		})
+	}
+}
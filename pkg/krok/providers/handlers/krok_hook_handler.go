@@ -11,6 +11,7 @@ import (
 	"github.com/rs/zerolog"
 
 	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/payload"
 	"github.com/krok-o/krok/pkg/krok/providers"
 	"github.com/krok-o/krok/pkg/models"
 )
@@ -90,6 +91,7 @@ func (k *KrokHookHandler) HandleHooks() echo.HandlerFunc {
 		}
 
 		log.Debug().Str("name", repo.Name).Msg("Found repository...")
+		ctx = providers.WithRepository(ctx, repo)
 		// Validate the request that it's a valid and subscribed to event.
 		provider, ok := k.PlatformProviders[vid]
 		if !ok {
@@ -111,7 +113,7 @@ func (k *KrokHookHandler) HandleHooks() echo.HandlerFunc {
 			apiError := kerr.APIError("failed to validate hook request", http.StatusBadRequest, err)
 			return c.JSON(http.StatusBadRequest, apiError)
 		}
-		payload, err := ioutil.ReadAll(rdr2)
+		body, err := ioutil.ReadAll(rdr2)
 		if err != nil {
 			apiError := kerr.APIError("failed to get payload", http.StatusBadRequest, err)
 			return c.JSON(http.StatusBadRequest, apiError)
@@ -125,9 +127,10 @@ func (k *KrokHookHandler) HandleHooks() echo.HandlerFunc {
 			RepositoryID: rid,
 			CreateAt:     k.Timer.Now(),
 			EventID:      id,
-			Payload:      string(payload),
+			Payload:      string(body),
 			VCS:          vid,
 			EventType:    eventType,
+			Source:       models.EventSourceHook,
 		}
 		// Create an ID for this event from the database.
 		storedEvent, err := k.EventsStorer.Create(ctx, event)
@@ -135,11 +138,25 @@ func (k *KrokHookHandler) HandleHooks() echo.HandlerFunc {
 			apiError := kerr.APIError("failed to store event", http.StatusBadRequest, err)
 			return c.JSON(http.StatusBadRequest, apiError)
 		}
+		commands := matchingCommands(log, repo.Commands, eventType, body)
 		// Create a run which runs the commands attached to this event.
-		if err := k.Executer.CreateRun(ctx, storedEvent, repo.Commands); err != nil {
+		if err := k.Executer.CreateRun(ctx, storedEvent, commands); err != nil {
 			apiError := kerr.APIError("failed to start run for event", http.StatusBadRequest, err)
 			return c.JSON(http.StatusBadRequest, apiError)
 		}
 		return c.String(http.StatusOK, "successfully processed event")
 	}
 }
+
+// matchingCommands returns the commands whose filter matches an event.
+func matchingCommands(log zerolog.Logger, commands []*models.Command, eventType string, body []byte) []*models.Command {
+	var result []*models.Command
+	for _, command := range commands {
+		if !payload.Matches(command.Filter, eventType, body) {
+			log.Debug().Str("command", command.Name).Str("event_type", eventType).Msg("Command filter does not match event, skipping.")
+			continue
+		}
+		result = append(result, command)
+	}
+	return result
+}
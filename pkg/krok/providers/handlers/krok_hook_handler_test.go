@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -108,3 +109,49 @@ func TestHandleHooksWithMissingEventId(t *testing.T) {
 		assert.Equal(tt, http.StatusBadRequest, rec.Code)
 	})
 }
+
+func TestHandleHooksFiltersCommands(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	notifier := &models.Command{ID: 1, Name: "notifier"}
+	pushOnly := &models.Command{ID: 2, Name: "push-only", Filter: &models.CommandFilter{EventTypes: []string{"push"}}}
+	releases := &models.Command{ID: 3, Name: "releases", Filter: &models.CommandFilter{Branch: "release/*"}}
+	pingOnly := &models.Command{ID: 4, Name: "ping-only", Filter: &models.CommandFilter{EventTypes: []string{"ping"}}}
+	mrs := &mocks.RepositoryStorer{}
+	mrs.On("Get", mock.Anything, 1).Return(&models.Repository{
+		ID:       1,
+		Commands: []*models.Command{notifier, pushOnly, releases, pingOnly},
+	}, nil)
+	mgp := &mocks.Platform{}
+	mgp.On("ValidateRequest", mock.Anything, mock.Anything, 1).Return(nil)
+	mgp.On("GetEventID", mock.Anything, mock.Anything).Return("id", nil)
+	mgp.On("GetEventType", mock.Anything, mock.Anything).Return("push", nil)
+	mt := &mocks.Clock{}
+	mt.On("Now").Return(time.Date(0, time.January, 1, 1, 1, 1, 1, time.UTC))
+	platformProviders := make(map[int]providers.Platform)
+	platformProviders[models.GITHUB] = mgp
+	event := &models.Event{ID: 1, EventID: "id", RepositoryID: 1, EventType: "push"}
+	es := &mocks.EventsStorer{}
+	es.On("Create", mock.Anything, mock.Anything).Return(event, nil)
+	ex := &mocks.Executor{}
+	ex.On("CreateRun", mock.Anything, event, []*models.Command{notifier, pushOnly}).Return(nil)
+	hh := NewHookHandler(HookDependencies{
+		Logger:            logger,
+		RepositoryStore:   mrs,
+		PlatformProviders: platformProviders,
+		EventsStorer:      es,
+		Executer:          ex,
+		Timer:             mt,
+	})
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"ref":"refs/heads/main"}`))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/hooks/:rid/:vid/callback")
+	c.SetParamNames("rid", "vid")
+	c.SetParamValues("1", "1")
+	err := hh.HandleHooks()(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	ex.AssertExpectations(t)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// RegistryCredentialHandlerDependencies defines the dependencies for the registry credential handler provider.
+type RegistryCredentialHandlerDependencies struct {
+	Logger              zerolog.Logger
+	RegistryCredentials providers.RegistryCredentialProvider
+}
+
+// RegistryCredentialHandler is a handler taking care of registry credential related api calls.
+type RegistryCredentialHandler struct {
+	RegistryCredentialHandlerDependencies
+}
+
+var _ providers.RegistryCredentialHandler = &RegistryCredentialHandler{}
+
+// NewRegistryCredentialHandler creates a new registry credential handler.
+func NewRegistryCredentialHandler(deps RegistryCredentialHandlerDependencies) *RegistryCredentialHandler {
+	return &RegistryCredentialHandler{
+		RegistryCredentialHandlerDependencies: deps,
+	}
+}
+
+// Create handles the Create rest event.
+// swagger:operation POST /registry-credential createRegistryCredential
+// Create or replace the credential for a container registry, which the images of commands are pulled with.
+// ---
+// consumes:
+// - application/json
+// parameters:
+// - name: credential
+//   in: body
+//   required: true
+//   schema:
+//     "$ref": "#/definitions/RegistryCredential"
+// responses:
+//   '201':
+//     description: 'OK credential successfully created'
+//   '400':
+//     description: 'invalid json payload or missing host, username or password'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '500':
+//     description: 'failed to create credential'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (r *RegistryCredentialHandler) Create() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		credential := &models.RegistryCredential{}
+		if err := c.Bind(credential); err != nil {
+			r.Logger.Debug().Err(err).Msg("Failed to bind registry credential.")
+			return c.JSON(http.StatusBadRequest, kerr.APIError("failed to bind registry credential", http.StatusBadRequest, err))
+		}
+		if credential.Host == "" || credential.Username == "" || credential.Password == "" {
+			return c.JSON(http.StatusBadRequest, kerr.APIError("invalid registry credential", http.StatusBadRequest, errors.New("host, username and password are required")))
+		}
+
+		if err := r.RegistryCredentials.SaveRegistryCredential(c.Request().Context(), credential); err != nil {
+			r.Logger.Debug().Err(err).Msg("Registry credential creation failed.")
+			return c.JSON(http.StatusInternalServerError, kerr.APIError("registry credential creation failed", http.StatusInternalServerError, err))
+		}
+
+		return c.NoContent(http.StatusCreated)
+	}
+}
+
+// Delete handles the Delete rest event.
+// swagger:operation DELETE /registry-credential/{host} deleteRegistryCredential
+// Delete the credential for a container registry.
+// ---
+// parameters:
+// - name: host
+//   in: path
+//   description: 'The host of the registry'
+//   required: true
+//   type: string
+// - name: command_id
+//   in: query
+//   description: 'The command the credential belongs to, if it is not used for every command'
+//   required: false
+//   type: integer
+// responses:
+//   '200':
+//     description: 'OK in case the deletion was successful'
+//   '400':
+//     description: 'in case of an invalid command id'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '404':
+//     description: 'in case the credential was not found'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '500':
+//     description: 'when the deletion operation failed'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (r *RegistryCredentialHandler) Delete() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		host := c.Param("host")
+		var commandID int
+		if id := c.QueryParam("command_id"); id != "" {
+			n, err := strconv.Atoi(id)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, kerr.APIError("invalid command id", http.StatusBadRequest, err))
+			}
+			commandID = n
+		}
+
+		if err := r.RegistryCredentials.DeleteRegistryCredential(c.Request().Context(), commandID, host); err != nil {
+			if errors.Is(err, kerr.ErrNotFound) {
+				return c.JSON(http.StatusNotFound, kerr.APIError("registry credential not found", http.StatusNotFound, err))
+			}
+			r.Logger.Debug().Err(err).Msg("Registry credential deletion failed.")
+			return c.JSON(http.StatusInternalServerError, kerr.APIError("registry credential deletion failed", http.StatusInternalServerError, err))
+		}
+
+		return c.NoContent(http.StatusOK)
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers/mocks"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+func TestRegistryCredentialHandler_Create(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	mrc := &mocks.RegistryCredentialProvider{}
+	mrc.On("SaveRegistryCredential", mock.Anything, &models.RegistryCredential{
+		Host:     "ghcr.io",
+		Username: "krok",
+		Password: "registry-token",
+	}).Return(nil)
+	rch := NewRegistryCredentialHandler(RegistryCredentialHandlerDependencies{
+		Logger:              logger,
+		RegistryCredentials: mrc,
+	})
+	token, err := generateTestToken("test@email.com")
+	assert.NoError(t, err)
+
+	t.Run("create", func(tt *testing.T) {
+		post := `{"host": "ghcr.io", "username": "krok", "password": "registry-token"}`
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/registry-credential", strings.NewReader(post))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		err = rch.Create()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusCreated, rec.Code)
+	})
+
+	t.Run("create without password", func(tt *testing.T) {
+		post := `{"host": "ghcr.io", "username": "krok"}`
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/registry-credential", strings.NewReader(post))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		err = rch.Create()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestRegistryCredentialHandler_Delete(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	mrc := &mocks.RegistryCredentialProvider{}
+	mrc.On("DeleteRegistryCredential", mock.Anything, 2, "ghcr.io").Return(nil)
+	mrc.On("DeleteRegistryCredential", mock.Anything, 0, "docker.io").Return(kerr.ErrNotFound)
+	rch := NewRegistryCredentialHandler(RegistryCredentialHandlerDependencies{
+		Logger:              logger,
+		RegistryCredentials: mrc,
+	})
+	token, err := generateTestToken("test@email.com")
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		name      string
+		host      string
+		commandID string
+		code      int
+	}{
+		{name: "delete credential of command", host: "ghcr.io", commandID: "2", code: http.StatusOK},
+		{name: "delete missing credential", host: "docker.io", code: http.StatusNotFound},
+		{name: "delete with invalid command id", host: "ghcr.io", commandID: "invalid", code: http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(tt *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodDelete, "/registry-credential/"+tc.host+"?command_id="+tc.commandID, nil)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("host")
+			c.SetParamValues(tc.host)
+			err = rch.Delete()(c)
+			assert.NoError(tt, err)
+			assert.Equal(tt, tc.code, rec.Code)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -129,14 +130,27 @@ func (r *RepoHandler) Create() echo.HandlerFunc {
 			r.Logger.Debug().Err(err).Msg("Failed to create Hook")
 			return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to create hook", http.StatusInternalServerError, err))
 		}
+		// Save the remote ID of the hook so it can be updated and deleted together with the repository.
+		if created.HookID != "" {
+			if err := r.RepositoryStorer.UpdateHookID(ctx, created.ID, created.HookID); err != nil {
+				r.Logger.Debug().Err(err).Msg("Failed to save hook id.")
+				// Without its ID the hook could never be updated or deleted, so remove it together with the repository.
+				if err := provider.DeleteHook(ctx, created); err != nil {
+					r.Logger.Error().Err(err).Int("id", created.ID).Str("hook_id", created.HookID).Msg("Failed to delete hook after saving its id failed.")
+				}
+				if err := r.RepositoryStorer.Delete(ctx, created.ID); err != nil {
+					r.Logger.Error().Err(err).Int("id", created.ID).Msg("Failed to delete repository after saving its hook id failed.")
+				}
+				return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to save hook id", http.StatusInternalServerError, err))
+			}
+		}
 		return c.JSON(http.StatusCreated, created)
 	}
 }
 
 // Delete handles the Delete rest event.
-// TODO: Delete the hook here as well?
 // swagger:operation DELETE /repository/{id} deleteRepository
-// Deletes the given repository.
+// Deletes the given repository and the hook which was registered for it on the platform.
 // ---
 // parameters:
 // - name: id
@@ -145,6 +159,11 @@ func (r *RepoHandler) Create() echo.HandlerFunc {
 //   required: true
 //   type: integer
 //   format: int
+// - name: force
+//   in: query
+//   description: 'Delete the repository even if the hook on the platform could not be deleted'
+//   required: false
+//   type: boolean
 // responses:
 //   '200':
 //     description: 'OK in case the deletion was successful'
@@ -157,7 +176,7 @@ func (r *RepoHandler) Create() echo.HandlerFunc {
 //     schema:
 //       "$ref": "#/responses/Message"
 //   '500':
-//     description: 'when the deletion operation failed'
+//     description: 'when the deletion of the hook or the repository failed'
 //     schema:
 //       "$ref": "#/responses/Message"
 func (r *RepoHandler) Delete() echo.HandlerFunc {
@@ -169,6 +188,28 @@ func (r *RepoHandler) Delete() echo.HandlerFunc {
 		}
 		ctx := c.Request().Context()
 
+		repo, err := r.RepositoryStorer.Get(ctx, n)
+		if err != nil {
+			if errors.Is(err, kerr.ErrNotFound) {
+				return c.JSON(http.StatusNotFound, kerr.APIError("repository not found", http.StatusNotFound, err))
+			}
+			r.Logger.Debug().Err(err).Msg("Repository Get failed.")
+			return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to get repository", http.StatusInternalServerError, err))
+		}
+
+		// Delete the hook first, so it doesn't keep sending events for a repository that no longer exists.
+		// With force, the repository is deleted anyway, for example if the platform or its token is gone.
+		force, _ := strconv.ParseBool(c.QueryParam("force"))
+		if provider, ok := r.PlatformProviders[repo.VCS]; ok && repo.HookID != "" {
+			if err := provider.DeleteHook(ctx, repo); err != nil {
+				if !force {
+					r.Logger.Debug().Err(err).Int("id", repo.ID).Str("hook_id", repo.HookID).Msg("Failed to delete hook.")
+					return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to delete hook, use force to delete the repository anyway", http.StatusInternalServerError, err))
+				}
+				r.Logger.Warn().Err(err).Int("id", repo.ID).Str("hook_id", repo.HookID).Msg("Failed to delete hook, deleting the repository anyway.")
+			}
+		}
+
 		if err := r.RepositoryStorer.Delete(ctx, n); err != nil {
 			if errors.Is(err, kerr.ErrNotFound) {
 				return c.JSON(http.StatusNotFound, kerr.APIError("repository not found", http.StatusNotFound, err))
@@ -293,7 +334,7 @@ func (r *RepoHandler) List() echo.HandlerFunc {
 
 // Update handles the update rest event.
 // swagger:operation POST /repository/update updateRepository
-// Updates an existing repository.
+// Updates an existing repository. If events or auth are provided, the hook on the platform is updated as well.
 // ---
 // produces:
 // - application/json
@@ -319,7 +360,7 @@ func (r *RepoHandler) List() echo.HandlerFunc {
 //     schema:
 //       "$ref": "#/responses/Message"
 //   '500':
-//     description: 'failed to update repository'
+//     description: 'failed to update repository or its hook'
 //     schema:
 //       "$ref": "#/responses/Message"
 func (r *RepoHandler) Update() echo.HandlerFunc {
@@ -329,9 +370,33 @@ func (r *RepoHandler) Update() echo.HandlerFunc {
 			r.Logger.Debug().Err(err).Msg("Failed to bind repository.")
 			return c.JSON(http.StatusBadRequest, kerr.APIError("failed to bind repository", http.StatusBadRequest, err))
 		}
+		if repo.Generic != nil {
+			if err := repo.Generic.Validate(); err != nil {
+				r.Logger.Debug().Err(err).Msg("Generic settings validation failed.")
+				return c.JSON(http.StatusBadRequest, kerr.APIError("repository validation failed", http.StatusBadRequest, err))
+			}
+		}
 
 		ctx := c.Request().Context()
 
+		existing, err := r.RepositoryStorer.Get(ctx, repo.ID)
+		if err != nil {
+			if errors.Is(err, kerr.ErrNotFound) {
+				return c.JSON(http.StatusNotFound, kerr.APIError("repository not found", http.StatusNotFound, err))
+			}
+			r.Logger.Debug().Err(err).Msg("Repository Get failed.")
+			return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to get repository", http.StatusInternalServerError, err))
+		}
+
+		// Propagate changed events or secrets to the hook on the platform first, so nothing is saved
+		// which the platform doesn't know about.
+		if len(repo.Events) > 0 || repo.Auth != nil {
+			if err := r.updateHook(ctx, existing, repo); err != nil {
+				r.Logger.Debug().Err(err).Int("id", existing.ID).Str("hook_id", existing.HookID).Msg("Failed to update hook.")
+				return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to update hook", http.StatusInternalServerError, err))
+			}
+		}
+
 		updated, err := r.RepositoryStorer.Update(ctx, repo)
 		if err != nil {
 			if errors.Is(err, kerr.ErrNotFound) {
@@ -340,6 +405,13 @@ func (r *RepoHandler) Update() echo.HandlerFunc {
 			r.Logger.Debug().Err(err).Msg("Repository UpdateRepository failed.")
 			return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to update repository", http.StatusInternalServerError, err))
 		}
+		if repo.Auth != nil {
+			if err := r.Auth.CreateRepositoryAuth(ctx, updated.ID, repo.Auth); err != nil {
+				r.Logger.Debug().Err(err).Msg("Failed to store auth information.")
+				return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to update repository auth information", http.StatusInternalServerError, err))
+			}
+			updated.Auth = repo.Auth
+		}
 
 		uurl, err := r.generateUniqueCallBackURL(updated)
 		if err != nil {
@@ -351,6 +423,34 @@ func (r *RepoHandler) Update() echo.HandlerFunc {
 	}
 }
 
+// updateHook updates the hook of an existing repository on its platform with the events and the auth of
+// the update. The stored events and auth are used for the ones which aren't part of the update.
+func (r *RepoHandler) updateHook(ctx context.Context, existing, update *models.Repository) error {
+	provider, ok := r.PlatformProviders[existing.VCS]
+	if !ok || existing.HookID == "" {
+		return nil
+	}
+	repo := *existing
+	if len(update.Events) > 0 {
+		repo.Events = update.Events
+	}
+	if update.Auth != nil {
+		repo.Auth = update.Auth
+	} else {
+		auth, err := r.Auth.GetRepositoryAuth(ctx, existing.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get repository auth information: %w", err)
+		}
+		repo.Auth = auth
+	}
+	uurl, err := r.generateUniqueCallBackURL(&repo)
+	if err != nil {
+		return err
+	}
+	repo.UniqueURL = uurl
+	return provider.UpdateHook(ctx, &repo)
+}
+
 // generateUniqueCallBackURL takes a repository and generates a unique URL based on the ID and Type of the repo
 // and the configured Krok hostname.
 func (r *RepoHandler) generateUniqueCallBackURL(repo *models.Repository) (string, error) {
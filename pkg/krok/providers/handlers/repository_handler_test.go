@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -14,6 +15,7 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"github.com/krok-o/krok/pkg/krok/providers"
 	"github.com/krok-o/krok/pkg/krok/providers/mocks"
@@ -76,12 +78,24 @@ func (mrs *mockRepositoryStorer) Delete(ctx context.Context, id int) error {
 
 type mockGithubPlatformProvider struct {
 	providers.Platform
+	updated *models.Repository
+	deleted *models.Repository
 }
 
 func (g *mockGithubPlatformProvider) CreateHook(ctx context.Context, repo *models.Repository) error {
 	return nil
 }
 
+func (g *mockGithubPlatformProvider) UpdateHook(ctx context.Context, repo *models.Repository) error {
+	g.updated = repo
+	return nil
+}
+
+func (g *mockGithubPlatformProvider) DeleteHook(ctx context.Context, repo *models.Repository) error {
+	g.deleted = repo
+	return nil
+}
+
 func TestRepoHandler_CreateRepository(t *testing.T) {
 	mrs := &mocks.RepositoryStorer{}
 	mars := &mocks.RepositoryAuth{}
@@ -182,6 +196,88 @@ func TestRepoHandler_CreateRepository(t *testing.T) {
 		assert.Equal(tt, repositoryExpected, rec.Body.String())
 	})
 
+	t.Run("create saves the hook id", func(tt *testing.T) {
+		mrs = &mocks.RepositoryStorer{}
+		mrs.On("Create", mock.Anything, mock.Anything).Return(&models.Repository{
+			Name: "test-name",
+			URL:  "https://github.com/Skarlso/test",
+			ID:   1,
+			VCS:  1,
+		}, nil)
+		mrs.On("UpdateHookID", mock.Anything, 1, "42").Return(nil)
+		mp := &mocks.Platform{}
+		mp.On("CreateHook", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			args.Get(1).(*models.Repository).HookID = "42"
+		}).Return(nil)
+		rh, err := NewRepositoryHandler(cfg, RepoHandlerDependencies{
+			Logger:           logger,
+			RepositoryStorer: mrs,
+			PlatformProviders: map[int]providers.Platform{
+				models.GITHUB: mp,
+			},
+			Auth: mars,
+		})
+		assert.NoError(t, err)
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		repositoryPost := `{"name" : "test-name", "url" : "https://github.com/Skarlso/test", "vcs" : 1, "auth": {"secret": "secret"}}`
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/repository", strings.NewReader(repositoryPost))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		err = rh.Create()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusCreated, rec.Code)
+		assert.Contains(tt, rec.Body.String(), `"hook_id":"42"`)
+		mrs.AssertExpectations(tt)
+	})
+
+	t.Run("create deletes the hook and the repository if the hook id can't be saved", func(tt *testing.T) {
+		mrs = &mocks.RepositoryStorer{}
+		mrs.On("Create", mock.Anything, mock.Anything).Return(&models.Repository{
+			Name: "test-name",
+			URL:  "https://github.com/Skarlso/test",
+			ID:   1,
+			VCS:  1,
+		}, nil)
+		mrs.On("UpdateHookID", mock.Anything, 1, "42").Return(errors.New("nope"))
+		mrs.On("Delete", mock.Anything, 1).Return(nil)
+		mp := &mocks.Platform{}
+		mp.On("CreateHook", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			args.Get(1).(*models.Repository).HookID = "42"
+		}).Return(nil)
+		mp.On("DeleteHook", mock.Anything, mock.MatchedBy(func(repo *models.Repository) bool {
+			return repo.HookID == "42"
+		})).Return(nil)
+		rh, err := NewRepositoryHandler(cfg, RepoHandlerDependencies{
+			Logger:           logger,
+			RepositoryStorer: mrs,
+			PlatformProviders: map[int]providers.Platform{
+				models.GITHUB: mp,
+			},
+			Auth: mars,
+		})
+		assert.NoError(t, err)
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		repositoryPost := `{"name" : "test-name", "url" : "https://github.com/Skarlso/test", "vcs" : 1, "auth": {"secret": "secret"}}`
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/repository", strings.NewReader(repositoryPost))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		err = rh.Create()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusInternalServerError, rec.Code)
+		mrs.AssertExpectations(tt)
+		mp.AssertExpectations(tt)
+	})
+
 	t.Run("invalid post data", func(tt *testing.T) {
 		mrs = &mocks.RepositoryStorer{}
 		rh, err := NewRepositoryHandler(cfg, RepoHandlerDependencies{
@@ -210,7 +306,9 @@ func TestRepoHandler_CreateRepository(t *testing.T) {
 }
 
 func TestRepoHandler_UpdateRepository(t *testing.T) {
-	mrs := &mockRepositoryStorer{}
+	mrs := &mockRepositoryStorer{
+		getRepo: &models.Repository{Name: "test", URL: "https://github.com/Skarlso/test", VCS: models.GITHUB},
+	}
 	mars := &mocks.RepositoryAuth{}
 	logger := zerolog.New(os.Stderr)
 	cfg := RepoConfig{
@@ -243,6 +341,131 @@ func TestRepoHandler_UpdateRepository(t *testing.T) {
 		assert.Equal(tt, repositoryExpected, rec.Body.String())
 	})
 
+	t.Run("update propagates events and secret to the hook", func(tt *testing.T) {
+		mrs.getRepo = &models.Repository{Name: "test", URL: "https://github.com/Skarlso/test", VCS: models.GITHUB, HookID: "42"}
+		defer func() {
+			mrs.getRepo = &models.Repository{Name: "test", URL: "https://github.com/Skarlso/test", VCS: models.GITHUB}
+		}()
+		mg := &mockGithubPlatformProvider{}
+		mars := &mocks.RepositoryAuth{}
+		mars.On("CreateRepositoryAuth", mock.Anything, 0, &models.Auth{Secret: "new-secret"}).Run(func(args mock.Arguments) {
+			// the secret is only saved once the hook uses it.
+			assert.NotNil(tt, mg.updated)
+		}).Return(nil)
+		rh, err := NewRepositoryHandler(cfg, RepoHandlerDependencies{
+			Logger:           logger,
+			RepositoryStorer: mrs,
+			Auth:             mars,
+			PlatformProviders: map[int]providers.Platform{
+				models.GITHUB: mg,
+			},
+		})
+		assert.NoError(tt, err)
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		repositoryPost := `{"name":"updated-name","id":0,"url":"https://github.com/Skarlso/test","vcs":1,"hook_id":"42","events":["push","pull_request"],"auth":{"secret":"new-secret"}}`
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/repository/update", strings.NewReader(repositoryPost))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		err = rh.Update()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusOK, rec.Code)
+		mars.AssertExpectations(tt)
+		require.NotNil(tt, mg.updated)
+		assert.Equal(tt, []string{"push", "pull_request"}, mg.updated.Events)
+		assert.Equal(tt, "new-secret", mg.updated.Auth.Secret)
+		assert.Equal(tt, "http://hookbase/rest/api/1/hooks/0/1/callback", mg.updated.UniqueURL)
+	})
+
+	t.Run("update of the secret uses the stored events of the hook", func(tt *testing.T) {
+		mrs.getRepo = &models.Repository{Name: "test", URL: "https://github.com/Skarlso/test", VCS: models.GITHUB, HookID: "42", Events: []string{"repo:push"}}
+		defer func() {
+			mrs.getRepo = &models.Repository{Name: "test", URL: "https://github.com/Skarlso/test", VCS: models.GITHUB}
+		}()
+		mg := &mockGithubPlatformProvider{}
+		mars := &mocks.RepositoryAuth{}
+		mars.On("CreateRepositoryAuth", mock.Anything, 0, &models.Auth{Secret: "new-secret"}).Return(nil)
+		rh, err := NewRepositoryHandler(cfg, RepoHandlerDependencies{
+			Logger:           logger,
+			RepositoryStorer: mrs,
+			Auth:             mars,
+			PlatformProviders: map[int]providers.Platform{
+				models.GITHUB: mg,
+			},
+		})
+		assert.NoError(tt, err)
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		repositoryPost := `{"name":"test","id":0,"auth":{"secret":"new-secret"}}`
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/repository/update", strings.NewReader(repositoryPost))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		err = rh.Update()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusOK, rec.Code)
+		mars.AssertExpectations(tt)
+		require.NotNil(tt, mg.updated)
+		assert.Equal(tt, []string{"repo:push"}, mg.updated.Events)
+		assert.Equal(tt, "new-secret", mg.updated.Auth.Secret)
+	})
+
+	t.Run("update doesn't save the secret if the hook update fails", func(tt *testing.T) {
+		mrs.getRepo = &models.Repository{Name: "test", URL: "https://github.com/Skarlso/test", VCS: models.GITHUB, HookID: "42", Events: []string{"push"}}
+		defer func() {
+			mrs.getRepo = &models.Repository{Name: "test", URL: "https://github.com/Skarlso/test", VCS: models.GITHUB}
+		}()
+		mp := &mocks.Platform{}
+		mp.On("UpdateHook", mock.Anything, mock.Anything).Return(errors.New("nope"))
+		mars := &mocks.RepositoryAuth{}
+		rh, err := NewRepositoryHandler(cfg, RepoHandlerDependencies{
+			Logger:           logger,
+			RepositoryStorer: mrs,
+			Auth:             mars,
+			PlatformProviders: map[int]providers.Platform{
+				models.GITHUB: mp,
+			},
+		})
+		assert.NoError(tt, err)
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		repositoryPost := `{"name":"test","id":0,"auth":{"secret":"new-secret"}}`
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/repository/update", strings.NewReader(repositoryPost))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		err = rh.Update()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusInternalServerError, rec.Code)
+		mars.AssertNotCalled(tt, "CreateRepositoryAuth", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("update invalid generic settings", func(tt *testing.T) {
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		repositoryPost := `{"name":"updated-name","id":0,"url":"https://sentry.io","vcs":6,"generic":{"signature_header":"X-Signature","signature_algorithm":"md5"}}`
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/repository/update", strings.NewReader(repositoryPost))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		err = rh.Update()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusBadRequest, rec.Code)
+	})
+
 	t.Run("update invalid syntax on body", func(tt *testing.T) {
 		token, err := generateTestToken("test@email.com")
 		assert.NoError(tt, err)
@@ -410,7 +633,10 @@ func TestRepoHandler_ListRepositories(t *testing.T) {
 }
 
 func TestRepoHandler_DeleteRepository(t *testing.T) {
-	mrs := &mockRepositoryStorer{}
+	mrs := &mockRepositoryStorer{
+		getRepo: &models.Repository{Name: "test", VCS: models.GITHUB},
+	}
+	mg := &mockGithubPlatformProvider{}
 	logger := zerolog.New(os.Stderr)
 	cfg := RepoConfig{
 		Protocol: "http",
@@ -419,6 +645,9 @@ func TestRepoHandler_DeleteRepository(t *testing.T) {
 	rh, err := NewRepositoryHandler(cfg, RepoHandlerDependencies{
 		Logger:           logger,
 		RepositoryStorer: mrs,
+		PlatformProviders: map[int]providers.Platform{
+			models.GITHUB: mg,
+		},
 	})
 	assert.NoError(t, err)
 
@@ -439,6 +668,88 @@ func TestRepoHandler_DeleteRepository(t *testing.T) {
 		assert.Equal(tt, http.StatusOK, rec.Code)
 	})
 
+	t.Run("delete deletes the hook", func(tt *testing.T) {
+		mrs.getRepo = &models.Repository{Name: "test", VCS: models.GITHUB, HookID: "42"}
+		defer func() { mrs.getRepo = &models.Repository{Name: "test", VCS: models.GITHUB} }()
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodDelete, "/", nil)
+		rec := httptest.NewRecorder()
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		c := e.NewContext(req, rec)
+		c.SetPath("/repository/:id")
+		c.SetParamNames("id")
+		c.SetParamValues("0")
+		err = rh.Delete()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusOK, rec.Code)
+		require.NotNil(tt, mg.deleted)
+		assert.Equal(tt, "42", mg.deleted.HookID)
+	})
+
+	t.Run("delete fails if the hook can't be deleted", func(tt *testing.T) {
+		mrs := &mocks.RepositoryStorer{}
+		mrs.On("Get", mock.Anything, 0).Return(&models.Repository{Name: "test", VCS: models.GITHUB, HookID: "42"}, nil)
+		mp := &mocks.Platform{}
+		mp.On("DeleteHook", mock.Anything, mock.Anything).Return(errors.New("nope"))
+		rh, err := NewRepositoryHandler(cfg, RepoHandlerDependencies{
+			Logger:           logger,
+			RepositoryStorer: mrs,
+			PlatformProviders: map[int]providers.Platform{
+				models.GITHUB: mp,
+			},
+		})
+		assert.NoError(tt, err)
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodDelete, "/", nil)
+		rec := httptest.NewRecorder()
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		c := e.NewContext(req, rec)
+		c.SetPath("/repository/:id")
+		c.SetParamNames("id")
+		c.SetParamValues("0")
+		err = rh.Delete()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusInternalServerError, rec.Code)
+		mrs.AssertNotCalled(tt, "Delete", mock.Anything, 0)
+	})
+
+	t.Run("delete with force deletes the repository if the hook can't be deleted", func(tt *testing.T) {
+		mrs := &mocks.RepositoryStorer{}
+		mrs.On("Get", mock.Anything, 0).Return(&models.Repository{Name: "test", VCS: models.GITHUB, HookID: "42"}, nil)
+		mrs.On("Delete", mock.Anything, 0).Return(nil)
+		mp := &mocks.Platform{}
+		mp.On("DeleteHook", mock.Anything, mock.Anything).Return(errors.New("nope"))
+		rh, err := NewRepositoryHandler(cfg, RepoHandlerDependencies{
+			Logger:           logger,
+			RepositoryStorer: mrs,
+			PlatformProviders: map[int]providers.Platform{
+				models.GITHUB: mp,
+			},
+		})
+		assert.NoError(tt, err)
+		token, err := generateTestToken("test@email.com")
+		assert.NoError(tt, err)
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodDelete, "/?force=true", nil)
+		rec := httptest.NewRecorder()
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		c := e.NewContext(req, rec)
+		c.SetPath("/repository/:id")
+		c.SetParamNames("id")
+		c.SetParamValues("0")
+		err = rh.Delete()(c)
+		assert.NoError(tt, err)
+		assert.Equal(tt, http.StatusOK, rec.Code)
+		mrs.AssertExpectations(tt)
+	})
+
 	t.Run("delete invalid id", func(tt *testing.T) {
 		token, err := generateTestToken("test@email.com")
 		assert.NoError(tt, err)
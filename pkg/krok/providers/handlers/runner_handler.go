@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+const (
+	// defaultClaimTimeout is how long a runner waits for a command run if none is configured.
+	defaultClaimTimeout = 30 * time.Second
+	// maximumLogChunkSize is the maximum number of bytes a runner can upload at once.
+	maximumLogChunkSize = 1024 * 1024
+)
+
+// RunnerHandlerConfig defines the configuration of the runner handler.
+type RunnerHandlerConfig struct {
+	// ClaimTimeout is how long a runner waits for a command run before it has to ask again. It has to be below
+	// the runner timeout of the remote executor. Defaults to 30 seconds.
+	ClaimTimeout time.Duration
+}
+
+// RunnerHandlerDependencies defines the dependencies for the runner handler provider.
+type RunnerHandlerDependencies struct {
+	Logger zerolog.Logger
+	// Coordinator is nil if the executor doesn't support remote runners.
+	Coordinator providers.RunnerCoordinator
+}
+
+// RunnerHandler is a handler taking care of the api calls of remote runners.
+type RunnerHandler struct {
+	RunnerHandlerConfig
+	RunnerHandlerDependencies
+}
+
+var _ providers.RunnerHandler = &RunnerHandler{}
+
+// NewRunnerHandler creates a new runner handler.
+func NewRunnerHandler(cfg RunnerHandlerConfig, deps RunnerHandlerDependencies) *RunnerHandler {
+	if cfg.ClaimTimeout <= 0 {
+		cfg.ClaimTimeout = defaultClaimTimeout
+	}
+	return &RunnerHandler{
+		RunnerHandlerConfig:       cfg,
+		RunnerHandlerDependencies: deps,
+	}
+}
+
+// notEnabled responds to calls while the executor doesn't support remote runners.
+func (r *RunnerHandler) notEnabled(c echo.Context) error {
+	err := errors.New("runners are only supported by the remote executor")
+	return c.JSON(http.StatusNotFound, kerr.APIError("runners are not enabled", http.StatusNotFound, err))
+}
+
+// Register handles the registration of a runner.
+// swagger:operation POST /runner/register registerRunner
+// Registers a remote runner, which can claim command runs afterwards. Runners authenticate with the runner token.
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// parameters:
+// - name: registration
+//   in: body
+//   required: true
+//   schema:
+//     "$ref": "#/definitions/RunnerRegistration"
+// responses:
+//   '201':
+//     schema:
+//       "$ref": "#/definitions/Runner"
+//   '400':
+//     description: 'invalid json payload or missing name'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '404':
+//     description: 'runners are not enabled'
+//   '500':
+//     description: 'failed to register runner'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (r *RunnerHandler) Register() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if r.Coordinator == nil {
+			return r.notEnabled(c)
+		}
+		registration := &models.RunnerRegistration{}
+		if err := c.Bind(registration); err != nil {
+			r.Logger.Debug().Err(err).Msg("Failed to bind runner registration.")
+			return c.JSON(http.StatusBadRequest, kerr.APIError("failed to bind runner registration", http.StatusBadRequest, err))
+		}
+		if registration.Name == "" {
+			return c.JSON(http.StatusBadRequest, kerr.APIError("invalid runner registration", http.StatusBadRequest, errors.New("name is required")))
+		}
+
+		runner, err := r.Coordinator.RegisterRunner(c.Request().Context(), registration)
+		if err != nil {
+			r.Logger.Debug().Err(err).Msg("Runner registration failed.")
+			return c.JSON(http.StatusInternalServerError, kerr.APIError("runner registration failed", http.StatusInternalServerError, err))
+		}
+		return c.JSON(http.StatusCreated, runner)
+	}
+}
+
+// List lists the registered runners.
+// swagger:operation GET /runners listRunners
+// Returns the registered runners which contacted Krok recently.
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     schema:
+//       type: array
+//       items:
+//         "$ref": "#/definitions/Runner"
+//   '404':
+//     description: 'runners are not enabled'
+//   '500':
+//     description: 'failed to list runners'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (r *RunnerHandler) List() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if r.Coordinator == nil {
+			return r.notEnabled(c)
+		}
+		runners, err := r.Coordinator.ListRunners(c.Request().Context())
+		if err != nil {
+			r.Logger.Debug().Err(err).Msg("Failed to list runners.")
+			return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to list runners", http.StatusInternalServerError, err))
+		}
+		return c.JSON(http.StatusOK, runners)
+	}
+}
+
+// Claim hands a command run to a runner.
+// swagger:operation POST /runner/{id}/claim claimJob
+// Waits for a command run the runner has all runner labels for. If there is none for a while, no content is returned
+// and the runner has to ask again.
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   type: string
+//   required: true
+// responses:
+//   '200':
+//     schema:
+//       "$ref": "#/definitions/RunnerJob"
+//   '204':
+//     description: 'there was no command run for the runner'
+//   '404':
+//     description: 'the runner is not registered or runners are not enabled'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '500':
+//     description: 'failed to claim command run'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (r *RunnerHandler) Claim() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if r.Coordinator == nil {
+			return r.notEnabled(c)
+		}
+		id := c.Param("id")
+		ctx, cancel := context.WithTimeout(c.Request().Context(), r.ClaimTimeout)
+		defer cancel()
+		job, err := r.Coordinator.ClaimJob(ctx, id)
+		if err != nil {
+			if errors.Is(err, kerr.ErrNotFound) {
+				return c.JSON(http.StatusNotFound, kerr.APIError("runner not found", http.StatusNotFound, err))
+			}
+			r.Logger.Debug().Err(err).Str("runner_id", id).Msg("Failed to claim command run.")
+			return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to claim command run", http.StatusInternalServerError, err))
+		}
+		if job == nil {
+			return c.NoContent(http.StatusNoContent)
+		}
+		return c.JSON(http.StatusOK, job)
+	}
+}
+
+// AppendLogs adds output of a command run which a runner runs to its logs.
+// swagger:operation POST /runner/{id}/job/{crid}/logs appendJobLogs
+// Adds the output in the body to the logs of the command run. The response tells the runner whether the command
+// run has been cancelled. Runners call this regularly, even without new output, to show that they are still there.
+// ---
+// consumes:
+// - application/octet-stream
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   type: string
+//   required: true
+// - name: crid
+//   in: path
+//   type: integer
+//   format: int
+//   required: true
+// responses:
+//   '200':
+//     schema:
+//       "$ref": "#/definitions/RunnerLogsResponse"
+//   '400':
+//     description: 'invalid command run id or logs'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '404':
+//     description: 'the runner does not run the command run or runners are not enabled'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '500':
+//     description: 'failed to append logs'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (r *RunnerHandler) AppendLogs() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if r.Coordinator == nil {
+			return r.notEnabled(c)
+		}
+		id := c.Param("id")
+		commandRunID, err := GetParamAsInt("crid", c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, kerr.APIError("failed to parse parameter", http.StatusBadRequest, err))
+		}
+		logs, err := io.ReadAll(io.LimitReader(c.Request().Body, maximumLogChunkSize+1))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, kerr.APIError("failed to read logs", http.StatusBadRequest, err))
+		}
+		if len(logs) > maximumLogChunkSize {
+			return c.JSON(http.StatusBadRequest, kerr.APIError("failed to read logs", http.StatusBadRequest, fmt.Errorf("logs are larger than %d bytes", maximumLogChunkSize)))
+		}
+		cancelled, err := r.Coordinator.AppendLogs(c.Request().Context(), id, commandRunID, logs)
+		if err != nil {
+			if errors.Is(err, kerr.ErrNotFound) {
+				return c.JSON(http.StatusNotFound, kerr.APIError("command run not found", http.StatusNotFound, err))
+			}
+			r.Logger.Debug().Err(err).Str("runner_id", id).Int("command_run_id", commandRunID).Msg("Failed to append logs.")
+			return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to append logs", http.StatusInternalServerError, err))
+		}
+		return c.JSON(http.StatusOK, &models.RunnerLogsResponse{Cancelled: cancelled})
+	}
+}
+
+// Finish saves the outcome of a command run which a runner ran.
+// swagger:operation POST /runner/{id}/job/{crid}/finish finishJob
+// Saves the outcome of the command run once the runner finished running the command.
+// ---
+// consumes:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   type: string
+//   required: true
+// - name: crid
+//   in: path
+//   type: integer
+//   format: int
+//   required: true
+// - name: result
+//   in: body
+//   required: true
+//   schema:
+//     "$ref": "#/definitions/RunnerJobResult"
+// responses:
+//   '200':
+//     description: 'the outcome has been saved'
+//   '400':
+//     description: 'invalid command run id, json payload or status'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '404':
+//     description: 'the runner does not run the command run or runners are not enabled'
+//     schema:
+//       "$ref": "#/responses/Message"
+//   '500':
+//     description: 'failed to save the outcome'
+//     schema:
+//       "$ref": "#/responses/Message"
+func (r *RunnerHandler) Finish() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if r.Coordinator == nil {
+			return r.notEnabled(c)
+		}
+		id := c.Param("id")
+		commandRunID, err := GetParamAsInt("crid", c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, kerr.APIError("failed to parse parameter", http.StatusBadRequest, err))
+		}
+		result := &models.RunnerJobResult{}
+		if err := c.Bind(result); err != nil {
+			r.Logger.Debug().Err(err).Msg("Failed to bind runner job result.")
+			return c.JSON(http.StatusBadRequest, kerr.APIError("failed to bind runner job result", http.StatusBadRequest, err))
+		}
+		switch result.Status {
+		case models.RunStatusSuccess, models.RunStatusFailed, models.RunStatusCancelled, models.RunStatusTimedOut:
+		default:
+			return c.JSON(http.StatusBadRequest, kerr.APIError("invalid runner job result", http.StatusBadRequest, fmt.Errorf("%q is not a final status", result.Status)))
+		}
+		if err := r.Coordinator.FinishJob(c.Request().Context(), id, commandRunID, result); err != nil {
+			if errors.Is(err, kerr.ErrNotFound) {
+				return c.JSON(http.StatusNotFound, kerr.APIError("command run not found", http.StatusNotFound, err))
+			}
+			r.Logger.Debug().Err(err).Str("runner_id", id).Int("command_run_id", commandRunID).Msg("Failed to finish command run.")
+			return c.JSON(http.StatusInternalServerError, kerr.APIError("failed to finish command run", http.StatusInternalServerError, err))
+		}
+		return c.NoContent(http.StatusOK)
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers/mocks"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+func TestRunnerHandler_Register(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	mrc := &mocks.RunnerCoordinator{}
+	mrc.On("RegisterRunner", mock.Anything, &models.RunnerRegistration{Name: "build-arm64-1", Labels: []string{"arm64"}}).
+		Return(&models.Runner{ID: "runner-1", Name: "build-arm64-1", Labels: []string{"arm64"}}, nil)
+	rh := NewRunnerHandler(RunnerHandlerConfig{}, RunnerHandlerDependencies{
+		Logger:      logger,
+		Coordinator: mrc,
+	})
+	for _, tc := range []struct {
+		name string
+		body string
+		code int
+	}{
+		{name: "a runner registers", body: `{"name":"build-arm64-1","labels":["arm64"]}`, code: http.StatusCreated},
+		{name: "a runner without a name is rejected", body: `{"labels":["arm64"]}`, code: http.StatusBadRequest},
+		{name: "invalid json", body: `{`, code: http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(tt *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/runner/register")
+			err := rh.Register()(c)
+			assert.NoError(tt, err)
+			assert.Equal(tt, tc.code, rec.Code)
+		})
+	}
+	mrc.AssertExpectations(t)
+}
+
+func TestRunnerHandler_Claim(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	mrc := &mocks.RunnerCoordinator{}
+	mrc.On("ClaimJob", mock.Anything, "runner-1").Return(&models.RunnerJob{CommandRunID: 1, Args: []string{"--platform=github"}}, nil)
+	mrc.On("ClaimJob", mock.Anything, "runner-2").Return(nil, nil)
+	mrc.On("ClaimJob", mock.Anything, "runner-3").Return(nil, fmt.Errorf("runner with ID runner-3: %w", kerr.ErrNotFound))
+	rh := NewRunnerHandler(RunnerHandlerConfig{}, RunnerHandlerDependencies{
+		Logger:      logger,
+		Coordinator: mrc,
+	})
+	for _, tc := range []struct {
+		name string
+		id   string
+		code int
+		body string
+	}{
+		{name: "a runner claims a command run", id: "runner-1", code: http.StatusOK, body: `"command_run_id":1`},
+		{name: "there is no command run for the runner", id: "runner-2", code: http.StatusNoContent},
+		{name: "the runner has to register first", id: "runner-3", code: http.StatusNotFound},
+	} {
+		t.Run(tc.name, func(tt *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/runner/:id/claim")
+			c.SetParamNames("id")
+			c.SetParamValues(tc.id)
+			err := rh.Claim()(c)
+			assert.NoError(tt, err)
+			assert.Equal(tt, tc.code, rec.Code)
+			assert.Contains(tt, rec.Body.String(), tc.body)
+		})
+	}
+}
+
+func TestRunnerHandler_AppendLogs(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	mrc := &mocks.RunnerCoordinator{}
+	mrc.On("AppendLogs", mock.Anything, "runner-1", 1, []byte("building...\n")).Return(false, nil)
+	mrc.On("AppendLogs", mock.Anything, "runner-1", 2, []byte("building...\n")).Return(true, nil)
+	mrc.On("AppendLogs", mock.Anything, "runner-1", 3, []byte("building...\n")).Return(false, kerr.ErrNotFound)
+	mrc.On("AppendLogs", mock.Anything, "runner-1", 4, []byte("building...\n")).Return(false, errors.New("nope"))
+	rh := NewRunnerHandler(RunnerHandlerConfig{}, RunnerHandlerDependencies{
+		Logger:      logger,
+		Coordinator: mrc,
+	})
+	for _, tc := range []struct {
+		name string
+		crid string
+		code int
+		body string
+	}{
+		{name: "logs are appended", crid: "1", code: http.StatusOK, body: `{"cancelled":false}`},
+		{name: "the runner learns about a cancellation", crid: "2", code: http.StatusOK, body: `{"cancelled":true}`},
+		{name: "the runner doesn't run the command run", crid: "3", code: http.StatusNotFound},
+		{name: "when the logs can't be appended", crid: "4", code: http.StatusInternalServerError},
+		{name: "when the command run id is invalid", crid: "invalid", code: http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(tt *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("building...\n"))
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/runner/:id/job/:crid/logs")
+			c.SetParamNames("id", "crid")
+			c.SetParamValues("runner-1", tc.crid)
+			err := rh.AppendLogs()(c)
+			assert.NoError(tt, err)
+			assert.Equal(tt, tc.code, rec.Code)
+			assert.Contains(tt, rec.Body.String(), tc.body)
+		})
+	}
+}
+
+func TestRunnerHandler_Finish(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	mrc := &mocks.RunnerCoordinator{}
+	mrc.On("FinishJob", mock.Anything, "runner-1", 1, &models.RunnerJobResult{Status: models.RunStatusSuccess, Outcome: "done"}).Return(nil)
+	mrc.On("FinishJob", mock.Anything, "runner-1", 2, mock.Anything).Return(kerr.ErrNotFound)
+	rh := NewRunnerHandler(RunnerHandlerConfig{}, RunnerHandlerDependencies{
+		Logger:      logger,
+		Coordinator: mrc,
+	})
+	for _, tc := range []struct {
+		name string
+		crid string
+		body string
+		code int
+	}{
+		{name: "the outcome is saved", crid: "1", body: `{"status":"success","outcome":"done"}`, code: http.StatusOK},
+		{name: "the runner doesn't run the command run", crid: "2", body: `{"status":"failed"}`, code: http.StatusNotFound},
+		{name: "a status which isn't final is rejected", crid: "1", body: `{"status":"running"}`, code: http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(tt *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/runner/:id/job/:crid/finish")
+			c.SetParamNames("id", "crid")
+			c.SetParamValues("runner-1", tc.crid)
+			err := rh.Finish()(c)
+			assert.NoError(tt, err)
+			assert.Equal(tt, tc.code, rec.Code)
+		})
+	}
+	mrc.AssertExpectations(t)
+}
+
+func TestRunnerHandler_NotEnabled(t *testing.T) {
+	rh := NewRunnerHandler(RunnerHandlerConfig{}, RunnerHandlerDependencies{
+		Logger: zerolog.New(os.Stderr),
+	})
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/runners")
+	err := rh.List()(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -39,11 +39,18 @@ var _ providers.CommandRunStorer = &CommandRunStore{}
 func (a *CommandRunStore) CreateRun(ctx context.Context, cmdRun *models.CommandRun) (*models.CommandRun, error) {
 	log := a.Logger.With().Int("event_id", cmdRun.EventID).Logger()
 	var returnID int
+	// Runs which aren't a retry are the first attempt of their command.
+	if cmdRun.Attempt == 0 {
+		cmdRun.Attempt = 1
+	}
 	f := func(tx pgx.Tx) error {
-		query := fmt.Sprintf("insert into %s(event_id, command_name, status, outcome, created_at) values($1, $2, $3, $4, $5) returning id", commandRunTable)
+		query := fmt.Sprintf("insert into %s(event_id, command_name, command_id, attempt, container_id, status, outcome, created_at) values($1, $2, $3, $4, $5, $6, $7, $8) returning id", commandRunTable)
 		row := tx.QueryRow(ctx, query,
 			cmdRun.EventID,
 			cmdRun.CommandName,
+			cmdRun.CommandID,
+			cmdRun.Attempt,
+			cmdRun.ContainerID,
 			cmdRun.Status,
 			cmdRun.Outcome,
 			cmdRun.CreateAt)
@@ -68,17 +75,21 @@ func (a *CommandRunStore) CreateRun(ctx context.Context, cmdRun *models.CommandR
 func (a *CommandRunStore) Get(ctx context.Context, id int) (*models.CommandRun, error) {
 	log := a.Logger.With().Int("id", id).Logger()
 	var (
-		storedID        int
-		storedName      string
-		storedEventID   int
-		storedStatus    string
-		storedOutcome   string
-		storedCreatedAt time.Time
+		storedID          int
+		storedName        string
+		storedCommandID   int
+		storedAttempt     int
+		storedContainerID string
+		storedEventID     int
+		storedStatus      string
+		storedOutcome     string
+		storedSignal      string
+		storedCreatedAt   time.Time
 	)
 	f := func(tx pgx.Tx) error {
-		query := fmt.Sprintf("select id, command_name, event_id, status, outcome, created_at from %s where id = $1", commandRunTable)
+		query := fmt.Sprintf("select id, command_name, command_id, attempt, container_id, event_id, status, outcome, signal, created_at from %s where id = $1", commandRunTable)
 		if err := tx.QueryRow(ctx, query, id).
-			Scan(&storedID, &storedName, &storedEventID, &storedStatus, &storedOutcome, &storedCreatedAt); err != nil {
+			Scan(&storedID, &storedName, &storedCommandID, &storedAttempt, &storedContainerID, &storedEventID, &storedStatus, &storedOutcome, &storedSignal, &storedCreatedAt); err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				return &kerr.QueryError{
 					Query: query,
@@ -102,9 +113,13 @@ func (a *CommandRunStore) Get(ctx context.Context, id int) (*models.CommandRun,
 	return &models.CommandRun{
 		ID:          storedID,
 		CommandName: storedName,
+		CommandID:   storedCommandID,
+		Attempt:     storedAttempt,
+		ContainerID: storedContainerID,
 		EventID:     storedEventID,
 		Status:      storedStatus,
 		Outcome:     storedOutcome,
+		Signal:      storedSignal,
 		CreateAt:    storedCreatedAt,
 	}, nil
 }
@@ -138,3 +153,92 @@ func (a *CommandRunStore) UpdateRunStatus(ctx context.Context, id int, status st
 	}
 	return nil
 }
+
+// UpdateRunContainer saves the ID of the container which executes the command run.
+func (a *CommandRunStore) UpdateRunContainer(ctx context.Context, id int, containerID string) error {
+	log := a.Logger.With().Int("id", id).Str("container_id", containerID).Logger()
+	f := func(tx pgx.Tx) error {
+		tags, err := tx.Exec(ctx, fmt.Sprintf("update %s set container_id = $1 where id = $2", commandRunTable),
+			containerID, id)
+		if err != nil {
+			return &kerr.QueryError{
+				Query: "update container_id",
+				Err:   fmt.Errorf("failed to update: %w", err),
+			}
+		}
+		if tags.RowsAffected() == 0 {
+			return &kerr.QueryError{
+				Query: "update container_id",
+				Err:   kerr.ErrNoRowsAffected,
+			}
+		}
+		return nil
+	}
+	if err := a.Connector.ExecuteWithTransaction(ctx, log, f); err != nil {
+		log.Debug().Err(err).Msg("Failed to execute with transaction.")
+		return fmt.Errorf("failed to execute update in transaction: %w", err)
+	}
+	return nil
+}
+
+// UpdateRunSignal saves the signal which stopped a cancelled or timed out command run.
+func (a *CommandRunStore) UpdateRunSignal(ctx context.Context, id int, signal string) error {
+	log := a.Logger.With().Int("id", id).Str("signal", signal).Logger()
+	f := func(tx pgx.Tx) error {
+		tags, err := tx.Exec(ctx, fmt.Sprintf("update %s set signal = $1 where id = $2", commandRunTable),
+			signal, id)
+		if err != nil {
+			return &kerr.QueryError{
+				Query: "update signal",
+				Err:   fmt.Errorf("failed to update: %w", err),
+			}
+		}
+		if tags.RowsAffected() == 0 {
+			return &kerr.QueryError{
+				Query: "update signal",
+				Err:   kerr.ErrNoRowsAffected,
+			}
+		}
+		return nil
+	}
+	if err := a.Connector.ExecuteWithTransaction(ctx, log, f); err != nil {
+		log.Debug().Err(err).Msg("Failed to execute with transaction.")
+		return fmt.Errorf("failed to execute update in transaction: %w", err)
+	}
+	return nil
+}
+
+// ListRunsWithStatus returns all command runs which are in any of the given statuses.
+func (a *CommandRunStore) ListRunsWithStatus(ctx context.Context, statuses ...string) ([]*models.CommandRun, error) {
+	log := a.Logger.With().Strs("statuses", statuses).Logger()
+	result := make([]*models.CommandRun, 0)
+	f := func(tx pgx.Tx) error {
+		query := fmt.Sprintf("select id, command_name, command_id, attempt, container_id, event_id, status, outcome, signal, created_at from %s where status = any($1)", commandRunTable)
+		rows, err := tx.Query(ctx, query, statuses)
+		if err != nil {
+			log.Debug().Err(err).Msg("Failed to query command runs.")
+			return &kerr.QueryError{
+				Query: query,
+				Err:   fmt.Errorf("failed to list command runs: %w", err),
+			}
+		}
+
+		for rows.Next() {
+			run := &models.CommandRun{}
+			if err := rows.Scan(&run.ID, &run.CommandName, &run.CommandID, &run.Attempt, &run.ContainerID, &run.EventID, &run.Status, &run.Outcome, &run.Signal, &run.CreateAt); err != nil {
+				log.Debug().Err(err).Msg("Failed to scan.")
+				return &kerr.QueryError{
+					Query: query,
+					Err:   fmt.Errorf("failed to scan: %w", err),
+				}
+			}
+			result = append(result, run)
+		}
+		return nil
+	}
+	if err := a.Connector.ExecuteWithTransaction(ctx, log, f); err != nil {
+		log.Debug().Err(err).Msg("Failed to execute with transaction.")
+		return nil, fmt.Errorf("failed to execute ListRunsWithStatus: %w", err)
+	}
+	return result, nil
+}
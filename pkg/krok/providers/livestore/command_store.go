@@ -41,19 +41,104 @@ func NewCommandStore(deps CommandDependencies) (*CommandStore, error) {
 
 var _ providers.CommandStorer = &CommandStore{}
 
+// concurrencyColumns holds the columns the concurrency policy of a command is stored in.
+type concurrencyColumns struct {
+	group            string
+	cancelInProgress bool
+}
+
+func newConcurrencyColumns(policy *models.ConcurrencyPolicy) concurrencyColumns {
+	if policy == nil {
+		return concurrencyColumns{}
+	}
+	return concurrencyColumns{
+		group:            policy.Group,
+		cancelInProgress: policy.CancelInProgress,
+	}
+}
+
+// policy returns the stored concurrency policy or nil if the runs of the command aren't grouped.
+func (c concurrencyColumns) policy() *models.ConcurrencyPolicy {
+	if c.group == "" {
+		return nil
+	}
+	return &models.ConcurrencyPolicy{
+		Group:            c.group,
+		CancelInProgress: c.cancelInProgress,
+	}
+}
+
+// retryColumns holds the columns the retry policy of a command is stored in.
+type retryColumns struct {
+	maxAttempts int
+	backoff     int
+	retryOn     []string
+}
+
+func newRetryColumns(policy *models.RetryPolicy) retryColumns {
+	columns := retryColumns{retryOn: []string{}}
+	if policy == nil {
+		return columns
+	}
+	columns.maxAttempts = policy.MaxAttempts
+	columns.backoff = policy.Backoff
+	if policy.RetryOn != nil {
+		columns.retryOn = policy.RetryOn
+	}
+	return columns
+}
+
+// policy returns the stored retry policy or nil if the command isn't retried.
+func (r retryColumns) policy() *models.RetryPolicy {
+	if r.maxAttempts == 0 {
+		return nil
+	}
+	return &models.RetryPolicy{
+		MaxAttempts: r.maxAttempts,
+		Backoff:     r.backoff,
+		RetryOn:     r.retryOn,
+	}
+}
+
+// runnerLabels returns the runner labels of a command, which are never null in the database.
+func runnerLabels(c *models.Command) []string {
+	if c.RunnerLabels == nil {
+		return []string{}
+	}
+	return c.RunnerLabels
+}
+
 // Create creates a command record.
 func (s *CommandStore) Create(ctx context.Context, c *models.Command) (*models.Command, error) {
 	log := s.Logger.With().Str("name", c.Name).Logger()
 	// duplicate key value violates unique constraint
 	// id will be generated.
 
+	retry := newRetryColumns(c.Retry)
+	concurrency := newConcurrencyColumns(c.Concurrency)
 	f := func(tx pgx.Tx) error {
-		if tags, err := tx.Exec(ctx, fmt.Sprintf("insert into %s(name, schedule, enabled, image, requires_clone) values($1, $2, $3, $4, $5)", commandsTable),
+		if tags, err := tx.Exec(ctx, fmt.Sprintf("insert into %s(name, schedule, enabled, image, requires_clone, timeout, cpus, memory_limit, pids_limit, disk_limit, stop_signal, stop_grace_period, retry_max_attempts, retry_backoff, retry_on, priority, max_parallel_runs, concurrency_group, concurrency_cancel_in_progress, pull_policy, runner_labels) values($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)", commandsTable),
 			c.Name,
 			c.Schedule,
 			c.Enabled,
 			c.Image,
-			c.RequiresClone); err != nil {
+			c.RequiresClone,
+			c.Timeout,
+			c.CPUs,
+			c.MemoryLimit,
+			c.PidsLimit,
+			c.DiskLimit,
+			c.StopSignal,
+			c.StopGracePeriod,
+			retry.maxAttempts,
+			retry.backoff,
+			retry.retryOn,
+			c.Priority,
+			c.MaxParallelRuns,
+			concurrency.group,
+			concurrency.cancelInProgress,
+			c.PullPolicy,
+			runnerLabels(c)); err != nil {
 			log.Debug().Err(err).Msg("Failed to create command.")
 			return &kerr.QueryError{
 				Err:   err,
@@ -104,11 +189,20 @@ func (s *CommandStore) getByX(ctx context.Context, log zerolog.Logger, field str
 		enabled       bool
 		image         string
 		requiresClone bool
+		limits        models.Command
+		retry         retryColumns
+		concurrency   concurrencyColumns
 	)
 	f := func(tx pgx.Tx) error {
-		query := fmt.Sprintf("select name, id, schedule, enabled, image, requires_clone from %s where %s = $1", commandsTable, field)
+		query := fmt.Sprintf("select name, id, schedule, enabled, image, requires_clone, timeout, cpus, memory_limit, pids_limit, disk_limit, stop_signal, stop_grace_period, retry_max_attempts, retry_backoff, retry_on, priority, max_parallel_runs, concurrency_group, concurrency_cancel_in_progress, pull_policy, runner_labels from %s where %s = $1", commandsTable, field)
 		if err := tx.QueryRow(ctx, query, value).
-			Scan(&name, &commandID, &schedule, &enabled, &image, &requiresClone); err != nil {
+			Scan(&name, &commandID, &schedule, &enabled, &image, &requiresClone,
+				&limits.Timeout, &limits.CPUs, &limits.MemoryLimit, &limits.PidsLimit, &limits.DiskLimit,
+				&limits.StopSignal, &limits.StopGracePeriod,
+				&retry.maxAttempts, &retry.backoff, &retry.retryOn,
+				&limits.Priority, &limits.MaxParallelRuns,
+				&concurrency.group, &concurrency.cancelInProgress,
+				&limits.PullPolicy, &limits.RunnerLabels); err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				return &kerr.QueryError{
 					Query: query,
@@ -147,15 +241,38 @@ func (s *CommandStore) getByX(ctx context.Context, log zerolog.Logger, field str
 		}
 	}
 
+	dependsOn, err := s.GetCommandDependencies(ctx, commandID)
+	if err != nil {
+		log.Debug().Err(err).Msg("GetCommandDependencies failed")
+		return nil, &kerr.QueryError{
+			Query: "select depends_on_id",
+			Err:   err,
+		}
+	}
+
 	return &models.Command{
-		Name:          name,
-		ID:            commandID,
-		Schedule:      schedule,
-		Repositories:  repositories,
-		Enabled:       enabled,
-		Image:         image,
-		Platforms:     platforms,
-		RequiresClone: requiresClone,
+		Name:            name,
+		ID:              commandID,
+		Schedule:        schedule,
+		Repositories:    repositories,
+		Enabled:         enabled,
+		Image:           image,
+		Platforms:       platforms,
+		RequiresClone:   requiresClone,
+		DependsOn:       dependsOn,
+		Timeout:         limits.Timeout,
+		CPUs:            limits.CPUs,
+		MemoryLimit:     limits.MemoryLimit,
+		PidsLimit:       limits.PidsLimit,
+		DiskLimit:       limits.DiskLimit,
+		StopSignal:      limits.StopSignal,
+		StopGracePeriod: limits.StopGracePeriod,
+		Retry:           retry.policy(),
+		Priority:        limits.Priority,
+		MaxParallelRuns: limits.MaxParallelRuns,
+		Concurrency:     concurrency.policy(),
+		PullPolicy:      limits.PullPolicy,
+		RunnerLabels:    limits.RunnerLabels,
 	}, nil
 }
 
@@ -200,7 +317,7 @@ func (s *CommandStore) getRepositoriesForCommand(ctx context.Context, id int) ([
 			}
 			repo := &models.Repository{
 				Name: name,
-				ID:   id,
+				ID:   repoID,
 				URL:  url,
 				VCS:  vcs,
 			}
@@ -296,6 +413,34 @@ func (s *CommandStore) Update(ctx context.Context, c *models.Command) (*models.C
 		args = append(args, c.Enabled)
 		sets = append(sets, "enabled = $"+strconv.Itoa(len(args)))
 
+		// The limits, the stop policy, the retry policy, the queue settings, the concurrency policy, the pull policy and the runner labels are always set, so they can be reset to their defaults.
+		retry := newRetryColumns(c.Retry)
+		concurrency := newConcurrencyColumns(c.Concurrency)
+		for _, l := range []struct {
+			column string
+			value  interface{}
+		}{
+			{"timeout", c.Timeout},
+			{"cpus", c.CPUs},
+			{"memory_limit", c.MemoryLimit},
+			{"pids_limit", c.PidsLimit},
+			{"disk_limit", c.DiskLimit},
+			{"stop_signal", c.StopSignal},
+			{"stop_grace_period", c.StopGracePeriod},
+			{"retry_max_attempts", retry.maxAttempts},
+			{"retry_backoff", retry.backoff},
+			{"retry_on", retry.retryOn},
+			{"priority", c.Priority},
+			{"max_parallel_runs", c.MaxParallelRuns},
+			{"concurrency_group", concurrency.group},
+			{"concurrency_cancel_in_progress", concurrency.cancelInProgress},
+			{"pull_policy", c.PullPolicy},
+			{"runner_labels", runnerLabels(c)},
+		} {
+			args = append(args, l.value)
+			sets = append(sets, l.column+" = $"+strconv.Itoa(len(args)))
+		}
+
 		set := strings.Join(sets, ",")
 		args = append(args, c.ID)
 
@@ -332,7 +477,7 @@ func (s *CommandStore) List(ctx context.Context, opts *models.ListOptions) ([]*m
 	// Select all commands.
 	result := make([]*models.Command, 0)
 	f := func(tx pgx.Tx) error {
-		sql := fmt.Sprintf("select id, name, schedule, enabled, image, requires_clone from %s", commandsTable)
+		sql := fmt.Sprintf("select id, name, schedule, enabled, image, requires_clone, timeout, cpus, memory_limit, pids_limit, disk_limit, stop_signal, stop_grace_period, retry_max_attempts, retry_backoff, retry_on, priority, max_parallel_runs, concurrency_group, concurrency_cancel_in_progress, pull_policy, runner_labels from %s", commandsTable)
 		where := " where "
 		filters := make([]string, 0)
 		if opts.Name != "" {
@@ -365,8 +510,17 @@ func (s *CommandStore) List(ctx context.Context, opts *models.ListOptions) ([]*m
 				image         string
 				enabled       bool
 				requiresClone bool
+				limits        models.Command
+				retry         retryColumns
+				concurrency   concurrencyColumns
 			)
-			if err := rows.Scan(&id, &name, &schedule, &enabled, &image, &requiresClone); err != nil {
+			if err := rows.Scan(&id, &name, &schedule, &enabled, &image, &requiresClone,
+				&limits.Timeout, &limits.CPUs, &limits.MemoryLimit, &limits.PidsLimit, &limits.DiskLimit,
+				&limits.StopSignal, &limits.StopGracePeriod,
+				&retry.maxAttempts, &retry.backoff, &retry.retryOn,
+				&limits.Priority, &limits.MaxParallelRuns,
+				&concurrency.group, &concurrency.cancelInProgress,
+				&limits.PullPolicy, &limits.RunnerLabels); err != nil {
 				log.Debug().Err(err).Msg("Failed to scan.")
 				return &kerr.QueryError{
 					Query: "select all commands",
@@ -374,12 +528,25 @@ func (s *CommandStore) List(ctx context.Context, opts *models.ListOptions) ([]*m
 				}
 			}
 			command := &models.Command{
-				Name:          name,
-				ID:            id,
-				Schedule:      schedule,
-				Enabled:       enabled,
-				Image:         image,
-				RequiresClone: requiresClone,
+				Name:            name,
+				ID:              id,
+				Schedule:        schedule,
+				Enabled:         enabled,
+				Image:           image,
+				RequiresClone:   requiresClone,
+				Timeout:         limits.Timeout,
+				CPUs:            limits.CPUs,
+				MemoryLimit:     limits.MemoryLimit,
+				PidsLimit:       limits.PidsLimit,
+				DiskLimit:       limits.DiskLimit,
+				StopSignal:      limits.StopSignal,
+				StopGracePeriod: limits.StopGracePeriod,
+				Retry:           retry.policy(),
+				Priority:        limits.Priority,
+				MaxParallelRuns: limits.MaxParallelRuns,
+				Concurrency:     concurrency.policy(),
+				PullPolicy:      limits.PullPolicy,
+				RunnerLabels:    limits.RunnerLabels,
 			}
 			result = append(result, command)
 		}
@@ -445,6 +612,41 @@ func (s *CommandStore) RemoveCommandRelForRepository(ctx context.Context, comman
 	return nil
 }
 
+// UpdateCommandRelForRepository sets the filter of the relationship between a command and a repository.
+func (s *CommandStore) UpdateCommandRelForRepository(ctx context.Context, commandID int, repositoryID int, filter *models.CommandFilter) error {
+	log := s.Logger.With().Str("func", "UpdateCommandRelForRepository").Int("command_id", commandID).Int("repository_id", repositoryID).Logger()
+	var (
+		eventTypes   = make([]string, 0)
+		branchFilter string
+	)
+	if filter != nil {
+		eventTypes = append(eventTypes, filter.EventTypes...)
+		branchFilter = filter.Branch
+	}
+	f := func(tx pgx.Tx) error {
+		if tags, err := tx.Exec(ctx, fmt.Sprintf("update %s set event_types = $1, branch_filter = $2 where command_id = $3 and repository_id = $4", commandsRepositoriesRelTable),
+			eventTypes, branchFilter, commandID, repositoryID); err != nil {
+			log.Debug().Err(err).Msg("Failed to update relationship between command and repository.")
+			return &kerr.QueryError{
+				Err:   err,
+				Query: "update " + commandsRepositoriesRelTable,
+			}
+		} else if tags.RowsAffected() == 0 {
+			return &kerr.QueryError{
+				Err:   kerr.ErrNoRowsAffected,
+				Query: "update " + commandsRepositoriesRelTable,
+			}
+		}
+		return nil
+	}
+
+	if err := s.Connector.ExecuteWithTransaction(ctx, log, f); err != nil {
+		log.Debug().Err(err).Msg("Failed to update " + commandsRepositoriesRelTable)
+		return err
+	}
+	return nil
+}
+
 // CreateSetting will create a setting for a command.
 func (s *CommandStore) CreateSetting(ctx context.Context, setting *models.CommandSetting) (*models.CommandSetting, error) {
 	log := s.Logger.
@@ -470,12 +672,13 @@ func (s *CommandStore) CreateSetting(ctx context.Context, setting *models.Comman
 			}
 			rollBackValue = value
 		}
-		query := fmt.Sprintf("insert into %s(command_id, key, value, in_vault) values($1, $2, $3, $4) returning id", commandSettingsTable)
+		query := fmt.Sprintf("insert into %s(command_id, key, value, in_vault, delivery) values($1, $2, $3, $4, $5) returning id", commandSettingsTable)
 		rows := tx.QueryRow(ctx, query,
 			setting.CommandID,
 			setting.Key,
 			value,
-			setting.InVault)
+			setting.InVault,
+			setting.Delivery)
 		if err := rows.Scan(&returnedID); err != nil {
 			log.Debug().Err(err).Str("query", query).Msg("Failed to scan row.")
 			return &kerr.QueryError{
@@ -569,7 +772,7 @@ func (s *CommandStore) ListSettings(ctx context.Context, commandID int) ([]*mode
 	// Select all commands.
 	result := make([]*models.CommandSetting, 0)
 	f := func(tx pgx.Tx) error {
-		sql := fmt.Sprintf("select id, command_id, key, value, in_vault from %s where command_id = $1", commandSettingsTable)
+		sql := fmt.Sprintf("select id, command_id, key, value, in_vault, delivery from %s where command_id = $1", commandSettingsTable)
 		rows, err := tx.Query(ctx, sql, commandID)
 		if err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
@@ -592,8 +795,9 @@ func (s *CommandStore) ListSettings(ctx context.Context, commandID int) ([]*mode
 				key             string
 				value           string
 				inVault         bool
+				delivery        string
 			)
-			if err := rows.Scan(&id, &storedCommandID, &key, &value, &inVault); err != nil {
+			if err := rows.Scan(&id, &storedCommandID, &key, &value, &inVault, &delivery); err != nil {
 				log.Debug().Err(err).Msg("Failed to scan.")
 				return &kerr.QueryError{
 					Query: "select all command settings",
@@ -618,6 +822,7 @@ func (s *CommandStore) ListSettings(ctx context.Context, commandID int) ([]*mode
 				Key:       key,
 				Value:     value,
 				InVault:   inVault,
+				Delivery:  delivery,
 			}
 			result = append(result, setting)
 		}
@@ -639,11 +844,12 @@ func (s *CommandStore) GetSetting(ctx context.Context, id int) (*models.CommandS
 		key       string
 		value     string
 		inVault   bool
+		delivery  string
 	)
 	f := func(tx pgx.Tx) error {
-		query := fmt.Sprintf("select id, command_id, key, value, in_vault from %s where id = $1", commandSettingsTable)
+		query := fmt.Sprintf("select id, command_id, key, value, in_vault, delivery from %s where id = $1", commandSettingsTable)
 		if err := tx.QueryRow(ctx, query, id).
-			Scan(&storedID, &commandID, &key, &value, &inVault); err != nil {
+			Scan(&storedID, &commandID, &key, &value, &inVault, &delivery); err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				return &kerr.QueryError{
 					Query: query,
@@ -682,13 +888,14 @@ func (s *CommandStore) GetSetting(ctx context.Context, id int) (*models.CommandS
 		Key:       key,
 		Value:     value,
 		InVault:   inVault,
+		Delivery:  delivery,
 	}, nil
 }
 
 // UpdateSetting updates the value of a setting. Transferring values is not supported. Aka.:
 // If a value was in Vault it must remain in vault. If it was in db it must remain in db.
 // Updating the key is also not supported.
-// Update: Only the value can be modified.
+// Update: Only the value and the delivery can be modified. The delivery is kept if it's not set.
 func (s *CommandStore) UpdateSetting(ctx context.Context, setting *models.CommandSetting) error {
 	log := s.Logger.
 		With().
@@ -725,8 +932,8 @@ func (s *CommandStore) UpdateSetting(ctx context.Context, setting *models.Comman
 			rollBackKey = value
 			setting.Value = value
 		}
-		if tags, err := tx.Exec(ctx, fmt.Sprintf("update %s set value = $1 where id = $2", commandSettingsTable),
-			setting.Value, storedSetting.ID); err != nil {
+		if tags, err := tx.Exec(ctx, fmt.Sprintf("update %s set value = $1, delivery = coalesce(nullif($2, ''), delivery) where id = $3", commandSettingsTable),
+			setting.Value, setting.Delivery, storedSetting.ID); err != nil {
 			log.Debug().Err(err).Msg("Failed to update setting.")
 			return &kerr.QueryError{
 				Err:   err,
@@ -845,3 +1052,91 @@ func (s *CommandStore) IsPlatformSupported(ctx context.Context, commandID, platf
 	}
 	return result == 1, nil
 }
+
+// AddCommandDependency defines that a command depends on another command.
+func (s *CommandStore) AddCommandDependency(ctx context.Context, commandID int, dependsOnID int) error {
+	log := s.Logger.With().Str("func", "AddCommandDependency").Int("command_id", commandID).Int("depends_on_id", dependsOnID).Logger()
+	f := func(tx pgx.Tx) error {
+		if tags, err := tx.Exec(ctx, fmt.Sprintf("insert into %s(command_id, depends_on_id) values($1, $2)", commandsDependenciesRelTable),
+			commandID, dependsOnID); err != nil {
+			log.Debug().Err(err).Msg("Failed to create dependency between commands.")
+			return &kerr.QueryError{
+				Err:   err,
+				Query: "insert into " + commandsDependenciesRelTable,
+			}
+		} else if tags.RowsAffected() == 0 {
+			return &kerr.QueryError{
+				Err:   kerr.ErrNoRowsAffected,
+				Query: "insert into " + commandsDependenciesRelTable,
+			}
+		}
+		return nil
+	}
+
+	if err := s.Connector.ExecuteWithTransaction(ctx, log, f); err != nil {
+		log.Debug().Err(err).Msg("Failed to insert into " + commandsDependenciesRelTable)
+		return err
+	}
+	return nil
+}
+
+// RemoveCommandDependency removes a dependency of a command.
+func (s *CommandStore) RemoveCommandDependency(ctx context.Context, commandID int, dependsOnID int) error {
+	log := s.Logger.With().Str("func", "RemoveCommandDependency").Int("command_id", commandID).Int("depends_on_id", dependsOnID).Logger()
+	f := func(tx pgx.Tx) error {
+		if tags, err := tx.Exec(ctx, fmt.Sprintf("delete from %s where command_id = $1 and depends_on_id = $2", commandsDependenciesRelTable),
+			commandID, dependsOnID); err != nil {
+			log.Debug().Err(err).Msg("Failed to remove dependency between commands.")
+			return &kerr.QueryError{
+				Err:   err,
+				Query: "delete from " + commandsDependenciesRelTable,
+			}
+		} else if tags.RowsAffected() == 0 {
+			return &kerr.QueryError{
+				Err:   kerr.ErrNoRowsAffected,
+				Query: "delete from " + commandsDependenciesRelTable,
+			}
+		}
+		return nil
+	}
+
+	if err := s.Connector.ExecuteWithTransaction(ctx, log, f); err != nil {
+		log.Debug().Err(err).Msg("Failed to delete from " + commandsDependenciesRelTable)
+		return err
+	}
+	return nil
+}
+
+// GetCommandDependencies returns the IDs of the commands the given command depends on.
+func (s *CommandStore) GetCommandDependencies(ctx context.Context, commandID int) ([]int, error) {
+	log := s.Logger.With().Int("command_id", commandID).Logger()
+	var result []int
+	f := func(tx pgx.Tx) error {
+		query := fmt.Sprintf("select depends_on_id from %s where command_id = $1 order by depends_on_id", commandsDependenciesRelTable)
+		rows, err := tx.Query(ctx, query, commandID)
+		if err != nil {
+			log.Debug().Err(err).Msg("Failed to query dependencies.")
+			return &kerr.QueryError{
+				Query: query,
+				Err:   fmt.Errorf("failed to query rel table: %w", err),
+			}
+		}
+
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				log.Debug().Err(err).Msg("Failed to scan.")
+				return &kerr.QueryError{
+					Query: query,
+					Err:   fmt.Errorf("failed to scan: %w", err),
+				}
+			}
+			result = append(result, id)
+		}
+		return nil
+	}
+	if err := s.Connector.ExecuteWithTransaction(ctx, log, f); err != nil {
+		return nil, fmt.Errorf("failed to execute GetCommandDependencies: %w", err)
+	}
+	return result, nil
+}
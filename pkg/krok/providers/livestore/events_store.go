@@ -41,14 +41,17 @@ func (e *EventsStore) Create(ctx context.Context, event *models.Event) (*models.
 	log := e.Logger.With().Str("event_id", event.EventID).Int("repository_id", event.RepositoryID).Logger()
 	var returnID int
 	f := func(tx pgx.Tx) error {
-		query := fmt.Sprintf("insert into %s(event_id, created_at, repository_id, payload, vcs, event_type) values($1, $2, $3, $4, $5, $6) returning id", eventsStoreTable)
+		query := fmt.Sprintf("insert into %s(event_id, created_at, repository_id, payload, vcs, event_type, rerun_of, source, triggered_by) values($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id", eventsStoreTable)
 		row := tx.QueryRow(ctx, query,
 			event.EventID,
 			event.CreateAt,
 			event.RepositoryID,
 			event.Payload,
 			event.VCS,
-			event.EventType)
+			event.EventType,
+			event.RerunOf,
+			event.Source,
+			event.TriggeredBy)
 		if err := row.Scan(&returnID); err != nil {
 			log.Debug().Err(err).Str("query", query).Msg("Failed to scan row.")
 			return &kerr.QueryError{
@@ -82,7 +85,7 @@ func (e *EventsStore) ListEventsForRepository(ctx context.Context, repoID int, o
 	// Select all commands.
 	result := make([]*models.Event, 0)
 	f := func(tx pgx.Tx) error {
-		sql := fmt.Sprintf("select id, event_id, repository_id, created_at, vcs, event_type from %s where repository_id = $1", eventsStoreTable)
+		sql := fmt.Sprintf("select id, event_id, repository_id, created_at, vcs, event_type, rerun_of, source, triggered_by from %s where repository_id = $1", eventsStoreTable)
 		args := []interface{}{
 			repoID,
 		}
@@ -116,8 +119,11 @@ func (e *EventsStore) ListEventsForRepository(ctx context.Context, repoID int, o
 				storedCreatedAt    time.Time
 				storedVCS          int
 				storedEventType    string
+				storedRerunOf      int
+				storedSource       string
+				storedTriggeredBy  int
 			)
-			if err := rows.Scan(&storedID, &storedEventID, &storedRepositoryID, &storedCreatedAt, &storedVCS, &storedEventType); err != nil {
+			if err := rows.Scan(&storedID, &storedEventID, &storedRepositoryID, &storedCreatedAt, &storedVCS, &storedEventType, &storedRerunOf, &storedSource, &storedTriggeredBy); err != nil {
 				log.Debug().Err(err).Msg("Failed to scan.")
 				return &kerr.QueryError{
 					Query: sql,
@@ -132,6 +138,9 @@ func (e *EventsStore) ListEventsForRepository(ctx context.Context, repoID int, o
 				RepositoryID: storedRepositoryID,
 				VCS:          storedVCS,
 				EventType:    storedEventType,
+				RerunOf:      storedRerunOf,
+				Source:       storedSource,
+				TriggeredBy:  storedTriggeredBy,
 			}
 			result = append(result, event)
 		}
@@ -152,11 +161,11 @@ func (e *EventsStore) GetEvent(ctx context.Context, id int) (*models.Event, erro
 	result := &models.Event{}
 	f := func(tx pgx.Tx) error {
 		var (
-			storedID, repoID, vcs       int
-			eventID, payload, eventType string
-			createdAt                   time.Time
+			storedID, repoID, vcs, rerunOf, triggeredBy int
+			eventID, payload, eventType, source         string
+			createdAt                                   time.Time
 		)
-		if err := tx.QueryRow(ctx, fmt.Sprintf("select id, event_id, created_at, repository_id, payload, vcs, event_type from %s where id=$1", eventsStoreTable), id).Scan(&storedID, &eventID, &createdAt, &repoID, &payload, &vcs, &eventType); err != nil {
+		if err := tx.QueryRow(ctx, fmt.Sprintf("select id, event_id, created_at, repository_id, payload, vcs, event_type, rerun_of, source, triggered_by from %s where id=$1", eventsStoreTable), id).Scan(&storedID, &eventID, &createdAt, &repoID, &payload, &vcs, &eventType, &rerunOf, &source, &triggeredBy); err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				return &kerr.QueryError{
 					Query: "select id in events",
@@ -175,6 +184,9 @@ func (e *EventsStore) GetEvent(ctx context.Context, id int) (*models.Event, erro
 		result.Payload = payload
 		result.VCS = vcs
 		result.EventType = eventType
+		result.RerunOf = rerunOf
+		result.Source = source
+		result.TriggeredBy = triggeredBy
 		return nil
 	}
 	if err := e.Connector.ExecuteWithTransaction(ctx, log, f); err != nil {
@@ -197,7 +209,7 @@ func (e *EventsStore) getCommandRunsForEvent(ctx context.Context, id int) ([]*mo
 	// Select the related commands.
 	result := make([]*models.CommandRun, 0)
 	f := func(tx pgx.Tx) error {
-		rows, err := tx.Query(ctx, fmt.Sprintf("select id, command_name, event_id, status, outcome, created_at from %s where event_id = $1", commandRunTable), id)
+		rows, err := tx.Query(ctx, fmt.Sprintf("select id, command_name, command_id, attempt, container_id, event_id, status, outcome, signal, created_at from %s where event_id = $1", commandRunTable), id)
 		if err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				return &kerr.QueryError{
@@ -216,12 +228,16 @@ func (e *EventsStore) getCommandRunsForEvent(ctx context.Context, id int) ([]*mo
 			var (
 				storedID          int
 				storedCommandName string
+				storedCommandID   int
+				storedAttempt     int
+				storedContainerID string
 				storedEventID     int
 				storedStatus      string
 				storedOutcome     string
+				storedSignal      string
 				storedCreatedAt   time.Time
 			)
-			if err := rows.Scan(&storedID, &storedCommandName, &storedEventID, &storedStatus, &storedOutcome, &storedCreatedAt); err != nil {
+			if err := rows.Scan(&storedID, &storedCommandName, &storedCommandID, &storedAttempt, &storedContainerID, &storedEventID, &storedStatus, &storedOutcome, &storedSignal, &storedCreatedAt); err != nil {
 				log.Debug().Err(err).Msg("Failed to scan.")
 				return &kerr.QueryError{
 					Query: "select id from command_runs",
@@ -232,8 +248,12 @@ func (e *EventsStore) getCommandRunsForEvent(ctx context.Context, id int) ([]*mo
 				ID:          storedID,
 				EventID:     storedEventID,
 				CommandName: storedCommandName,
+				CommandID:   storedCommandID,
+				Attempt:     storedAttempt,
+				ContainerID: storedContainerID,
 				Status:      storedStatus,
 				Outcome:     storedOutcome,
+				Signal:      storedSignal,
 				CreateAt:    storedCreatedAt,
 			}
 			result = append(result, command)
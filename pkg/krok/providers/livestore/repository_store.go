@@ -2,6 +2,7 @@ package livestore
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
@@ -44,12 +45,20 @@ func (r *RepositoryStore) Create(ctx context.Context, c *models.Repository) (*mo
 	// duplicate key value violates unique constraint
 	// id will be generated.
 
+	generic, err := marshalGeneric(c.Generic)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to marshal generic settings.")
+		return nil, err
+	}
+	events := append([]string{}, c.Events...)
 	f := func(tx pgx.Tx) error {
-		if tags, err := tx.Exec(ctx, fmt.Sprintf("insert into %s(name, url, vcs, project_id) values($1, $2, $3, $4)", repositoriesTable),
+		if tags, err := tx.Exec(ctx, fmt.Sprintf("insert into %s(name, url, vcs, project_id, generic_settings, events) values($1, $2, $3, $4, $5, $6)", repositoriesTable),
 			c.Name,
 			c.URL,
 			c.VCS,
-			c.GitLab.GetProjectID()); err != nil {
+			c.GitLab.GetProjectID(),
+			generic,
+			events); err != nil {
 			log.Debug().Err(err).Msg("Failed to create repository.")
 			return &kerr.QueryError{
 				Err:   err,
@@ -74,7 +83,6 @@ func (r *RepositoryStore) Create(ctx context.Context, c *models.Repository) (*mo
 		log.Debug().Err(err).Msg("Failed to get created repository.")
 		return nil, err
 	}
-	result.Events = c.Events
 	return result, nil
 }
 
@@ -103,15 +111,21 @@ func (r *RepositoryStore) Delete(ctx context.Context, id int) error {
 	return r.Connector.ExecuteWithTransaction(ctx, log, f)
 }
 
-// Update can only update the name of the repository. If auth information is updated for the repository,
-// it has to be re-created. Since auth is stored elsewhere.
+// Update can only update the name, the generic settings and the events of the repository. Generic settings
+// and events are kept if they aren't provided. If auth information is updated for the repository, it has to
+// be re-created. Since auth is stored elsewhere.
 func (r *RepositoryStore) Update(ctx context.Context, c *models.Repository) (*models.Repository, error) {
 	log := r.Logger.With().Int("id", c.ID).Str("name", c.Name).Logger()
+	generic, err := marshalGeneric(c.Generic)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to marshal generic settings.")
+		return nil, err
+	}
 	f := func(tx pgx.Tx) error {
 		// Prevent updating the ID and the creation timestamp.
 		// construct update statement:
-		tags, err := tx.Exec(ctx, fmt.Sprintf("update %s set name = $1 where id = $2", repositoriesTable),
-			c.Name, c.ID)
+		tags, err := tx.Exec(ctx, fmt.Sprintf("update %s set name = $1, generic_settings = coalesce($2, generic_settings), events = coalesce($3, events) where id = $4", repositoriesTable),
+			c.Name, generic, c.Events, c.ID)
 		if errors.Is(err, pgx.ErrNoRows) {
 			return &kerr.QueryError{
 				Query: "select id",
@@ -146,6 +160,29 @@ func (r *RepositoryStore) Update(ctx context.Context, c *models.Repository) (*mo
 	return result, nil
 }
 
+// UpdateHookID saves the ID of the hook which was created for the repository on its platform.
+func (r *RepositoryStore) UpdateHookID(ctx context.Context, id int, hookID string) error {
+	log := r.Logger.With().Int("id", id).Str("hook_id", hookID).Logger()
+	f := func(tx pgx.Tx) error {
+		tags, err := tx.Exec(ctx, fmt.Sprintf("update %s set hook_id = $1 where id = $2", repositoriesTable), hookID, id)
+		if err != nil {
+			log.Debug().Err(err).Msg("Failed to update hook id.")
+			return &kerr.QueryError{
+				Query: "update hook_id",
+				Err:   fmt.Errorf("failed to update hook id: %w", err),
+			}
+		}
+		if tags.RowsAffected() == 0 {
+			return &kerr.QueryError{
+				Query: "update hook_id",
+				Err:   kerr.ErrNotFound,
+			}
+		}
+		return nil
+	}
+	return r.Connector.ExecuteWithTransaction(ctx, log, f)
+}
+
 // List all repositories or the ones specified by the filter opts.
 // We are ignoring auth information here.
 func (r *RepositoryStore) List(ctx context.Context, opts *models.ListOptions) ([]*models.Repository, error) {
@@ -153,7 +190,7 @@ func (r *RepositoryStore) List(ctx context.Context, opts *models.ListOptions) ([
 	// Select all repositories.
 	result := make([]*models.Repository, 0)
 	f := func(tx pgx.Tx) error {
-		sql := fmt.Sprintf("select id, name, url, vcs, project_id from %s", repositoriesTable)
+		sql := fmt.Sprintf("select id, name, url, vcs, project_id, generic_settings, hook_id, events from %s", repositoriesTable)
 		where := " where "
 		filters := make([]string, 0)
 		if opts.Name != "" {
@@ -188,8 +225,11 @@ func (r *RepositoryStore) List(ctx context.Context, opts *models.ListOptions) ([
 				url       string
 				vcs       int
 				projectID int // this field needs to be a pointer because it can be nil which will result in a nil value.
+				generic   []byte
+				hookID    *string
+				events    []string
 			)
-			if err := rows.Scan(&id, &name, &url, &vcs, &projectID); err != nil {
+			if err := rows.Scan(&id, &name, &url, &vcs, &projectID, &generic, &hookID, &events); err != nil {
 				log.Debug().Err(err).Msg("Failed to scan.")
 				return &kerr.QueryError{
 					Query: "select all repositories",
@@ -204,6 +244,17 @@ func (r *RepositoryStore) List(ctx context.Context, opts *models.ListOptions) ([
 				GitLab: &models.GitLab{
 					ProjectID: projectID,
 				},
+				Events: events,
+			}
+			if hookID != nil {
+				repository.HookID = *hookID
+			}
+			if repository.Generic, err = unmarshalGeneric(generic); err != nil {
+				log.Debug().Err(err).Msg("Failed to unmarshal generic settings.")
+				return &kerr.QueryError{
+					Query: "select all repositories",
+					Err:   fmt.Errorf("failed to unmarshal generic settings: %w", err),
+				}
 			}
 			result = append(result, repository)
 		}
@@ -238,8 +289,11 @@ func (r *RepositoryStore) getByX(ctx context.Context, log zerolog.Logger, field
 			id, vcs   int
 			name, url string
 			projectID int
+			generic   []byte
+			hookID    *string
+			events    []string
 		)
-		if err := tx.QueryRow(ctx, fmt.Sprintf("select id, name, url, vcs, project_id from %s where %s=$1", repositoriesTable, field), value).Scan(&id, &name, &url, &vcs, &projectID); err != nil {
+		if err := tx.QueryRow(ctx, fmt.Sprintf("select id, name, url, vcs, project_id, generic_settings, hook_id, events from %s where %s=$1", repositoriesTable, field), value).Scan(&id, &name, &url, &vcs, &projectID, &generic, &hookID, &events); err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				return &kerr.QueryError{
 					Query: "select id",
@@ -256,6 +310,18 @@ func (r *RepositoryStore) getByX(ctx context.Context, log zerolog.Logger, field
 		result.URL = url
 		result.VCS = vcs
 		result.GitLab = &models.GitLab{ProjectID: projectID}
+		result.Events = events
+		if hookID != nil {
+			result.HookID = *hookID
+		}
+		settings, err := unmarshalGeneric(generic)
+		if err != nil {
+			return &kerr.QueryError{
+				Query: "select id",
+				Err:   fmt.Errorf("failed to unmarshal generic settings: %w", err),
+			}
+		}
+		result.Generic = settings
 		return nil
 	}
 	if err := r.Connector.ExecuteWithTransaction(ctx, log, f); err != nil {
@@ -279,7 +345,7 @@ func (r *RepositoryStore) getCommandsForRepository(ctx context.Context, id int)
 	// Select the related commands.
 	result := make([]*models.Command, 0)
 	f := func(tx pgx.Tx) error {
-		rows, err := tx.Query(ctx, fmt.Sprintf("select c.id, name, schedule, enabled, image from %s as c inner join %s as relc"+
+		rows, err := tx.Query(ctx, fmt.Sprintf("select c.id, name, schedule, enabled, image, requires_clone, timeout, cpus, memory_limit, pids_limit, disk_limit, stop_signal, stop_grace_period, retry_max_attempts, retry_backoff, retry_on, runner_labels, relc.event_types, relc.branch_filter from %s as c inner join %s as relc"+
 			" on c.id = relc.command_id where relc.repository_id = $1", commandsTable, commandsRepositoriesRelTable), id)
 		if err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
@@ -297,13 +363,21 @@ func (r *RepositoryStore) getCommandsForRepository(ctx context.Context, id int)
 
 		for rows.Next() {
 			var (
-				storedID int
-				name     string
-				schedule string
-				enabled  bool
-				image    string
+				storedID      int
+				name          string
+				schedule      string
+				enabled       bool
+				image         string
+				requiresClone bool
+				limits        models.Command
+				retry         retryColumns
+				eventTypes    []string
+				branchFilter  string
 			)
-			if err := rows.Scan(&storedID, &name, &schedule, &enabled, &image); err != nil {
+			if err := rows.Scan(&storedID, &name, &schedule, &enabled, &image, &requiresClone,
+				&limits.Timeout, &limits.CPUs, &limits.MemoryLimit, &limits.PidsLimit, &limits.DiskLimit,
+				&limits.StopSignal, &limits.StopGracePeriod,
+				&retry.maxAttempts, &retry.backoff, &retry.retryOn, &limits.RunnerLabels, &eventTypes, &branchFilter); err != nil {
 				log.Debug().Err(err).Msg("Failed to scan.")
 				return &kerr.QueryError{
 					Query: "select id",
@@ -311,11 +385,27 @@ func (r *RepositoryStore) getCommandsForRepository(ctx context.Context, id int)
 				}
 			}
 			command := &models.Command{
-				Name:     name,
-				ID:       storedID,
-				Schedule: schedule,
-				Enabled:  enabled,
-				Image:    image,
+				Name:            name,
+				ID:              storedID,
+				Schedule:        schedule,
+				Enabled:         enabled,
+				Image:           image,
+				RequiresClone:   requiresClone,
+				Timeout:         limits.Timeout,
+				CPUs:            limits.CPUs,
+				MemoryLimit:     limits.MemoryLimit,
+				PidsLimit:       limits.PidsLimit,
+				DiskLimit:       limits.DiskLimit,
+				StopSignal:      limits.StopSignal,
+				StopGracePeriod: limits.StopGracePeriod,
+				Retry:           retry.policy(),
+				RunnerLabels:    limits.RunnerLabels,
+			}
+			if len(eventTypes) > 0 || branchFilter != "" {
+				command.Filter = &models.CommandFilter{
+					EventTypes: eventTypes,
+					Branch:     branchFilter,
+				}
 			}
 			result = append(result, command)
 		}
@@ -326,3 +416,23 @@ func (r *RepositoryStore) getCommandsForRepository(ctx context.Context, id int)
 	}
 	return result, nil
 }
+
+// marshalGeneric returns the generic settings as json or nil if they are not set.
+func marshalGeneric(g *models.Generic) ([]byte, error) {
+	if g == nil {
+		return nil, nil
+	}
+	return json.Marshal(g)
+}
+
+// unmarshalGeneric returns the generic settings stored as json or nil if they are not set.
+func unmarshalGeneric(data []byte) (*models.Generic, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	g := &models.Generic{}
+	if err := json.Unmarshal(data, g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
@@ -17,6 +17,7 @@ const (
 	timeoutForTransactions       = 1 * time.Minute
 	commandsRepositoriesRelTable = "rel_commands_repositories"
 	commandsPlatformsRelTable    = "rel_commands_platforms"
+	commandsDependenciesRelTable = "rel_commands_dependencies"
 	databaseType                 = "postgres"
 )
 
@@ -0,0 +1,15 @@
+package providers
+
+import "io"
+
+// LogStreamer keeps the logs of running commands and streams them to subscribers.
+type LogStreamer interface {
+	// Open starts a new log stream for a command run. Everything written to the
+	// returned writer is buffered and sent to all subscribers. Closing the writer
+	// ends the stream and lets go of the buffered logs.
+	Open(commandRunID int) io.WriteCloser
+	// Subscribe returns the logs which have been written so far and a channel which receives
+	// new log chunks until the stream ends. The returned function must be called to unsubscribe.
+	// Returns errors.ErrNotFound if there is no log stream for the command run.
+	Subscribe(commandRunID int) ([]byte, <-chan []byte, func(), error)
+}
@@ -0,0 +1,162 @@
+package logstream
+
+import (
+	"io"
+	"sync"
+
+	"github.com/rs/zerolog"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers"
+)
+
+// subscriberBuffer is the number of log chunks a subscriber can fall behind.
+const subscriberBuffer = 256
+
+// Config defines configuration for the broker.
+type Config struct {
+	// BacklogSize is the maximum number of bytes kept per command run for late subscribers.
+	// Older logs are dropped once the limit is reached.
+	BacklogSize int
+}
+
+// Dependencies defines the dependencies of the broker.
+type Dependencies struct {
+	Logger zerolog.Logger
+}
+
+// stream is the log of a single command run.
+type stream struct {
+	lock        sync.Mutex
+	backlog     []byte
+	subscribers map[int]chan []byte
+	next        int
+}
+
+// Broker keeps the logs of running commands in memory and fans them out to subscribers.
+type Broker struct {
+	Config
+	Dependencies
+
+	lock    sync.Mutex
+	streams map[int]*stream
+}
+
+var _ providers.LogStreamer = &Broker{}
+
+// NewBroker creates a new log broker.
+func NewBroker(cfg Config, deps Dependencies) *Broker {
+	return &Broker{
+		Config:       cfg,
+		Dependencies: deps,
+		streams:      make(map[int]*stream),
+	}
+}
+
+// Open starts a new log stream for a command run.
+func (b *Broker) Open(commandRunID int) io.WriteCloser {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	s, ok := b.streams[commandRunID]
+	if !ok {
+		s = &stream{
+			subscribers: make(map[int]chan []byte),
+		}
+		b.streams[commandRunID] = s
+	}
+	return &writer{
+		broker:       b,
+		stream:       s,
+		commandRunID: commandRunID,
+	}
+}
+
+// Subscribe returns the backlog of a command run and a channel for the live logs.
+func (b *Broker) Subscribe(commandRunID int) ([]byte, <-chan []byte, func(), error) {
+	b.lock.Lock()
+	s, ok := b.streams[commandRunID]
+	b.lock.Unlock()
+	if !ok {
+		return nil, nil, nil, kerr.ErrNotFound
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	backlog := make([]byte, len(s.backlog))
+	copy(backlog, s.backlog)
+	ch := make(chan []byte, subscriberBuffer)
+	id := s.next
+	s.next++
+	s.subscribers[id] = ch
+	unsubscribe := func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if c, ok := s.subscribers[id]; ok {
+			delete(s.subscribers, id)
+			close(c)
+		}
+	}
+	return backlog, ch, unsubscribe, nil
+}
+
+// write appends to the backlog and sends the chunk to all subscribers.
+// Subscribers which can't keep up are dropped instead of blocking the command.
+func (b *Broker) write(commandRunID int, s *stream, p []byte) {
+	chunk := make([]byte, len(p))
+	copy(chunk, p)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.backlog = append(s.backlog, chunk...)
+	if b.BacklogSize > 0 && len(s.backlog) > b.BacklogSize {
+		s.backlog = s.backlog[len(s.backlog)-b.BacklogSize:]
+	}
+	for id, ch := range s.subscribers {
+		select {
+		case ch <- chunk:
+		default:
+			b.Logger.Debug().Int("command_run_id", commandRunID).Msg("Dropping slow log subscriber.")
+			delete(s.subscribers, id)
+			close(ch)
+		}
+	}
+}
+
+// close ends the stream of a command run and closes all subscriber channels.
+func (b *Broker) close(commandRunID int, s *stream) {
+	b.lock.Lock()
+	if b.streams[commandRunID] == s {
+		delete(b.streams, commandRunID)
+	}
+	b.lock.Unlock()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for id, ch := range s.subscribers {
+		delete(s.subscribers, id)
+		close(ch)
+	}
+	s.backlog = nil
+}
+
+// writer writes into the log stream of a single command run.
+type writer struct {
+	broker       *Broker
+	stream       *stream
+	commandRunID int
+	once         sync.Once
+}
+
+// Write sends p to the stream.
+func (w *writer) Write(p []byte) (int, error) {
+	w.broker.write(w.commandRunID, w.stream, p)
+	return len(p), nil
+}
+
+// Close ends the stream.
+func (w *writer) Close() error {
+	w.once.Do(func() {
+		w.broker.close(w.commandRunID, w.stream)
+	})
+	return nil
+}
@@ -0,0 +1,69 @@
+package logstream
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	kerr "github.com/krok-o/krok/errors"
+)
+
+func TestBroker_Flow(t *testing.T) {
+	b := NewBroker(Config{BacklogSize: 1024}, Dependencies{Logger: zerolog.New(os.Stderr)})
+	_, _, _, err := b.Subscribe(1)
+	assert.True(t, errors.Is(err, kerr.ErrNotFound))
+
+	w := b.Open(1)
+	_, err = w.Write([]byte("line 1\n"))
+	require.NoError(t, err)
+
+	// late joiners get the backlog first
+	backlog, logs, unsubscribe, err := b.Subscribe(1)
+	require.NoError(t, err)
+	defer unsubscribe()
+	assert.Equal(t, "line 1\n", string(backlog))
+
+	_, err = w.Write([]byte("line 2\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "line 2\n", string(<-logs))
+
+	assert.NoError(t, w.Close())
+	_, ok := <-logs
+	assert.False(t, ok)
+	_, _, _, err = b.Subscribe(1)
+	assert.True(t, errors.Is(err, kerr.ErrNotFound))
+}
+
+func TestBroker_BacklogSize(t *testing.T) {
+	b := NewBroker(Config{BacklogSize: 4}, Dependencies{Logger: zerolog.New(os.Stderr)})
+	w := b.Open(1)
+	defer w.Close()
+	_, err := w.Write([]byte("abcdef"))
+	require.NoError(t, err)
+	backlog, _, unsubscribe, err := b.Subscribe(1)
+	require.NoError(t, err)
+	defer unsubscribe()
+	assert.Equal(t, "cdef", string(backlog))
+}
+
+func TestBroker_DropsSlowSubscriber(t *testing.T) {
+	b := NewBroker(Config{}, Dependencies{Logger: zerolog.New(os.Stderr)})
+	w := b.Open(1)
+	defer w.Close()
+	_, logs, unsubscribe, err := b.Subscribe(1)
+	require.NoError(t, err)
+	defer unsubscribe()
+	for i := 0; i < subscriberBuffer+1; i++ {
+		_, err := w.Write([]byte("x"))
+		require.NoError(t, err)
+	}
+	count := 0
+	for range logs {
+		count++
+	}
+	assert.Equal(t, subscriberBuffer, count)
+}
@@ -0,0 +1,83 @@
+// Code generated by mockery 2.9.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	io "io"
+
+	models "github.com/krok-o/krok/pkg/models"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ArtifactStorer is an autogenerated mock type for the ArtifactStorer type
+type ArtifactStorer struct {
+	mock.Mock
+}
+
+// List provides a mock function with given fields: ctx, eventID
+func (_m *ArtifactStorer) List(ctx context.Context, eventID int) ([]*models.Artifact, error) {
+	ret := _m.Called(ctx, eventID)
+
+	var r0 []*models.Artifact
+	if rf, ok := ret.Get(0).(func(context.Context, int) []*models.Artifact); ok {
+		r0 = rf(ctx, eventID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Artifact)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, eventID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Open provides a mock function with given fields: ctx, eventID, name
+func (_m *ArtifactStorer) Open(ctx context.Context, eventID int, name string) (io.ReadCloser, error) {
+	ret := _m.Called(ctx, eventID, name)
+
+	var r0 io.ReadCloser
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) io.ReadCloser); ok {
+		r0 = rf(ctx, eventID, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.ReadCloser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int, string) error); ok {
+		r1 = rf(ctx, eventID, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Workspace provides a mock function with given fields: ctx, eventID
+func (_m *ArtifactStorer) Workspace(ctx context.Context, eventID int) (string, error) {
+	ret := _m.Called(ctx, eventID)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, int) string); ok {
+		r0 = rf(ctx, eventID)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, eventID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
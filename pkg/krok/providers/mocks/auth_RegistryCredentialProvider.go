@@ -0,0 +1,66 @@
+// Code generated by mockery 2.9.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	models "github.com/krok-o/krok/pkg/models"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// RegistryCredentialProvider is an autogenerated mock type for the RegistryCredentialProvider type
+type RegistryCredentialProvider struct {
+	mock.Mock
+}
+
+// DeleteRegistryCredential provides a mock function with given fields: ctx, commandID, host
+func (_m *RegistryCredentialProvider) DeleteRegistryCredential(ctx context.Context, commandID int, host string) error {
+	ret := _m.Called(ctx, commandID, host)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) error); ok {
+		r0 = rf(ctx, commandID, host)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetRegistryCredential provides a mock function with given fields: ctx, commandID, host
+func (_m *RegistryCredentialProvider) GetRegistryCredential(ctx context.Context, commandID int, host string) (*models.RegistryCredential, error) {
+	ret := _m.Called(ctx, commandID, host)
+
+	var r0 *models.RegistryCredential
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) *models.RegistryCredential); ok {
+		r0 = rf(ctx, commandID, host)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.RegistryCredential)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int, string) error); ok {
+		r1 = rf(ctx, commandID, host)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveRegistryCredential provides a mock function with given fields: ctx, credential
+func (_m *RegistryCredentialProvider) SaveRegistryCredential(ctx context.Context, credential *models.RegistryCredential) error {
+	ret := _m.Called(ctx, credential)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.RegistryCredential) error); ok {
+		r0 = rf(ctx, credential)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -60,6 +60,64 @@ func (_m *CommandRunStorer) Get(ctx context.Context, id int) (*models.CommandRun
 	return r0, r1
 }
 
+// ListRunsWithStatus provides a mock function with given fields: ctx, statuses
+func (_m *CommandRunStorer) ListRunsWithStatus(ctx context.Context, statuses ...string) ([]*models.CommandRun, error) {
+	_va := make([]interface{}, len(statuses))
+	for _i := range statuses {
+		_va[_i] = statuses[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 []*models.CommandRun
+	if rf, ok := ret.Get(0).(func(context.Context, ...string) []*models.CommandRun); ok {
+		r0 = rf(ctx, statuses...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.CommandRun)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, ...string) error); ok {
+		r1 = rf(ctx, statuses...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateRunContainer provides a mock function with given fields: ctx, id, containerID
+func (_m *CommandRunStorer) UpdateRunContainer(ctx context.Context, id int, containerID string) error {
+	ret := _m.Called(ctx, id, containerID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) error); ok {
+		r0 = rf(ctx, id, containerID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateRunSignal provides a mock function with given fields: ctx, id, signal
+func (_m *CommandRunStorer) UpdateRunSignal(ctx context.Context, id int, signal string) error {
+	ret := _m.Called(ctx, id, signal)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) error); ok {
+		r0 = rf(ctx, id, signal)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateRunStatus provides a mock function with given fields: ctx, id, status, outcome
 func (_m *CommandRunStorer) UpdateRunStatus(ctx context.Context, id int, status string, outcome string) error {
 	ret := _m.Called(ctx, id, status, outcome)
@@ -14,6 +14,20 @@ type CommandStorer struct {
 	mock.Mock
 }
 
+// AddCommandDependency provides a mock function with given fields: ctx, commandID, dependsOnID
+func (_m *CommandStorer) AddCommandDependency(ctx context.Context, commandID int, dependsOnID int) error {
+	ret := _m.Called(ctx, commandID, dependsOnID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) error); ok {
+		r0 = rf(ctx, commandID, dependsOnID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // AddCommandRelForPlatform provides a mock function with given fields: ctx, commandID, platformID
 func (_m *CommandStorer) AddCommandRelForPlatform(ctx context.Context, commandID int, platformID int) error {
 	ret := _m.Called(ctx, commandID, platformID)
@@ -162,6 +176,29 @@ func (_m *CommandStorer) GetByName(ctx context.Context, name string) (*models.Co
 	return r0, r1
 }
 
+// GetCommandDependencies provides a mock function with given fields: ctx, commandID
+func (_m *CommandStorer) GetCommandDependencies(ctx context.Context, commandID int) ([]int, error) {
+	ret := _m.Called(ctx, commandID)
+
+	var r0 []int
+	if rf, ok := ret.Get(0).(func(context.Context, int) []int); ok {
+		r0 = rf(ctx, commandID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]int)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, commandID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetSetting provides a mock function with given fields: ctx, id
 func (_m *CommandStorer) GetSetting(ctx context.Context, id int) (*models.CommandSetting, error) {
 	ret := _m.Called(ctx, id)
@@ -252,6 +289,20 @@ func (_m *CommandStorer) ListSettings(ctx context.Context, commandID int) ([]*mo
 	return r0, r1
 }
 
+// RemoveCommandDependency provides a mock function with given fields: ctx, commandID, dependsOnID
+func (_m *CommandStorer) RemoveCommandDependency(ctx context.Context, commandID int, dependsOnID int) error {
+	ret := _m.Called(ctx, commandID, dependsOnID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) error); ok {
+		r0 = rf(ctx, commandID, dependsOnID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // RemoveCommandRelForPlatform provides a mock function with given fields: ctx, commandID, platformID
 func (_m *CommandStorer) RemoveCommandRelForPlatform(ctx context.Context, commandID int, platformID int) error {
 	ret := _m.Called(ctx, commandID, platformID)
@@ -303,6 +354,20 @@ func (_m *CommandStorer) Update(ctx context.Context, c *models.Command) (*models
 	return r0, r1
 }
 
+// UpdateCommandRelForRepository provides a mock function with given fields: ctx, commandID, repositoryID, filter
+func (_m *CommandStorer) UpdateCommandRelForRepository(ctx context.Context, commandID int, repositoryID int, filter *models.CommandFilter) error {
+	ret := _m.Called(ctx, commandID, repositoryID, filter)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, *models.CommandFilter) error); ok {
+		r0 = rf(ctx, commandID, repositoryID, filter)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateSetting provides a mock function with given fields: ctx, setting
 func (_m *CommandStorer) UpdateSetting(ctx context.Context, setting *models.CommandSetting) error {
 	ret := _m.Called(ctx, setting)
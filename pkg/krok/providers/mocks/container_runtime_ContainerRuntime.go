@@ -0,0 +1,180 @@
+// Code generated by mockery 2.9.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	io "io"
+
+	providers "github.com/krok-o/krok/pkg/krok/providers"
+	models "github.com/krok-o/krok/pkg/models"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ContainerRuntime is an autogenerated mock type for the ContainerRuntime type
+type ContainerRuntime struct {
+	mock.Mock
+}
+
+// Create provides a mock function with given fields: ctx, cfg
+func (_m *ContainerRuntime) Create(ctx context.Context, cfg providers.ContainerConfig) (string, error) {
+	ret := _m.Called(ctx, cfg)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, providers.ContainerConfig) string); ok {
+		r0 = rf(ctx, cfg)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, providers.ContainerConfig) error); ok {
+		r1 = rf(ctx, cfg)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HasImage provides a mock function with given fields: ctx, image
+func (_m *ContainerRuntime) HasImage(ctx context.Context, image string) (bool, error) {
+	ret := _m.Called(ctx, image)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, image)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, image)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Kill provides a mock function with given fields: ctx, id, signal
+func (_m *ContainerRuntime) Kill(ctx context.Context, id string, signal string) error {
+	ret := _m.Called(ctx, id, signal)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, signal)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Logs provides a mock function with given fields: ctx, id, follow
+func (_m *ContainerRuntime) Logs(ctx context.Context, id string, follow bool) (io.ReadCloser, error) {
+	ret := _m.Called(ctx, id, follow)
+
+	var r0 io.ReadCloser
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool) io.ReadCloser); ok {
+		r0 = rf(ctx, id, follow)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.ReadCloser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, bool) error); ok {
+		r1 = rf(ctx, id, follow)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Pull provides a mock function with given fields: ctx, image, credential
+func (_m *ContainerRuntime) Pull(ctx context.Context, image string, credential *models.RegistryCredential) error {
+	ret := _m.Called(ctx, image, credential)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *models.RegistryCredential) error); ok {
+		r0 = rf(ctx, image, credential)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Remove provides a mock function with given fields: ctx, id
+func (_m *ContainerRuntime) Remove(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Start provides a mock function with given fields: ctx, id
+func (_m *ContainerRuntime) Start(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// State provides a mock function with given fields: ctx, id
+func (_m *ContainerRuntime) State(ctx context.Context, id string) (string, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Wait provides a mock function with given fields: ctx, id
+func (_m *ContainerRuntime) Wait(ctx context.Context, id string) (int, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -14,6 +14,20 @@ type Executor struct {
 	mock.Mock
 }
 
+// CancelCommandRun provides a mock function with given fields: ctx, id
+func (_m *Executor) CancelCommandRun(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CancelRun provides a mock function with given fields: ctx, id
 func (_m *Executor) CancelRun(ctx context.Context, id int) error {
 	ret := _m.Called(ctx, id)
@@ -41,3 +55,26 @@ func (_m *Executor) CreateRun(ctx context.Context, event *models.Event, commands
 
 	return r0
 }
+
+// Queue provides a mock function with given fields: ctx
+func (_m *Executor) Queue(ctx context.Context) ([]*models.QueuedRun, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []*models.QueuedRun
+	if rf, ok := ret.Get(0).(func(context.Context) []*models.QueuedRun); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.QueuedRun)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -12,6 +12,22 @@ type CommandHandler struct {
 	mock.Mock
 }
 
+// AddCommandDependency provides a mock function with given fields:
+func (_m *CommandHandler) AddCommandDependency() echo.HandlerFunc {
+	ret := _m.Called()
+
+	var r0 echo.HandlerFunc
+	if rf, ok := ret.Get(0).(func() echo.HandlerFunc); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(echo.HandlerFunc)
+		}
+	}
+
+	return r0
+}
+
 // AddCommandRelForPlatform provides a mock function with given fields:
 func (_m *CommandHandler) AddCommandRelForPlatform() echo.HandlerFunc {
 	ret := _m.Called()
@@ -108,6 +124,22 @@ func (_m *CommandHandler) List() echo.HandlerFunc {
 	return r0
 }
 
+// RemoveCommandDependency provides a mock function with given fields:
+func (_m *CommandHandler) RemoveCommandDependency() echo.HandlerFunc {
+	ret := _m.Called()
+
+	var r0 echo.HandlerFunc
+	if rf, ok := ret.Get(0).(func() echo.HandlerFunc); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(echo.HandlerFunc)
+		}
+	}
+
+	return r0
+}
+
 // RemoveCommandRelForPlatform provides a mock function with given fields:
 func (_m *CommandHandler) RemoveCommandRelForPlatform() echo.HandlerFunc {
 	ret := _m.Called()
@@ -155,3 +187,19 @@ func (_m *CommandHandler) Update() echo.HandlerFunc {
 
 	return r0
 }
+
+// UpdateCommandRelForRepository provides a mock function with given fields:
+func (_m *CommandHandler) UpdateCommandRelForRepository() echo.HandlerFunc {
+	ret := _m.Called()
+
+	var r0 echo.HandlerFunc
+	if rf, ok := ret.Get(0).(func() echo.HandlerFunc); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(echo.HandlerFunc)
+		}
+	}
+
+	return r0
+}
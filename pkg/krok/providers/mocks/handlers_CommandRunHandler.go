@@ -12,6 +12,22 @@ type CommandRunHandler struct {
 	mock.Mock
 }
 
+// CancelCommandRun provides a mock function with given fields:
+func (_m *CommandRunHandler) CancelCommandRun() echo.HandlerFunc {
+	ret := _m.Called()
+
+	var r0 echo.HandlerFunc
+	if rf, ok := ret.Get(0).(func() echo.HandlerFunc); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(echo.HandlerFunc)
+		}
+	}
+
+	return r0
+}
+
 // GetCommandRun provides a mock function with given fields:
 func (_m *CommandRunHandler) GetCommandRun() echo.HandlerFunc {
 	ret := _m.Called()
@@ -27,3 +43,51 @@ func (_m *CommandRunHandler) GetCommandRun() echo.HandlerFunc {
 
 	return r0
 }
+
+// GetQueuedRun provides a mock function with given fields:
+func (_m *CommandRunHandler) GetQueuedRun() echo.HandlerFunc {
+	ret := _m.Called()
+
+	var r0 echo.HandlerFunc
+	if rf, ok := ret.Get(0).(func() echo.HandlerFunc); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(echo.HandlerFunc)
+		}
+	}
+
+	return r0
+}
+
+// ListQueue provides a mock function with given fields:
+func (_m *CommandRunHandler) ListQueue() echo.HandlerFunc {
+	ret := _m.Called()
+
+	var r0 echo.HandlerFunc
+	if rf, ok := ret.Get(0).(func() echo.HandlerFunc); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(echo.HandlerFunc)
+		}
+	}
+
+	return r0
+}
+
+// StreamCommandRunLogs provides a mock function with given fields:
+func (_m *CommandRunHandler) StreamCommandRunLogs() echo.HandlerFunc {
+	ret := _m.Called()
+
+	var r0 echo.HandlerFunc
+	if rf, ok := ret.Get(0).(func() echo.HandlerFunc); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(echo.HandlerFunc)
+		}
+	}
+
+	return r0
+}
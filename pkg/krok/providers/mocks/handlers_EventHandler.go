@@ -12,6 +12,22 @@ type EventHandler struct {
 	mock.Mock
 }
 
+// CancelRun provides a mock function with given fields:
+func (_m *EventHandler) CancelRun() echo.HandlerFunc {
+	ret := _m.Called()
+
+	var r0 echo.HandlerFunc
+	if rf, ok := ret.Get(0).(func() echo.HandlerFunc); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(echo.HandlerFunc)
+		}
+	}
+
+	return r0
+}
+
 // Get provides a mock function with given fields:
 func (_m *EventHandler) Get() echo.HandlerFunc {
 	ret := _m.Called()
@@ -28,6 +44,22 @@ func (_m *EventHandler) Get() echo.HandlerFunc {
 	return r0
 }
 
+// GetArtifact provides a mock function with given fields:
+func (_m *EventHandler) GetArtifact() echo.HandlerFunc {
+	ret := _m.Called()
+
+	var r0 echo.HandlerFunc
+	if rf, ok := ret.Get(0).(func() echo.HandlerFunc); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(echo.HandlerFunc)
+		}
+	}
+
+	return r0
+}
+
 // List provides a mock function with given fields:
 func (_m *EventHandler) List() echo.HandlerFunc {
 	ret := _m.Called()
@@ -43,3 +75,51 @@ func (_m *EventHandler) List() echo.HandlerFunc {
 
 	return r0
 }
+
+// ListArtifacts provides a mock function with given fields:
+func (_m *EventHandler) ListArtifacts() echo.HandlerFunc {
+	ret := _m.Called()
+
+	var r0 echo.HandlerFunc
+	if rf, ok := ret.Get(0).(func() echo.HandlerFunc); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(echo.HandlerFunc)
+		}
+	}
+
+	return r0
+}
+
+// Rerun provides a mock function with given fields:
+func (_m *EventHandler) Rerun() echo.HandlerFunc {
+	ret := _m.Called()
+
+	var r0 echo.HandlerFunc
+	if rf, ok := ret.Get(0).(func() echo.HandlerFunc); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(echo.HandlerFunc)
+		}
+	}
+
+	return r0
+}
+
+// Trigger provides a mock function with given fields:
+func (_m *EventHandler) Trigger() echo.HandlerFunc {
+	ret := _m.Called()
+
+	var r0 echo.HandlerFunc
+	if rf, ok := ret.Get(0).(func() echo.HandlerFunc); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(echo.HandlerFunc)
+		}
+	}
+
+	return r0
+}
@@ -0,0 +1,29 @@
+// Code generated by mockery 2.9.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	echo "github.com/labstack/echo/v4"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// RunnerMiddleware is an autogenerated mock type for the RunnerMiddleware type
+type RunnerMiddleware struct {
+	mock.Mock
+}
+
+// Token provides a mock function with given fields:
+func (_m *RunnerMiddleware) Token() echo.MiddlewareFunc {
+	ret := _m.Called()
+
+	var r0 echo.MiddlewareFunc
+	if rf, ok := ret.Get(0).(func() echo.MiddlewareFunc); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(echo.MiddlewareFunc)
+		}
+	}
+
+	return r0
+}
@@ -0,0 +1,71 @@
+// Code generated by mockery 2.9.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	io "io"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// LogStreamer is an autogenerated mock type for the LogStreamer type
+type LogStreamer struct {
+	mock.Mock
+}
+
+// Open provides a mock function with given fields: commandRunID
+func (_m *LogStreamer) Open(commandRunID int) io.WriteCloser {
+	ret := _m.Called(commandRunID)
+
+	var r0 io.WriteCloser
+	if rf, ok := ret.Get(0).(func(int) io.WriteCloser); ok {
+		r0 = rf(commandRunID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.WriteCloser)
+		}
+	}
+
+	return r0
+}
+
+// Subscribe provides a mock function with given fields: commandRunID
+func (_m *LogStreamer) Subscribe(commandRunID int) ([]byte, <-chan []byte, func(), error) {
+	ret := _m.Called(commandRunID)
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func(int) []byte); ok {
+		r0 = rf(commandRunID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	var r1 <-chan []byte
+	if rf, ok := ret.Get(1).(func(int) <-chan []byte); ok {
+		r1 = rf(commandRunID)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(<-chan []byte)
+		}
+	}
+
+	var r2 func()
+	if rf, ok := ret.Get(2).(func(int) func()); ok {
+		r2 = rf(commandRunID)
+	} else {
+		if ret.Get(2) != nil {
+			r2 = ret.Get(2).(func())
+		}
+	}
+
+	var r3 error
+	if rf, ok := ret.Get(3).(func(int) error); ok {
+		r3 = rf(commandRunID)
+	} else {
+		r3 = ret.Error(3)
+	}
+
+	return r0, r1, r2, r3
+}
@@ -6,9 +6,8 @@ import (
 	context "context"
 	http "net/http"
 
-	mock "github.com/stretchr/testify/mock"
-
 	models "github.com/krok-o/krok/pkg/models"
+	mock "github.com/stretchr/testify/mock"
 )
 
 // Platform is an autogenerated mock type for the Platform type
@@ -30,6 +29,20 @@ func (_m *Platform) CreateHook(ctx context.Context, repo *models.Repository) err
 	return r0
 }
 
+// DeleteHook provides a mock function with given fields: ctx, repo
+func (_m *Platform) DeleteHook(ctx context.Context, repo *models.Repository) error {
+	ret := _m.Called(ctx, repo)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Repository) error); ok {
+		r0 = rf(ctx, repo)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // GetEventID provides a mock function with given fields: ctx, r
 func (_m *Platform) GetEventID(ctx context.Context, r *http.Request) (string, error) {
 	ret := _m.Called(ctx, r)
@@ -72,6 +85,20 @@ func (_m *Platform) GetEventType(ctx context.Context, r *http.Request) (string,
 	return r0, r1
 }
 
+// UpdateHook provides a mock function with given fields: ctx, repo
+func (_m *Platform) UpdateHook(ctx context.Context, repo *models.Repository) error {
+	ret := _m.Called(ctx, repo)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Repository) error); ok {
+		r0 = rf(ctx, repo)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // ValidateRequest provides a mock function with given fields: ctx, r, repoID
 func (_m *Platform) ValidateRequest(ctx context.Context, r *http.Request, repoID int) error {
 	ret := _m.Called(ctx, r, repoID)
@@ -142,3 +142,17 @@ func (_m *RepositoryStorer) Update(ctx context.Context, c *models.Repository) (*
 
 	return r0, r1
 }
+
+// UpdateHookID provides a mock function with given fields: ctx, id, hookID
+func (_m *RepositoryStorer) UpdateHookID(ctx context.Context, id int, hookID string) error {
+	ret := _m.Called(ctx, id, hookID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) error); ok {
+		r0 = rf(ctx, id, hookID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,119 @@
+// Code generated by mockery 2.9.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	models "github.com/krok-o/krok/pkg/models"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// RunnerCoordinator is an autogenerated mock type for the RunnerCoordinator type
+type RunnerCoordinator struct {
+	mock.Mock
+}
+
+// AppendLogs provides a mock function with given fields: ctx, runnerID, commandRunID, logs
+func (_m *RunnerCoordinator) AppendLogs(ctx context.Context, runnerID string, commandRunID int, logs []byte) (bool, error) {
+	ret := _m.Called(ctx, runnerID, commandRunID, logs)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, []byte) bool); ok {
+		r0 = rf(ctx, runnerID, commandRunID, logs)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, int, []byte) error); ok {
+		r1 = rf(ctx, runnerID, commandRunID, logs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClaimJob provides a mock function with given fields: ctx, runnerID
+func (_m *RunnerCoordinator) ClaimJob(ctx context.Context, runnerID string) (*models.RunnerJob, error) {
+	ret := _m.Called(ctx, runnerID)
+
+	var r0 *models.RunnerJob
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.RunnerJob); ok {
+		r0 = rf(ctx, runnerID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.RunnerJob)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, runnerID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FinishJob provides a mock function with given fields: ctx, runnerID, commandRunID, result
+func (_m *RunnerCoordinator) FinishJob(ctx context.Context, runnerID string, commandRunID int, result *models.RunnerJobResult) error {
+	ret := _m.Called(ctx, runnerID, commandRunID, result)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, *models.RunnerJobResult) error); ok {
+		r0 = rf(ctx, runnerID, commandRunID, result)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ListRunners provides a mock function with given fields: ctx
+func (_m *RunnerCoordinator) ListRunners(ctx context.Context) ([]*models.Runner, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []*models.Runner
+	if rf, ok := ret.Get(0).(func(context.Context) []*models.Runner); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Runner)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterRunner provides a mock function with given fields: ctx, registration
+func (_m *RunnerCoordinator) RegisterRunner(ctx context.Context, registration *models.RunnerRegistration) (*models.Runner, error) {
+	ret := _m.Called(ctx, registration)
+
+	var r0 *models.Runner
+	if rf, ok := ret.Get(0).(func(context.Context, *models.RunnerRegistration) *models.Runner); ok {
+		r0 = rf(ctx, registration)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Runner)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *models.RunnerRegistration) error); ok {
+		r1 = rf(ctx, registration)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -15,8 +15,14 @@ type Platform interface {
 	// CreateHook creates a hook for the respective platform.
 	// Events define the events this hook subscribes to. Since we don't want all hooks
 	// to subscribe to all events all the time, we provide the option to the user
-	// to select the events.
+	// to select the events. The ID of the created hook is set as the HookID of the repository.
 	CreateHook(ctx context.Context, repo *models.Repository) error
+	// UpdateHook updates the hook of the repository with its HookID on the platform
+	// using the events, auth and unique url of the repository.
+	UpdateHook(ctx context.Context, repo *models.Repository) error
+	// DeleteHook deletes the hook of the repository with its HookID from the platform.
+	// A hook which doesn't exist anymore is not an error.
+	DeleteHook(ctx context.Context, repo *models.Repository) error
 	// ValidateRequest will take a hook and verify it being a valid hook request according to
 	// platform rules.
 	ValidateRequest(ctx context.Context, r *http.Request, repoID int) error
@@ -35,3 +41,18 @@ type PlatformTokenProvider interface {
 	// for now, people can manually delete the secret from the vault directly.
 	//DeleteTokenForPlatform(vcs int) error
 }
+
+type repositoryKey struct{}
+
+// WithRepository returns a context which carries the repository a hook request was sent to.
+// Platforms which need the settings of the repository to handle a request can retrieve it
+// with RepositoryFromContext.
+func WithRepository(ctx context.Context, repo *models.Repository) context.Context {
+	return context.WithValue(ctx, repositoryKey{}, repo)
+}
+
+// RepositoryFromContext returns the repository stored by WithRepository.
+func RepositoryFromContext(ctx context.Context) (*models.Repository, bool) {
+	repo, ok := ctx.Value(repositoryKey{}).(*models.Repository)
+	return repo, ok && repo != nil
+}
@@ -0,0 +1,75 @@
+// Package platformapi contains the client which talks to the REST APIs of the platforms to manage hooks.
+package platformapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+// Client sends JSON requests to the API of a platform.
+type Client struct {
+	// Name of the platform, used in log messages.
+	Name string
+	// Authorize adds the token of the platform to a request.
+	Authorize  func(req *http.Request, token string)
+	HTTPClient *http.Client
+	Logger     zerolog.Logger
+}
+
+// NewClient creates a new client for the API of a platform.
+func NewClient(name string, authorize func(req *http.Request, token string), logger zerolog.Logger) *Client {
+	return &Client{
+		Name:       name,
+		Authorize:  authorize,
+		HTTPClient: http.DefaultClient,
+		Logger:     logger,
+	}
+}
+
+// BearerToken authorizes a request with the token as a bearer token.
+func BearerToken(req *http.Request, token string) {
+	req.Header.Set("Authorization", "Bearer "+token)
+}
+
+// Do sends a request for an action to the API and decodes the response into out if it's not nil.
+// It returns the status code of the response.
+func (c *Client) Do(ctx context.Context, action, method, u, token string, in, out interface{}) (int, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	c.Authorize(req, token)
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		content, _ := ioutil.ReadAll(resp.Body)
+		c.Logger.Debug().Int("code", resp.StatusCode).Str("body", string(content)).Msgf("Invalid status code received from %s.", c.Name)
+		return resp.StatusCode, fmt.Errorf("invalid status code %d received from %s", resp.StatusCode, action)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,42 @@
+package platformapi
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Do(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		assert.Equal(t, `{"name":"krok"}`, string(body))
+		_, _ = w.Write([]byte(`{"id":"1"}`))
+	}))
+	defer server.Close()
+	c := NewClient("test", BearerToken, zerolog.New(os.Stderr))
+
+	var out struct {
+		ID string `json:"id"`
+	}
+	code, err := c.Do(context.Background(), "hook creation", http.MethodPost, server.URL+"/hooks", "token", map[string]string{"name": "krok"}, &out)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "1", out.ID)
+
+	code, err = c.Do(context.Background(), "hook deletion", http.MethodDelete, server.URL+"/missing", "token", nil, nil)
+	assert.EqualError(t, err, "invalid status code 404 received from hook deletion")
+	assert.Equal(t, http.StatusNotFound, code)
+}
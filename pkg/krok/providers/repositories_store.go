@@ -16,4 +16,6 @@ type RepositoryStorer interface {
 	Delete(ctx context.Context, id int) error
 	Update(ctx context.Context, c *models.Repository) (*models.Repository, error)
 	List(ctx context.Context, opt *models.ListOptions) ([]*models.Repository, error)
+	// UpdateHookID saves the ID of the hook which was created for the repository on its platform.
+	UpdateHookID(ctx context.Context, id int, hookID string) error
 }
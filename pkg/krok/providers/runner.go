@@ -0,0 +1,24 @@
+package providers
+
+import (
+	"context"
+
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// RunnerCoordinator hands out command runs to remote runners and collects their logs and outcomes.
+type RunnerCoordinator interface {
+	// RegisterRunner adds a runner, which can claim command runs from now on.
+	RegisterRunner(ctx context.Context, registration *models.RunnerRegistration) (*models.Runner, error)
+	// ListRunners returns the registered runners.
+	ListRunners(ctx context.Context) ([]*models.Runner, error)
+	// ClaimJob waits until there is a command run the runner has all runner labels for and hands it to the runner.
+	// It returns nil if there was none until ctx is done and errors.ErrNotFound if the runner isn't registered.
+	ClaimJob(ctx context.Context, runnerID string) (*models.RunnerJob, error)
+	// AppendLogs adds output of a claimed command run to its logs. It returns true if the command run has been
+	// cancelled and the runner should stop it. Returns errors.ErrNotFound if the runner doesn't run the command run.
+	AppendLogs(ctx context.Context, runnerID string, commandRunID int, logs []byte) (bool, error)
+	// FinishJob saves the outcome of a claimed command run. Returns errors.ErrNotFound if the runner doesn't run the
+	// command run.
+	FinishJob(ctx context.Context, runnerID string, commandRunID int, result *models.RunnerJobResult) error
+}
@@ -0,0 +1,351 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	kerr "github.com/krok-o/krok/errors"
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/krok/providers/executor"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+const (
+	// runnerAPI is where Krok serves the api of the runners.
+	runnerAPI = "/rest/api/1/runner"
+	// defaultHeartbeatInterval is how often the logs of a running command are uploaded if nothing is configured.
+	defaultHeartbeatInterval = 5 * time.Second
+	// retryInterval is how long the runner waits before it contacts Krok again after a failed call.
+	retryInterval = 5 * time.Second
+	// maximumLogChunkSize is the maximum number of bytes Krok accepts in one upload of logs.
+	maximumLogChunkSize = 1024 * 1024
+)
+
+// Config defines configuration for the runner.
+type Config struct {
+	// Server is the address of Krok, i.e.: https://krok.example.com.
+	Server string
+	// Token is the runner token Krok has been started with.
+	Token string
+	// Name of the runner as it's shown in Krok.
+	Name string
+	// Labels of the runner. Commands with runner labels only run on runners which have all of them.
+	Labels []string
+	// Parallel is how many commands the runner runs at the same time. Defaults to 1.
+	Parallel int
+	// HeartbeatInterval is how often the logs of a running command are uploaded. Krok also learns from that,
+	// that the runner is still there, so it has to be well below the runner timeout of Krok. Defaults to 5 seconds.
+	HeartbeatInterval time.Duration
+	// Executor defines where the secret files of the commands are written. The timeouts and limits of
+	// the commands come from Krok.
+	Executor executor.Config
+}
+
+// Dependencies defines dependencies for the runner.
+type Dependencies struct {
+	Logger           zerolog.Logger
+	ContainerRuntime providers.ContainerRuntime
+	Clock            providers.Clock
+	// Client talks to Krok. It must not have a timeout below the time Krok waits for command runs, which is
+	// half of its runner timeout.
+	Client *http.Client
+}
+
+// Runner pulls command runs from Krok and runs them with its own container runtime. The logs and
+// the outcomes of the commands are sent back to Krok.
+type Runner struct {
+	Config
+	Dependencies
+
+	mu sync.Mutex
+	// id is the ID Krok registered the runner with.
+	id string
+}
+
+// NewRunner creates a new Runner.
+func NewRunner(cfg Config, deps Dependencies) *Runner {
+	if cfg.Parallel < 1 {
+		cfg.Parallel = 1
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = defaultHeartbeatInterval
+	}
+	cfg.Server = strings.TrimSuffix(cfg.Server, "/")
+	if deps.Client == nil {
+		deps.Client = &http.Client{}
+	}
+	if deps.Clock == nil {
+		deps.Clock = providers.NewClock()
+	}
+	return &Runner{
+		Config:       cfg,
+		Dependencies: deps,
+	}
+}
+
+// Run registers the runner and runs the command runs it claims until ctx is done. The commands which are
+// still running then are stopped with their stop signal and reported as cancelled.
+func (r *Runner) Run(ctx context.Context) error {
+	if _, err := r.register(ctx, ""); err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < r.Parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// register registers the runner with Krok unless another worker did so since the given ID has been
+// rejected. It returns the current ID of the runner.
+func (r *Runner) register(ctx context.Context, rejected string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.id != rejected {
+		return r.id, nil
+	}
+	runner := &models.Runner{}
+	if _, err := r.call(ctx, "/register", "application/json", &models.RunnerRegistration{
+		Name:   r.Name,
+		Labels: r.Labels,
+	}, runner); err != nil {
+		return "", fmt.Errorf("failed to register runner: %w", err)
+	}
+	r.Logger.Info().Str("id", runner.ID).Str("name", r.Name).Strs("labels", r.Labels).Msg("Runner registered.")
+	r.id = runner.ID
+	return r.id, nil
+}
+
+// runnerID returns the ID Krok registered the runner with.
+func (r *Runner) runnerID() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.id
+}
+
+// work claims command runs and runs them one after the other until ctx is done.
+func (r *Runner) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		id := r.runnerID()
+		job := &models.RunnerJob{}
+		status, err := r.call(ctx, "/"+id+"/claim", "", nil, job)
+		if errors.Is(err, kerr.ErrNotFound) {
+			// Krok forgot the runner, i.e.: because it restarted.
+			_, err = r.register(ctx, id)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			r.Logger.Error().Err(err).Msg("Failed to claim command run, retrying...")
+			wait(ctx, retryInterval)
+			continue
+		}
+		if status == http.StatusNoContent {
+			continue
+		}
+		r.runJob(ctx, id, job)
+	}
+}
+
+// runJob runs a claimed command run while uploading its logs, and reports its outcome.
+func (r *Runner) runJob(ctx context.Context, id string, job *models.RunnerJob) {
+	log := r.Logger.With().Int("command_run_id", job.CommandRunID).Logger()
+	if job.Command != nil {
+		log = log.With().Str("command", job.Command.Name).Str("image", job.Command.Image).Logger()
+	}
+	log.Info().Msg("Running command...")
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	logs := &jobLogs{}
+	done := make(chan struct{})
+	var heartbeat sync.WaitGroup
+	heartbeat.Add(1)
+	go func() {
+		defer heartbeat.Done()
+		r.heartbeat(jobCtx, id, job.CommandRunID, logs, cancel, done)
+	}()
+
+	result := executor.RunJob(jobCtx, r.Executor, executor.Dependencies{
+		Logger:           log,
+		Clock:            r.Clock,
+		ContainerRuntime: r.ContainerRuntime,
+		LogStreamer:      logs,
+	}, job)
+	close(done)
+	heartbeat.Wait()
+
+	// The outcome is reported even if the runner is stopped.
+	for chunk := logs.take(); len(chunk) > 0; chunk = logs.take() {
+		if _, err := r.appendLogs(context.Background(), id, job.CommandRunID, chunk); err != nil {
+			log.Debug().Err(err).Msg("Failed to upload logs.")
+			break
+		}
+	}
+	if _, err := r.call(context.Background(), fmt.Sprintf("/%s/job/%d/finish", id, job.CommandRunID), "application/json", result, nil); err != nil {
+		log.Error().Err(err).Msg("Failed to report outcome of command run.")
+		return
+	}
+	log.Info().Str("status", result.Status).Msg("Command finished.")
+}
+
+// heartbeat uploads the logs of a running command regularly until done is closed. The command is cancelled
+// once Krok tells that it has been cancelled or that the runner doesn't run it anymore.
+func (r *Runner) heartbeat(ctx context.Context, id string, commandRunID int, logs *jobLogs, cancel context.CancelFunc, done <-chan struct{}) {
+	ticker := time.NewTicker(r.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		chunk := logs.take()
+		cancelled, err := r.appendLogs(ctx, id, commandRunID, chunk)
+		if err != nil {
+			logs.restore(chunk)
+			if errors.Is(err, kerr.ErrNotFound) {
+				r.Logger.Warn().Int("command_run_id", commandRunID).Msg("Krok doesn't know the command run anymore, cancelling it.")
+				cancel()
+				continue
+			}
+			r.Logger.Debug().Err(err).Int("command_run_id", commandRunID).Msg("Failed to upload logs.")
+			continue
+		}
+		if cancelled {
+			r.Logger.Info().Int("command_run_id", commandRunID).Msg("Command run has been cancelled.")
+			cancel()
+		}
+	}
+}
+
+// appendLogs uploads logs of a command run and returns true if the command run has been cancelled.
+func (r *Runner) appendLogs(ctx context.Context, id string, commandRunID int, chunk []byte) (bool, error) {
+	response := &models.RunnerLogsResponse{}
+	if _, err := r.call(ctx, fmt.Sprintf("/%s/job/%d/logs", id, commandRunID), "application/octet-stream", chunk, response); err != nil {
+		return false, err
+	}
+	return response.Cancelled, nil
+}
+
+// call posts to the runner api of Krok. The body is sent as is if it's a byte slice and encoded as json
+// otherwise. The response is decoded into out unless it has no content. Returns errors.ErrNotFound if Krok
+// responds with not found.
+func (r *Runner) call(ctx context.Context, path string, contentType string, body interface{}, out interface{}) (int, error) {
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.Server+runnerAPI+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+r.Token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return resp.StatusCode, fmt.Errorf("%s: %w", path, kerr.ErrNotFound)
+	case resp.StatusCode >= http.StatusMultipleChoices:
+		content, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, fmt.Errorf("unexpected status %d from krok: %s", resp.StatusCode, strings.TrimSpace(string(content)))
+	case resp.StatusCode == http.StatusNoContent || out == nil:
+		return resp.StatusCode, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// wait waits for the given duration or until ctx is done.
+func wait(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// jobLogs buffers the output of a command until it's uploaded.
+type jobLogs struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+var _ providers.LogStreamer = &jobLogs{}
+
+// Open returns a writer into the buffer. The runner has a buffer for every command run.
+func (l *jobLogs) Open(commandRunID int) io.WriteCloser {
+	return l
+}
+
+// Subscribe isn't supported, the logs are only uploaded to Krok.
+func (l *jobLogs) Subscribe(commandRunID int) ([]byte, <-chan []byte, func(), error) {
+	return nil, nil, nil, kerr.ErrNotFound
+}
+
+// Write adds output to the buffer.
+func (l *jobLogs) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf = append(l.buf, p...)
+	return len(p), nil
+}
+
+// Close does nothing, the logs are uploaded until the command finished.
+func (l *jobLogs) Close() error {
+	return nil
+}
+
+// take removes the oldest output from the buffer, at most as much as Krok accepts at once.
+func (l *jobLogs) take() []byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := len(l.buf)
+	if n > maximumLogChunkSize {
+		n = maximumLogChunkSize
+	}
+	chunk := make([]byte, n)
+	copy(chunk, l.buf)
+	l.buf = l.buf[n:]
+	return chunk
+}
+
+// restore puts output which couldn't be uploaded back in front of the buffer.
+func (l *jobLogs) restore(chunk []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf = append(chunk, l.buf...)
+}
@@ -0,0 +1,158 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/krok-o/krok/pkg/krok/providers/containerruntime"
+	"github.com/krok-o/krok/pkg/krok/providers/executor"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// fakeKrok serves the runner api with a single command run.
+type fakeKrok struct {
+	mu           sync.Mutex
+	registration *models.RunnerRegistration
+	claimed      bool
+	cancel       bool
+	logs         []byte
+	result       chan *models.RunnerJobResult
+}
+
+func (f *fakeKrok) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer runner-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path {
+	case "/rest/api/1/runner/register":
+		f.registration = &models.RunnerRegistration{}
+		_ = json.NewDecoder(r.Body).Decode(f.registration)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&models.Runner{ID: "runner-1", Name: f.registration.Name})
+	case "/rest/api/1/runner/runner-1/claim":
+		if f.claimed {
+			time.Sleep(10 * time.Millisecond)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		f.claimed = true
+		_ = json.NewEncoder(w).Encode(&models.RunnerJob{
+			CommandRunID: 1,
+			EventID:      1,
+			Command:      &models.Command{Name: "test-command", Image: "test-image"},
+			Timeout:      10,
+			Args:         []string{"--platform=github"},
+			Secrets:      []string{"s3cr3t"},
+		})
+	case "/rest/api/1/runner/runner-1/job/1/logs":
+		logs, _ := io.ReadAll(r.Body)
+		f.logs = append(f.logs, logs...)
+		_ = json.NewEncoder(w).Encode(&models.RunnerLogsResponse{Cancelled: f.cancel})
+	case "/rest/api/1/runner/runner-1/job/1/finish":
+		result := &models.RunnerJobResult{}
+		_ = json.NewDecoder(r.Body).Decode(result)
+		f.result <- result
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestRunner(t *testing.T, server string, fake *containerruntime.Fake) *Runner {
+	return NewRunner(Config{
+		Server:            server,
+		Token:             "runner-token",
+		Name:              "build-arm64-1",
+		Labels:            []string{"arm64"},
+		HeartbeatInterval: 10 * time.Millisecond,
+		Executor:          executor.Config{SecretsLocation: t.TempDir()},
+	}, Dependencies{
+		Logger:           zerolog.New(os.Stderr),
+		ContainerRuntime: fake,
+	})
+}
+
+// waitForResult waits for the outcome the runner reports.
+func waitForResult(t *testing.T, krok *fakeKrok) *models.RunnerJobResult {
+	select {
+	case result := <-krok.result:
+		return result
+	case <-time.After(5 * time.Second):
+		t.Fatal("runner did not report an outcome")
+	}
+	return nil
+}
+
+func TestRunner_Run(t *testing.T) {
+	krok := &fakeKrok{result: make(chan *models.RunnerJobResult, 1)}
+	server := httptest.NewServer(krok)
+	defer server.Close()
+	fake := containerruntime.NewFakeRuntime(map[string]containerruntime.FakeImage{
+		"test-image": {Output: "the secret is s3cr3t\n"},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runner := newTestRunner(t, server.URL+"/", fake)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- runner.Run(ctx)
+	}()
+
+	result := waitForResult(t, krok)
+	assert.Equal(t, &models.RunnerJobResult{Status: models.RunStatusSuccess, Outcome: "the secret is [redacted]\n"}, result)
+	krok.mu.Lock()
+	assert.Equal(t, &models.RunnerRegistration{Name: "build-arm64-1", Labels: []string{"arm64"}}, krok.registration)
+	assert.NotContains(t, string(krok.logs), "s3cr3t")
+	krok.mu.Unlock()
+	created := fake.Created()
+	require.Len(t, created, 1)
+	assert.Equal(t, []string{"--platform=github"}, created[0].Args)
+
+	cancel()
+	select {
+	case err := <-errs:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("runner did not stop")
+	}
+}
+
+func TestRunner_Run_Cancelled(t *testing.T) {
+	krok := &fakeKrok{result: make(chan *models.RunnerJobResult, 1), cancel: true}
+	server := httptest.NewServer(krok)
+	defer server.Close()
+	fake := containerruntime.NewFakeRuntime(map[string]containerruntime.FakeImage{
+		"test-image": {Blocks: true},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = newTestRunner(t, server.URL, fake).Run(ctx)
+	}()
+
+	result := waitForResult(t, krok)
+	assert.Equal(t, models.RunStatusCancelled, result.Status)
+	assert.Equal(t, "SIGTERM", result.Signal)
+}
+
+func TestRunner_Run_InvalidToken(t *testing.T) {
+	server := httptest.NewServer(&fakeKrok{})
+	defer server.Close()
+	runner := newTestRunner(t, server.URL, containerruntime.NewFakeRuntime(nil))
+	runner.Token = "invalid"
+	err := runner.Run(context.Background())
+	assert.EqualError(t, err, "failed to register runner: unexpected status 401 from krok: ")
+}
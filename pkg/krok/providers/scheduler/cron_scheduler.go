@@ -0,0 +1,205 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog"
+
+	"github.com/krok-o/krok/pkg/krok/providers"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+// parser is a standard cron parser which also accepts descriptors like @daily or @every 1h.
+var parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ValidateSchedule returns an error if the given schedule does not follow cron job syntax.
+func ValidateSchedule(schedule string) error {
+	if _, err := parser.Parse(schedule); err != nil {
+		return fmt.Errorf("invalid schedule %q: %w", schedule, err)
+	}
+	return nil
+}
+
+// defaultRefreshInterval is used if the configured refresh interval isn't positive.
+const defaultRefreshInterval = 60
+
+// Config defines configuration for the scheduler.
+type Config struct {
+	// RefreshInterval defines how often, in seconds, the scheduler looks for new, updated or removed schedules.
+	RefreshInterval int
+}
+
+// Dependencies defines dependencies for the scheduler.
+type Dependencies struct {
+	Logger        zerolog.Logger
+	CommandStorer providers.CommandStorer
+	EventsStorer  providers.EventsStorer
+	Executor      providers.Executor
+	Clock         providers.Clock
+	UUIDGenerator providers.UUIDGenerator
+}
+
+// entry is a registered schedule of a command.
+type entry struct {
+	schedule string
+	id       cron.EntryID
+}
+
+// CronScheduler runs commands which have a schedule defined.
+// For every attached repository of a scheduled command an event is created
+// and passed to the executor.
+type CronScheduler struct {
+	Config
+	Dependencies
+
+	cron    *cron.Cron
+	entries map[int]entry
+	lock    sync.Mutex
+}
+
+// NewCronScheduler creates a new scheduler.
+func NewCronScheduler(cfg Config, deps Dependencies) *CronScheduler {
+	if cfg.RefreshInterval <= 0 {
+		deps.Logger.Warn().Int("refresh_interval", cfg.RefreshInterval).Msg("Refresh interval must be positive, using the default.")
+		cfg.RefreshInterval = defaultRefreshInterval
+	}
+	return &CronScheduler{
+		Config:       cfg,
+		Dependencies: deps,
+		cron:         cron.New(cron.WithParser(parser)),
+		entries:      make(map[int]entry),
+	}
+}
+
+// Run starts the scheduler and periodically refreshes the schedules of the commands.
+// It blocks until the context is cancelled.
+func (s *CronScheduler) Run(ctx context.Context) error {
+	s.Logger.Info().Msg("Starting scheduler...")
+	s.cron.Start()
+	defer func() {
+		<-s.cron.Stop().Done()
+	}()
+
+	s.refresh(ctx)
+	ticker := time.NewTicker(time.Duration(s.RefreshInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.Logger.Info().Msg("Stopping scheduler...")
+			return nil
+		case <-ticker.C:
+			s.refresh(ctx)
+		}
+	}
+}
+
+// refresh syncs the registered entries with the schedules of the stored commands.
+func (s *CronScheduler) refresh(ctx context.Context) {
+	commands, err := s.CommandStorer.List(ctx, &models.ListOptions{})
+	if err != nil {
+		s.Logger.Error().Err(err).Msg("Failed to list commands for scheduling.")
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	seen := make(map[int]struct{})
+	for _, c := range commands {
+		if !c.Enabled || c.Schedule == "" {
+			continue
+		}
+		log := s.Logger.With().Int("command_id", c.ID).Str("schedule", c.Schedule).Logger()
+		if e, ok := s.entries[c.ID]; ok {
+			if e.schedule == c.Schedule {
+				seen[c.ID] = struct{}{}
+				continue
+			}
+			s.cron.Remove(e.id)
+			delete(s.entries, c.ID)
+		}
+		commandID := c.ID
+		id, err := s.cron.AddFunc(c.Schedule, func() {
+			if err := s.runCommand(ctx, commandID); err != nil {
+				log.Error().Err(err).Msg("Failed to run scheduled command.")
+			}
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to schedule command.")
+			continue
+		}
+		log.Debug().Msg("Command scheduled.")
+		s.entries[c.ID] = entry{
+			schedule: c.Schedule,
+			id:       id,
+		}
+		seen[c.ID] = struct{}{}
+	}
+
+	// Remove schedules of commands which were deleted, disabled or lost their schedule.
+	for id, e := range s.entries {
+		if _, ok := seen[id]; !ok {
+			s.Logger.Debug().Int("command_id", id).Msg("Removing command schedule.")
+			s.cron.Remove(e.id)
+			delete(s.entries, id)
+		}
+	}
+}
+
+// schedulePayload is the payload which is passed to a scheduled command.
+type schedulePayload struct {
+	Command  string `json:"command"`
+	Schedule string `json:"schedule"`
+}
+
+// runCommand creates an event for every repository of a command and starts a run for it.
+func (s *CronScheduler) runCommand(ctx context.Context, commandID int) error {
+	log := s.Logger.With().Int("command_id", commandID).Logger()
+	command, err := s.CommandStorer.Get(ctx, commandID)
+	if err != nil {
+		return fmt.Errorf("failed to get command: %w", err)
+	}
+	if !command.Enabled {
+		log.Debug().Msg("Command is disabled, skipping scheduled run.")
+		return nil
+	}
+	payload, err := json.Marshal(schedulePayload{
+		Command:  command.Name,
+		Schedule: command.Schedule,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	for _, repo := range command.Repositories {
+		id, err := s.UUIDGenerator.Generate()
+		if err != nil {
+			log.Debug().Err(err).Msg("Failed to generate event id.")
+			return err
+		}
+		event, err := s.EventsStorer.Create(ctx, &models.Event{
+			EventID:      id,
+			CreateAt:     s.Clock.Now(),
+			RepositoryID: repo.ID,
+			Payload:      string(payload),
+			VCS:          repo.VCS,
+			EventType:    models.ScheduleEventType,
+			Source:       models.EventSourceSchedule,
+		})
+		if err != nil {
+			log.Debug().Err(err).Int("repository_id", repo.ID).Msg("Failed to store event.")
+			return err
+		}
+		if err := s.Executor.CreateRun(ctx, event, []*models.Command{command}); err != nil {
+			log.Debug().Err(err).Int("repository_id", repo.ID).Msg("Failed to create run.")
+			return err
+		}
+		log.Info().Int("repository_id", repo.ID).Int("event_id", event.ID).Msg("Scheduled run created.")
+	}
+	return nil
+}
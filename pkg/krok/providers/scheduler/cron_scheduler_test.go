@@ -0,0 +1,148 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/krok-o/krok/pkg/krok/providers/mocks"
+	"github.com/krok-o/krok/pkg/models"
+)
+
+func TestValidateSchedule(t *testing.T) {
+	assert.NoError(t, ValidateSchedule("0 * * * *"))
+	assert.NoError(t, ValidateSchedule("@daily"))
+	assert.NoError(t, ValidateSchedule("@every 1h"))
+	assert.Error(t, ValidateSchedule("not a schedule"))
+	assert.Error(t, ValidateSchedule("* * * * * *"))
+}
+
+func TestNewCronScheduler_InvalidRefreshInterval(t *testing.T) {
+	for _, interval := range []int{0, -5} {
+		s := NewCronScheduler(Config{RefreshInterval: interval}, Dependencies{Logger: zerolog.New(os.Stderr)})
+		assert.Equal(t, defaultRefreshInterval, s.RefreshInterval)
+	}
+}
+
+func TestCronScheduler_Refresh(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	mcs := &mocks.CommandStorer{}
+	mcs.On("List", mock.Anything, &models.ListOptions{}).Return([]*models.Command{
+		{ID: 1, Name: "nightly", Schedule: "0 2 * * *", Enabled: true},
+		{ID: 2, Name: "disabled", Schedule: "0 2 * * *", Enabled: false},
+		{ID: 3, Name: "no-schedule", Enabled: true},
+		{ID: 4, Name: "invalid", Schedule: "invalid", Enabled: true},
+	}, nil).Once()
+	s := NewCronScheduler(Config{RefreshInterval: 60}, Dependencies{
+		Logger:        logger,
+		CommandStorer: mcs,
+	})
+	s.refresh(context.Background())
+	assert.Len(t, s.entries, 1)
+	assert.Contains(t, s.entries, 1)
+	assert.Len(t, s.cron.Entries(), 1)
+
+	// The schedule changed for the first command and the second one has been enabled.
+	mcs.On("List", mock.Anything, &models.ListOptions{}).Return([]*models.Command{
+		{ID: 1, Name: "nightly", Schedule: "0 3 * * *", Enabled: true},
+		{ID: 2, Name: "disabled", Schedule: "0 2 * * *", Enabled: true},
+	}, nil).Once()
+	s.refresh(context.Background())
+	assert.Len(t, s.entries, 2)
+	assert.Equal(t, "0 3 * * *", s.entries[1].schedule)
+	assert.Len(t, s.cron.Entries(), 2)
+
+	// All commands have been deleted.
+	mcs.On("List", mock.Anything, &models.ListOptions{}).Return([]*models.Command{}, nil).Once()
+	s.refresh(context.Background())
+	assert.Empty(t, s.entries)
+	assert.Empty(t, s.cron.Entries())
+}
+
+func TestCronScheduler_RunCommand(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	command := &models.Command{
+		ID:       1,
+		Name:     "nightly",
+		Schedule: "0 2 * * *",
+		Enabled:  true,
+		Repositories: []*models.Repository{
+			{ID: 10, VCS: models.GITHUB},
+			{ID: 11, VCS: models.GITLAB},
+		},
+	}
+	mcs := &mocks.CommandStorer{}
+	mcs.On("Get", mock.Anything, 1).Return(command, nil)
+	mes := &mocks.EventsStorer{}
+	mes.On("Create", mock.Anything, mock.Anything).Return(func(ctx context.Context, e *models.Event) *models.Event {
+		e.ID = e.RepositoryID
+		return e
+	}, nil)
+	mex := &mocks.Executor{}
+	mex.On("CreateRun", mock.Anything, mock.Anything, []*models.Command{command}).Return(nil)
+	mt := &mocks.Clock{}
+	mt.On("Now").Return(time.Date(1981, 1, 1, 1, 1, 1, 1, time.UTC))
+	mu := &mocks.UUIDGenerator{}
+	mu.On("Generate").Return("uuid", nil)
+	s := NewCronScheduler(Config{RefreshInterval: 60}, Dependencies{
+		Logger:        logger,
+		CommandStorer: mcs,
+		EventsStorer:  mes,
+		Executor:      mex,
+		Clock:         mt,
+		UUIDGenerator: mu,
+	})
+	err := s.runCommand(context.Background(), 1)
+	assert.NoError(t, err)
+	mes.AssertNumberOfCalls(t, "Create", 2)
+	mex.AssertNumberOfCalls(t, "CreateRun", 2)
+	mex.AssertCalled(t, "CreateRun", mock.Anything, &models.Event{
+		ID:           10,
+		EventID:      "uuid",
+		CreateAt:     time.Date(1981, 1, 1, 1, 1, 1, 1, time.UTC),
+		RepositoryID: 10,
+		Payload:      `{"command":"nightly","schedule":"0 2 * * *"}`,
+		VCS:          models.GITHUB,
+		EventType:    models.ScheduleEventType,
+		Source:       models.EventSourceSchedule,
+	}, []*models.Command{command})
+}
+
+func TestCronScheduler_RunCommand_Disabled(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	mcs := &mocks.CommandStorer{}
+	mcs.On("Get", mock.Anything, 1).Return(&models.Command{
+		ID:      1,
+		Enabled: false,
+		Repositories: []*models.Repository{
+			{ID: 10, VCS: models.GITHUB},
+		},
+	}, nil)
+	mex := &mocks.Executor{}
+	s := NewCronScheduler(Config{RefreshInterval: 60}, Dependencies{
+		Logger:        logger,
+		CommandStorer: mcs,
+		Executor:      mex,
+	})
+	err := s.runCommand(context.Background(), 1)
+	assert.NoError(t, err)
+	mex.AssertNotCalled(t, "CreateRun", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCronScheduler_RunCommand_GetFails(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	mcs := &mocks.CommandStorer{}
+	mcs.On("Get", mock.Anything, 1).Return(nil, errors.New("nope"))
+	s := NewCronScheduler(Config{RefreshInterval: 60}, Dependencies{
+		Logger:        logger,
+		CommandStorer: mcs,
+	})
+	err := s.runCommand(context.Background(), 1)
+	assert.EqualError(t, err, "failed to get command: nope")
+}
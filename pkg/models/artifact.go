@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Artifact is a file which a command left in the workspace of a run.
+// swagger:model
+type Artifact struct {
+	// Name is the path of the artifact relative to the workspace.
+	//
+	// required: true
+	// example: public/index.html
+	Name string `json:"name"`
+	// Size of the artifact in bytes.
+	//
+	// required: true
+	Size int64 `json:"size"`
+	// ModifiedAt is the last time the artifact was written.
+	//
+	// required: true
+	ModifiedAt time.Time `json:"modified_at"`
+}
@@ -4,6 +4,23 @@ import (
 	"time"
 )
 
+const (
+	// RunStatusCreated is the status of a command run which has been created but not yet started.
+	RunStatusCreated = "created"
+	// RunStatusRunning is the status of a command run which is currently executing.
+	RunStatusRunning = "running"
+	// RunStatusFailed is the status of a command run which finished with an error.
+	RunStatusFailed = "failed"
+	// RunStatusSuccess is the status of a command run which finished successfully.
+	RunStatusSuccess = "success"
+	// RunStatusSkipped is the status of a command run which did not run because a dependency did not succeed.
+	RunStatusSkipped = "skipped"
+	// RunStatusCancelled is the status of a command run which has been stopped by a user.
+	RunStatusCancelled = "cancelled"
+	// RunStatusTimedOut is the status of a command run which has been stopped because it ran too long.
+	RunStatusTimedOut = "timed_out"
+)
+
 // CommandRun is a single run of a command belonging to an event
 // including things like, state, event, and created at.
 // swagger:model
@@ -20,17 +37,72 @@ type CommandRun struct {
 	//
 	// required: true
 	CommandName string `json:"command_name"`
+	// CommandID is the ID of the command that is being executed. The command might
+	// have been deleted since.
+	//
+	// required: false
+	CommandID int `json:"command_id,omitempty"`
+	// Attempt is the number of the attempt of the command, starting at 1. Failed commands
+	// which are retried get a new command run for every attempt.
+	//
+	// required: false
+	// example: 1
+	Attempt int `json:"attempt,omitempty"`
+	// ContainerID is the ID of the container which executes the command.
+	//
+	// required: false
+	ContainerID string `json:"container_id,omitempty"`
 	// Status is the current state of the command run.
 	//
 	// required: true
-	// example: created, running, failed, success
+	// example: created, running, failed, success, skipped, cancelled, timed_out
 	Status string `json:"status"`
 	// Outcome is any output of the command. Stdout and stderr combined.
 	//
 	// required: false
 	Outcome string `json:"outcome"`
+	// Signal is the signal which stopped the command if it has been cancelled or timed out.
+	//
+	// required: false
+	// example: SIGTERM
+	Signal string `json:"signal,omitempty"`
 	// CreatedAt is the time when this command run was created.
 	//
 	// required: true
 	CreateAt time.Time `json:"create_at"`
 }
+
+// QueuedRun is a command run which waits for a free slot in the run queue.
+// swagger:model
+type QueuedRun struct {
+	// CommandRunID is the ID of the waiting command run.
+	//
+	// required: true
+	CommandRunID int `json:"command_run_id"`
+	// EventID is the ID of the event that this run belongs to.
+	//
+	// required: true
+	EventID int `json:"event_id"`
+	// RepositoryID is the ID of the repository of the event.
+	//
+	// required: true
+	RepositoryID int `json:"repository_id"`
+	// CommandName is the name of the waiting command.
+	//
+	// required: true
+	CommandName string `json:"command_name"`
+	// Priority is the priority of the command.
+	//
+	// required: false
+	Priority int `json:"priority"`
+	// Position is the place of the run in the queue, starting at 1. Runs which are held back by the
+	// limits of their repository or command are passed by the runs after them.
+	//
+	// required: true
+	// example: 1
+	Position int `json:"position"`
+	// WaitingSince is the time when the run entered the queue.
+	//
+	// required: true
+	WaitingSince time.Time `json:"waiting_since"`
+}
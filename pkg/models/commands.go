@@ -1,5 +1,11 @@
 package models
 
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
 // Command is a command which can be executed by Krok.
 // swagger:model
 type Command struct {
@@ -21,7 +27,7 @@ type Command struct {
 	// required: false
 	Repositories []*Repository `json:"repositories,omitempty"`
 	// Image defines the image name and tag of the command
-	// Note: At the moment, only docker is supported. Later, runc, containerd...
+	// Note: At the moment, docker and Kubernetes Jobs are supported. Later, runc, containerd...
 	//
 	// required: true
 	// example: krok-hook/slack-notification:v0.0.1
@@ -41,6 +47,167 @@ type Command struct {
 	//
 	// required: false
 	RequiresClone bool `json:"requires_clone"`
+	// DependsOn holds the IDs of the commands which have to succeed before this command runs.
+	// Calculated, not saved.
+	//
+	// required: false
+	DependsOn []int `json:"depends_on,omitempty"`
+	// Filter restricts the events of a repository for which this command runs.
+	// Only set if the command has been loaded for a repository. Calculated, not saved.
+	//
+	// required: false
+	Filter *CommandFilter `json:"filter,omitempty"`
+	// Timeout is the maximum runtime of the command in seconds. If not set, or if it's
+	// above the maximum runtime configured for Krok, the configured maximum is used.
+	//
+	// required: false
+	// example: 60
+	Timeout int `json:"timeout,omitempty"`
+	// CPUs is the number of CPUs the container of the command can use. Fractions are allowed.
+	//
+	// required: false
+	// example: 0.5
+	CPUs float64 `json:"cpus,omitempty"`
+	// MemoryLimit is the maximum memory the container of the command can use in bytes.
+	//
+	// required: false
+	// example: 268435456
+	MemoryLimit int64 `json:"memory_limit,omitempty"`
+	// PidsLimit is the maximum number of processes in the container of the command.
+	//
+	// required: false
+	// example: 100
+	PidsLimit int64 `json:"pids_limit,omitempty"`
+	// DiskLimit is the maximum disk space the container of the command can write in bytes.
+	// Not every container runtime supports this.
+	//
+	// required: false
+	// example: 1073741824
+	DiskLimit int64 `json:"disk_limit,omitempty"`
+	// StopSignal is sent to the command when it times out or is cancelled. If the command
+	// didn't stop after the StopGracePeriod, it's killed. Defaults to SIGTERM.
+	//
+	// required: false
+	// example: SIGINT
+	StopSignal string `json:"stop_signal,omitempty"`
+	// StopGracePeriod is how long the command has to stop after the StopSignal in seconds. Defaults to 10.
+	//
+	// required: false
+	// example: 30
+	StopGracePeriod int `json:"stop_grace_period,omitempty"`
+	// Retry defines if the command is run again when it fails.
+	//
+	// required: false
+	Retry *RetryPolicy `json:"retry,omitempty"`
+	// Priority of the command in the run queue. Commands with a higher priority are started first
+	// once there is a free slot. Defaults to 0 and can be negative.
+	//
+	// required: false
+	// example: 10
+	Priority int `json:"priority,omitempty"`
+	// MaxParallelRuns is how many runs of the command may run at the same time. 0 means no limit.
+	//
+	// required: false
+	// example: 2
+	MaxParallelRuns int `json:"max_parallel_runs,omitempty"`
+	// Concurrency puts the runs of the command for the same branch, pull request or other value of the
+	// payload into a group, so only one of them runs at a time.
+	//
+	// required: false
+	Concurrency *ConcurrencyPolicy `json:"concurrency,omitempty"`
+	// PullPolicy defines when the image of the command is pulled: always, if-not-present or never.
+	// Defaults to always, or to the default of Kubernetes for the Kubernetes executor. With never, the image
+	// has to be loaded onto the host beforehand.
+	//
+	// required: false
+	// example: if-not-present
+	PullPolicy string `json:"pull_policy,omitempty"`
+	// RunnerLabels restricts the runners of the remote executor which may run the command to those which
+	// have all of these labels. Ignored by the other executors.
+	//
+	// required: false
+	// example: ["arm64"]
+	RunnerLabels []string `json:"runner_labels,omitempty"`
+}
+
+const (
+	// PullPolicyAlways pulls the image of a command before every run.
+	PullPolicyAlways = "always"
+	// PullPolicyIfNotPresent only pulls the image of a command if it isn't on the host yet.
+	PullPolicyIfNotPresent = "if-not-present"
+	// PullPolicyNever never pulls the image of a command. Runs fail if it isn't on the host.
+	PullPolicyNever = "never"
+)
+
+const (
+	// ConcurrencyGroupBranch groups the runs of a command by the branch of their event.
+	ConcurrencyGroupBranch = "branch"
+	// ConcurrencyGroupPullRequest groups the runs of a command by the pull or merge request of their event.
+	ConcurrencyGroupPullRequest = "pull_request"
+)
+
+// ConcurrencyPolicy defines which runs of a command of the same repository can't run at the same time.
+// A new run waits for the older runs of its group to finish, or cancels them.
+// swagger:model
+type ConcurrencyPolicy struct {
+	// Group is branch, pull_request or a dot separated path in the payload of the event, i.e.: head_commit.author.name.
+	// Events without a value for the group aren't part of any group.
+	//
+	// required: true
+	// example: pull_request
+	Group string `json:"group"`
+	// CancelInProgress cancels the older runs of the group instead of waiting for them.
+	//
+	// required: false
+	// example: true
+	CancelInProgress bool `json:"cancel_in_progress,omitempty"`
+}
+
+const (
+	// RetryOnPullFailure retries a command if its image could not be pulled.
+	RetryOnPullFailure = "pull_failure"
+	// RetryOnNonZeroExit retries a command if it exited with a non-zero exit code.
+	RetryOnNonZeroExit = "non_zero_exit"
+	// RetryOnTimeout retries a command if it timed out.
+	RetryOnTimeout = "timeout"
+)
+
+// RetryPolicy defines how often and for which failures a command is run again.
+// Every attempt gets its own command run.
+// swagger:model
+type RetryPolicy struct {
+	// MaxAttempts is how often the command runs at most, including the first attempt.
+	//
+	// required: true
+	// example: 3
+	MaxAttempts int `json:"max_attempts"`
+	// Backoff is the delay before the first retry in seconds. It doubles with every further retry.
+	//
+	// required: false
+	// example: 5
+	Backoff int `json:"backoff,omitempty"`
+	// RetryOn lists the failures which are retried.
+	//
+	// required: true
+	// example: ["pull_failure", "timeout"]
+	RetryOn []string `json:"retry_on"`
+}
+
+// CommandFilter restricts the events of a repository for which a command runs.
+// swagger:model
+type CommandFilter struct {
+	// EventTypes the command runs for. If empty, the command runs for every event.
+	//
+	// required: false
+	// example: ["push", "pull_request"]
+	EventTypes []string `json:"event_types,omitempty"`
+	// Branch is a glob pattern the branch of the event must match. For pull and merge
+	// requests this is the target branch. Events which don't belong to a branch, like tags
+	// or pings, never match if this is set.
+	//
+	// required: false
+	// example: release/*
+	Branch string `json:"branch,omitempty"`
 }
 
 // CommandSetting defines the settings a command can have.
@@ -66,4 +233,56 @@ type CommandSetting struct {
 	//
 	// required: false
 	InVault bool `json:"in_vault"`
+	// Delivery defines how the setting is handed to the container of the command. Settings in the vault
+	// are delivered as a file by default and can't be delivered as an argument, so their values never end up in the
+	// container spec.
+	//
+	// required: false
+	// example: arg, env, file
+	Delivery string `json:"delivery,omitempty"`
+}
+
+// Delivery modes of command settings.
+const (
+	// DeliveryArg passes a setting as the argument --key=value.
+	DeliveryArg = "arg"
+	// DeliveryEnv passes a setting as the environment variable KEY=value.
+	DeliveryEnv = "env"
+	// DeliveryFile writes a setting into a file on tmpfs which is mounted into the container. The path
+	// of the file is passed as the argument --key-file=path.
+	DeliveryFile = "file"
+)
+
+// settingKey is the format of keys which can be used as file and environment variable names.
+var settingKey = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// DeliveryMode returns how the setting is handed to the container of the command.
+func (s *CommandSetting) DeliveryMode() string {
+	if s.Delivery != "" {
+		return s.Delivery
+	}
+	if s.InVault {
+		return DeliveryFile
+	}
+	return DeliveryArg
+}
+
+// Validate validates the command setting.
+func (s *CommandSetting) Validate() error {
+	if s.Key == "" {
+		return errors.New("key must be defined")
+	}
+	switch s.DeliveryMode() {
+	case DeliveryArg:
+		if s.InVault {
+			return errors.New("settings in the vault can't be delivered as an argument")
+		}
+	case DeliveryEnv, DeliveryFile:
+		if !settingKey.MatchString(s.Key) {
+			return fmt.Errorf("key %q can only contain letters, digits, '_', '.' and '-'", s.Key)
+		}
+	default:
+		return fmt.Errorf("unsupported delivery %q", s.Delivery)
+	}
+	return nil
 }
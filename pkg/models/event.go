@@ -1,9 +1,25 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
+// ScheduleEventType is the type of events which are created by the scheduler
+// for commands which define a schedule.
+const ScheduleEventType = "schedule"
+
+const (
+	// EventSourceHook is the source of events which a platform sent through a hook.
+	EventSourceHook = "hook"
+	// EventSourceSchedule is the source of events which the scheduler created.
+	EventSourceSchedule = "schedule"
+	// EventSourceManual is the source of events which a user triggered through the API.
+	EventSourceManual = "manual"
+	// EventSourceRerun is the source of events which a user created by running the commands of an event again.
+	EventSourceRerun = "rerun"
+)
+
 // Event contains details about a platform event, such as
 // the repository it belongs to and the event that created it...
 // swagger:model
@@ -37,8 +53,55 @@ type Event struct {
 	//
 	// required: true
 	VCS int `json:"vcs"`
-	// EventType of the name, i.e.: push, ping, pull-request...
+	// EventType of the name, i.e.: push, ping, pull-request, schedule...
+	//
+	// required: true
+	EventType string `json:"event_type"`
+	// RerunOf is the ID of the event which this event runs the commands of again. It's 0 for events
+	// which didn't come from a rerun.
+	//
+	// required: false
+	RerunOf int `json:"rerun_of,omitempty"`
+	// Source defines where the event came from, i.e.: hook, schedule, manual or rerun.
+	//
+	// required: false
+	Source string `json:"source,omitempty"`
+	// TriggeredBy is the ID of the user who triggered a manual event or a rerun.
+	//
+	// required: false
+	TriggeredBy int `json:"triggered_by,omitempty"`
+}
+
+// TriggerOptions define a manual event for the commands of a repository.
+// swagger:model
+type TriggerOptions struct {
+	// CommandID is the command to run. 0 runs all commands of the repository which match the event.
+	//
+	// required: false
+	// example: 1
+	CommandID int `json:"command_id,omitempty"`
+	// EventType is the type of the event the commands get, i.e.: push.
 	//
 	// required: true
+	// example: push
 	EventType string `json:"event_type"`
+	// Payload is the payload the commands get. It defaults to an empty object.
+	//
+	// required: false
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// RerunOptions define which commands of an event run again.
+// swagger:model
+type RerunOptions struct {
+	// Commands are the names of the commands to run again. They run even if they didn't run for the event
+	// before. Empty means the commands which ran for the event.
+	//
+	// required: false
+	// example: ["build", "publish"]
+	Commands []string `json:"commands,omitempty"`
+	// FailedOnly only runs the commands again which didn't succeed the last time they ran for the event.
+	//
+	// required: false
+	FailedOnly bool `json:"failed_only,omitempty"`
 }
@@ -9,6 +9,12 @@ const (
 	GITLAB
 	// GITEA based hooks
 	GITEA
+	// BITBUCKET based hooks for Bitbucket Cloud
+	BITBUCKET
+	// BITBUCKETSERVER based hooks for Bitbucket Server and Data Center
+	BITBUCKETSERVER
+	// GENERIC based hooks for arbitrary HTTP senders
+	GENERIC
 )
 
 // Platform defines a platform like Github, Gitlab etc.
@@ -21,7 +27,7 @@ type Platform struct {
 	// Name of the platform.
 	//
 	// required: true
-	// example: github, gitlab, gitea
+	// example: github, gitlab, gitea, bitbucket, bitbucket-server, generic
 	Name string `json:"name"`
 }
 
@@ -39,4 +45,16 @@ var SupportedPlatforms = map[int]Platform{
 		ID:   GITEA,
 		Name: "gitea",
 	},
+	BITBUCKET: {
+		ID:   BITBUCKET,
+		Name: "bitbucket",
+	},
+	BITBUCKETSERVER: {
+		ID:   BITBUCKETSERVER,
+		Name: "bitbucket-server",
+	},
+	GENERIC: {
+		ID:   GENERIC,
+		Name: "generic",
+	},
 }
@@ -0,0 +1,27 @@
+package models
+
+// RegistryCredential is the login for a container registry which the images of commands are pulled from.
+// The credential is kept in the vault.
+// swagger:model
+type RegistryCredential struct {
+	// Host is the registry, i.e.: ghcr.io or registry.example.com:5000. Images without a registry are
+	// pulled from docker.io.
+	//
+	// required: true
+	// example: ghcr.io
+	Host string `json:"host"`
+	// CommandID restricts the credential to the images of a single command. It's used instead of the
+	// credential for the whole registry. 0 means the credential is used for every command.
+	//
+	// required: false
+	// example: 1
+	CommandID int `json:"command_id,omitempty"`
+	// Username is the user to log into the registry with.
+	//
+	// required: true
+	Username string `json:"username"`
+	// Password is the password or access token of the user.
+	//
+	// required: true
+	Password string `json:"password"`
+}
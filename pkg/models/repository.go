@@ -1,6 +1,9 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // Auth is authentication option for a repository.
 // swagger:model
@@ -40,6 +43,71 @@ func (g *GitLab) GetProjectID() int {
 	return -1
 }
 
+// Signature algorithms supported by generic hooks.
+const (
+	SignatureSHA1   = "sha1"
+	SignatureSHA256 = "sha256"
+	SignatureSHA512 = "sha512"
+	// SignatureToken means that the header contains the secret itself.
+	SignatureToken = "token"
+)
+
+// Generic contains the settings of a repository which receives hooks from an arbitrary sender,
+// like Sentry or an internal service.
+// swagger:model
+type Generic struct {
+	// SignatureHeader is the header which contains the signature of the payload.
+	//
+	// required: true
+	// example: X-Hub-Signature-256
+	SignatureHeader string `json:"signature_header"`
+	// SignatureAlgorithm is the HMAC algorithm the payload is signed with using the secret of the repository.
+	// If it's token, the header has to contain the secret itself.
+	//
+	// required: true
+	// example: sha1, sha256, sha512, token
+	SignatureAlgorithm string `json:"signature_algorithm"`
+	// SignaturePrefix is removed from the signature before comparing it.
+	//
+	// required: false
+	// example: sha256=
+	SignaturePrefix string `json:"signature_prefix,omitempty"`
+	// EventTypeHeader is the header which contains the type of the event.
+	//
+	// required: false
+	EventTypeHeader string `json:"event_type_header,omitempty"`
+	// EventTypePath is the path in the JSON payload of the type of the event. It is
+	// used if there is no EventTypeHeader.
+	//
+	// required: false
+	// example: data.action
+	EventTypePath string `json:"event_type_path,omitempty"`
+	// EventIDHeader is the header which contains the ID of the event. If not set,
+	// an ID is generated.
+	//
+	// required: false
+	EventIDHeader string `json:"event_id_header,omitempty"`
+}
+
+// Validate validates the generic settings.
+func (g *Generic) Validate() error {
+	if g == nil {
+		return errors.New("generic settings must be defined")
+	}
+	if g.SignatureHeader == "" {
+		return errors.New("signature header must be defined")
+	}
+	switch g.SignatureAlgorithm {
+	case SignatureSHA1, SignatureSHA256, SignatureSHA512, SignatureToken:
+	default:
+		return fmt.Errorf("unsupported signature algorithm %q", g.SignatureAlgorithm)
+	}
+	if g.EventTypeHeader == "" && g.EventTypePath == "" {
+		return errors.New("either event type header or event type path must be defined")
+	}
+	return nil
+}
+
 // Repository is a repository which can be managed by Krok.
 // swagger:model
 type Repository struct {
@@ -63,6 +131,10 @@ type Repository struct {
 	//
 	// required: false
 	GitLab *GitLab `json:"git_lab,omitempty"`
+	// Generic settings. Required if VCS is generic.
+	//
+	// required: false
+	Generic *Generic `json:"generic,omitempty"`
 	// Auth contains authentication details for this repository.
 	//
 	// required: true
@@ -76,8 +148,14 @@ type Repository struct {
 	//
 	// required: true
 	UniqueURL string `json:"unique_url,omitempty"`
-	// TODO: Think about storing this
+	// Events are the events the hook on the platform is registered for.
+	//
+	// required: false
 	Events []string `json:"events,omitempty"`
+	// HookID is the ID of the hook which was created for this repository on the platform.
+	//
+	// required: false
+	HookID string `json:"hook_id,omitempty"`
 }
 
 // Validate validates this model.
@@ -91,5 +169,10 @@ func (r *Repository) Validate() (ok bool, field string, err error) {
 	if r.Auth.Secret == "" {
 		return false, "Auth.Secret", errors.New("secret for the webhook must be defined")
 	}
+	if r.VCS == GENERIC {
+		if err := r.Generic.Validate(); err != nil {
+			return false, "Generic", err
+		}
+	}
 	return true, "", nil
 }
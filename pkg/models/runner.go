@@ -0,0 +1,119 @@
+package models
+
+import (
+	"time"
+)
+
+// Runner is a remote agent which pulls command runs from Krok and runs them with its own container runtime.
+// swagger:model
+type Runner struct {
+	// ID is generated when the runner registers.
+	//
+	// required: true
+	ID string `json:"id"`
+	// Name of the runner, i.e.: the hostname of its machine.
+	//
+	// required: true
+	// example: build-arm64-1
+	Name string `json:"name"`
+	// Labels of the runner. A runner only runs commands whose runner labels it all has.
+	//
+	// required: false
+	// example: ["arm64"]
+	Labels []string `json:"labels,omitempty"`
+	// LastSeen is when the runner contacted Krok the last time.
+	//
+	// required: true
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// RunnerRegistration is what a runner registers with.
+// swagger:model
+type RunnerRegistration struct {
+	// Name of the runner.
+	//
+	// required: true
+	// example: build-arm64-1
+	Name string `json:"name"`
+	// Labels of the runner.
+	//
+	// required: false
+	// example: ["arm64"]
+	Labels []string `json:"labels,omitempty"`
+}
+
+// RunnerJob is a command run which a runner claimed. It holds everything the runner needs to run the
+// container of the command, including the secrets of the command.
+// swagger:model
+type RunnerJob struct {
+	// CommandRunID is the command run the runner reports the logs and the outcome of the command for.
+	//
+	// required: true
+	CommandRunID int `json:"command_run_id"`
+	// EventID is the event the command runs for.
+	//
+	// required: true
+	EventID int `json:"event_id"`
+	// Command holds the image, the limits and the pull and stop policies of the command. The runner runs the
+	// command once, retries are up to Krok.
+	//
+	// required: true
+	Command *Command `json:"command"`
+	// Timeout is how long the command may run in seconds.
+	//
+	// required: true
+	Timeout int `json:"timeout"`
+	// Args are the arguments of the container.
+	//
+	// required: true
+	Args []string `json:"args"`
+	// Env are the environment variables of the container as key=value.
+	//
+	// required: false
+	Env []string `json:"env,omitempty"`
+	// Files are mounted into the container of the command. The key is the name of the file.
+	//
+	// required: false
+	Files map[string]string `json:"files,omitempty"`
+	// Secrets are masked in the output of the command.
+	//
+	// required: false
+	Secrets []string `json:"secrets,omitempty"`
+	// RegistryCredential is what the image of the command is pulled with, if any.
+	//
+	// required: false
+	RegistryCredential *RegistryCredential `json:"registry_credential,omitempty"`
+}
+
+// RunnerJobResult is the outcome of a command run which a runner reports once the command finished.
+// swagger:model
+type RunnerJobResult struct {
+	// Status is the final status of the command run.
+	//
+	// required: true
+	// example: success
+	Status string `json:"status"`
+	// Outcome is the output of the command.
+	//
+	// required: false
+	Outcome string `json:"outcome"`
+	// Signal is the signal which stopped the command if it has been cancelled or timed out.
+	//
+	// required: false
+	// example: SIGTERM
+	Signal string `json:"signal,omitempty"`
+	// Failure is the kind of failure a retry policy can cover, if any.
+	//
+	// required: false
+	// example: timeout
+	Failure string `json:"failure,omitempty"`
+}
+
+// RunnerLogsResponse is the response of a runner uploading the logs of a command run.
+// swagger:model
+type RunnerLogsResponse struct {
+	// Cancelled tells the runner to stop the command.
+	//
+	// required: true
+	Cancelled bool `json:"cancelled"`
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+)
+
+// RunnerMiddlewareConfig represents the RunnerMiddleware config.
+type RunnerMiddlewareConfig struct {
+	// RunnerToken is the token remote runners authenticate with. If it's empty, no runner is accepted.
+	RunnerToken string
+}
+
+// RunnerMiddlewareDeps represents the RunnerMiddleware dependencies.
+type RunnerMiddlewareDeps struct {
+	Logger zerolog.Logger
+}
+
+// RunnerMiddleware authenticates remote runners.
+type RunnerMiddleware struct {
+	RunnerMiddlewareConfig
+	RunnerMiddlewareDeps
+}
+
+// NewRunnerMiddleware creates a new RunnerMiddleware.
+func NewRunnerMiddleware(cfg RunnerMiddlewareConfig, deps RunnerMiddlewareDeps) *RunnerMiddleware {
+	return &RunnerMiddleware{RunnerMiddlewareConfig: cfg, RunnerMiddlewareDeps: deps}
+}
+
+// Token checks that the request carries the runner token as bearer token.
+func (rm *RunnerMiddleware) Token() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if rm.RunnerToken == "" {
+				return c.JSON(http.StatusUnauthorized, "Runners are not enabled.")
+			}
+			authHeader := c.Request().Header.Get("Authorization")
+			if !strings.HasPrefix(authHeader, bearerHeader) {
+				return c.JSON(http.StatusUnauthorized, "failed to extract token")
+			}
+			token := strings.TrimPrefix(authHeader, bearerHeader)
+			if subtle.ConstantTimeCompare([]byte(token), []byte(rm.RunnerToken)) != 1 {
+				rm.Logger.Warn().Str("remote", c.RealIP()).Msg("runner token authentication failed")
+				return c.JSON(http.StatusUnauthorized, "Token authentication failed.")
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunnerToken(t *testing.T) {
+	e := echo.New()
+	handler := func(c echo.Context) error {
+		return c.String(http.StatusOK, "test")
+	}
+
+	tt := []struct {
+		name   string
+		token  string
+		header string
+		status int
+	}{
+		{name: "valid token", token: "runner-token", header: "Bearer runner-token", status: http.StatusOK},
+		{name: "invalid token", token: "runner-token", header: "Bearer invalid", status: http.StatusUnauthorized},
+		{name: "missing token", token: "runner-token", status: http.StatusUnauthorized},
+		{name: "runners not enabled", header: "Bearer ", status: http.StatusUnauthorized},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			hf := NewRunnerMiddleware(RunnerMiddlewareConfig{RunnerToken: tc.token}, RunnerMiddlewareDeps{}).Token()(handler)
+
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			res := httptest.NewRecorder()
+			c := e.NewContext(req, res)
+			err := hf(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.status, c.Response().Status)
+		})
+	}
+}
@@ -40,22 +40,25 @@ type KrokServer struct {
 
 // Dependencies defines needed dependencies for the krok server.
 type Dependencies struct {
-	Logger                 zerolog.Logger
-	HookHandler            providers.HookHandler
-	UserMiddleware         providers.UserMiddleware
-	CommandHandler         providers.CommandHandler
-	CommandSettingsHandler providers.CommandSettingsHandler
-	CommandRunHandler      providers.CommandRunHandler
-	RepositoryHandler      providers.RepositoryHandler
-	APIKeyHandler          providers.APIKeysHandler
-	AuthHandler            providers.AuthHandler
-	TokenHandler           providers.TokenHandler
-	VCSTokenHandler        providers.VCSTokenHandler
-	SupportedPlatformList  providers.SupportedPlatformListHandler
-	EventsHandler          providers.EventHandler
-	VaultHandler           providers.VaultHandler
-	UserHandler            providers.UserHandler
-	ReadyHandler           providers.ReadyHandler
+	Logger                    zerolog.Logger
+	HookHandler               providers.HookHandler
+	UserMiddleware            providers.UserMiddleware
+	CommandHandler            providers.CommandHandler
+	CommandSettingsHandler    providers.CommandSettingsHandler
+	CommandRunHandler         providers.CommandRunHandler
+	RepositoryHandler         providers.RepositoryHandler
+	APIKeyHandler             providers.APIKeysHandler
+	AuthHandler               providers.AuthHandler
+	TokenHandler              providers.TokenHandler
+	VCSTokenHandler           providers.VCSTokenHandler
+	RegistryCredentialHandler providers.RegistryCredentialHandler
+	SupportedPlatformList     providers.SupportedPlatformListHandler
+	EventsHandler             providers.EventHandler
+	VaultHandler              providers.VaultHandler
+	UserHandler               providers.UserHandler
+	ReadyHandler              providers.ReadyHandler
+	RunnerMiddleware          providers.RunnerMiddleware
+	RunnerHandler             providers.RunnerHandler
 }
 
 // Server defines a server which runs and accepts requests.
@@ -102,6 +105,13 @@ func (s *KrokServer) Run(ctx context.Context) error {
 	// @vid vcs id
 	e.POST(api+"/hooks/:rid/:vid/callback", s.Dependencies.HookHandler.HandleHooks())
 	e.POST(api+"/get-token", s.Dependencies.TokenHandler.TokenHandler())
+
+	// Remote runners authenticate with the runner token instead of a user.
+	runner := e.Group(api+"/runner", s.Dependencies.RunnerMiddleware.Token())
+	runner.POST("/register", s.Dependencies.RunnerHandler.Register())
+	runner.POST("/:id/claim", s.Dependencies.RunnerHandler.Claim())
+	runner.POST("/:id/job/:crid/logs", s.Dependencies.RunnerHandler.AppendLogs())
+	runner.POST("/:id/job/:crid/finish", s.Dependencies.RunnerHandler.Finish())
 	// Admin related actions
 
 	auth := e.Group(api+"/krok", s.Dependencies.UserMiddleware.JWT())
@@ -121,8 +131,11 @@ func (s *KrokServer) Run(ctx context.Context) error {
 	auth.POST("/command/update", s.Dependencies.CommandHandler.Update())
 	auth.POST("/command/add-command-rel-for-repository/:cmdid/:repoid", s.Dependencies.CommandHandler.AddCommandRelForRepository())
 	auth.POST("/command/remove-command-rel-for-repository/:cmdid/:repoid", s.Dependencies.CommandHandler.RemoveCommandRelForRepository())
+	auth.POST("/command/update-command-rel-for-repository/:cmdid/:repoid", s.Dependencies.CommandHandler.UpdateCommandRelForRepository())
 	auth.POST("/command/add-command-rel-for-platform/:cmdid/:pid", s.Dependencies.CommandHandler.AddCommandRelForPlatform())
 	auth.POST("/command/remove-command-rel-for-platform/:cmdid/:pid", s.Dependencies.CommandHandler.RemoveCommandRelForPlatform())
+	auth.POST("/command/add-command-dependency/:cmdid/:depid", s.Dependencies.CommandHandler.AddCommandDependency())
+	auth.POST("/command/remove-command-dependency/:cmdid/:depid", s.Dependencies.CommandHandler.RemoveCommandDependency())
 
 	// command settings
 	auth.GET("/command/settings/:id", s.Dependencies.CommandSettingsHandler.Get())
@@ -133,6 +146,10 @@ func (s *KrokServer) Run(ctx context.Context) error {
 
 	// command runs
 	auth.GET("/command/run/:id", s.Dependencies.CommandRunHandler.GetCommandRun())
+	auth.GET("/command/run/:id/logs", s.Dependencies.CommandRunHandler.StreamCommandRunLogs())
+	auth.GET("/command/run/:id/queue", s.Dependencies.CommandRunHandler.GetQueuedRun())
+	auth.GET("/command/runs/queue", s.Dependencies.CommandRunHandler.ListQueue())
+	auth.POST("/command/run/:id/cancel", s.Dependencies.CommandRunHandler.CancelCommandRun())
 
 	// api keys related actions
 	auth.POST("/user/apikey/generate/:name", s.Dependencies.APIKeyHandler.Create())
@@ -143,9 +160,21 @@ func (s *KrokServer) Run(ctx context.Context) error {
 	// vcs token handler
 	auth.POST("/vcs-token", s.Dependencies.VCSTokenHandler.Create())
 
+	// registry credentials
+	auth.POST("/registry-credential", s.Dependencies.RegistryCredentialHandler.Create())
+	auth.DELETE("/registry-credential/:host", s.Dependencies.RegistryCredentialHandler.Delete())
+
 	// events
 	auth.POST("/events/:repoid", s.Dependencies.EventsHandler.List())
+	auth.POST("/events/:repoid/trigger", s.Dependencies.EventsHandler.Trigger())
 	auth.GET("/event/:id", s.Dependencies.EventsHandler.Get())
+	auth.GET("/event/:id/artifacts", s.Dependencies.EventsHandler.ListArtifacts())
+	auth.GET("/event/:id/artifacts/*", s.Dependencies.EventsHandler.GetArtifact())
+	auth.POST("/event/:id/cancel", s.Dependencies.EventsHandler.CancelRun())
+	auth.POST("/event/:id/rerun", s.Dependencies.EventsHandler.Rerun())
+
+	// runners
+	auth.GET("/runners", s.Dependencies.RunnerHandler.List())
 
 	// vault settings
 	auth.POST("/vault/secret", s.Dependencies.VaultHandler.CreateSecret())
@@ -81,3 +81,138 @@ func TestCommandRun_UpdateRunStatus(t *testing.T) {
 	_, err = crs.Get(ctx, 999)
 	assert.Error(t, err)
 }
+
+func TestCommandRun_ContainerAndListRunsWithStatus(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	env := environment.NewDockerConverter(environment.Dependencies{Logger: logger})
+	crs := livestore.NewCommandRunStore(livestore.CommandRunDependencies{
+		Connector: livestore.NewDatabaseConnector(livestore.Config{
+			Hostname: hostname,
+			Database: dbaccess.Db,
+			Username: dbaccess.Username,
+			Password: dbaccess.Password,
+		}, livestore.Dependencies{
+			Logger:    logger,
+			Converter: env,
+		}),
+	})
+	ctx := context.Background()
+	r, err := crs.CreateRun(ctx, &models.CommandRun{
+		EventID:     1,
+		CommandName: "test-command-reconcile",
+		CommandID:   10,
+		Status:      "created",
+		CreateAt:    time.Now(),
+	})
+	assert.NoError(t, err)
+
+	err = crs.UpdateRunContainer(ctx, r.ID, "container-id")
+	assert.NoError(t, err)
+	err = crs.UpdateRunStatus(ctx, r.ID, "running", "")
+	assert.NoError(t, err)
+
+	r, err = crs.Get(ctx, r.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, r.CommandID)
+	assert.Equal(t, "container-id", r.ContainerID)
+
+	runs, err := crs.ListRunsWithStatus(ctx, "created", "running")
+	assert.NoError(t, err)
+	found := false
+	for _, run := range runs {
+		if run.ID == r.ID {
+			found = true
+			assert.Equal(t, "container-id", run.ContainerID)
+		}
+	}
+	assert.True(t, found, "running command run not found")
+
+	err = crs.UpdateRunStatus(ctx, r.ID, "success", "done")
+	assert.NoError(t, err)
+	runs, err = crs.ListRunsWithStatus(ctx, "created", "running")
+	assert.NoError(t, err)
+	for _, run := range runs {
+		assert.NotEqual(t, r.ID, run.ID)
+	}
+
+	err = crs.UpdateRunContainer(ctx, 999, "container-id")
+	assert.Error(t, err)
+}
+
+func TestCommandRun_UpdateRunSignal(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	env := environment.NewDockerConverter(environment.Dependencies{Logger: logger})
+	crs := livestore.NewCommandRunStore(livestore.CommandRunDependencies{
+		Connector: livestore.NewDatabaseConnector(livestore.Config{
+			Hostname: hostname,
+			Database: dbaccess.Db,
+			Username: dbaccess.Username,
+			Password: dbaccess.Password,
+		}, livestore.Dependencies{
+			Logger:    logger,
+			Converter: env,
+		}),
+	})
+	ctx := context.Background()
+	r, err := crs.CreateRun(ctx, &models.CommandRun{
+		EventID:     1,
+		CommandName: "test-command-signal",
+		Status:      "running",
+		CreateAt:    time.Now(),
+	})
+	assert.NoError(t, err)
+
+	err = crs.UpdateRunSignal(ctx, r.ID, "SIGTERM")
+	assert.NoError(t, err)
+	err = crs.UpdateRunStatus(ctx, r.ID, "cancelled", "stopped")
+	assert.NoError(t, err)
+
+	r, err = crs.Get(ctx, r.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "cancelled", r.Status)
+	assert.Equal(t, "SIGTERM", r.Signal)
+
+	err = crs.UpdateRunSignal(ctx, 999, "SIGTERM")
+	assert.Error(t, err)
+}
+
+func TestCommandRun_Attempt(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	env := environment.NewDockerConverter(environment.Dependencies{Logger: logger})
+	crs := livestore.NewCommandRunStore(livestore.CommandRunDependencies{
+		Connector: livestore.NewDatabaseConnector(livestore.Config{
+			Hostname: hostname,
+			Database: dbaccess.Db,
+			Username: dbaccess.Username,
+			Password: dbaccess.Password,
+		}, livestore.Dependencies{
+			Logger:    logger,
+			Converter: env,
+		}),
+	})
+	ctx := context.Background()
+	first, err := crs.CreateRun(ctx, &models.CommandRun{
+		EventID:     1,
+		CommandName: "test-command-attempt",
+		Status:      "created",
+		CreateAt:    time.Now(),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, first.Attempt)
+
+	retry, err := crs.CreateRun(ctx, &models.CommandRun{
+		EventID:     1,
+		CommandName: "test-command-attempt",
+		Attempt:     2,
+		Status:      "created",
+		CreateAt:    time.Now(),
+	})
+	assert.NoError(t, err)
+
+	r, err := crs.Get(ctx, retry.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, r.Attempt)
+	r, err = crs.Get(ctx, first.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, r.Attempt)
+}
@@ -156,6 +156,15 @@ func TestCommandSettings_Vault(t *testing.T) {
 	getSetting, err := cp.GetSetting(ctx, setting.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, "confidential_value", getSetting.Value)
+	assert.Equal(t, models.DeliveryFile, getSetting.DeliveryMode())
+
+	// the delivery can be changed together with the value.
+	getSetting.Delivery = models.DeliveryEnv
+	err = cp.UpdateSetting(ctx, getSetting)
+	assert.NoError(t, err)
+	getSetting, err = cp.GetSetting(ctx, setting.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DeliveryEnv, getSetting.Delivery)
 }
 
 func TestCommandSettings_CascadingDelete(t *testing.T) {
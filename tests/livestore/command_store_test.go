@@ -319,3 +319,229 @@ func TestCommandStore_Update(t *testing.T) {
 	assert.Equal(t, c.Enabled, updatedC.Enabled)
 	assert.Equal(t, c.Image, updatedC.Image)
 }
+
+func TestCommandStore_Limits(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	env := environment.NewDockerConverter(environment.Dependencies{Logger: logger})
+	cp, err := livestore.NewCommandStore(livestore.CommandDependencies{
+		Connector: livestore.NewDatabaseConnector(livestore.Config{
+			Hostname: hostname,
+			Database: dbaccess.Db,
+			Username: dbaccess.Username,
+			Password: dbaccess.Password,
+		}, livestore.Dependencies{
+			Logger:    logger,
+			Converter: env,
+		}),
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+	c, err := cp.Create(ctx, &models.Command{
+		Name:        "Test_Limits",
+		Image:       "krokhook/slack-notification:v0.0.1",
+		Enabled:     true,
+		Timeout:     60,
+		CPUs:        0.5,
+		MemoryLimit: 268435456,
+		PidsLimit:   100,
+		DiskLimit:   1073741824,
+		StopSignal:  "SIGINT",
+		Retry: &models.RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     5,
+			RetryOn:     []string{models.RetryOnPullFailure, models.RetryOnTimeout},
+		},
+		Priority:        10,
+		MaxParallelRuns: 2,
+		Concurrency: &models.ConcurrencyPolicy{
+			Group:            models.ConcurrencyGroupPullRequest,
+			CancelInProgress: true,
+		},
+		PullPolicy:   models.PullPolicyIfNotPresent,
+		RunnerLabels: []string{"arm64"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "SIGINT", c.StopSignal)
+	assert.Equal(t, 10, c.Priority)
+	assert.Equal(t, 2, c.MaxParallelRuns)
+	assert.Equal(t, &models.ConcurrencyPolicy{Group: models.ConcurrencyGroupPullRequest, CancelInProgress: true}, c.Concurrency)
+	assert.Equal(t, models.PullPolicyIfNotPresent, c.PullPolicy)
+	assert.Equal(t, []string{"arm64"}, c.RunnerLabels)
+	assert.Equal(t, &models.RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     5,
+		RetryOn:     []string{models.RetryOnPullFailure, models.RetryOnTimeout},
+	}, c.Retry)
+	assert.Equal(t, 60, c.Timeout)
+	assert.Equal(t, 0.5, c.CPUs)
+	assert.Equal(t, int64(268435456), c.MemoryLimit)
+	assert.Equal(t, int64(100), c.PidsLimit)
+	assert.Equal(t, int64(1073741824), c.DiskLimit)
+
+	commands, err := cp.List(ctx, &models.ListOptions{})
+	require.NoError(t, err)
+	found := false
+	for _, listed := range commands {
+		if listed.ID == c.ID {
+			found = true
+			assert.Equal(t, 60, listed.Timeout)
+			assert.Equal(t, int64(100), listed.PidsLimit)
+			assert.Equal(t, 3, listed.Retry.MaxAttempts)
+			assert.Equal(t, 10, listed.Priority)
+			assert.Equal(t, models.ConcurrencyGroupPullRequest, listed.Concurrency.Group)
+			assert.Equal(t, models.PullPolicyIfNotPresent, listed.PullPolicy)
+			assert.Equal(t, []string{"arm64"}, listed.RunnerLabels)
+		}
+	}
+	assert.True(t, found)
+
+	// Limits which are not set are removed.
+	updated, err := cp.Update(ctx, &models.Command{ID: c.ID, Enabled: true, Timeout: 30, StopGracePeriod: 20})
+	require.NoError(t, err)
+	assert.Equal(t, 30, updated.Timeout)
+	assert.Equal(t, 20, updated.StopGracePeriod)
+	assert.Empty(t, updated.StopSignal)
+	assert.Nil(t, updated.Retry)
+	assert.Equal(t, float64(0), updated.CPUs)
+	assert.Equal(t, int64(0), updated.MemoryLimit)
+	assert.Equal(t, int64(0), updated.PidsLimit)
+	assert.Equal(t, int64(0), updated.DiskLimit)
+	assert.Equal(t, 0, updated.Priority)
+	assert.Equal(t, 0, updated.MaxParallelRuns)
+	assert.Nil(t, updated.Concurrency)
+	assert.Empty(t, updated.PullPolicy)
+	assert.Empty(t, updated.RunnerLabels)
+}
+
+func TestCommandStore_DependencyFlow(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	env := environment.NewDockerConverter(environment.Dependencies{Logger: logger})
+	cp, err := livestore.NewCommandStore(livestore.CommandDependencies{
+		Connector: livestore.NewDatabaseConnector(livestore.Config{
+			Hostname: hostname,
+			Database: dbaccess.Db,
+			Username: dbaccess.Username,
+			Password: dbaccess.Password,
+		}, livestore.Dependencies{
+			Logger:    logger,
+			Converter: env,
+		}),
+	})
+	assert.NoError(t, err)
+	ctx := context.Background()
+	build, err := cp.Create(ctx, &models.Command{
+		Name:    "Test_Dependency_Build",
+		Enabled: true,
+		Image:   "krokhook/slack-notification:v0.0.1",
+	})
+	require.NoError(t, err)
+	publish, err := cp.Create(ctx, &models.Command{
+		Name:    "Test_Dependency_Publish",
+		Enabled: true,
+		Image:   "krokhook/slack-notification:v0.0.1",
+	})
+	require.NoError(t, err)
+
+	err = cp.AddCommandDependency(ctx, publish.ID, build.ID)
+	assert.NoError(t, err)
+	// the same dependency can't be added twice.
+	err = cp.AddCommandDependency(ctx, publish.ID, build.ID)
+	assert.Error(t, err)
+
+	deps, err := cp.GetCommandDependencies(ctx, publish.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{build.ID}, deps)
+	c, err := cp.Get(ctx, publish.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{build.ID}, c.DependsOn)
+
+	// deleting the dependency removes the relationship.
+	err = cp.Delete(ctx, build.ID)
+	assert.NoError(t, err)
+	deps, err = cp.GetCommandDependencies(ctx, publish.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, deps)
+
+	err = cp.RemoveCommandDependency(ctx, publish.ID, build.ID)
+	assert.Error(t, err)
+}
+
+func TestCommandStore_RepositoryRelationshipFilter(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	location, _ := ioutil.TempDir("", "TestCommandStore_RepositoryRelationshipFilter")
+	env := environment.NewDockerConverter(environment.Dependencies{Logger: logger})
+	fileStore := filevault.NewFileStorer(filevault.Config{
+		Location: location,
+		Key:      "password123",
+	}, filevault.Dependencies{Logger: logger})
+	require.NoError(t, fileStore.Init())
+	v := vault.NewKrokVault(vault.Dependencies{Logger: logger, Storer: fileStore})
+	connector := livestore.NewDatabaseConnector(livestore.Config{
+		Hostname: hostname,
+		Database: dbaccess.Db,
+		Username: dbaccess.Username,
+		Password: dbaccess.Password,
+	}, livestore.Dependencies{
+		Logger:    logger,
+		Converter: env,
+	})
+	cp, err := livestore.NewCommandStore(livestore.CommandDependencies{
+		Connector: connector,
+	})
+	require.NoError(t, err)
+	rp := livestore.NewRepositoryStore(livestore.RepositoryDependencies{
+		Dependencies: livestore.Dependencies{
+			Converter: env,
+			Logger:    logger,
+		},
+		Connector: connector,
+		Vault:     v,
+	})
+	ctx := context.Background()
+	c, err := cp.Create(ctx, &models.Command{
+		Name:          "Test_Relationship_Filter",
+		Enabled:       true,
+		Image:         "krokhook/slack-notification:v0.0.1",
+		RequiresClone: true,
+	})
+	require.NoError(t, err)
+	repo, err := rp.Create(ctx, &models.Repository{
+		Name: "TestRepoFilter",
+		URL:  "https://github.com/Skarlso/test",
+		Auth: &models.Auth{
+			Secret: "secret",
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, cp.AddCommandRelForRepository(ctx, c.ID, repo.ID))
+
+	// Without a filter the command runs for everything.
+	repo, err = rp.Get(ctx, repo.ID)
+	require.NoError(t, err)
+	require.Len(t, repo.Commands, 1)
+	assert.Nil(t, repo.Commands[0].Filter)
+	assert.True(t, repo.Commands[0].RequiresClone)
+
+	err = cp.UpdateCommandRelForRepository(ctx, c.ID, repo.ID, &models.CommandFilter{
+		EventTypes: []string{"push", "pull_request"},
+		Branch:     "main",
+	})
+	assert.NoError(t, err)
+	repo, err = rp.Get(ctx, repo.ID)
+	require.NoError(t, err)
+	require.Len(t, repo.Commands, 1)
+	assert.Equal(t, &models.CommandFilter{
+		EventTypes: []string{"push", "pull_request"},
+		Branch:     "main",
+	}, repo.Commands[0].Filter)
+
+	// Removing the filter.
+	err = cp.UpdateCommandRelForRepository(ctx, c.ID, repo.ID, nil)
+	assert.NoError(t, err)
+	repo, err = rp.Get(ctx, repo.ID)
+	require.NoError(t, err)
+	assert.Nil(t, repo.Commands[0].Filter)
+
+	err = cp.UpdateCommandRelForRepository(ctx, c.ID, 9999, nil)
+	assert.Error(t, err)
+}
@@ -108,6 +108,26 @@ func TestEventsStore_GetWithRuns(t *testing.T) {
 	assert.Equal(t, run.Outcome, e.CommandRuns[0].Outcome)
 	assert.Equal(t, run.Status, e.CommandRuns[0].Status)
 
+	assert.Equal(t, 0, e.RerunOf)
+
+	rerun, err := es.Create(ctx, &models.Event{
+		EventID:      event.EventID,
+		CreateAt:     time.Now(),
+		RepositoryID: 1,
+		Payload:      "{}",
+		VCS:          1,
+		EventType:    "push",
+		RerunOf:      event.ID,
+		Source:       models.EventSourceManual,
+		TriggeredBy:  1,
+	})
+	assert.NoError(t, err)
+	e, err = es.GetEvent(ctx, rerun.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, event.ID, e.RerunOf)
+	assert.Equal(t, models.EventSourceManual, e.Source)
+	assert.Equal(t, 1, e.TriggeredBy)
+
 	_, err = es.GetEvent(ctx, 999)
 	assert.Error(t, err)
 }
@@ -55,9 +55,11 @@ func TestRepositoryStore_Flow(t *testing.T) {
 		GitLab: &models.GitLab{
 			ProjectID: 10,
 		},
+		Events: []string{"push"},
 	})
 	assert.NoError(t, err)
 	assert.True(t, repo.ID > 0)
+	assert.Equal(t, []string{"push"}, repo.Events)
 
 	// Get the repo.
 	getRepo, err := rp.Get(ctx, repo.ID)
@@ -74,6 +76,22 @@ func TestRepositoryStore_Flow(t *testing.T) {
 	updatedR, err := rp.Update(ctx, getRepo)
 	assert.NoError(t, err)
 	assert.Equal(t, "UpdatedName", updatedR.Name)
+	assert.Equal(t, []string{"push"}, updatedR.Events)
+
+	// Update the events of the hook
+	getRepo.Events = []string{"push", "pull_request"}
+	updatedR, err = rp.Update(ctx, getRepo)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"push", "pull_request"}, updatedR.Events)
+
+	// Save the hook id
+	err = rp.UpdateHookID(ctx, getRepo.ID, "42")
+	assert.NoError(t, err)
+	hookRepo, err := rp.Get(ctx, getRepo.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "42", hookRepo.HookID)
+	err = rp.UpdateHookID(ctx, 9999, "42")
+	assert.True(t, errors.Is(err, kerr.ErrNotFound))
 
 	// Delete repo
 	err = rp.Delete(ctx, getRepo.ID)
@@ -192,10 +210,13 @@ func TestRepositoryStore_Create_Unique(t *testing.T) {
 	assert.NoError(t, err)
 	ctx := context.Background()
 	c, err := cp.Create(ctx, &models.Command{
-		Name:     "CommandConnectionName",
-		Schedule: "Schedule100",
-		Enabled:  false,
-		Image:    "krokhook/slack-notification:v0.0.1",
+		Name:        "CommandConnectionName",
+		Schedule:    "Schedule100",
+		Enabled:     false,
+		Image:       "krokhook/slack-notification:v0.0.1",
+		Timeout:     30,
+		MemoryLimit: 1024,
+		StopSignal:  "SIGINT",
 	})
 	assert.NoError(t, err)
 	repo, err := rp.Create(ctx, &models.Repository{
@@ -216,6 +237,9 @@ func TestRepositoryStore_Create_Unique(t *testing.T) {
 	repo, err = rp.Get(ctx, repo.ID)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, repo.Commands)
+	assert.Equal(t, 30, repo.Commands[0].Timeout)
+	assert.Equal(t, int64(1024), repo.Commands[0].MemoryLimit)
+	assert.Equal(t, "SIGINT", repo.Commands[0].StopSignal)
 
 	// delete the relationship and see if the command was removed
 	err = cp.RemoveCommandRelForRepository(ctx, c.ID, repo.ID)
@@ -269,3 +293,80 @@ func TestRepositoryStore_Create_WithCommands(t *testing.T) {
 	})
 	assert.Error(t, err)
 }
+
+func TestRepositoryStore_GenericSettings(t *testing.T) {
+	logger := zerolog.New(os.Stderr)
+	location, _ := ioutil.TempDir("", "TestRepositoryStore_GenericSettings")
+	env := environment.NewDockerConverter(environment.Dependencies{Logger: logger})
+	fileStore := filevault.NewFileStorer(filevault.Config{
+		Location: location,
+		Key:      "password123",
+	}, filevault.Dependencies{Logger: logger})
+	err := fileStore.Init()
+	assert.NoError(t, err)
+	v := vault.NewKrokVault(vault.Dependencies{Logger: logger, Storer: fileStore})
+	connector := livestore.NewDatabaseConnector(livestore.Config{
+		Hostname: hostname,
+		Database: dbaccess.Db,
+		Username: dbaccess.Username,
+		Password: dbaccess.Password,
+	}, livestore.Dependencies{
+		Logger:    logger,
+		Converter: env,
+	})
+	rp := livestore.NewRepositoryStore(livestore.RepositoryDependencies{
+		Dependencies: livestore.Dependencies{
+			Converter: env,
+			Logger:    logger,
+		},
+		Connector: connector,
+		Vault:     v,
+	})
+	ctx := context.Background()
+	settings := &models.Generic{
+		SignatureHeader:    "Sentry-Hook-Signature",
+		SignatureAlgorithm: models.SignatureSHA256,
+		EventTypeHeader:    "Sentry-Hook-Resource",
+	}
+	repo, err := rp.Create(ctx, &models.Repository{
+		Name:    "TestRepo_GenericSettings",
+		URL:     "https://sentry.io",
+		VCS:     models.GENERIC,
+		Generic: settings,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, settings, repo.Generic)
+
+	repos, err := rp.List(ctx, &models.ListOptions{VCS: models.GENERIC})
+	assert.NoError(t, err)
+	assert.Len(t, repos, 1)
+	assert.Equal(t, settings, repos[0].Generic)
+
+	// Repositories of other platforms don't have generic settings.
+	other, err := rp.Create(ctx, &models.Repository{
+		Name: "TestRepo_GenericSettings_Github",
+		URL:  "https://github.com/krok-o/test",
+		VCS:  models.GITHUB,
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, other.Generic)
+
+	// Update the generic settings.
+	updatedSettings := &models.Generic{
+		SignatureHeader:    "X-Signature",
+		SignatureAlgorithm: models.SignatureSHA1,
+		EventTypePath:      "action",
+	}
+	repo.Generic = updatedSettings
+	updated, err := rp.Update(ctx, repo)
+	assert.NoError(t, err)
+	assert.Equal(t, updatedSettings, updated.Generic)
+
+	// Generic settings are kept if only the name is updated.
+	repo.Name = "TestRepo_GenericSettings_Renamed"
+	repo.Generic = nil
+	updated, err = rp.Update(ctx, repo)
+	assert.NoError(t, err)
+	assert.Equal(t, "TestRepo_GenericSettings_Renamed", updated.Name)
+	assert.Equal(t, updatedSettings, updated.Generic)
+}